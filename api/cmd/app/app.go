@@ -5,6 +5,9 @@ import (
 	"log/slog"
 	"os"
 
+	// the runtime image ships no zoneinfo, schedules need named time zones
+	_ "time/tzdata"
+
 	"github.com/joho/godotenv"
 )
 
@@ -1,19 +1,29 @@
 package adapters
 
 import (
+	"context"
 	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
 	"em-test/internal/lib/filters"
 	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type ActivityService interface {
-	Start(userId string) error
-	Stop(userId string) error
-	GetSummary(*filters.Activity) (*domain.ActivitySummary, error)
+	Start(ctx context.Context, d *dto.SaveActivity) error
+	Stop(ctx context.Context, d *dto.StopActivityDto) error
+	Switch(ctx context.Context, d *dto.SwitchActivityDto) (*domain.Session, error)
+	GetSummary(ctx context.Context, f *filters.Activity) (*domain.ActivitySummary, error)
+	GetReport(ctx context.Context, f *filters.ActivityReport) (*domain.ActivityReport, error)
+	ExportSummary(ctx context.Context, f *filters.Activity, format, locale string) (*domain.ExportStream, error)
+	ExportReport(ctx context.Context, f *filters.ActivityReport, format, locale string) (*domain.ExportStream, error)
+	AddManual(ctx context.Context, d *dto.SaveActivity) (*domain.Session, error)
+	UpdateSession(ctx context.Context, d *dto.UpdateSessionDto) (*domain.Session, error)
 }
 
 type ActivityAdapter struct {
@@ -29,7 +39,10 @@ func NewActivityAdapter(activityService ActivityService) *ActivityAdapter {
 func (a *ActivityAdapter) Start() fiber.Handler {
 
 	type request struct {
-		UserId string `json:"userId"`
+		UserId string   `json:"userId"`
+		TaskId *string  `json:"taskId"`
+		Note   *string  `json:"note"`
+		TagIds []string `json:"tagIds"`
 	}
 
 	return func(c *fiber.Ctx) error {
@@ -41,13 +54,34 @@ func (a *ActivityAdapter) Start() fiber.Handler {
 			})
 		}
 
-		if err := a.activityService.Start(req.UserId); err != nil {
-			if errors.Is(err, domain.ErrUserAlreadyWorking) {
+		if err := a.activityService.Start(c.UserContext(), &dto.SaveActivity{
+			UserId: req.UserId,
+			TaskId: req.TaskId,
+			Note:   req.Note,
+			TagIds: req.TagIds,
+		}); err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
+
+			if errors.Is(err, domain.ErrTaskNotFound) || errors.Is(err, domain.ErrTagNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			if errors.Is(err, domain.ErrUserAlreadyWorking) || errors.Is(err, domain.ErrAlreadyOnTask) || errors.Is(err, domain.ErrInvalidNote) {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 					"error": err.Error(),
 				})
 			}
 
+			if errors.Is(err, domain.ErrPeriodLocked) {
+				return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
 			return internal(c, fiber.Map{
 				"error": err.Error(),
 			})
@@ -59,9 +93,15 @@ func (a *ActivityAdapter) Start() fiber.Handler {
 	}
 }
 
+// Stop finishes the running session of the user. When several are running,
+// sessionId or taskId picks the one to finish.
 func (a *ActivityAdapter) Stop() fiber.Handler {
 	type request struct {
-		UserId string `json:"userId"`
+		UserId    string   `json:"userId"`
+		SessionId *int64   `json:"sessionId"`
+		TaskId    *string  `json:"taskId"`
+		Note      *string  `json:"note"`
+		TagIds    []string `json:"tagIds"`
 	}
 
 	return func(c *fiber.Ctx) error {
@@ -73,12 +113,28 @@ func (a *ActivityAdapter) Stop() fiber.Handler {
 			})
 		}
 
-		if err := a.activityService.Stop(req.UserId); err != nil {
-			if errors.Is(err, domain.ErrUserNotWorking) {
+		if err := a.activityService.Stop(c.UserContext(), &dto.StopActivityDto{
+			UserId:    req.UserId,
+			SessionId: req.SessionId,
+			TaskId:    req.TaskId,
+			Note:      req.Note,
+			TagIds:    req.TagIds,
+		}); err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
+
+			if errors.Is(err, domain.ErrUserNotWorking) || errors.Is(err, domain.ErrSessionAmbiguous) || errors.Is(err, domain.ErrInvalidNote) {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 					"error": err.Error(),
 				})
 			}
+
+			if errors.Is(err, domain.ErrSessionNotFound) || errors.Is(err, domain.ErrTagNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
 			return internal(c, fiber.Map{
 				"error": err.Error(),
 			})
@@ -91,6 +147,91 @@ func (a *ActivityAdapter) Stop() fiber.Handler {
 
 }
 
+// Switch finishes the running session of the user, the one of sessionId
+// when several are running, and starts one on the task of taskId, or on no
+// task when it is null, at the same instant.
+func (a *ActivityAdapter) Switch() fiber.Handler {
+	type request struct {
+		UserId    string   `json:"userId"`
+		SessionId *int64   `json:"sessionId"`
+		TaskId    *string  `json:"taskId"`
+		Note      *string  `json:"note"`
+		TagIds    []string `json:"tagIds"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		session, err := a.activityService.Switch(c.UserContext(), &dto.SwitchActivityDto{
+			UserId:    req.UserId,
+			SessionId: req.SessionId,
+			TaskId:    req.TaskId,
+			Note:      req.Note,
+			TagIds:    req.TagIds,
+		})
+		if err != nil {
+			return sessionError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"session": session,
+		})
+	}
+}
+
+// activityTimeLayout is dd:MM:YYYY-HH:MM, the format of the start_time and
+// end_time query parameters.
+const activityTimeLayout = "02:01:2006-15:04"
+
+// activityFilters parses the period of query parameters start_time and
+// end_time, the comma separated tag ids of tagIds and groupBy=tag.
+func activityFilters(c *fiber.Ctx, userId string) (*filters.Activity, error) {
+	startTime := c.Query("start_time")
+	endTime := c.Query("end_time")
+
+	filters := &filters.Activity{
+		UserId: userId,
+	}
+
+	if tagIds := c.Query("tagIds"); tagIds != "" {
+		filters.TagIds = strings.Split(tagIds, ",")
+	}
+
+	switch groupBy := c.Query("groupBy"); groupBy {
+	case "":
+	case "tag":
+		filters.GroupByTag = true
+	default:
+		return nil, fmt.Errorf("cannot group by %q", groupBy)
+	}
+
+	if startTime != "" {
+		time, err := time.Parse(activityTimeLayout, startTime)
+		if err != nil {
+			return nil, err
+		}
+		filters.StartTime = &time
+	}
+
+	if endTime != "" {
+		time, err := time.Parse(activityTimeLayout, endTime)
+		if err != nil {
+			return nil, err
+		}
+		filters.EndTime = &time
+	}
+
+	return filters, nil
+}
+
+// GetSummary returns the sessions and totals of a user. With query
+// parameter format, or an Accept header, of csv or xlsx it downloads them
+// instead, numbers written for query parameter locale or Accept-Language.
 func (a *ActivityAdapter) GetSummary() fiber.Handler {
 
 	fn := "ActivityAdapter.GetSummary"
@@ -98,46 +239,198 @@ func (a *ActivityAdapter) GetSummary() fiber.Handler {
 
 	return func(c *fiber.Ctx) error {
 
-		userId := c.Params("user_id")
-		startTime := c.Query("start_time")
-		endTime := c.Query("end_time")
-
-		filters := &filters.Activity{
-			UserId: userId,
+		filters, err := activityFilters(c, c.Params("user_id"))
+		if err != nil {
+			logger.Error("cannot parse filters", slog.String("err", err.Error()))
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
 		}
 
-		if startTime != "" {
-			time, err := time.Parse("dd:MM:YYYY-HH:MM", startTime)
+		logger.Debug("filters setup", slog.Any("filters", filters))
+
+		if format := exportFormat(c); format != "" {
+			file, err := a.activityService.ExportSummary(c.UserContext(), filters, format, exportLocale(c))
 			if err != nil {
-				logger.Error("cannot parse startTime", slog.String("rawStartTime", startTime))
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": err.Error(),
-				})
+				return sessionError(c, err)
 			}
-			filters.StartTime = &time
+			return sendStream(c, file)
 		}
 
-		if endTime != "" {
-			time, err := time.Parse("dd:MM:YYYY-HH:MM", endTime)
-			if err != nil {
-				logger.Error("cannot parse endTime", slog.String("rawEndTime", endTime))
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": err.Error(),
-				})
+		summary, err := a.activityService.GetSummary(c.UserContext(), filters)
+		if err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
 			}
-			filters.EndTime = &time
+
+			logger.Error("failed to get summary", slog.Any("filters", filters), slog.String("err", err.Error()))
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
 		}
 
-		logger.Debug("filters setup", slog.Any("filters", filters))
+		return c.Status(fiber.StatusOK).JSON(summary)
+	}
+}
+
+// GetReport totals activity per user. Optional query parameter teamId
+// limits it to members of the team and its descendant teams, tagIds to
+// sessions with any of the tags. Like GetSummary, it downloads the sessions
+// and totals as csv or xlsx on request.
+func (a *ActivityAdapter) GetReport() fiber.Handler {
+
+	fn := "ActivityAdapter.GetReport"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
 
-		summary, err := a.activityService.GetSummary(filters)
+		period, err := activityFilters(c, "")
 		if err != nil {
-			logger.Error("failed to get summary", slog.Any("filters", filters), slog.String("err", err.Error()))
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		filters := &filters.ActivityReport{
+			StartTime:  period.StartTime,
+			EndTime:    period.EndTime,
+			TagIds:     period.TagIds,
+			GroupByTag: period.GroupByTag,
+		}
+
+		if teamId := c.Query("teamId"); teamId != "" {
+			filters.TeamId = &teamId
+		}
+
+		if format := exportFormat(c); format != "" {
+			file, err := a.activityService.ExportReport(c.UserContext(), filters, format, exportLocale(c))
+			if err != nil {
+				return sessionError(c, err)
+			}
+			return sendStream(c, file)
+		}
+
+		report, err := a.activityService.GetReport(c.UserContext(), filters)
+		if err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
+
+			logger.Error("failed to get report", slog.String("err", err.Error()))
 			return internal(c, fiber.Map{
 				"error": err.Error(),
 			})
 		}
 
-		return c.Status(fiber.StatusOK).JSON(summary)
+		return c.Status(fiber.StatusOK).JSON(report)
+	}
+}
+
+func sessionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrSessionNotFound), errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrTaskNotFound), errors.Is(err, domain.ErrTagNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidInterval), errors.Is(err, domain.ErrInvalidNote),
+		errors.Is(err, domain.ErrUserNotWorking), errors.Is(err, domain.ErrAlreadyOnTask), errors.Is(err, domain.ErrSessionAmbiguous),
+		errors.Is(err, domain.ErrUnknownFormat):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrSessionOverlaps):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrPeriodLocked), errors.Is(err, domain.ErrSessionInvoiced):
+		return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func (a *ActivityAdapter) AddManual() fiber.Handler {
+	type request struct {
+		UserId    string    `json:"userId"`
+		TaskId    *string   `json:"taskId"`
+		StartTime time.Time `json:"startTime"`
+		EndTime   time.Time `json:"endTime"`
+		Note      *string   `json:"note"`
+		TagIds    []string  `json:"tagIds"`
+	}
+
+	return func(c *fiber.Ctx) error {
+
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		session, err := a.activityService.AddManual(c.UserContext(), &dto.SaveActivity{
+			UserId:    req.UserId,
+			TaskId:    req.TaskId,
+			StartTime: req.StartTime,
+			EndTime:   &req.EndTime,
+			Note:      req.Note,
+			TagIds:    req.TagIds,
+		})
+		if err != nil {
+			return sessionError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"session": session,
+		})
+	}
+}
+
+func (a *ActivityAdapter) UpdateSession() fiber.Handler {
+	type request struct {
+		StartTime *time.Time `json:"startTime"`
+		EndTime   *time.Time `json:"endTime"`
+		TaskId    *string    `json:"taskId"`
+		Note      *string    `json:"note"`
+		TagIds    *[]string  `json:"tagIds"`
+	}
+
+	return func(c *fiber.Ctx) error {
+
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		session, err := a.activityService.UpdateSession(c.UserContext(), &dto.UpdateSessionDto{
+			Id:        int64(id),
+			StartTime: req.StartTime,
+			EndTime:   req.EndTime,
+			TaskId:    req.TaskId,
+			Note:      req.Note,
+			TagIds:    req.TagIds,
+		})
+		if err != nil {
+			return sessionError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"session": session,
+		})
 	}
 }
@@ -0,0 +1,84 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/filters"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditService interface {
+	List(ctx context.Context, f *filters.Audit) ([]*domain.AuditEntry, error)
+}
+
+type AuditAdapter struct {
+	auditService AuditService
+}
+
+func NewAuditAdapter(auditService AuditService) *AuditAdapter {
+	return &AuditAdapter{
+		auditService: auditService,
+	}
+}
+
+// List serves the audit log, newest first. Optional query parameters are
+// entity, entityId, actorId and the RFC 3339 bounds from and to.
+func (a *AuditAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", 100)
+		if limit <= 0 || limit > 1000 {
+			limit = 100
+		}
+		page := c.QueryInt("page", 1)
+		if page < 1 {
+			page = 1
+		}
+
+		f := &filters.Audit{
+			Limit:  limit,
+			Offset: (page - 1) * limit,
+		}
+
+		if entity := c.Query("entity"); entity != "" {
+			f.Entity = &entity
+		}
+		if entityId := c.Query("entityId"); entityId != "" {
+			f.EntityId = &entityId
+		}
+		if actorId := c.Query("actorId"); actorId != "" {
+			f.ActorId = &actorId
+		}
+
+		for param, dst := range map[string]**time.Time{"from": &f.StartTime, "to": &f.EndTime} {
+			raw := c.Query(param)
+			if raw == "" {
+				continue
+			}
+
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "malformed " + param + ", expected RFC 3339",
+				})
+			}
+			*dst = &t
+		}
+
+		entries, err := a.auditService.List(c.UserContext(), f)
+		if err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"entries": entries,
+		})
+	}
+}
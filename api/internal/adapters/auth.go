@@ -0,0 +1,256 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	principalLocal = "principal"
+	bearerScheme   = "Bearer"
+	orgHeader      = "X-Organization-Id"
+)
+
+type AuthService interface {
+	AuthenticateApiKey(ctx context.Context, raw string) (*domain.Principal, error)
+	AuthenticateToken(ctx context.Context, raw string) (*domain.Principal, error)
+	ResolveOrg(ctx context.Context, principal *domain.Principal, requested string) (string, error)
+	IssueToken(ctx context.Context, userId string) (*domain.AccessToken, error)
+
+	CreateApiKey(ctx context.Context, d *dto.CreateApiKeyDto) (*domain.ApiKey, string, error)
+	ListApiKeys(ctx context.Context) ([]*domain.ApiKey, error)
+	RevokeApiKey(ctx context.Context, id string) error
+}
+
+type AuthAdapter struct {
+	authService AuthService
+}
+
+func NewAuthAdapter(authService AuthService) *AuthAdapter {
+	return &AuthAdapter{
+		authService: authService,
+	}
+}
+
+func principal(c *fiber.Ctx) *domain.Principal {
+	p, _ := c.Locals(principalLocal).(*domain.Principal)
+	return p
+}
+
+func unauthorized(c *fiber.Ctx, err error) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="time-tracker"`)
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// Middleware authenticates requests either with an api key in the X-Api-Key
+// header or a JWT in the Authorization header. Event stream clients, which
+// cannot set headers, may pass the JWT in the access_token query parameter.
+// The organization of the request is the one of the caller, platform keys
+// pick one with the X-Organization-Id header.
+func (a *AuthAdapter) Middleware() fiber.Handler {
+
+	fn := "AuthAdapter.Middleware"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		var (
+			p   *domain.Principal
+			err error
+		)
+
+		apiKey := c.Get("X-Api-Key")
+		scheme, token, _ := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+		if token == "" && strings.Contains(c.Get(fiber.HeaderAccept), "text/event-stream") {
+			scheme, token = bearerScheme, c.Query("access_token")
+		}
+
+		switch {
+		case apiKey != "":
+			p, err = a.authService.AuthenticateApiKey(c.UserContext(), apiKey)
+		case strings.EqualFold(scheme, bearerScheme) && token != "":
+			p, err = a.authService.AuthenticateToken(c.UserContext(), token)
+		default:
+			err = domain.ErrUnauthorized
+		}
+
+		if err != nil {
+			if errors.Is(err, domain.ErrUnauthorized) || errors.Is(err, domain.ErrInvalidCredentials) {
+				return unauthorized(c, err)
+			}
+			logger.Error("authentication failed", slog.String("err", err.Error()))
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		orgId, err := a.authService.ResolveOrg(c.UserContext(), p, c.Get(orgHeader))
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrForbidden):
+				return forbidden(c, err)
+			case errors.Is(err, domain.ErrOrgNotFound):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			logger.Error("cannot resolve organization", slog.String("err", err.Error()))
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		ctx := domain.WithPrincipal(c.UserContext(), p)
+		if orgId != "" {
+			ctx = domain.WithOrg(ctx, orgId)
+		}
+
+		c.Locals(principalLocal, p)
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
+
+// RequireOrg rejects requests that do not act in an organization, which only
+// happens for platform keys without the X-Organization-Id header.
+func (a *AuthAdapter) RequireOrg() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := domain.OrgFrom(c.UserContext()); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrOrgRequired.Error(),
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+func (a *AuthAdapter) IssueToken() fiber.Handler {
+	type request struct {
+		UserId string `json:"userId"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		token, err := a.authService.IssueToken(c.UserContext(), req.UserId)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrForbidden):
+				return forbidden(c, err)
+			case errors.Is(err, domain.ErrUserNotFound):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(token)
+	}
+}
+
+func (a *AuthAdapter) CreateApiKey() fiber.Handler {
+	type request struct {
+		Name      string      `json:"name"`
+		UserId    *string     `json:"userId"`
+		Role      domain.Role `json:"role"`
+		ExpiresAt *time.Time  `json:"expiresAt"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if strings.TrimSpace(req.Name) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "name is required",
+			})
+		}
+
+		key, raw, err := a.authService.CreateApiKey(c.UserContext(), &dto.CreateApiKeyDto{
+			Name:      req.Name,
+			UserId:    req.UserId,
+			Role:      req.Role,
+			ExpiresAt: req.ExpiresAt,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrForbidden):
+				return forbidden(c, err)
+			case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrInvalidRole):
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"apiKey": key,
+			"key":    raw,
+		})
+	}
+}
+
+func (a *AuthAdapter) ListApiKeys() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keys, err := a.authService.ListApiKeys(c.UserContext())
+		if err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"apiKeys": keys,
+		})
+	}
+}
+
+func (a *AuthAdapter) RevokeApiKey() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.authService.RevokeApiKey(c.UserContext(), c.Params("id")); err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
+			if errors.Is(err, domain.ErrApiKeyNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "api key revoked",
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"em-test/internal/domain"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+type BackupService interface {
+	Export(ctx context.Context) (*domain.ExportStream, error)
+	Restore(ctx context.Context, r io.Reader) (*domain.RestoreReport, error)
+}
+
+type BackupsAdapter struct {
+	backupService BackupService
+}
+
+func NewBackupsAdapter(backupService BackupService) *BackupsAdapter {
+	return &BackupsAdapter{
+		backupService: backupService,
+	}
+}
+
+func backupError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrInvalidBackup):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrSchemaMismatch), errors.Is(err, domain.ErrDatabaseNotEmpty):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// Export streams a backup of all organizations as JSON Lines.
+func (a *BackupsAdapter) Export() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		file, err := a.backupService.Export(c.UserContext())
+		if err != nil {
+			return backupError(c, err)
+		}
+
+		return sendStream(c, file)
+	}
+}
+
+// Import restores a backup sent as the request body into an empty
+// database.
+func (a *BackupsAdapter) Import() fiber.Handler {
+	fn := "BackupsAdapter.Import"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		report, err := a.backupService.Restore(c.UserContext(), bytes.NewReader(c.Body()))
+		if err != nil {
+			logger.Debug("failed to restore backup", slog.String("err", err.Error()))
+			return backupError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"report": report,
+		})
+	}
+}
+
+// RestoreBodyLimit raises the body limit of POST requests to path, restores
+// of backups, to limit bytes. Other requests keep the limit of the server.
+// Bodies are read before requests are routed, so the limit is picked from
+// the request header.
+func RestoreBodyLimit(path string, limit int) func(h *fasthttp.RequestHeader) fasthttp.RequestConfig {
+	return func(h *fasthttp.RequestHeader) fasthttp.RequestConfig {
+		uri, _, _ := bytes.Cut(h.RequestURI(), []byte("?"))
+		if !h.IsPost() || !strings.EqualFold(strings.TrimSuffix(string(uri), "/"), path) {
+			return fasthttp.RequestConfig{}
+		}
+		return fasthttp.RequestConfig{MaxRequestBodySize: limit}
+	}
+}
@@ -0,0 +1,141 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type BudgetService interface {
+	Save(ctx context.Context, d *dto.SaveBudgetDto) (*domain.Budget, error)
+	List(ctx context.Context, f *filters.Budgets) ([]*domain.Budget, error)
+	Delete(ctx context.Context, f *filters.Budgets) error
+	Burn(ctx context.Context, f *filters.Budgets) (*domain.BudgetBurn, error)
+}
+
+type BudgetsAdapter struct {
+	budgetService BudgetService
+}
+
+func NewBudgetsAdapter(budgetService BudgetService) *BudgetsAdapter {
+	return &BudgetsAdapter{
+		budgetService: budgetService,
+	}
+}
+
+func budgetError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrBudgetNotFound), errors.Is(err, domain.ErrProjectNotFound), errors.Is(err, domain.ErrTaskNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidBudget):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// budgetTarget selects the budget of the project or, when task is set, the
+// task in path parameter id.
+func budgetTarget(c *fiber.Ctx, task bool) *filters.Budgets {
+	id := c.Params("id")
+	if task {
+		return &filters.Budgets{TaskId: &id}
+	}
+	return &filters.Budgets{ProjectId: &id}
+}
+
+// List returns budgets, optionally of the project or the task in query
+// parameters projectId and taskId.
+func (a *BudgetsAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f := &filters.Budgets{}
+		if projectId := c.Query("projectId"); projectId != "" {
+			f.ProjectId = &projectId
+		}
+		if taskId := c.Query("taskId"); taskId != "" {
+			f.TaskId = &taskId
+		}
+
+		budgets, err := a.budgetService.List(c.UserContext(), f)
+		if err != nil {
+			return budgetError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"budgets": budgets,
+		})
+	}
+}
+
+// Save sets the budget of a project or a task. Thresholds default to 80
+// and 100 percent.
+func (a *BudgetsAdapter) Save(task bool) fiber.Handler {
+	type request struct {
+		TimeMinutes *int   `json:"timeMinutes"`
+		Amount      *int64 `json:"amount"`
+		Thresholds  []int  `json:"thresholds"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		target := budgetTarget(c, task)
+		budget, err := a.budgetService.Save(c.UserContext(), &dto.SaveBudgetDto{
+			ProjectId:   target.ProjectId,
+			TaskId:      target.TaskId,
+			TimeMinutes: req.TimeMinutes,
+			Amount:      req.Amount,
+			Thresholds:  req.Thresholds,
+		})
+		if err != nil {
+			return budgetError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"budget": budget,
+		})
+	}
+}
+
+func (a *BudgetsAdapter) Delete(task bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.budgetService.Delete(c.UserContext(), budgetTarget(c, task)); err != nil {
+			return budgetError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "budget deleted",
+		})
+	}
+}
+
+// Burn returns the spent and remaining budget and when it runs out.
+func (a *BudgetsAdapter) Burn(task bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		burn, err := a.budgetService.Burn(c.UserContext(), budgetTarget(c, task))
+		if err != nil {
+			return budgetError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"burn": burn,
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CalendarService interface {
+	Save(ctx context.Context, d *dto.SaveCalendarDayDto) (*domain.CalendarDay, error)
+	Import(ctx context.Context, d *dto.ImportCalendarDto) ([]*domain.CalendarDay, error)
+	List(ctx context.Context, f *filters.Calendar) ([]*domain.CalendarDay, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type CalendarAdapter struct {
+	calendarService CalendarService
+}
+
+func NewCalendarAdapter(calendarService CalendarService) *CalendarAdapter {
+	return &CalendarAdapter{
+		calendarService: calendarService,
+	}
+}
+
+func calendarError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrCalendarDayNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidCalendarDay), errors.Is(err, domain.ErrInvalidCalendarFile):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// List returns calendar days. Optional query parameters region and the
+// YYYY-MM-DD bounds from and to filter them, days of the whole
+// organization have the empty region.
+func (a *CalendarAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f := &filters.Calendar{}
+
+		if c.Context().QueryArgs().Has("region") {
+			f.Regions = []string{c.Query("region")}
+		}
+		if from := c.Query("from"); from != "" {
+			t, err := time.Parse(dateLayout, from)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": domain.ErrInvalidPeriod.Error(),
+				})
+			}
+			f.From = &t
+		}
+		if to := c.Query("to"); to != "" {
+			t, err := time.Parse(dateLayout, to)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": domain.ErrInvalidPeriod.Error(),
+				})
+			}
+			f.To = &t
+		}
+
+		days, err := a.calendarService.List(c.UserContext(), f)
+		if err != nil {
+			return calendarError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"days": days,
+		})
+	}
+}
+
+// Save stores a holiday or working day, replacing the day of the same
+// region and date.
+func (a *CalendarAdapter) Save() fiber.Handler {
+	type request struct {
+		Region    string                 `json:"region"`
+		Date      string                 `json:"date"`
+		Name      string                 `json:"name"`
+		Kind      domain.CalendarDayKind `json:"kind"`
+		AsWeekday *int                   `json:"asWeekday"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		date, err := time.Parse(dateLayout, req.Date)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrInvalidCalendarDay.Error(),
+			})
+		}
+
+		day, err := a.calendarService.Save(c.UserContext(), &dto.SaveCalendarDayDto{
+			Region:    req.Region,
+			Date:      date,
+			Name:      req.Name,
+			Kind:      req.Kind,
+			AsWeekday: req.AsWeekday,
+		})
+		if err != nil {
+			return calendarError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"day": day,
+		})
+	}
+}
+
+// Import reads holidays from an iCalendar or CSV file, uploaded as the
+// form file "file" or sent as the request body. Query parameter region
+// selects the region, format is ics or csv and otherwise guessed.
+func (a *CalendarAdapter) Import() fiber.Handler {
+	fn := "CalendarAdapter.Import"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		d := &dto.ImportCalendarDto{
+			Region: c.Query("region"),
+			Format: c.Query("format"),
+			Data:   c.Body(),
+		}
+
+		if file, err := c.FormFile("file"); err == nil {
+			if d.Format == "" {
+				d.Format = strings.TrimPrefix(filepath.Ext(file.Filename), ".")
+			}
+
+			f, err := file.Open()
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			defer f.Close()
+
+			if d.Data, err = io.ReadAll(f); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		} else if d.Format == "" && strings.HasPrefix(c.Get(fiber.HeaderContentType), "text/calendar") {
+			d.Format = "ics"
+		}
+
+		days, err := a.calendarService.Import(c.UserContext(), d)
+		if err != nil {
+			logger.Debug("failed to import calendar", slog.String("err", err.Error()))
+			return calendarError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"days": days,
+		})
+	}
+}
+
+func (a *CalendarAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.calendarService.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return calendarError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "calendar day deleted",
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package adapters
+
+import (
+	"bufio"
+	"context"
+	"em-test/internal/config"
+	"em-test/internal/domain"
+	"em-test/internal/lib/events"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+type EventSubscriber interface {
+	Subscribe(filter events.Filter) *events.Subscription
+}
+
+type EventAuthorizer interface {
+	VisibleUsers(ctx context.Context) (all bool, ids []string, err error)
+}
+
+type EventTeamResolver interface {
+	MemberIds(ctx context.Context, teamId string) ([]string, error)
+}
+
+type EventsAdapter struct {
+	subscriber EventSubscriber
+	authorizer EventAuthorizer
+	teams      EventTeamResolver
+	heartbeat  time.Duration
+}
+
+func NewEventsAdapter(cfg *config.Config, subscriber EventSubscriber, authorizer EventAuthorizer, teams EventTeamResolver) *EventsAdapter {
+	return &EventsAdapter{
+		subscriber: subscriber,
+		authorizer: authorizer,
+		teams:      teams,
+		heartbeat:  cfg.Events.HeartbeatInterval,
+	}
+}
+
+// Stream serves activity and user events as Server-Sent Events.
+// Optional query parameter userId takes a comma separated list of users
+// to receive events for and teamId limits them to the members of a team and
+// its descendant teams, as of subscribing. Callers only ever receive events
+// of users whose activity they are allowed to view, within their own
+// organization.
+func (a *EventsAdapter) Stream() fiber.Handler {
+
+	fn := "EventsAdapter.Stream"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+
+		all, visible, err := a.authorizer.VisibleUsers(c.UserContext())
+		if err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		userIds := make(map[string]struct{})
+		for _, id := range strings.Split(c.Query("userId"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				userIds[strings.Clone(id)] = struct{}{}
+			}
+		}
+
+		if teamId := c.Query("teamId"); teamId != "" {
+			members, err := a.teams.MemberIds(c.UserContext(), teamId)
+			if err != nil {
+				switch {
+				case errors.Is(err, domain.ErrForbidden):
+					return forbidden(c, err)
+				case errors.Is(err, domain.ErrTeamNotFound):
+					return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+						"error": err.Error(),
+					})
+				}
+				return internal(c, fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			inTeam := make(map[string]struct{}, len(members))
+			for _, id := range members {
+				if _, ok := userIds[id]; ok || len(userIds) == 0 {
+					inTeam[id] = struct{}{}
+				}
+			}
+
+			if len(inTeam) == 0 {
+				return forbidden(c, domain.ErrForbidden)
+			}
+			userIds = inTeam
+		}
+
+		if !all {
+			allowed := make(map[string]struct{}, len(visible))
+			for _, id := range visible {
+				if _, ok := userIds[id]; ok || len(userIds) == 0 {
+					allowed[id] = struct{}{}
+				}
+			}
+
+			if len(allowed) == 0 {
+				return forbidden(c, domain.ErrForbidden)
+			}
+			userIds = allowed
+		}
+
+		orgId, _ := domain.OrgFrom(c.UserContext())
+
+		filter := func(e *domain.Event) bool {
+			if e.OrgId != orgId {
+				return false
+			}
+			if len(userIds) == 0 {
+				return true
+			}
+			_, ok := userIds[e.UserId]
+			return ok
+		}
+
+		sub := a.subscriber.Subscribe(filter)
+		logger.Debug("subscribed", slog.Int("users", len(userIds)))
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer sub.Close()
+
+			heartbeat := time.NewTicker(a.heartbeat)
+			defer heartbeat.Stop()
+
+			fmt.Fprintf(w, "retry: %d\n\n", a.heartbeat.Milliseconds())
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			for {
+				select {
+				case e, ok := <-sub.Events():
+					if !ok {
+						if err := sub.Err(); err != nil {
+							logger.Warn("subscription dropped", slog.String("err", err.Error()))
+							fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
+							w.Flush()
+						}
+						return
+					}
+
+					data, err := json.Marshal(e)
+					if err != nil {
+						logger.Error("cannot marshal event", slog.String("err", err.Error()))
+						continue
+					}
+					fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+
+				case <-heartbeat.C:
+					fmt.Fprint(w, ": heartbeat\n\n")
+				}
+
+				if err := w.Flush(); err != nil {
+					logger.Debug("client disconnected", slog.String("err", err.Error()))
+					return
+				}
+			}
+		}))
+
+		return nil
+	}
+}
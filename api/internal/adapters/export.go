@@ -0,0 +1,63 @@
+package adapters
+
+import (
+	"bufio"
+	"em-test/internal/domain"
+	"em-test/internal/lib/xlsx"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// exportFormat returns the format of query parameter format or else the
+// one the Accept header prefers, csv or xlsx, and an empty string for json.
+func exportFormat(c *fiber.Ctx) string {
+	if format := c.Query("format"); format != "" {
+		if strings.EqualFold(format, "json") {
+			return ""
+		}
+		return format
+	}
+
+	switch c.Accepts(fiber.MIMEApplicationJSON, "text/csv", xlsx.ContentType) {
+	case "text/csv":
+		return "csv"
+	case xlsx.ContentType:
+		return "xlsx"
+	}
+	return ""
+}
+
+// exportLocale returns the language tag of query parameter locale or else
+// the first one of the Accept-Language header.
+func exportLocale(c *fiber.Ctx) string {
+	if locale := c.Query("locale"); locale != "" {
+		return locale
+	}
+
+	tag, _, _ := strings.Cut(c.Get(fiber.HeaderAcceptLanguage), ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	return strings.TrimSpace(tag)
+}
+
+// sendStream downloads file, writing it as it is produced. Once streaming
+// began the status can no longer change, errors are only logged then.
+func sendStream(c *fiber.Ctx, file *domain.ExportStream) error {
+	c.Set(fiber.HeaderContentType, file.ContentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", file.Name))
+	c.Status(fiber.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		logger := slog.With(slog.String("fn", "sendStream"), slog.String("name", file.Name))
+
+		if err := file.Write(w); err != nil {
+			logger.Error("failed to write export", slog.String("err", err.Error()))
+			return
+		}
+
+		if err := w.Flush(); err != nil {
+			logger.Error("failed to flush export", slog.String("err", err.Error()))
+		}
+	})
+	return nil
+}
@@ -0,0 +1,116 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// feedPath is where feeds are served, the token fills in the gap.
+const feedPath = "/api/v1/feeds/"
+
+type FeedService interface {
+	Create(ctx context.Context, userId string) (*domain.Feed, string, error)
+	Get(ctx context.Context, userId string) (*domain.Feed, error)
+	Delete(ctx context.Context, userId string) error
+	Render(ctx context.Context, token string) (*domain.ExportFile, error)
+}
+
+type FeedsAdapter struct {
+	feedService FeedService
+}
+
+func NewFeedsAdapter(feedService FeedService) *FeedsAdapter {
+	return &FeedsAdapter{
+		feedService: feedService,
+	}
+}
+
+func feedError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrFeedNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// Create issues the calendar feed of the authenticated user, revoking the
+// previous one. Its url holds the token and is returned only once.
+func (a *FeedsAdapter) Create() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId := me(c)
+		if userId == "" {
+			return forbidden(c, errNotAUser)
+		}
+
+		feed, token, err := a.feedService.Create(c.UserContext(), userId)
+		if err != nil {
+			return feedError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"feed": feed,
+			"url":  c.BaseURL() + feedPath + token + ".ics",
+		})
+	}
+}
+
+func (a *FeedsAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId := me(c)
+		if userId == "" {
+			return forbidden(c, errNotAUser)
+		}
+
+		feed, err := a.feedService.Get(c.UserContext(), userId)
+		if err != nil {
+			return feedError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"feed": feed,
+		})
+	}
+}
+
+func (a *FeedsAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId := me(c)
+		if userId == "" {
+			return forbidden(c, errNotAUser)
+		}
+
+		if err := a.feedService.Delete(c.UserContext(), userId); err != nil {
+			return feedError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "feed deleted",
+		})
+	}
+}
+
+// Calendar serves the iCalendar of the feed of path parameter token. It is
+// not behind the authentication middleware, calendar apps cannot send
+// credentials other than the url.
+func (a *FeedsAdapter) Calendar() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		file, err := a.feedService.Render(c.UserContext(), c.Params("token"))
+		if err != nil {
+			return feedError(c, err)
+		}
+
+		c.Set(fiber.HeaderContentType, file.ContentType)
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		return c.Status(fiber.StatusOK).Send(file.Data)
+	}
+}
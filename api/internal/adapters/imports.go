@@ -0,0 +1,99 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ImportService interface {
+	Import(ctx context.Context, d *dto.ImportTimeDto) (*domain.ImportReport, error)
+}
+
+type ImportsAdapter struct {
+	importService ImportService
+}
+
+func NewImportsAdapter(importService ImportService) *ImportsAdapter {
+	return &ImportsAdapter{
+		importService: importService,
+	}
+}
+
+func importError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrInvalidImportFile):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// Import reads time entries from a Toggl or Clockify CSV export, uploaded
+// as the form file "file" or sent as the request body. Query parameter
+// source is toggl or clockify and otherwise guessed, tz is the timezone of
+// the file and dryRun=true reports what would be imported without saving.
+func (a *ImportsAdapter) Import() fiber.Handler {
+	fn := "ImportsAdapter.Import"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		d := &dto.ImportTimeDto{
+			Source:   domain.ImportSource(c.Query("source")),
+			Timezone: c.Query("tz"),
+			Data:     c.Body(),
+		}
+
+		if dryRun := c.Query("dryRun"); dryRun != "" {
+			var err error
+			if d.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "dryRun must be true or false",
+				})
+			}
+		}
+
+		if file, err := c.FormFile("file"); err == nil {
+			f, err := file.Open()
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			defer f.Close()
+
+			if d.Data, err = io.ReadAll(f); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		report, err := a.importService.Import(c.UserContext(), d)
+		if err != nil {
+			logger.Debug("failed to import time entries", slog.String("err", err.Error()))
+			return importError(c, err)
+		}
+
+		status := fiber.StatusCreated
+		if report.DryRun || report.Imported == 0 {
+			status = fiber.StatusOK
+		}
+
+		return c.Status(status).JSON(fiber.Map{
+			"report": report,
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InvoiceService interface {
+	Create(ctx context.Context, d *dto.CreateInvoiceDto) (*domain.Invoice, error)
+	Get(ctx context.Context, id string) (*domain.Invoice, error)
+	List(ctx context.Context, f *filters.Invoices) ([]*domain.Invoice, error)
+	Issue(ctx context.Context, id string) (*domain.Invoice, error)
+	Pay(ctx context.Context, id string) (*domain.Invoice, error)
+	Delete(ctx context.Context, id string) error
+	Export(ctx context.Context, id, format string) (*domain.ExportFile, error)
+}
+
+type InvoicesAdapter struct {
+	invoiceService InvoiceService
+}
+
+func NewInvoicesAdapter(invoiceService InvoiceService) *InvoicesAdapter {
+	return &InvoicesAdapter{
+		invoiceService: invoiceService,
+	}
+}
+
+func invoiceError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrInvoiceNotFound), errors.Is(err, domain.ErrClientNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidInvoice), errors.Is(err, domain.ErrUnknownFormat):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrNothingToInvoice):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvoiceNotDraft), errors.Is(err, domain.ErrInvoiceTransition):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// Create drafts an invoice of a client for the YYYY-MM-DD days from
+// periodStart to periodEnd. Lines are grouped by task unless groupBy is
+// user.
+func (a *InvoicesAdapter) Create() fiber.Handler {
+	type request struct {
+		ClientId    string                 `json:"clientId"`
+		Currency    string                 `json:"currency"`
+		GroupBy     domain.InvoiceGrouping `json:"groupBy"`
+		PeriodStart string                 `json:"periodStart"`
+		PeriodEnd   string                 `json:"periodEnd"`
+	}
+
+	fn := "InvoicesAdapter.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		start, err := time.Parse(dateLayout, req.PeriodStart)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrInvalidPeriod.Error(),
+			})
+		}
+
+		end, err := time.Parse(dateLayout, req.PeriodEnd)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrInvalidPeriod.Error(),
+			})
+		}
+
+		invoice, err := a.invoiceService.Create(c.UserContext(), &dto.CreateInvoiceDto{
+			ClientId:    req.ClientId,
+			Currency:    req.Currency,
+			GroupBy:     req.GroupBy,
+			PeriodStart: start,
+			PeriodEnd:   end,
+		})
+		if err != nil {
+			logger.Error("failed to create invoice", slog.String("err", err.Error()))
+			return invoiceError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"invoice": invoice,
+		})
+	}
+}
+
+// List returns invoices without their lines, optionally of the client in
+// query parameter clientId and with the given status.
+func (a *InvoicesAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f := &filters.Invoices{}
+		if clientId := c.Query("clientId"); clientId != "" {
+			f.ClientId = &clientId
+		}
+		if status := c.Query("status"); status != "" {
+			s := domain.InvoiceStatus(status)
+			f.Status = &s
+		}
+
+		invoices, err := a.invoiceService.List(c.UserContext(), f)
+		if err != nil {
+			return invoiceError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"invoices": invoices,
+		})
+	}
+}
+
+func (a *InvoicesAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		invoice, err := a.invoiceService.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return invoiceError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"invoice": invoice,
+		})
+	}
+}
+
+func (a *InvoicesAdapter) Issue() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		invoice, err := a.invoiceService.Issue(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return invoiceError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"invoice": invoice,
+		})
+	}
+}
+
+func (a *InvoicesAdapter) Pay() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		invoice, err := a.invoiceService.Pay(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return invoiceError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"invoice": invoice,
+		})
+	}
+}
+
+func (a *InvoicesAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.invoiceService.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return invoiceError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "invoice deleted",
+		})
+	}
+}
+
+// Export downloads the invoice in query parameter format, json, csv or
+// html.
+func (a *InvoicesAdapter) Export() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		file, err := a.invoiceService.Export(c.UserContext(), c.Params("id"), c.Query("format"))
+		if err != nil {
+			return invoiceError(c, err)
+		}
+
+		c.Set(fiber.HeaderContentType, file.ContentType)
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", file.Name))
+		return c.Status(fiber.StatusOK).Send(file.Data)
+	}
+}
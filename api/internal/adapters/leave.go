@@ -0,0 +1,380 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LeaveService interface {
+	CreateType(ctx context.Context, d *dto.SaveLeaveTypeDto) (*domain.LeaveType, error)
+	ListTypes(ctx context.Context) ([]*domain.LeaveType, error)
+	UpdateType(ctx context.Context, id string, d *dto.UpdateLeaveTypeDto) (*domain.LeaveType, error)
+	DeleteType(ctx context.Context, id string) error
+	SetAllowance(ctx context.Context, d *dto.SetAllowanceDto) ([]*domain.LeaveBalance, error)
+	Balances(ctx context.Context, userId string, year int) ([]*domain.LeaveBalance, error)
+	Request(ctx context.Context, d *dto.RequestLeaveDto) (*domain.LeaveRequest, error)
+	Approve(ctx context.Context, id string, comment *string) (*domain.LeaveRequest, error)
+	Reject(ctx context.Context, id string, comment *string) (*domain.LeaveRequest, error)
+	Cancel(ctx context.Context, id string) (*domain.LeaveRequest, error)
+	Get(ctx context.Context, id string) (*domain.LeaveRequest, error)
+	List(ctx context.Context, f *filters.Leave) ([]*domain.LeaveRequest, error)
+}
+
+type LeaveAdapter struct {
+	leaveService LeaveService
+}
+
+func NewLeaveAdapter(leaveService LeaveService) *LeaveAdapter {
+	return &LeaveAdapter{
+		leaveService: leaveService,
+	}
+}
+
+func leaveError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrLeaveNotFound), errors.Is(err, domain.ErrLeaveTypeNotFound), errors.Is(err, domain.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidLeave), errors.Is(err, domain.ErrInvalidLeaveType), errors.Is(err, domain.ErrCommentRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidTransition), errors.Is(err, domain.ErrLeaveOverlap),
+		errors.Is(err, domain.ErrInsufficientBalance), errors.Is(err, domain.ErrLeaveTypeInUse):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func (a *LeaveAdapter) CreateType() fiber.Handler {
+	type request struct {
+		Name            string `json:"name"`
+		Paid            *bool  `json:"paid"`
+		RequiresBalance *bool  `json:"requiresBalance"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		// leave is paid and limited by an allowance unless told otherwise
+		d := &dto.SaveLeaveTypeDto{
+			Name:            req.Name,
+			Paid:            true,
+			RequiresBalance: true,
+		}
+		if req.Paid != nil {
+			d.Paid = *req.Paid
+		}
+		if req.RequiresBalance != nil {
+			d.RequiresBalance = *req.RequiresBalance
+		}
+
+		leaveType, err := a.leaveService.CreateType(c.UserContext(), d)
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"leaveType": leaveType,
+		})
+	}
+}
+
+func (a *LeaveAdapter) ListTypes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		types, err := a.leaveService.ListTypes(c.UserContext())
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"leaveTypes": types,
+		})
+	}
+}
+
+func (a *LeaveAdapter) UpdateType() fiber.Handler {
+	type request struct {
+		Name            *string `json:"name"`
+		Paid            *bool   `json:"paid"`
+		RequiresBalance *bool   `json:"requiresBalance"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		leaveType, err := a.leaveService.UpdateType(c.UserContext(), c.Params("id"), &dto.UpdateLeaveTypeDto{
+			Name:            req.Name,
+			Paid:            req.Paid,
+			RequiresBalance: req.RequiresBalance,
+		})
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"leaveType": leaveType,
+		})
+	}
+}
+
+func (a *LeaveAdapter) DeleteType() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.leaveService.DeleteType(c.UserContext(), c.Params("id")); err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "leave type deleted",
+		})
+	}
+}
+
+// Balances returns the leave balances of a user in a year. userId defaults
+// to the authenticated user and year to the current one.
+func (a *LeaveAdapter) Balances() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId := c.Query("userId", me(c))
+		year := c.QueryInt("year", time.Now().Year())
+
+		balances, err := a.leaveService.Balances(c.UserContext(), userId, year)
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"balances": balances,
+		})
+	}
+}
+
+func (a *LeaveAdapter) SetAllowance() fiber.Handler {
+	type request struct {
+		Year          int `json:"year"`
+		AllowanceDays int `json:"allowanceDays"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if req.Year == 0 {
+			req.Year = time.Now().Year()
+		}
+
+		balances, err := a.leaveService.SetAllowance(c.UserContext(), &dto.SetAllowanceDto{
+			UserId:        c.Params("user_id"),
+			LeaveTypeId:   c.Params("leave_type_id"),
+			Year:          req.Year,
+			AllowanceDays: req.AllowanceDays,
+		})
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"balances": balances,
+		})
+	}
+}
+
+// Request files a leave request. userId defaults to the authenticated user,
+// startDate and endDate are YYYY-MM-DD and both inclusive.
+func (a *LeaveAdapter) Request() fiber.Handler {
+	type request struct {
+		UserId      string  `json:"userId"`
+		LeaveTypeId string  `json:"leaveTypeId"`
+		StartDate   string  `json:"startDate"`
+		EndDate     string  `json:"endDate"`
+		Reason      *string `json:"reason"`
+	}
+
+	fn := "LeaveAdapter.Request"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		start, err := time.Parse(dateLayout, req.StartDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrInvalidLeave.Error(),
+			})
+		}
+
+		end, err := time.Parse(dateLayout, req.EndDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrInvalidLeave.Error(),
+			})
+		}
+
+		userId := req.UserId
+		if userId == "" {
+			userId = me(c)
+		}
+
+		leave, err := a.leaveService.Request(c.UserContext(), &dto.RequestLeaveDto{
+			UserId:      userId,
+			LeaveTypeId: req.LeaveTypeId,
+			StartDate:   start,
+			EndDate:     end,
+			Reason:      req.Reason,
+		})
+		if err != nil {
+			logger.Debug("failed to request leave", slog.String("err", err.Error()))
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"leave": leave,
+		})
+	}
+}
+
+func (a *LeaveAdapter) review(approve bool) fiber.Handler {
+	type request struct {
+		Comment *string `json:"comment"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if len(c.Body()) != 0 {
+			if err := c.BodyParser(req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		var (
+			leave *domain.LeaveRequest
+			err   error
+		)
+		if approve {
+			leave, err = a.leaveService.Approve(c.UserContext(), c.Params("id"), req.Comment)
+		} else {
+			leave, err = a.leaveService.Reject(c.UserContext(), c.Params("id"), req.Comment)
+		}
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"leave": leave,
+		})
+	}
+}
+
+func (a *LeaveAdapter) Approve() fiber.Handler {
+	return a.review(true)
+}
+
+func (a *LeaveAdapter) Reject() fiber.Handler {
+	return a.review(false)
+}
+
+func (a *LeaveAdapter) Cancel() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		leave, err := a.leaveService.Cancel(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"leave": leave,
+		})
+	}
+}
+
+func (a *LeaveAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		leave, err := a.leaveService.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"leave": leave,
+		})
+	}
+}
+
+// List returns leave requests. Optional query parameters userId, teamId,
+// status and the YYYY-MM-DD bounds from and to filter them.
+func (a *LeaveAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f := &filters.Leave{}
+
+		if userId := c.Query("userId"); userId != "" {
+			f.UserId = &userId
+		}
+		if teamId := c.Query("teamId"); teamId != "" {
+			f.TeamId = &teamId
+		}
+		if status := domain.LeaveStatus(c.Query("status")); status != "" {
+			f.Status = &status
+		}
+		if from := c.Query("from"); from != "" {
+			t, err := time.Parse(dateLayout, from)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": domain.ErrInvalidPeriod.Error(),
+				})
+			}
+			f.From = &t
+		}
+		if to := c.Query("to"); to != "" {
+			t, err := time.Parse(dateLayout, to)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": domain.ErrInvalidPeriod.Error(),
+				})
+			}
+			f.To = &t
+		}
+
+		requests, err := a.leaveService.List(c.UserContext(), f)
+		if err != nil {
+			return leaveError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"leave": requests,
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package adapters
+
+import (
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MeAdapter serves endpoints acting on the user the request is
+// authenticated as.
+type MeAdapter struct {
+	usersService    UsersService
+	activityService ActivityService
+}
+
+func NewMeAdapter(usersService UsersService, activityService ActivityService) *MeAdapter {
+	return &MeAdapter{
+		usersService:    usersService,
+		activityService: activityService,
+	}
+}
+
+var errNotAUser = errors.New("credentials are not bound to a user")
+
+// me returns the id of the authenticated user, or an empty string when the
+// request is authenticated by a key without a user.
+func me(c *fiber.Ctx) string {
+	p := principal(c)
+	if p == nil {
+		return ""
+	}
+	return p.UserId
+}
+
+func (a *MeAdapter) Profile() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId := me(c)
+		if userId == "" {
+			return forbidden(c, errNotAUser)
+		}
+
+		user, err := a.usersService.GetUser(c.UserContext(), userId)
+		if err != nil {
+			if errors.Is(err, domain.ErrUserNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return internal(c, fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"user": user,
+		})
+	}
+}
+
+// Start begins a session, on the task of the optional body field taskId,
+// with the optional note and tagIds.
+func (a *MeAdapter) Start() fiber.Handler {
+	type request struct {
+		TaskId *string  `json:"taskId"`
+		Note   *string  `json:"note"`
+		TagIds []string `json:"tagIds"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		userId := me(c)
+		if userId == "" {
+			return forbidden(c, errNotAUser)
+		}
+
+		req := new(request)
+		if len(c.Body()) != 0 {
+			if err := c.BodyParser(req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		if err := a.activityService.Start(c.UserContext(), &dto.SaveActivity{
+			UserId: userId,
+			TaskId: req.TaskId,
+			Note:   req.Note,
+			TagIds: req.TagIds,
+		}); err != nil {
+			if errors.Is(err, domain.ErrUserAlreadyWorking) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return sessionError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "activity started",
+		})
+	}
+}
+
+// Stop finishes the running session, the one of the optional body field
+// sessionId or taskId when several are running. The optional body fields
+// note and tagIds replace those of the session.
+func (a *MeAdapter) Stop() fiber.Handler {
+	type request struct {
+		SessionId *int64   `json:"sessionId"`
+		TaskId    *string  `json:"taskId"`
+		Note      *string  `json:"note"`
+		TagIds    []string `json:"tagIds"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		userId := me(c)
+		if userId == "" {
+			return forbidden(c, errNotAUser)
+		}
+
+		req := new(request)
+		if len(c.Body()) != 0 {
+			if err := c.BodyParser(req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		if err := a.activityService.Stop(c.UserContext(), &dto.StopActivityDto{
+			UserId:    userId,
+			SessionId: req.SessionId,
+			TaskId:    req.TaskId,
+			Note:      req.Note,
+			TagIds:    req.TagIds,
+		}); err != nil {
+			if errors.Is(err, domain.ErrUserNotWorking) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return sessionError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "activity finished",
+		})
+	}
+}
+
+// Switch finishes the running session, the one of sessionId when several
+// are running, and starts one on the task of taskId, or on no task when it
+// is null, at the same instant.
+func (a *MeAdapter) Switch() fiber.Handler {
+	type request struct {
+		SessionId *int64   `json:"sessionId"`
+		TaskId    *string  `json:"taskId"`
+		Note      *string  `json:"note"`
+		TagIds    []string `json:"tagIds"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		userId := me(c)
+		if userId == "" {
+			return forbidden(c, errNotAUser)
+		}
+
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		session, err := a.activityService.Switch(c.UserContext(), &dto.SwitchActivityDto{
+			UserId:    userId,
+			SessionId: req.SessionId,
+			TaskId:    req.TaskId,
+			Note:      req.Note,
+			TagIds:    req.TagIds,
+		})
+		if err != nil {
+			return sessionError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"session": session,
+		})
+	}
+}
+
+func (a *MeAdapter) Summary() fiber.Handler {
+
+	fn := "MeAdapter.Summary"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		userId := me(c)
+		if userId == "" {
+			return forbidden(c, errNotAUser)
+		}
+
+		filters, err := activityFilters(c, userId)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		summary, err := a.activityService.GetSummary(c.UserContext(), filters)
+		if err != nil {
+			logger.Error("failed to get summary", slog.String("err", err.Error()))
+			return sessionError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(summary)
+	}
+}
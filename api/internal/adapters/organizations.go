@@ -0,0 +1,133 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OrganizationService interface {
+	Create(ctx context.Context, d *dto.SaveOrganizationDto) (*domain.Organization, error)
+	List(ctx context.Context) ([]*domain.Organization, error)
+	Current(ctx context.Context) (*domain.Organization, error)
+	Update(ctx context.Context, d *dto.UpdateOrganizationDto) (*domain.Organization, error)
+}
+
+type OrganizationsAdapter struct {
+	organizationService OrganizationService
+}
+
+func NewOrganizationsAdapter(organizationService OrganizationService) *OrganizationsAdapter {
+	return &OrganizationsAdapter{
+		organizationService: organizationService,
+	}
+}
+
+func organizationError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrOrgNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidOrgName), errors.Is(err, domain.ErrInvalidTimerPolicy):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func (a *OrganizationsAdapter) Create() fiber.Handler {
+	type request struct {
+		Name string `json:"name"`
+	}
+
+	fn := "OrganizationsAdapter.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		org, err := a.organizationService.Create(c.UserContext(), &dto.SaveOrganizationDto{
+			Name: req.Name,
+		})
+		if err != nil {
+			logger.Error("failed to create organization", slog.String("err", err.Error()))
+			return organizationError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"organization": org,
+		})
+	}
+}
+
+func (a *OrganizationsAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgs, err := a.organizationService.List(c.UserContext())
+		if err != nil {
+			return organizationError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"organizations": orgs,
+		})
+	}
+}
+
+// Current returns the organization of the request with its settings.
+func (a *OrganizationsAdapter) Current() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		org, err := a.organizationService.Current(c.UserContext())
+		if err != nil {
+			return organizationError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"organization": org,
+		})
+	}
+}
+
+// Update changes the settings of the organization of the request. The
+// timerPolicy is single, one running session per user, or multiple, one
+// per task.
+func (a *OrganizationsAdapter) Update() fiber.Handler {
+	type request struct {
+		TimerPolicy *domain.TimerPolicy `json:"timerPolicy"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		org, err := a.organizationService.Update(c.UserContext(), &dto.UpdateOrganizationDto{
+			TimerPolicy: req.TimerPolicy,
+		})
+		if err != nil {
+			return organizationError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"organization": org,
+		})
+	}
+}
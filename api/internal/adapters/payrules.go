@@ -0,0 +1,172 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PayRulesService interface {
+	List(ctx context.Context) ([]*domain.PayRules, error)
+	Save(ctx context.Context, d *dto.SavePayRulesDto) (*domain.PayRules, error)
+	Delete(ctx context.Context, teamId *string) error
+	Effective(ctx context.Context, userId string) (*domain.PayRules, error)
+	Compute(ctx context.Context, f *filters.Activity) (*domain.ComputedSummary, error)
+}
+
+type PayRulesAdapter struct {
+	payRulesService PayRulesService
+}
+
+func NewPayRulesAdapter(payRulesService PayRulesService) *PayRulesAdapter {
+	return &PayRulesAdapter{
+		payRulesService: payRulesService,
+	}
+}
+
+func payRulesError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrPayRulesNotFound), errors.Is(err, domain.ErrTeamNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidPayRules):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// teamParam returns the team_id route parameter, nil on the organization
+// wide routes.
+func teamParam(c *fiber.Ctx) *string {
+	if teamId := c.Params("team_id"); teamId != "" {
+		return &teamId
+	}
+	return nil
+}
+
+func (a *PayRulesAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rules, err := a.payRulesService.List(c.UserContext())
+		if err != nil {
+			return payRulesError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"rules": rules,
+		})
+	}
+}
+
+// Save replaces the rules of the team_id route parameter, or the
+// organization wide rules without it.
+func (a *PayRulesAdapter) Save() fiber.Handler {
+	type request struct {
+		RoundingMode          domain.RoundingMode `json:"roundingMode"`
+		RoundingMinutes       int                 `json:"roundingMinutes"`
+		MinSessionMinutes     int                 `json:"minSessionMinutes"`
+		DailyOvertimeMinutes  *int                `json:"dailyOvertimeMinutes"`
+		DailyMultiplier       float64             `json:"dailyMultiplier"`
+		WeeklyOvertimeMinutes *int                `json:"weeklyOvertimeMinutes"`
+		WeeklyMultiplier      float64             `json:"weeklyMultiplier"`
+		Timezone              string              `json:"timezone"`
+	}
+
+	fn := "PayRulesAdapter.Save"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		rules, err := a.payRulesService.Save(c.UserContext(), &dto.SavePayRulesDto{
+			TeamId: teamParam(c),
+			Rules: &domain.PayRules{
+				RoundingMode:          req.RoundingMode,
+				RoundingMinutes:       req.RoundingMinutes,
+				MinSessionMinutes:     req.MinSessionMinutes,
+				DailyOvertimeMinutes:  req.DailyOvertimeMinutes,
+				DailyMultiplier:       req.DailyMultiplier,
+				WeeklyOvertimeMinutes: req.WeeklyOvertimeMinutes,
+				WeeklyMultiplier:      req.WeeklyMultiplier,
+				Timezone:              req.Timezone,
+			},
+		})
+		if err != nil {
+			logger.Debug("failed to save pay rules", slog.String("err", err.Error()))
+			return payRulesError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"rules": rules,
+		})
+	}
+}
+
+func (a *PayRulesAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.payRulesService.Delete(c.UserContext(), teamParam(c)); err != nil {
+			return payRulesError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "pay rules deleted",
+		})
+	}
+}
+
+// Effective returns the rules a user is paid by, userId defaults to the
+// authenticated user.
+func (a *PayRulesAdapter) Effective() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rules, err := a.payRulesService.Effective(c.UserContext(), c.Query("userId", me(c)))
+		if err != nil {
+			return payRulesError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"rules": rules,
+		})
+	}
+}
+
+// Computed returns the activity summary of a user together with the
+// totals computed by their pay rules. It takes the query parameters of the
+// activity summary.
+func (a *PayRulesAdapter) Computed() fiber.Handler {
+	fn := "PayRulesAdapter.Computed"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		filters, err := activityFilters(c, c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		summary, err := a.payRulesService.Compute(c.UserContext(), filters)
+		if err != nil {
+			logger.Debug("failed to compute summary", slog.String("err", err.Error()))
+			return payRulesError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(summary)
+	}
+}
@@ -0,0 +1,356 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ProjectService interface {
+	CreateClient(ctx context.Context, d *dto.SaveClientDto) (*domain.Client, error)
+	Clients(ctx context.Context) ([]*domain.Client, error)
+	GetClient(ctx context.Context, id string) (*domain.Client, error)
+	UpdateClient(ctx context.Context, id string, d *dto.SaveClientDto) (*domain.Client, error)
+	DeleteClient(ctx context.Context, id string) error
+	Create(ctx context.Context, d *dto.SaveProjectDto) (*domain.Project, error)
+	List(ctx context.Context, f *filters.Projects) ([]*domain.Project, error)
+	Get(ctx context.Context, id string) (*domain.Project, error)
+	Update(ctx context.Context, id string, d *dto.UpdateProjectDto) (*domain.Project, error)
+	Delete(ctx context.Context, id string) error
+	SetRate(ctx context.Context, d *dto.SetRateDto) (*domain.ProjectRate, error)
+	DeleteRate(ctx context.Context, projectId, userId string) error
+	Rates(ctx context.Context, projectId string) ([]*domain.ProjectRate, error)
+	Summary(ctx context.Context, f *filters.ProjectSummary) (*domain.ProjectSummary, error)
+}
+
+type ProjectsAdapter struct {
+	projectService ProjectService
+}
+
+func NewProjectsAdapter(projectService ProjectService) *ProjectsAdapter {
+	return &ProjectsAdapter{
+		projectService: projectService,
+	}
+}
+
+func projectError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrClientNotFound), errors.Is(err, domain.ErrProjectNotFound), errors.Is(err, domain.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidClient), errors.Is(err, domain.ErrInvalidProject):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrClientInUse), errors.Is(err, domain.ErrProjectInUse):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func (a *ProjectsAdapter) CreateClient() fiber.Handler {
+	type request struct {
+		Name string `json:"name"`
+	}
+
+	fn := "ProjectsAdapter.CreateClient"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		client, err := a.projectService.CreateClient(c.UserContext(), &dto.SaveClientDto{
+			Name: req.Name,
+		})
+		if err != nil {
+			logger.Error("failed to create client", slog.String("err", err.Error()))
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"client": client,
+		})
+	}
+}
+
+func (a *ProjectsAdapter) Clients() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		clients, err := a.projectService.Clients(c.UserContext())
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"clients": clients,
+		})
+	}
+}
+
+func (a *ProjectsAdapter) GetClient() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		client, err := a.projectService.GetClient(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"client": client,
+		})
+	}
+}
+
+func (a *ProjectsAdapter) UpdateClient() fiber.Handler {
+	type request struct {
+		Name string `json:"name"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		client, err := a.projectService.UpdateClient(c.UserContext(), c.Params("id"), &dto.SaveClientDto{
+			Name: req.Name,
+		})
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"client": client,
+		})
+	}
+}
+
+func (a *ProjectsAdapter) DeleteClient() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.projectService.DeleteClient(c.UserContext(), c.Params("id")); err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "client deleted",
+		})
+	}
+}
+
+// Create adds a project. Rates are in the minor unit of the currency,
+// which defaults to USD.
+func (a *ProjectsAdapter) Create() fiber.Handler {
+	type request struct {
+		ClientId   *string `json:"clientId"`
+		Name       string  `json:"name"`
+		Billable   bool    `json:"billable"`
+		HourlyRate int64   `json:"hourlyRate"`
+		Currency   string  `json:"currency"`
+	}
+
+	fn := "ProjectsAdapter.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		project, err := a.projectService.Create(c.UserContext(), &dto.SaveProjectDto{
+			ClientId:   req.ClientId,
+			Name:       req.Name,
+			Billable:   req.Billable,
+			HourlyRate: req.HourlyRate,
+			Currency:   req.Currency,
+		})
+		if err != nil {
+			logger.Error("failed to create project", slog.String("err", err.Error()))
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"project": project,
+		})
+	}
+}
+
+// List returns projects, optionally of the client in query parameter
+// clientId.
+func (a *ProjectsAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f := &filters.Projects{}
+		if clientId := c.Query("clientId"); clientId != "" {
+			f.ClientId = &clientId
+		}
+
+		projects, err := a.projectService.List(c.UserContext(), f)
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"projects": projects,
+		})
+	}
+}
+
+func (a *ProjectsAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		project, err := a.projectService.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"project": project,
+		})
+	}
+}
+
+// Update changes a project, an empty clientId detaches it from its client.
+func (a *ProjectsAdapter) Update() fiber.Handler {
+	type request struct {
+		ClientId   *string `json:"clientId"`
+		Name       *string `json:"name"`
+		Billable   *bool   `json:"billable"`
+		HourlyRate *int64  `json:"hourlyRate"`
+		Currency   *string `json:"currency"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		project, err := a.projectService.Update(c.UserContext(), c.Params("id"), &dto.UpdateProjectDto{
+			ClientId:   req.ClientId,
+			Name:       req.Name,
+			Billable:   req.Billable,
+			HourlyRate: req.HourlyRate,
+			Currency:   req.Currency,
+		})
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"project": project,
+		})
+	}
+}
+
+func (a *ProjectsAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.projectService.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "project deleted",
+		})
+	}
+}
+
+func (a *ProjectsAdapter) Rates() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rates, err := a.projectService.Rates(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"rates": rates,
+		})
+	}
+}
+
+// SetRate overrides the hourly rate of the project for a user.
+func (a *ProjectsAdapter) SetRate() fiber.Handler {
+	type request struct {
+		HourlyRate int64 `json:"hourlyRate"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		rate, err := a.projectService.SetRate(c.UserContext(), &dto.SetRateDto{
+			ProjectId:  c.Params("id"),
+			UserId:     c.Params("user_id"),
+			HourlyRate: req.HourlyRate,
+		})
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"rate": rate,
+		})
+	}
+}
+
+func (a *ProjectsAdapter) DeleteRate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.projectService.DeleteRate(c.UserContext(), c.Params("id"), c.Params("user_id")); err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "rate deleted",
+		})
+	}
+}
+
+// Summary totals and bills the time tracked on the project between the
+// optional start_time and end_time.
+func (a *ProjectsAdapter) Summary() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		af, err := activityFilters(c, "")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		summary, err := a.projectService.Summary(c.UserContext(), &filters.ProjectSummary{
+			ProjectId: c.Params("id"),
+			StartTime: af.StartTime,
+			EndTime:   af.EndTime,
+		})
+		if err != nil {
+			return projectError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"summary": summary,
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package adapters
+
+import (
+	"em-test/internal/domain"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const maxRequestIdLength = 128
+
+// RequestId tags every request with an id, taken from the X-Request-Id
+// header when the client sent a usable one. The id is echoed back and
+// recorded in the audit log.
+func RequestId() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := strings.Clone(c.Get(fiber.HeaderXRequestID))
+		if id == "" || len(id) > maxRequestIdLength {
+			id = uuid.New().String()
+		}
+
+		c.Set(fiber.HeaderXRequestID, id)
+		c.SetUserContext(domain.WithRequestId(c.UserContext(), id))
+
+		return c.Next()
+	}
+}
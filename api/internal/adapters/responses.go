@@ -5,3 +5,9 @@ import "github.com/gofiber/fiber/v2"
 func internal(c *fiber.Ctx, payload any) error {
 	return c.Status(fiber.StatusInternalServerError).JSON(payload)
 }
+
+func forbidden(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
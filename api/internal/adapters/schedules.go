@@ -0,0 +1,247 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// dateLayout is the format of the days of an attendance report.
+const dateLayout = "2006-01-02"
+
+type ScheduleService interface {
+	Create(ctx context.Context, d *dto.SaveScheduleDto) (*domain.Schedule, error)
+	List(ctx context.Context) ([]*domain.Schedule, error)
+	Get(ctx context.Context, id string) (*domain.Schedule, error)
+	Update(ctx context.Context, id string, d *dto.UpdateScheduleDto) (*domain.Schedule, error)
+	Delete(ctx context.Context, id string) error
+	AssignUser(ctx context.Context, id, userId string, assign bool) (*domain.User, error)
+	AssignTeam(ctx context.Context, id, teamId string, assign bool) (*domain.Team, error)
+	Effective(ctx context.Context, userId string) (*domain.Schedule, error)
+	Report(ctx context.Context, userId string, from, to time.Time) (*domain.AttendanceReport, error)
+}
+
+type SchedulesAdapter struct {
+	scheduleService ScheduleService
+}
+
+func NewSchedulesAdapter(scheduleService ScheduleService) *SchedulesAdapter {
+	return &SchedulesAdapter{
+		scheduleService: scheduleService,
+	}
+}
+
+func scheduleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrScheduleNotFound), errors.Is(err, domain.ErrNoSchedule),
+		errors.Is(err, domain.ErrScheduleNotAssigned), errors.Is(err, domain.ErrUserNotFound),
+		errors.Is(err, domain.ErrTeamNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidSchedule), errors.Is(err, domain.ErrInvalidPeriod):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func (a *SchedulesAdapter) Create() fiber.Handler {
+	type request struct {
+		Name         string               `json:"name"`
+		Timezone     string               `json:"timezone"`
+		Region       string               `json:"region"`
+		GraceMinutes int                  `json:"graceMinutes"`
+		Days         []domain.ScheduleDay `json:"days"`
+	}
+
+	fn := "SchedulesAdapter.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		schedule, err := a.scheduleService.Create(c.UserContext(), &dto.SaveScheduleDto{
+			Name:         req.Name,
+			Timezone:     req.Timezone,
+			Region:       req.Region,
+			GraceMinutes: req.GraceMinutes,
+			Days:         req.Days,
+		})
+		if err != nil {
+			logger.Debug("failed to create schedule", slog.String("err", err.Error()))
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"schedule": schedule,
+		})
+	}
+}
+
+func (a *SchedulesAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		schedules, err := a.scheduleService.List(c.UserContext())
+		if err != nil {
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"schedules": schedules,
+		})
+	}
+}
+
+func (a *SchedulesAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		schedule, err := a.scheduleService.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"schedule": schedule,
+		})
+	}
+}
+
+func (a *SchedulesAdapter) Update() fiber.Handler {
+	type request struct {
+		Name         *string              `json:"name"`
+		Timezone     *string              `json:"timezone"`
+		Region       *string              `json:"region"`
+		GraceMinutes *int                 `json:"graceMinutes"`
+		Days         []domain.ScheduleDay `json:"days"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		schedule, err := a.scheduleService.Update(c.UserContext(), c.Params("id"), &dto.UpdateScheduleDto{
+			Name:         req.Name,
+			Timezone:     req.Timezone,
+			Region:       req.Region,
+			GraceMinutes: req.GraceMinutes,
+			Days:         req.Days,
+		})
+		if err != nil {
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"schedule": schedule,
+		})
+	}
+}
+
+func (a *SchedulesAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.scheduleService.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "schedule deleted",
+		})
+	}
+}
+
+// AssignUser assigns the schedule to a user, or takes it away again when
+// assign is false.
+func (a *SchedulesAdapter) AssignUser(assign bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := a.scheduleService.AssignUser(c.UserContext(), c.Params("id"), c.Params("user_id"), assign)
+		if err != nil {
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"user": user,
+		})
+	}
+}
+
+// AssignTeam assigns the schedule to a team, or takes it away again when
+// assign is false.
+func (a *SchedulesAdapter) AssignTeam(assign bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		team, err := a.scheduleService.AssignTeam(c.UserContext(), c.Params("id"), c.Params("team_id"), assign)
+		if err != nil {
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"team": team,
+		})
+	}
+}
+
+// Effective returns the schedule a user follows, userId defaults to the
+// authenticated user.
+func (a *SchedulesAdapter) Effective() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId := c.Query("userId", me(c))
+
+		schedule, err := a.scheduleService.Effective(c.UserContext(), userId)
+		if err != nil {
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"schedule": schedule,
+		})
+	}
+}
+
+// Report compares the sessions of a user against their schedule day by day.
+// from and to are YYYY-MM-DD and both inclusive, userId defaults to the
+// authenticated user.
+func (a *SchedulesAdapter) Report() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId := c.Query("userId", me(c))
+
+		from, err := time.Parse(dateLayout, c.Query("from"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrInvalidPeriod.Error(),
+			})
+		}
+
+		to, err := time.Parse(dateLayout, c.Query("to"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrInvalidPeriod.Error(),
+			})
+		}
+
+		report, err := a.scheduleService.Report(c.UserContext(), userId, from, to)
+		if err != nil {
+			return scheduleError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"report": report,
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TagService interface {
+	Create(ctx context.Context, d *dto.SaveTagDto) (*domain.Tag, error)
+	List(ctx context.Context) ([]*domain.Tag, error)
+	Update(ctx context.Context, id string, d *dto.SaveTagDto) (*domain.Tag, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type TagsAdapter struct {
+	tagService TagService
+}
+
+func NewTagsAdapter(tagService TagService) *TagsAdapter {
+	return &TagsAdapter{
+		tagService: tagService,
+	}
+}
+
+func tagError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrTagNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidTag):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func (a *TagsAdapter) Create() fiber.Handler {
+	type request struct {
+		Name string `json:"name"`
+	}
+
+	fn := "TagsAdapter.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		tag, err := a.tagService.Create(c.UserContext(), &dto.SaveTagDto{
+			Name: req.Name,
+		})
+		if err != nil {
+			logger.Error("failed to create tag", slog.String("err", err.Error()))
+			return tagError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"tag": tag,
+		})
+	}
+}
+
+func (a *TagsAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tags, err := a.tagService.List(c.UserContext())
+		if err != nil {
+			return tagError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"tags": tags,
+		})
+	}
+}
+
+func (a *TagsAdapter) Update() fiber.Handler {
+	type request struct {
+		Name string `json:"name"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		tag, err := a.tagService.Update(c.UserContext(), c.Params("id"), &dto.SaveTagDto{
+			Name: req.Name,
+		})
+		if err != nil {
+			return tagError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"tag": tag,
+		})
+	}
+}
+
+func (a *TagsAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.tagService.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return tagError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "tag deleted",
+		})
+	}
+}
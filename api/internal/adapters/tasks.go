@@ -0,0 +1,253 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TaskService interface {
+	Create(ctx context.Context, d *dto.SaveTaskDto) (*domain.Task, error)
+	List(ctx context.Context, f *filters.Tasks) ([]*domain.Task, error)
+	Get(ctx context.Context, id string) (*domain.Task, error)
+	Update(ctx context.Context, id string, d *dto.UpdateTaskDto) (*domain.Task, error)
+	Delete(ctx context.Context, id string) error
+	EstimateReport(ctx context.Context, f *filters.Tasks) (*domain.EstimateReport, error)
+	Time(ctx context.Context, id string) (*domain.TaskTime, error)
+}
+
+type TasksAdapter struct {
+	taskService TaskService
+}
+
+func NewTasksAdapter(taskService TaskService) *TasksAdapter {
+	return &TasksAdapter{
+		taskService: taskService,
+	}
+}
+
+func taskError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrTaskNotFound), errors.Is(err, domain.ErrProjectNotFound), errors.Is(err, domain.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidTask), errors.Is(err, domain.ErrInvalidParentTask):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrTaskInUse), errors.Is(err, domain.ErrTaskHasSubtasks):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// taskFilters parses the optional query parameters projectId, status,
+// assigneeId and parentId, where an empty parentId selects top level tasks.
+func taskFilters(c *fiber.Ctx) *filters.Tasks {
+	f := &filters.Tasks{}
+	if projectId := c.Query("projectId"); projectId != "" {
+		f.ProjectId = &projectId
+	}
+	if c.Context().QueryArgs().Has("parentId") {
+		parentId := c.Query("parentId")
+		f.ParentId = &parentId
+	}
+	if status := c.Query("status"); status != "" {
+		s := domain.TaskStatus(status)
+		f.Status = &s
+	}
+	if assigneeId := c.Query("assigneeId"); assigneeId != "" {
+		f.AssigneeId = &assigneeId
+	}
+	return f
+}
+
+// Create adds a task, a subtask when parentId is set. Field dueDate is a
+// YYYY-MM-DD date.
+func (a *TasksAdapter) Create() fiber.Handler {
+	type request struct {
+		ProjectId       string            `json:"projectId"`
+		ParentId        *string           `json:"parentId"`
+		Title           string            `json:"title"`
+		EstimateMinutes *int              `json:"estimateMinutes"`
+		Status          domain.TaskStatus `json:"status"`
+		DueDate         *string           `json:"dueDate"`
+		AssigneeIds     []string          `json:"assigneeIds"`
+	}
+
+	fn := "TasksAdapter.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		d := &dto.SaveTaskDto{
+			ProjectId:       req.ProjectId,
+			ParentId:        req.ParentId,
+			Title:           req.Title,
+			EstimateMinutes: req.EstimateMinutes,
+			Status:          req.Status,
+			AssigneeIds:     req.AssigneeIds,
+		}
+
+		if req.DueDate != nil {
+			due, err := time.Parse(dateLayout, *req.DueDate)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			d.DueDate = &due
+		}
+
+		task, err := a.taskService.Create(c.UserContext(), d)
+		if err != nil {
+			logger.Error("failed to create task", slog.String("err", err.Error()))
+			return taskError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"task": task,
+		})
+	}
+}
+
+// List returns tasks, optionally of the project in query parameter
+// projectId, in status or assigned to assigneeId.
+func (a *TasksAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tasks, err := a.taskService.List(c.UserContext(), taskFilters(c))
+		if err != nil {
+			return taskError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"tasks": tasks,
+		})
+	}
+}
+
+func (a *TasksAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		task, err := a.taskService.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return taskError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"task": task,
+		})
+	}
+}
+
+// Update changes a task. An estimateMinutes of 0 and an empty dueDate
+// clear them, an empty parentId makes the task a top level one.
+func (a *TasksAdapter) Update() fiber.Handler {
+	type request struct {
+		ProjectId       *string            `json:"projectId"`
+		ParentId        *string            `json:"parentId"`
+		Title           *string            `json:"title"`
+		EstimateMinutes *int               `json:"estimateMinutes"`
+		Status          *domain.TaskStatus `json:"status"`
+		DueDate         *string            `json:"dueDate"`
+		AssigneeIds     *[]string          `json:"assigneeIds"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		d := &dto.UpdateTaskDto{
+			ProjectId:       req.ProjectId,
+			ParentId:        req.ParentId,
+			Title:           req.Title,
+			EstimateMinutes: req.EstimateMinutes,
+			Status:          req.Status,
+			AssigneeIds:     req.AssigneeIds,
+		}
+
+		if req.DueDate != nil {
+			var due time.Time
+			if *req.DueDate != "" {
+				parsed, err := time.Parse(dateLayout, *req.DueDate)
+				if err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error": err.Error(),
+					})
+				}
+				due = parsed
+			}
+			d.DueDate = &due
+		}
+
+		task, err := a.taskService.Update(c.UserContext(), c.Params("id"), d)
+		if err != nil {
+			return taskError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"task": task,
+		})
+	}
+}
+
+func (a *TasksAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.taskService.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return taskError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "task deleted",
+		})
+	}
+}
+
+// EstimateReport compares estimated and tracked time per task and per
+// assignee, of the tasks selected like List.
+func (a *TasksAdapter) EstimateReport() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report, err := a.taskService.EstimateReport(c.UserContext(), taskFilters(c))
+		if err != nil {
+			return taskError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(report)
+	}
+}
+
+// Time returns the time tracked on a task itself and on the task and all
+// of its subtasks, per user.
+func (a *TasksAdapter) Time() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		taskTime, err := a.taskService.Time(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return taskError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(taskTime)
+	}
+}
@@ -0,0 +1,191 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TeamService interface {
+	Create(ctx context.Context, d *dto.SaveTeamDto) (*domain.Team, error)
+	List(ctx context.Context) ([]*domain.Team, error)
+	Get(ctx context.Context, id string) (*domain.Team, error)
+	Update(ctx context.Context, id string, d *dto.UpdateTeamDto) (*domain.Team, error)
+	Delete(ctx context.Context, id string) error
+	AddMember(ctx context.Context, teamId, userId string) error
+	RemoveMember(ctx context.Context, teamId, userId string) error
+	MemberIds(ctx context.Context, teamId string) ([]string, error)
+}
+
+type TeamsAdapter struct {
+	teamService TeamService
+}
+
+func NewTeamsAdapter(teamService TeamService) *TeamsAdapter {
+	return &TeamsAdapter{
+		teamService: teamService,
+	}
+}
+
+func teamError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrTeamNotFound), errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrMemberNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidTeamName), errors.Is(err, domain.ErrInvalidParentTeam), errors.Is(err, domain.ErrInvalidManager):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func (a *TeamsAdapter) Create() fiber.Handler {
+	type request struct {
+		Name      string  `json:"name"`
+		ParentId  *string `json:"parentId"`
+		ManagerId *string `json:"managerId"`
+	}
+
+	fn := "TeamsAdapter.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		team, err := a.teamService.Create(c.UserContext(), &dto.SaveTeamDto{
+			Name:      req.Name,
+			ParentId:  req.ParentId,
+			ManagerId: req.ManagerId,
+		})
+		if err != nil {
+			logger.Error("failed to create team", slog.String("err", err.Error()))
+			return teamError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"team": team,
+		})
+	}
+}
+
+func (a *TeamsAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		teams, err := a.teamService.List(c.UserContext())
+		if err != nil {
+			return teamError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"teams": teams,
+		})
+	}
+}
+
+func (a *TeamsAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		team, err := a.teamService.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return teamError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"team": team,
+		})
+	}
+}
+
+func (a *TeamsAdapter) Update() fiber.Handler {
+	type request struct {
+		Name      *string `json:"name"`
+		ParentId  *string `json:"parentId"`
+		ManagerId *string `json:"managerId"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		team, err := a.teamService.Update(c.UserContext(), c.Params("id"), &dto.UpdateTeamDto{
+			Name:      req.Name,
+			ParentId:  req.ParentId,
+			ManagerId: req.ManagerId,
+		})
+		if err != nil {
+			return teamError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"team": team,
+		})
+	}
+}
+
+func (a *TeamsAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.teamService.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return teamError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "team deleted",
+		})
+	}
+}
+
+// Members lists ids of the members of the team and its descendant teams.
+func (a *TeamsAdapter) Members() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ids, err := a.teamService.MemberIds(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return teamError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"userIds": ids,
+		})
+	}
+}
+
+func (a *TeamsAdapter) AddMember() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.teamService.AddMember(c.UserContext(), c.Params("id"), c.Params("user_id")); err != nil {
+			return teamError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "member added",
+		})
+	}
+}
+
+func (a *TeamsAdapter) RemoveMember() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.teamService.RemoveMember(c.UserContext(), c.Params("id"), c.Params("user_id")); err != nil {
+			return teamError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "member removed",
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// weekLayout is the format of the week start of a timesheet.
+const weekLayout = "2006-01-02"
+
+type TimesheetService interface {
+	Submit(ctx context.Context, d *dto.SubmitTimesheetDto) (*domain.Timesheet, error)
+	Approve(ctx context.Context, id string, comment *string) (*domain.Timesheet, error)
+	Reject(ctx context.Context, id string, comment *string) (*domain.Timesheet, error)
+	Get(ctx context.Context, id string) (*domain.Timesheet, error)
+	List(ctx context.Context, f *filters.Timesheets) ([]*domain.Timesheet, error)
+}
+
+type TimesheetsAdapter struct {
+	timesheetService TimesheetService
+}
+
+func NewTimesheetsAdapter(timesheetService TimesheetService) *TimesheetsAdapter {
+	return &TimesheetsAdapter{
+		timesheetService: timesheetService,
+	}
+}
+
+func timesheetError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrTimesheetNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidWeekStart), errors.Is(err, domain.ErrCommentRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidTransition), errors.Is(err, domain.ErrSessionRunning), errors.Is(err, domain.ErrWeekNotOver):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// Submit submits a week for approval. userId defaults to the authenticated
+// user, weekStart is the monday of the week as YYYY-MM-DD.
+func (a *TimesheetsAdapter) Submit() fiber.Handler {
+	type request struct {
+		UserId    string `json:"userId"`
+		WeekStart string `json:"weekStart"`
+	}
+
+	fn := "TimesheetsAdapter.Submit"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		weekStart, err := time.Parse(weekLayout, req.WeekStart)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": domain.ErrInvalidWeekStart.Error(),
+			})
+		}
+
+		userId := req.UserId
+		if userId == "" {
+			userId = me(c)
+		}
+
+		timesheet, err := a.timesheetService.Submit(c.UserContext(), &dto.SubmitTimesheetDto{
+			UserId:    userId,
+			WeekStart: weekStart,
+		})
+		if err != nil {
+			logger.Debug("failed to submit timesheet", slog.String("err", err.Error()))
+			return timesheetError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"timesheet": timesheet,
+		})
+	}
+}
+
+func (a *TimesheetsAdapter) review(approve bool) fiber.Handler {
+	type request struct {
+		Comment *string `json:"comment"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if len(c.Body()) != 0 {
+			if err := c.BodyParser(req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		var (
+			timesheet *domain.Timesheet
+			err       error
+		)
+		if approve {
+			timesheet, err = a.timesheetService.Approve(c.UserContext(), c.Params("id"), req.Comment)
+		} else {
+			timesheet, err = a.timesheetService.Reject(c.UserContext(), c.Params("id"), req.Comment)
+		}
+		if err != nil {
+			return timesheetError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"timesheet": timesheet,
+		})
+	}
+}
+
+func (a *TimesheetsAdapter) Approve() fiber.Handler {
+	return a.review(true)
+}
+
+func (a *TimesheetsAdapter) Reject() fiber.Handler {
+	return a.review(false)
+}
+
+func (a *TimesheetsAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		timesheet, err := a.timesheetService.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return timesheetError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"timesheet": timesheet,
+		})
+	}
+}
+
+func (a *TimesheetsAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f := &filters.Timesheets{}
+
+		if userId := c.Query("userId"); userId != "" {
+			f.UserId = &userId
+		}
+		if status := domain.TimesheetStatus(c.Query("status")); status != "" {
+			f.Status = &status
+		}
+
+		timesheets, err := a.timesheetService.List(c.UserContext(), f)
+		if err != nil {
+			return timesheetError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"timesheets": timesheets,
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"context"
 	"em-test/internal/domain"
 	"em-test/internal/lib/dto"
 	"em-test/internal/lib/filters"
@@ -13,8 +14,11 @@ import (
 )
 
 type UsersService interface {
-	AddUser(dto *dto.AddUserDto) (*domain.User, error)
-	GetUsers(filters *filters.UsersFilters) (users []*domain.User, total int64, err error)
+	AddUser(ctx context.Context, dto *dto.AddUserDto) (*domain.User, error)
+	GetUsers(ctx context.Context, filters *filters.UsersFilters) (users []*domain.User, total int64, err error)
+	GetUser(ctx context.Context, id string) (*domain.User, error)
+	UpdateUser(ctx context.Context, id string, dto *dto.UpdateUserDto) (*domain.User, error)
+	DeleteUser(ctx context.Context, id string) error
 }
 
 type UsersAdapter struct {
@@ -74,11 +78,15 @@ func (a *UsersAdapter) AddUser() fiber.Handler {
 			})
 		}
 
-		user, err := a.usersService.AddUser(&dto.AddUserDto{
+		user, err := a.usersService.AddUser(c.UserContext(), &dto.AddUserDto{
 			PassportSerie:  serie,
 			PassportNumber: number,
 		})
 		if err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
+
 			if errors.Is(err, domain.ErrUserAlreadyExists) {
 				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 					"error": "User already exists",
@@ -114,6 +122,7 @@ func (a *UsersAdapter) GetUsers() fiber.Handler {
 		surname := c.Query("surname")
 		name := c.Query("name")
 		address := c.Query("address")
+		teamId := c.Query("teamId")
 
 		logger.Debug(
 			"query params",
@@ -123,6 +132,7 @@ func (a *UsersAdapter) GetUsers() fiber.Handler {
 			slog.String("surname", surname),
 			slog.String("name", name),
 			slog.String("address", address),
+			slog.String("teamId", teamId),
 		)
 
 		filters := &filters.UsersFilters{
@@ -142,9 +152,15 @@ func (a *UsersAdapter) GetUsers() fiber.Handler {
 		if address != "" {
 			filters.Address = &address
 		}
+		if teamId != "" {
+			filters.TeamId = &teamId
+		}
 
-		users, total, err := a.usersService.GetUsers(filters)
+		users, total, err := a.usersService.GetUsers(c.UserContext(), filters)
 		if err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				return forbidden(c, err)
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
 			})
@@ -156,3 +172,70 @@ func (a *UsersAdapter) GetUsers() fiber.Handler {
 		})
 	}
 }
+
+func (a *UsersAdapter) UpdateUser() fiber.Handler {
+	type request struct {
+		Role      *domain.Role `json:"role"`
+		ManagerId *string      `json:"managerId"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		var req request
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		user, err := a.usersService.UpdateUser(c.UserContext(), c.Params("id"), &dto.UpdateUserDto{
+			Role:      req.Role,
+			ManagerId: req.ManagerId,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrForbidden):
+				return forbidden(c, err)
+			case errors.Is(err, domain.ErrUserNotFound):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			case errors.Is(err, domain.ErrInvalidRole), errors.Is(err, domain.ErrInvalidManager):
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"user": user,
+		})
+	}
+}
+
+func (a *UsersAdapter) DeleteUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.usersService.DeleteUser(c.UserContext(), c.Params("id")); err != nil {
+			switch {
+			case errors.Is(err, domain.ErrForbidden):
+				return forbidden(c, err)
+			case errors.Is(err, domain.ErrUserNotFound):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "user deleted",
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package adapters
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookService interface {
+	Create(ctx context.Context, d *dto.SaveWebhookDto) (*domain.Webhook, error)
+	Get(ctx context.Context, id string) (*domain.Webhook, error)
+	List(ctx context.Context) ([]*domain.Webhook, error)
+	Update(ctx context.Context, id string, d *dto.UpdateWebhookDto) (*domain.Webhook, error)
+	Delete(ctx context.Context, id string) error
+	Deliveries(ctx context.Context, id string, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+type WebhooksAdapter struct {
+	webhookService WebhookService
+}
+
+func NewWebhooksAdapter(webhookService WebhookService) *WebhooksAdapter {
+	return &WebhooksAdapter{
+		webhookService: webhookService,
+	}
+}
+
+func webhookError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return forbidden(c, err)
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidWebhookUrl), errors.Is(err, domain.ErrUnknownEventType):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return internal(c, fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func (a *WebhooksAdapter) Create() fiber.Handler {
+	type request struct {
+		Url    string             `json:"url"`
+		Secret string             `json:"secret"`
+		Events []domain.EventType `json:"events"`
+	}
+
+	fn := "WebhooksAdapter.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		webhook, err := a.webhookService.Create(c.UserContext(), &dto.SaveWebhookDto{
+			Url:    req.Url,
+			Secret: req.Secret,
+			Events: req.Events,
+		})
+		if err != nil {
+			logger.Error("failed to create webhook", slog.String("err", err.Error()))
+			return webhookError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"webhook": webhook,
+		})
+	}
+}
+
+func (a *WebhooksAdapter) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		webhooks, err := a.webhookService.List(c.UserContext())
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"webhooks": webhooks,
+		})
+	}
+}
+
+func (a *WebhooksAdapter) Get() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		webhook, err := a.webhookService.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"webhook": webhook,
+		})
+	}
+}
+
+func (a *WebhooksAdapter) Update() fiber.Handler {
+	type request struct {
+		Url    *string            `json:"url"`
+		Events []domain.EventType `json:"events"`
+		Active *bool              `json:"active"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := new(request)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		webhook, err := a.webhookService.Update(c.UserContext(), c.Params("id"), &dto.UpdateWebhookDto{
+			Url:    req.Url,
+			Events: req.Events,
+			Active: req.Active,
+		})
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"webhook": webhook,
+		})
+	}
+}
+
+func (a *WebhooksAdapter) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := a.webhookService.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "webhook deleted",
+		})
+	}
+}
+
+func (a *WebhooksAdapter) Deliveries() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", 50)
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+
+		deliveries, err := a.webhookService.Deliveries(c.UserContext(), c.Params("id"), limit)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"deliveries": deliveries,
+		})
+	}
+}
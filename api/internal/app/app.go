@@ -1,8 +1,10 @@
 package app
 
 import (
+	"context"
 	"em-test/internal/adapters"
 	"em-test/internal/config"
+	"em-test/internal/services"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,36 +16,272 @@ type App struct {
 
 	uc *adapters.UsersAdapter
 	ac *adapters.ActivityAdapter
+	ec *adapters.EventsAdapter
+	wc *adapters.WebhooksAdapter
+	au *adapters.AuthAdapter
+	mc *adapters.MeAdapter
+	oc *adapters.OrganizationsAdapter
+	tc *adapters.TeamsAdapter
+	lc *adapters.AuditAdapter
+	sc *adapters.TimesheetsAdapter
+	hc *adapters.SchedulesAdapter
+	pc *adapters.PayRulesAdapter
+	vc *adapters.LeaveAdapter
+	cc *adapters.CalendarAdapter
+	jc *adapters.ProjectsAdapter
+	kc *adapters.TasksAdapter
+	ic *adapters.InvoicesAdapter
+	bc *adapters.BudgetsAdapter
+	gc *adapters.TagsAdapter
+	fc *adapters.FeedsAdapter
+	dc *adapters.ImportsAdapter
+	rc *adapters.BackupsAdapter
+
+	dispatcher *services.WebhookDispatcher
 }
 
-func New(cfg *config.Config, user *adapters.UsersAdapter, activity *adapters.ActivityAdapter) *App {
+func New(
+	cfg *config.Config,
+	user *adapters.UsersAdapter,
+	activity *adapters.ActivityAdapter,
+	events *adapters.EventsAdapter,
+	webhooks *adapters.WebhooksAdapter,
+	auth *adapters.AuthAdapter,
+	me *adapters.MeAdapter,
+	organizations *adapters.OrganizationsAdapter,
+	teams *adapters.TeamsAdapter,
+	audit *adapters.AuditAdapter,
+	timesheets *adapters.TimesheetsAdapter,
+	schedules *adapters.SchedulesAdapter,
+	payRules *adapters.PayRulesAdapter,
+	leave *adapters.LeaveAdapter,
+	calendar *adapters.CalendarAdapter,
+	projects *adapters.ProjectsAdapter,
+	tasks *adapters.TasksAdapter,
+	invoices *adapters.InvoicesAdapter,
+	budgets *adapters.BudgetsAdapter,
+	tags *adapters.TagsAdapter,
+	feeds *adapters.FeedsAdapter,
+	imports *adapters.ImportsAdapter,
+	backups *adapters.BackupsAdapter,
+	dispatcher *services.WebhookDispatcher,
+) *App {
 
 	http := fiber.New(fiber.Config{
 		CaseSensitive: false,
 	})
 
 	return &App{
-		cfg:  cfg,
-		http: http,
-		uc:   user,
-		ac:   activity,
+		cfg:        cfg,
+		http:       http,
+		uc:         user,
+		ac:         activity,
+		ec:         events,
+		wc:         webhooks,
+		au:         auth,
+		mc:         me,
+		oc:         organizations,
+		tc:         teams,
+		lc:         audit,
+		sc:         timesheets,
+		hc:         schedules,
+		pc:         payRules,
+		vc:         leave,
+		cc:         calendar,
+		jc:         projects,
+		kc:         tasks,
+		ic:         invoices,
+		bc:         budgets,
+		gc:         tags,
+		fc:         feeds,
+		dc:         imports,
+		rc:         backups,
+		dispatcher: dispatcher,
 	}
 }
 
 func (a *App) initRoutes() {
-	v1 := a.http.Group("/api/v1")
+	a.http.Use(adapters.RequestId())
+
+	// ahead of the v1 group so that its authentication does not apply, the
+	// token in the path is the credential of calendar apps
+	a.http.Get("/api/v1/feeds/:token.ics", a.fc.Calendar())
+
+	v1 := a.http.Group("/api/v1", a.au.Middleware())
+
+	organizations := v1.Group("/organizations")
+	organizations.Get("/", a.oc.List())
+	organizations.Post("/", a.oc.Create())
+
+	admin := v1.Group("/admin")
+	admin.Get("/export", a.rc.Export())
+	admin.Post("/import", a.rc.Import())
+	a.http.Server().HeaderReceived = adapters.RestoreBodyLimit("/api/v1/admin/import", a.cfg.Backups.MaxSizeMb<<20)
+
+	organization := v1.Group("/organization", a.au.RequireOrg())
+	organization.Get("/", a.oc.Current())
+	organization.Patch("/", a.oc.Update())
+
+	auth := v1.Group("/auth", a.au.RequireOrg())
+	auth.Post("/token", a.au.IssueToken())
+	auth.Get("/keys", a.au.ListApiKeys())
+	auth.Post("/keys", a.au.CreateApiKey())
+	auth.Delete("/keys/:id", a.au.RevokeApiKey())
 
-	users := v1.Group("/users")
+	me := v1.Group("/me", a.au.RequireOrg())
+	me.Get("/", a.mc.Profile())
+	me.Post("/activity/start", a.mc.Start())
+	me.Post("/activity/stop", a.mc.Stop())
+	me.Post("/activity/switch", a.mc.Switch())
+	me.Get("/activity/summary", a.mc.Summary())
+	me.Get("/feed", a.fc.Get())
+	me.Post("/feed", a.fc.Create())
+	me.Delete("/feed", a.fc.Delete())
+
+	users := v1.Group("/users", a.au.RequireOrg())
 	users.Get("/", a.uc.GetUsers())
 	users.Post("/", a.uc.AddUser())
+	users.Patch("/:id", a.uc.UpdateUser())
+	users.Delete("/:id", a.uc.DeleteUser())
 
-	activities := v1.Group("/activities")
+	activities := v1.Group("/activities", a.au.RequireOrg())
 	activities.Post("/", a.ac.Start())
 	activities.Patch("/", a.ac.Stop())
+	activities.Post("/switch", a.ac.Switch())
+	activities.Post("/manual", a.ac.AddManual())
+	activities.Post("/import", a.dc.Import())
+	activities.Put("/sessions/:id", a.ac.UpdateSession())
+	activities.Get("/report", a.ac.GetReport())
 	activities.Get("/:user_id", a.ac.GetSummary())
+	activities.Get("/:user_id/computed", a.pc.Computed())
+
+	teams := v1.Group("/teams", a.au.RequireOrg())
+	teams.Get("/", a.tc.List())
+	teams.Post("/", a.tc.Create())
+	teams.Get("/:id", a.tc.Get())
+	teams.Patch("/:id", a.tc.Update())
+	teams.Delete("/:id", a.tc.Delete())
+	teams.Get("/:id/members", a.tc.Members())
+	teams.Put("/:id/members/:user_id", a.tc.AddMember())
+	teams.Delete("/:id/members/:user_id", a.tc.RemoveMember())
+
+	timesheets := v1.Group("/timesheets", a.au.RequireOrg())
+	timesheets.Get("/", a.sc.List())
+	timesheets.Post("/", a.sc.Submit())
+	timesheets.Get("/:id", a.sc.Get())
+	timesheets.Post("/:id/approve", a.sc.Approve())
+	timesheets.Post("/:id/reject", a.sc.Reject())
+
+	schedules := v1.Group("/schedules", a.au.RequireOrg())
+	schedules.Get("/", a.hc.List())
+	schedules.Post("/", a.hc.Create())
+	schedules.Get("/effective", a.hc.Effective())
+	schedules.Get("/report", a.hc.Report())
+	schedules.Get("/:id", a.hc.Get())
+	schedules.Patch("/:id", a.hc.Update())
+	schedules.Delete("/:id", a.hc.Delete())
+	schedules.Put("/:id/users/:user_id", a.hc.AssignUser(true))
+	schedules.Delete("/:id/users/:user_id", a.hc.AssignUser(false))
+	schedules.Put("/:id/teams/:team_id", a.hc.AssignTeam(true))
+	schedules.Delete("/:id/teams/:team_id", a.hc.AssignTeam(false))
+
+	payRules := v1.Group("/pay-rules", a.au.RequireOrg())
+	payRules.Get("/", a.pc.List())
+	payRules.Put("/", a.pc.Save())
+	payRules.Delete("/", a.pc.Delete())
+	payRules.Get("/effective", a.pc.Effective())
+	payRules.Put("/teams/:team_id", a.pc.Save())
+	payRules.Delete("/teams/:team_id", a.pc.Delete())
+
+	leave := v1.Group("/leave", a.au.RequireOrg())
+	leave.Get("/types", a.vc.ListTypes())
+	leave.Post("/types", a.vc.CreateType())
+	leave.Patch("/types/:id", a.vc.UpdateType())
+	leave.Delete("/types/:id", a.vc.DeleteType())
+	leave.Get("/balances", a.vc.Balances())
+	leave.Put("/balances/:user_id/:leave_type_id", a.vc.SetAllowance())
+	leave.Get("/requests", a.vc.List())
+	leave.Post("/requests", a.vc.Request())
+	leave.Get("/requests/:id", a.vc.Get())
+	leave.Post("/requests/:id/approve", a.vc.Approve())
+	leave.Post("/requests/:id/reject", a.vc.Reject())
+	leave.Post("/requests/:id/cancel", a.vc.Cancel())
+
+	calendar := v1.Group("/calendar", a.au.RequireOrg())
+	calendar.Get("/days", a.cc.List())
+	calendar.Put("/days", a.cc.Save())
+	calendar.Delete("/days/:id", a.cc.Delete())
+	calendar.Post("/import", a.cc.Import())
+
+	clients := v1.Group("/clients", a.au.RequireOrg())
+	clients.Get("/", a.jc.Clients())
+	clients.Post("/", a.jc.CreateClient())
+	clients.Get("/:id", a.jc.GetClient())
+	clients.Patch("/:id", a.jc.UpdateClient())
+	clients.Delete("/:id", a.jc.DeleteClient())
+
+	projects := v1.Group("/projects", a.au.RequireOrg())
+	projects.Get("/", a.jc.List())
+	projects.Post("/", a.jc.Create())
+	projects.Get("/:id", a.jc.Get())
+	projects.Patch("/:id", a.jc.Update())
+	projects.Delete("/:id", a.jc.Delete())
+	projects.Get("/:id/rates", a.jc.Rates())
+	projects.Put("/:id/rates/:user_id", a.jc.SetRate())
+	projects.Delete("/:id/rates/:user_id", a.jc.DeleteRate())
+	projects.Get("/:id/summary", a.jc.Summary())
+	projects.Put("/:id/budget", a.bc.Save(false))
+	projects.Delete("/:id/budget", a.bc.Delete(false))
+	projects.Get("/:id/budget/burn", a.bc.Burn(false))
+
+	tasks := v1.Group("/tasks", a.au.RequireOrg())
+	tasks.Get("/", a.kc.List())
+	tasks.Post("/", a.kc.Create())
+	tasks.Get("/report", a.kc.EstimateReport())
+	tasks.Get("/:id", a.kc.Get())
+	tasks.Patch("/:id", a.kc.Update())
+	tasks.Delete("/:id", a.kc.Delete())
+	tasks.Get("/:id/time", a.kc.Time())
+	tasks.Put("/:id/budget", a.bc.Save(true))
+	tasks.Delete("/:id/budget", a.bc.Delete(true))
+	tasks.Get("/:id/budget/burn", a.bc.Burn(true))
+
+	v1.Get("/budgets", a.au.RequireOrg(), a.bc.List())
+
+	tags := v1.Group("/tags", a.au.RequireOrg())
+	tags.Get("/", a.gc.List())
+	tags.Post("/", a.gc.Create())
+	tags.Patch("/:id", a.gc.Update())
+	tags.Delete("/:id", a.gc.Delete())
+
+	invoices := v1.Group("/invoices", a.au.RequireOrg())
+	invoices.Get("/", a.ic.List())
+	invoices.Post("/", a.ic.Create())
+	invoices.Get("/:id", a.ic.Get())
+	invoices.Delete("/:id", a.ic.Delete())
+	invoices.Post("/:id/issue", a.ic.Issue())
+	invoices.Post("/:id/pay", a.ic.Pay())
+	invoices.Get("/:id/export", a.ic.Export())
+
+	v1.Get("/audit", a.au.RequireOrg(), a.lc.List())
+
+	v1.Get("/events", a.au.RequireOrg(), a.ec.Stream())
+
+	webhooks := v1.Group("/webhooks", a.au.RequireOrg())
+	webhooks.Get("/", a.wc.List())
+	webhooks.Post("/", a.wc.Create())
+	webhooks.Get("/:id", a.wc.Get())
+	webhooks.Patch("/:id", a.wc.Update())
+	webhooks.Delete("/:id", a.wc.Delete())
+	webhooks.Get("/:id/deliveries", a.wc.Deliveries())
 }
 
 func (a *App) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go a.dispatcher.Run(ctx)
+
 	a.initRoutes()
 	return a.http.Listen(fmt.Sprintf(":%d", a.cfg.App.Port))
 }
@@ -6,6 +6,7 @@ package app
 import (
 	"em-test/internal/adapters"
 	"em-test/internal/config"
+	"em-test/internal/lib/events"
 	"em-test/internal/repositories"
 	"em-test/internal/services"
 	"fmt"
@@ -22,23 +23,142 @@ func Init() (*App, func(), error) {
 		New,
 		wire.NewSet(config.New),
 		wire.NewSet(initDB),
+		wire.NewSet(events.NewBus),
 
 		wire.NewSet(repositories.NewUsersRepository),
 		wire.NewSet(repositories.NewActivityRepository),
 		wire.NewSet(repositories.NewPassportApi),
+		wire.NewSet(repositories.NewWebhookRepository),
+		wire.NewSet(repositories.NewApiKeyRepository),
+		wire.NewSet(repositories.NewOrganizationRepository),
+		wire.NewSet(repositories.NewTeamRepository),
+		wire.NewSet(repositories.NewAuditRepository),
+		wire.NewSet(repositories.NewTimesheetRepository),
+		wire.NewSet(repositories.NewScheduleRepository),
+		wire.NewSet(repositories.NewPayRulesRepository),
+		wire.NewSet(repositories.NewLeaveRepository),
+		wire.NewSet(repositories.NewCalendarRepository),
+		wire.NewSet(repositories.NewProjectRepository),
+		wire.NewSet(repositories.NewTaskRepository),
+		wire.NewSet(repositories.NewInvoiceRepository),
+		wire.NewSet(repositories.NewBudgetRepository),
+		wire.NewSet(repositories.NewTagRepository),
+		wire.NewSet(repositories.NewFeedRepository),
+		wire.NewSet(repositories.NewBackupRepository),
 
 		wire.Bind(new(services.UserRepository), new(*repositories.UsersRepository)),
 		wire.Bind(new(services.UserFinder), new(*repositories.PassportApi)),
 		wire.Bind(new(services.ActivityRepository), new(*repositories.ActivityRepository)),
+		wire.Bind(new(services.EventPublisher), new(*events.Bus)),
+		wire.Bind(new(services.WebhookRepository), new(*repositories.WebhookRepository)),
+		wire.Bind(new(services.WebhookOutbox), new(*repositories.WebhookRepository)),
+		wire.Bind(new(services.ApiKeyRepository), new(*repositories.ApiKeyRepository)),
+		wire.Bind(new(services.OrganizationRepository), new(*repositories.OrganizationRepository)),
+		wire.Bind(new(services.OrganizationReader), new(*repositories.OrganizationRepository)),
+		wire.Bind(new(services.TeamRepository), new(*repositories.TeamRepository)),
+		wire.Bind(new(services.AuditRepository), new(*repositories.AuditRepository)),
+		wire.Bind(new(services.TimesheetRepository), new(*repositories.TimesheetRepository)),
+		wire.Bind(new(services.PeriodLocks), new(*repositories.TimesheetRepository)),
+		wire.Bind(new(services.SessionReader), new(*repositories.ActivityRepository)),
+		wire.Bind(new(services.ScheduleRepository), new(*repositories.ScheduleRepository)),
+		wire.Bind(new(services.PayRulesRepository), new(*repositories.PayRulesRepository)),
+		wire.Bind(new(services.SummaryReader), new(*services.ActivityService)),
+		wire.Bind(new(services.LeaveRepository), new(*repositories.LeaveRepository)),
+		wire.Bind(new(services.LeaveReader), new(*repositories.LeaveRepository)),
+		wire.Bind(new(services.ScheduleResolver), new(*repositories.ScheduleRepository)),
+		wire.Bind(new(services.LeaveCredits), new(*services.LeaveService)),
+		wire.Bind(new(services.CalendarRepository), new(*repositories.CalendarRepository)),
+		wire.Bind(new(services.UserCalendars), new(*services.CalendarService)),
+		wire.Bind(new(services.RegionCalendars), new(*services.CalendarService)),
+		wire.Bind(new(services.ExpectedHours), new(*services.CalendarService)),
+		wire.Bind(new(services.ProjectRepository), new(*repositories.ProjectRepository)),
+		wire.Bind(new(services.ProjectReader), new(*repositories.ProjectRepository)),
+		wire.Bind(new(services.TaskRepository), new(*repositories.TaskRepository)),
+		wire.Bind(new(services.TaskReader), new(*repositories.TaskRepository)),
+		wire.Bind(new(services.InvoiceRepository), new(*repositories.InvoiceRepository)),
+		wire.Bind(new(services.ClientProjects), new(*repositories.ProjectRepository)),
+		wire.Bind(new(services.BudgetRepository), new(*repositories.BudgetRepository)),
+		wire.Bind(new(services.ProjectCosts), new(*repositories.ProjectRepository)),
+		wire.Bind(new(services.BudgetWatcher), new(*services.BudgetService)),
+		wire.Bind(new(services.TagRepository), new(*repositories.TagRepository)),
+		wire.Bind(new(services.FeedRepository), new(*repositories.FeedRepository)),
+		wire.Bind(new(services.UserLister), new(*repositories.UsersRepository)),
+		wire.Bind(new(services.ProjectLister), new(*repositories.ProjectRepository)),
+		wire.Bind(new(services.TaskLister), new(*repositories.TaskRepository)),
+		wire.Bind(new(services.SessionImporter), new(*repositories.ActivityRepository)),
+		wire.Bind(new(services.BackupRepository), new(*repositories.BackupRepository)),
+
+		wire.NewSet(services.NewPolicy),
+		wire.Bind(new(services.ReportsResolver), new(*repositories.UsersRepository)),
 
 		wire.NewSet(services.NewUserService),
 		wire.NewSet(services.NewActivityService),
+		wire.NewSet(services.NewWebhookService),
+		wire.NewSet(services.NewWebhookDispatcher),
+		wire.NewSet(services.NewAuthService),
+		wire.NewSet(services.NewOrganizationService),
+		wire.NewSet(services.NewTeamService),
+		wire.NewSet(services.NewAuditService),
+		wire.NewSet(services.NewTimesheetService),
+		wire.NewSet(services.NewScheduleService),
+		wire.NewSet(services.NewPayRulesService),
+		wire.NewSet(services.NewLeaveService),
+		wire.NewSet(services.NewCalendarService),
+		wire.NewSet(services.NewProjectService),
+		wire.NewSet(services.NewTaskService),
+		wire.NewSet(services.NewInvoiceService),
+		wire.NewSet(services.NewBudgetService),
+		wire.NewSet(services.NewTagService),
+		wire.NewSet(services.NewFeedService),
+		wire.NewSet(services.NewImportService),
+		wire.NewSet(services.NewBackupService),
 
 		wire.Bind(new(adapters.UsersService), new(*services.UsersService)),
 		wire.Bind(new(adapters.ActivityService), new(*services.ActivityService)),
+		wire.Bind(new(adapters.EventSubscriber), new(*events.Bus)),
+		wire.Bind(new(adapters.EventAuthorizer), new(*services.Policy)),
+		wire.Bind(new(adapters.WebhookService), new(*services.WebhookService)),
+		wire.Bind(new(adapters.AuthService), new(*services.AuthService)),
+		wire.Bind(new(adapters.OrganizationService), new(*services.OrganizationService)),
+		wire.Bind(new(adapters.TeamService), new(*services.TeamService)),
+		wire.Bind(new(adapters.AuditService), new(*services.AuditService)),
+		wire.Bind(new(adapters.TimesheetService), new(*services.TimesheetService)),
+		wire.Bind(new(adapters.ScheduleService), new(*services.ScheduleService)),
+		wire.Bind(new(adapters.PayRulesService), new(*services.PayRulesService)),
+		wire.Bind(new(adapters.LeaveService), new(*services.LeaveService)),
+		wire.Bind(new(adapters.CalendarService), new(*services.CalendarService)),
+		wire.Bind(new(adapters.ProjectService), new(*services.ProjectService)),
+		wire.Bind(new(adapters.TaskService), new(*services.TaskService)),
+		wire.Bind(new(adapters.InvoiceService), new(*services.InvoiceService)),
+		wire.Bind(new(adapters.BudgetService), new(*services.BudgetService)),
+		wire.Bind(new(adapters.TagService), new(*services.TagService)),
+		wire.Bind(new(adapters.FeedService), new(*services.FeedService)),
+		wire.Bind(new(adapters.ImportService), new(*services.ImportService)),
+		wire.Bind(new(adapters.BackupService), new(*services.BackupService)),
+		wire.Bind(new(adapters.EventTeamResolver), new(*services.TeamService)),
 
 		wire.NewSet(adapters.NewUsersAdapter),
 		wire.NewSet(adapters.NewActivityAdapter),
+		wire.NewSet(adapters.NewEventsAdapter),
+		wire.NewSet(adapters.NewWebhooksAdapter),
+		wire.NewSet(adapters.NewAuthAdapter),
+		wire.NewSet(adapters.NewMeAdapter),
+		wire.NewSet(adapters.NewOrganizationsAdapter),
+		wire.NewSet(adapters.NewTeamsAdapter),
+		wire.NewSet(adapters.NewAuditAdapter),
+		wire.NewSet(adapters.NewTimesheetsAdapter),
+		wire.NewSet(adapters.NewSchedulesAdapter),
+		wire.NewSet(adapters.NewPayRulesAdapter),
+		wire.NewSet(adapters.NewLeaveAdapter),
+		wire.NewSet(adapters.NewCalendarAdapter),
+		wire.NewSet(adapters.NewProjectsAdapter),
+		wire.NewSet(adapters.NewTasksAdapter),
+		wire.NewSet(adapters.NewInvoicesAdapter),
+		wire.NewSet(adapters.NewBudgetsAdapter),
+		wire.NewSet(adapters.NewTagsAdapter),
+		wire.NewSet(adapters.NewFeedsAdapter),
+		wire.NewSet(adapters.NewImportsAdapter),
+		wire.NewSet(adapters.NewBackupsAdapter),
 	))
 }
 
@@ -9,6 +9,7 @@ package app
 import (
 	"em-test/internal/adapters"
 	"em-test/internal/config"
+	"em-test/internal/lib/events"
 	"em-test/internal/repositories"
 	"em-test/internal/services"
 	"fmt"
@@ -30,12 +31,74 @@ func Init() (*App, func(), error) {
 	}
 	usersRepository := repositories.NewUsersRepository(db)
 	passportApi := repositories.NewPassportApi(configConfig)
-	usersService := services.NewUserService(usersRepository, passportApi)
+	bus := events.NewBus(configConfig)
+	policy := services.NewPolicy(usersRepository)
+	usersService := services.NewUserService(usersRepository, passportApi, bus, policy)
 	usersAdapter := adapters.NewUsersAdapter(usersService)
 	activityRepository := repositories.NewActivityRepository(db)
-	activityService := services.NewActivityService(activityRepository)
+	timesheetRepository := repositories.NewTimesheetRepository(db)
+	leaveRepository := repositories.NewLeaveRepository(db)
+	calendarRepository := repositories.NewCalendarRepository(db)
+	scheduleRepository := repositories.NewScheduleRepository(db)
+	calendarService := services.NewCalendarService(calendarRepository, scheduleRepository, policy)
+	leaveService := services.NewLeaveService(leaveRepository, calendarService, bus, policy)
+	taskRepository := repositories.NewTaskRepository(db)
+	budgetRepository := repositories.NewBudgetRepository(db)
+	projectRepository := repositories.NewProjectRepository(db)
+	budgetService := services.NewBudgetService(budgetRepository, projectRepository, taskRepository, bus, policy)
+	organizationRepository := repositories.NewOrganizationRepository(db)
+	activityService := services.NewActivityService(activityRepository, timesheetRepository, leaveService, calendarService, taskRepository, budgetService, organizationRepository, bus, policy)
 	activityAdapter := adapters.NewActivityAdapter(activityService)
-	app := New(configConfig, usersAdapter, activityAdapter)
+	teamRepository := repositories.NewTeamRepository(db)
+	teamService := services.NewTeamService(teamRepository, usersRepository, policy)
+	eventsAdapter := adapters.NewEventsAdapter(configConfig, bus, policy, teamService)
+	webhookRepository := repositories.NewWebhookRepository(db)
+	webhookService := services.NewWebhookService(webhookRepository, policy)
+	webhooksAdapter := adapters.NewWebhooksAdapter(webhookService)
+	apiKeyRepository := repositories.NewApiKeyRepository(db)
+	authService, err := services.NewAuthService(configConfig, apiKeyRepository, usersRepository, organizationRepository, policy)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	authAdapter := adapters.NewAuthAdapter(authService)
+	meAdapter := adapters.NewMeAdapter(usersService, activityService)
+	organizationService := services.NewOrganizationService(organizationRepository, policy)
+	organizationsAdapter := adapters.NewOrganizationsAdapter(organizationService)
+	teamsAdapter := adapters.NewTeamsAdapter(teamService)
+	auditRepository := repositories.NewAuditRepository(db)
+	auditService := services.NewAuditService(auditRepository, policy)
+	auditAdapter := adapters.NewAuditAdapter(auditService)
+	timesheetService := services.NewTimesheetService(timesheetRepository, activityRepository, bus, policy)
+	timesheetsAdapter := adapters.NewTimesheetsAdapter(timesheetService)
+	scheduleService := services.NewScheduleService(scheduleRepository, activityRepository, leaveRepository, calendarService, bus, policy)
+	schedulesAdapter := adapters.NewSchedulesAdapter(scheduleService)
+	payRulesRepository := repositories.NewPayRulesRepository(db)
+	payRulesService := services.NewPayRulesService(payRulesRepository, teamRepository, activityService, calendarService, policy)
+	payRulesAdapter := adapters.NewPayRulesAdapter(payRulesService)
+	leaveAdapter := adapters.NewLeaveAdapter(leaveService)
+	calendarAdapter := adapters.NewCalendarAdapter(calendarService)
+	projectService := services.NewProjectService(projectRepository, policy)
+	projectsAdapter := adapters.NewProjectsAdapter(projectService)
+	taskService := services.NewTaskService(taskRepository, projectRepository, policy)
+	tasksAdapter := adapters.NewTasksAdapter(taskService)
+	invoiceRepository := repositories.NewInvoiceRepository(db)
+	invoiceService := services.NewInvoiceService(invoiceRepository, projectRepository, bus, policy)
+	invoicesAdapter := adapters.NewInvoicesAdapter(invoiceService)
+	budgetsAdapter := adapters.NewBudgetsAdapter(budgetService)
+	tagRepository := repositories.NewTagRepository(db)
+	tagService := services.NewTagService(tagRepository, policy)
+	tagsAdapter := adapters.NewTagsAdapter(tagService)
+	feedRepository := repositories.NewFeedRepository(db)
+	feedService := services.NewFeedService(feedRepository, activityRepository, taskRepository, policy)
+	feedsAdapter := adapters.NewFeedsAdapter(feedService)
+	importService := services.NewImportService(usersRepository, projectRepository, taskRepository, activityRepository, timesheetRepository, organizationRepository, bus, policy)
+	importsAdapter := adapters.NewImportsAdapter(importService)
+	backupRepository := repositories.NewBackupRepository(db)
+	backupService := services.NewBackupService(backupRepository, policy)
+	backupsAdapter := adapters.NewBackupsAdapter(backupService)
+	webhookDispatcher := services.NewWebhookDispatcher(configConfig, webhookRepository)
+	app := New(configConfig, usersAdapter, activityAdapter, eventsAdapter, webhooksAdapter, authAdapter, meAdapter, organizationsAdapter, teamsAdapter, auditAdapter, timesheetsAdapter, schedulesAdapter, payRulesAdapter, leaveAdapter, calendarAdapter, projectsAdapter, tasksAdapter, invoicesAdapter, budgetsAdapter, tagsAdapter, feedsAdapter, importsAdapter, backupsAdapter, webhookDispatcher)
 	return app, func() {
 		cleanup()
 	}, nil
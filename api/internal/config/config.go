@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -25,6 +26,39 @@ type Config struct {
 	PassportApi struct {
 		Host string `env:"PASSPORT_API_HOST" env-required:"true"`
 	}
+
+	Events struct {
+		BufferSize        int           `env:"EVENTS_BUFFER_SIZE" env-default:"64"`
+		HeartbeatInterval time.Duration `env:"EVENTS_HEARTBEAT_INTERVAL" env-default:"15s"`
+	}
+
+	Auth struct {
+		// JwtSigningKeys is a comma separated list of "kid:secret" pairs.
+		// Tokens are signed with the first key and verified with any of them,
+		// so keys can be rotated by prepending a new one.
+		JwtSigningKeys []string      `env:"AUTH_JWT_SIGNING_KEYS" env-required:"true" env-separator:","`
+		JwtIssuer      string        `env:"AUTH_JWT_ISSUER" env-default:"time-tracker"`
+		AccessTokenTTL time.Duration `env:"AUTH_ACCESS_TOKEN_TTL" env-default:"15m"`
+		ApiKeyTTL      time.Duration `env:"AUTH_API_KEY_TTL" env-default:"0"`
+		// BootstrapKey is a static api key from the environment, used to
+		// create the first real api keys.
+		BootstrapKey string `env:"AUTH_BOOTSTRAP_KEY"`
+	}
+
+	Webhooks struct {
+		PollInterval time.Duration `env:"WEBHOOKS_POLL_INTERVAL" env-default:"2s"`
+		BatchSize    int           `env:"WEBHOOKS_BATCH_SIZE" env-default:"50"`
+		Timeout      time.Duration `env:"WEBHOOKS_TIMEOUT" env-default:"10s"`
+		MaxAttempts  int           `env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"10"`
+		BackoffBase  time.Duration `env:"WEBHOOKS_BACKOFF_BASE" env-default:"10s"`
+		BackoffMax   time.Duration `env:"WEBHOOKS_BACKOFF_MAX" env-default:"1h"`
+	}
+
+	Backups struct {
+		// MaxSizeMb bounds the body of a restore of a backup, other
+		// requests are held to 4 MB.
+		MaxSizeMb int `env:"BACKUPS_MAX_SIZE_MB" env-default:"256"`
+	}
 }
 
 func New() *Config {
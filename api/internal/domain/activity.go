@@ -1,23 +1,119 @@
 package domain
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 type ActivityRecord struct {
 	Id        int64      `json:"id" db:"id"`
 	User      User       `json:"user" db:"user"`
+	TaskId    *string    `json:"taskId,omitempty" db:"task_id"`
 	StartTime time.Time  `json:"startTime" db:"start_time"`
 	EndTime   *time.Time `json:"endTime,omitempty" db:"end_time"`
 }
 
 type Session struct {
+	Id        int64      `json:"id" db:"id"`
+	UserId    string     `json:"userId,omitempty" db:"user_id"`
+	TaskId    *string    `json:"taskId,omitempty" db:"task_id"`
 	StartTime time.Time  `json:"startTime" db:"start_time"`
 	EndTime   *time.Time `json:"endTime,omitempty" db:"end_time"`
+	// InvoiceId is set once the session is billed, it can no longer be
+	// edited then.
+	InvoiceId *string `json:"invoiceId,omitempty" db:"invoice_id"`
+	Note      *string `json:"note,omitempty" db:"note"`
+	// TagIds are the tags of the session, sorted.
+	TagIds []string `json:"tagIds,omitempty" db:"-"`
+}
+
+// OnTask reports whether the session is on taskId, a nil taskId meaning no
+// task.
+func (s *Session) OnTask(taskId *string) bool {
+	if s.TaskId == nil || taskId == nil {
+		return s.TaskId == nil && taskId == nil
+	}
+	return *s.TaskId == *taskId
+}
+
+// Timeline sums the time covered by finished sessions added in order of
+// start time, time of parallel sessions counting once.
+type Timeline struct {
+	total      time.Duration
+	start, end time.Time
+	started    bool
+}
+
+// Add adds a session, unfinished sessions are skipped.
+func (t *Timeline) Add(s *Session) {
+	if s.EndTime == nil {
+		return
+	}
+
+	if t.started && !s.StartTime.After(t.end) {
+		if s.EndTime.After(t.end) {
+			t.end = *s.EndTime
+		}
+		return
+	}
+
+	t.total += t.end.Sub(t.start)
+	t.start, t.end, t.started = s.StartTime, *s.EndTime, true
+}
+
+// Total returns the time covered so far.
+func (t *Timeline) Total() time.Duration {
+	return t.total + t.end.Sub(t.start)
+}
+
+// WallClock returns the time covered by the finished sessions, time of
+// parallel sessions counting once.
+func WallClock(sessions []*Session) time.Duration {
+	sorted := slices.Clone(sessions)
+	slices.SortFunc(sorted, func(a, b *Session) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+
+	var timeline Timeline
+	for _, s := range sorted {
+		timeline.Add(s)
+	}
+	return timeline.Total()
 }
 
 type ActivitySummary struct {
-	UserId      string        `json:"userId" db:"user_id"`
-	IsActiveNow bool          `json:"isActiveNow" db:"is_active_now"`
-	Sessions    []*Session    `json:"sessions"`
-	TotalTime   time.Duration `json:"totalTime" db:"total_time"`
-	TotalCount  int           `json:"totalCount" db:"total_count"`
+	UserId      string     `json:"userId" db:"user_id"`
+	IsActiveNow bool       `json:"isActiveNow" db:"is_active_now"`
+	Sessions    []*Session `json:"sessions"`
+	// TotalTime sums the finished sessions, WallClockTime counts the time
+	// of parallel sessions once.
+	TotalTime     time.Duration `json:"totalTime" db:"total_time"`
+	WallClockTime time.Duration `json:"wallClockTime" db:"-"`
+	TotalCount    int           `json:"totalCount" db:"total_count"`
+	// Tags totals the sessions per tag when grouping by tag.
+	Tags []*TagTotal `json:"tags,omitempty"`
+}
+
+type UserActivityTotal struct {
+	UserId     string        `json:"userId" db:"user_id"`
+	TotalTime  time.Duration `json:"totalTime" db:"total_time"`
+	TotalCount int           `json:"totalCount" db:"total_count"`
+	// CreditedTime is the time credited for approved leave.
+	CreditedTime time.Duration `json:"creditedTime" db:"-"`
+	// ExpectedTime is the working time expected by the schedule and
+	// calendar of the user, only known for periods with both bounds.
+	ExpectedTime *time.Duration `json:"expectedTime,omitempty" db:"-"`
+}
+
+// ActivityReport totals finished sessions per user over a period.
+type ActivityReport struct {
+	TeamId     *string              `json:"teamId,omitempty"`
+	Users      []*UserActivityTotal `json:"users"`
+	TotalTime  time.Duration        `json:"totalTime"`
+	TotalCount int                  `json:"totalCount"`
+	// CreditedTime is not part of TotalTime.
+	CreditedTime time.Duration  `json:"creditedTime"`
+	ExpectedTime *time.Duration `json:"expectedTime,omitempty"`
+	// Tags totals the sessions per tag when grouping by tag.
+	Tags []*TagTotal `json:"tags,omitempty"`
 }
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+)
+
+type AuditEntity string
+
+const (
+	AuditEntityUser      AuditEntity = "user"
+	AuditEntityActivity  AuditEntity = "activity"
+	AuditEntityTimesheet AuditEntity = "timesheet"
+	AuditEntityLeave     AuditEntity = "leave"
+	AuditEntityInvoice   AuditEntity = "invoice"
+)
+
+// ActorSystem is the actor kind of changes made without a principal, such
+// as background jobs.
+const ActorSystem PrincipalKind = "system"
+
+// AuditEntry records a single change of tenant data. Entries are never
+// updated or removed.
+type AuditEntry struct {
+	Id        int64           `json:"id" db:"id"`
+	OrgId     string          `json:"orgId" db:"org_id"`
+	ActorKind PrincipalKind   `json:"actorKind" db:"actor_kind"`
+	ActorId   string          `json:"actorId" db:"actor_id"`
+	Action    AuditAction     `json:"action" db:"action"`
+	Entity    AuditEntity     `json:"entity" db:"entity"`
+	EntityId  string          `json:"entityId" db:"entity_id"`
+	Before    json.RawMessage `json:"before" db:"before"`
+	After     json.RawMessage `json:"after" db:"after"`
+	RequestId *string         `json:"requestId,omitempty" db:"request_id"`
+	CreatedAt time.Time       `json:"createdAt" db:"created_at"`
+}
+
+type requestIdKey struct{}
+
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+func RequestIdFrom(ctx context.Context) (string, bool) {
+	requestId, ok := ctx.Value(requestIdKey{}).(string)
+	return requestId, ok && requestId != ""
+}
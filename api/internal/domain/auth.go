@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type PrincipalKind string
+
+const (
+	PrincipalApiKey PrincipalKind = "api_key"
+	PrincipalUser   PrincipalKind = "user"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Kind PrincipalKind `json:"kind"`
+	// Id is the api key id for api keys and the user id for users.
+	Id string `json:"id"`
+	// UserId is the user the caller acts as, empty for api keys that are
+	// not bound to a user.
+	UserId string `json:"userId,omitempty"`
+	Role   Role   `json:"role"`
+	// OrgId is the organization the caller belongs to, empty for platform
+	// keys that may act in any organization.
+	OrgId string `json:"orgId,omitempty"`
+}
+
+// IsPlatform reports whether the principal is not bound to an organization.
+func (p *Principal) IsPlatform() bool {
+	return p.OrgId == ""
+}
+
+func (p *Principal) Is(roles ...Role) bool {
+	for _, r := range roles {
+		if p.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
+type ApiKey struct {
+	Id         string     `json:"id" db:"id"`
+	OrgId      string     `json:"orgId" db:"org_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Hash       string     `json:"-" db:"hash"`
+	UserId     *string    `json:"userId,omitempty" db:"user_id"`
+	Role       Role       `json:"role" db:"role"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+}
+
+func (k *ApiKey) IsUsable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+type AccessToken struct {
+	Token     string    `json:"accessToken"`
+	TokenType string    `json:"tokenType"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
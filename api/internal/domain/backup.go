@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// BackupFormat is the version of the layout of backups, raised when it
+// changes incompatibly.
+const BackupFormat = 1
+
+type BackupRecordType string
+
+const (
+	BackupHeader BackupRecordType = "header"
+	BackupRow    BackupRecordType = "row"
+	BackupEnd    BackupRecordType = "end"
+)
+
+// BackupRecord is a line of a backup in JSON Lines. The header comes first
+// and tells the format and the schema version of the database, then comes
+// a line per row, then the end telling the number of rows so that a
+// truncated backup is noticed.
+type BackupRecord struct {
+	Type BackupRecordType `json:"type"`
+	// Format, SchemaVersion and CreatedAt are those of the header.
+	Format        int        `json:"format,omitempty"`
+	SchemaVersion uint       `json:"schemaVersion,omitempty"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	// Table and Data are those of rows, Data holding the columns.
+	Table string          `json:"table,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	// Rows is that of the end.
+	Rows *int `json:"rows,omitempty"`
+}
+
+// RestoreReport tells how many rows of each table a restore inserted.
+type RestoreReport struct {
+	SchemaVersion uint           `json:"schemaVersion"`
+	Tables        map[string]int `json:"tables"`
+	Rows          int            `json:"rows"`
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// DefaultBudgetThresholds are the percentages of a budget that raise an
+// event when first reached.
+var DefaultBudgetThresholds = []int{80, 100}
+
+// BurnWindow is the recent history the daily burn of a budget is averaged
+// over.
+const BurnWindow = 28 * 24 * time.Hour
+
+// Budget limits the time or money spent on a project or a task, whichever
+// of ProjectId and TaskId is set. Money is priced at the hourly rates of
+// the project whether or not it is billable.
+type Budget struct {
+	Id        string  `json:"id" db:"id"`
+	OrgId     string  `json:"orgId" db:"org_id"`
+	ProjectId *string `json:"projectId,omitempty" db:"project_id"`
+	TaskId    *string `json:"taskId,omitempty" db:"task_id"`
+	// TimeMinutes and Amount are the limits, nil leaves one out. Amount is
+	// in the minor unit of the currency of the project.
+	TimeMinutes *int   `json:"timeMinutes,omitempty" db:"time_minutes"`
+	Amount      *int64 `json:"amount,omitempty" db:"amount"`
+	// Thresholds are percentages of the budget, ascending.
+	Thresholds []int `json:"thresholds" db:"-"`
+	// NotifiedPercent is the highest threshold an event was raised for.
+	NotifiedPercent int       `json:"notifiedPercent" db:"notified_percent"`
+	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+func (b *Budget) Validate() error {
+	if b.TimeMinutes == nil && b.Amount == nil {
+		return fmt.Errorf("%w: a time or an amount is required", ErrInvalidBudget)
+	}
+	if b.TimeMinutes != nil && *b.TimeMinutes <= 0 {
+		return fmt.Errorf("%w: time must be positive", ErrInvalidBudget)
+	}
+	if b.Amount != nil && *b.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidBudget)
+	}
+
+	if b.Thresholds == nil {
+		b.Thresholds = slices.Clone(DefaultBudgetThresholds)
+	}
+	slices.Sort(b.Thresholds)
+	b.Thresholds = slices.Compact(b.Thresholds)
+	for _, t := range b.Thresholds {
+		if t <= 0 || t > 1000 {
+			return fmt.Errorf("%w: thresholds must be percentages from 1 to 1000", ErrInvalidBudget)
+		}
+	}
+
+	return nil
+}
+
+// BudgetBurn is how much of a budget is spent and when the rest runs out
+// at the pace of the burn window.
+type BudgetBurn struct {
+	Budget   *Budget `json:"budget"`
+	Currency string  `json:"currency"`
+
+	SpentTime       time.Duration  `json:"spentTime"`
+	SpentAmount     int64          `json:"spentAmount"`
+	RemainingTime   *time.Duration `json:"remainingTime,omitempty"`
+	RemainingAmount *int64         `json:"remainingAmount,omitempty"`
+	// Percent is the larger spent share of the time and amount budgets.
+	Percent float64 `json:"percent"`
+
+	// DailyTime and DailyAmount average the burn window.
+	DailyTime   time.Duration `json:"dailyTime"`
+	DailyAmount int64         `json:"dailyAmount"`
+	Exhausted   bool          `json:"exhausted"`
+	// ForecastExhaustion is when the first of the budgets runs out, nil
+	// when it already has or nothing was spent lately.
+	ForecastExhaustion *time.Time `json:"forecastExhaustion,omitempty"`
+}
+
+// Burn compares what was spent in total and over the burn window before
+// now with the budget.
+func (b *Budget) Burn(spentTime time.Duration, spentAmount int64, windowTime time.Duration, windowAmount int64, now time.Time) *BudgetBurn {
+	burn := &BudgetBurn{
+		Budget:      b,
+		SpentTime:   spentTime,
+		SpentAmount: spentAmount,
+		DailyTime:   windowTime / time.Duration(BurnWindow/(24*time.Hour)),
+		DailyAmount: windowAmount / int64(BurnWindow/(24*time.Hour)),
+	}
+
+	var forecasts []time.Time
+
+	if b.TimeMinutes != nil {
+		limit := time.Duration(*b.TimeMinutes) * time.Minute
+		remaining := limit - spentTime
+		burn.RemainingTime = &remaining
+		burn.Percent = max(burn.Percent, 100*float64(spentTime)/float64(limit))
+
+		if remaining <= 0 {
+			burn.Exhausted = true
+		} else if burn.DailyTime > 0 {
+			days := float64(remaining) / float64(burn.DailyTime)
+			forecasts = append(forecasts, now.Add(time.Duration(days*float64(24*time.Hour))))
+		}
+	}
+
+	if b.Amount != nil {
+		remaining := *b.Amount - spentAmount
+		burn.RemainingAmount = &remaining
+		burn.Percent = max(burn.Percent, 100*float64(spentAmount)/float64(*b.Amount))
+
+		if remaining <= 0 {
+			burn.Exhausted = true
+		} else if windowAmount > 0 {
+			days := float64(remaining) * float64(BurnWindow/(24*time.Hour)) / float64(windowAmount)
+			forecasts = append(forecasts, now.Add(time.Duration(days*float64(24*time.Hour))))
+		}
+	}
+
+	if !burn.Exhausted && len(forecasts) > 0 {
+		first := slices.MinFunc(forecasts, func(a, b time.Time) int { return a.Compare(b) })
+		burn.ForecastExhaustion = &first
+	}
+
+	return burn
+}
+
+// Crossed returns the highest threshold of the budget the burn reached, 0
+// when none.
+func (b *BudgetBurn) Crossed() int {
+	crossed := 0
+	for _, t := range b.Budget.Thresholds {
+		if b.Percent >= float64(t) {
+			crossed = t
+		}
+	}
+	return crossed
+}
+
+// BudgetAlert is the data of an event raised when a budget reaches one of
+// its thresholds.
+type BudgetAlert struct {
+	Threshold int         `json:"threshold"`
+	Burn      *BudgetBurn `json:"burn"`
+}
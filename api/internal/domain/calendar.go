@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type CalendarDayKind string
+
+const (
+	CalendarHoliday CalendarDayKind = "holiday"
+	// CalendarWorkingDay is a day off that is worked instead of another
+	// one, such as a saturday a bridge day is transferred to.
+	CalendarWorkingDay CalendarDayKind = "working_day"
+)
+
+// DefaultWorkingDay is the expected working time of a day for users
+// without a schedule, who are expected to work monday to friday.
+const DefaultWorkingDay = 8 * time.Hour
+
+// CalendarDay overrides the working week on one date, for the whole
+// organization or for the schedules of a region.
+type CalendarDay struct {
+	Id    string `json:"id" db:"id"`
+	OrgId string `json:"orgId" db:"org_id"`
+	// Region is empty for days of the whole organization.
+	Region string          `json:"region" db:"region"`
+	Date   time.Time       `json:"date" db:"date"`
+	Name   string          `json:"name" db:"name"`
+	Kind   CalendarDayKind `json:"kind" db:"kind"`
+	// AsWeekday is the ISO weekday whose schedule a working day follows,
+	// monday when nil.
+	AsWeekday *int      `json:"asWeekday,omitempty" db:"as_weekday"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+func (d *CalendarDay) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCalendarDay)
+	}
+
+	switch d.Kind {
+	case CalendarHoliday:
+		if d.AsWeekday != nil {
+			return fmt.Errorf("%w: only working days follow another weekday", ErrInvalidCalendarDay)
+		}
+	case CalendarWorkingDay:
+		if d.AsWeekday != nil && (*d.AsWeekday < 1 || *d.AsWeekday > 7) {
+			return fmt.Errorf("%w: weekday must be between 1 and 7", ErrInvalidCalendarDay)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind", ErrInvalidCalendarDay)
+	}
+
+	return nil
+}
+
+// Calendar holds the calendar days that apply to a user by date, a nil
+// Calendar has none.
+type Calendar map[string]*CalendarDay
+
+// NewCalendar indexes days by date. Days of a region take precedence over
+// organization wide days on the same date.
+func NewCalendar(days []*CalendarDay) Calendar {
+	c := make(Calendar, len(days))
+	for _, day := range days {
+		date := day.Date.Format("2006-01-02")
+		if existing, ok := c[date]; ok && existing.Region != "" {
+			continue
+		}
+		c[date] = day
+	}
+	return c
+}
+
+// On returns the calendar day of the calendar date of date, nil when the
+// working week applies as usual.
+func (c Calendar) On(date time.Time) *CalendarDay {
+	return c[date.Format("2006-01-02")]
+}
+
+// WorkDay returns the day of schedule that is worked on date, nil when
+// date is a day off or a holiday.
+func (c Calendar) WorkDay(schedule *Schedule, date time.Time) *ScheduleDay {
+	day := c.On(date)
+	if day == nil {
+		return schedule.Day(date.Weekday())
+	}
+
+	if day.Kind == CalendarHoliday {
+		return nil
+	}
+
+	weekday := 1
+	if day.AsWeekday != nil {
+		weekday = *day.AsWeekday
+	}
+	return schedule.Day(time.Weekday(weekday % 7))
+}
+
+// Expected returns the expected working time on date. Users without a
+// schedule work monday to friday and on transferred working days.
+func (c Calendar) Expected(schedule *Schedule, date time.Time) time.Duration {
+	if schedule != nil {
+		day := c.WorkDay(schedule, date)
+		if day == nil {
+			return 0
+		}
+		return day.Duration()
+	}
+
+	if day := c.On(date); day != nil {
+		if day.Kind == CalendarHoliday {
+			return 0
+		}
+		return DefaultWorkingDay
+	}
+
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return 0
+	}
+	return DefaultWorkingDay
+}
+
+// ExpectedTime totals the expected working time of the days from from to
+// to, both inclusive.
+func (c Calendar) ExpectedTime(schedule *Schedule, from, to time.Time) time.Duration {
+	var expected time.Duration
+	for date := DayOf(from); !date.After(DayOf(to)); date = date.AddDate(0, 0, 1) {
+		expected += c.Expected(schedule, date)
+	}
+	return expected
+}
@@ -3,9 +3,83 @@ package domain
 import "errors"
 
 var (
-	ErrNotImplemented     = errors.New("not implemented")
-	ErrUserAlreadyExists  = errors.New("user already exists")
-	ErrUserNotFound       = errors.New("user not found")
-	ErrUserAlreadyWorking = errors.New("user already working")
-	ErrUserNotWorking     = errors.New("user not working")
+	ErrNotImplemented      = errors.New("not implemented")
+	ErrUserAlreadyExists   = errors.New("user already exists")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserAlreadyWorking  = errors.New("user already working")
+	ErrUserNotWorking      = errors.New("user not working")
+	ErrAlreadyOnTask       = errors.New("user already working on this task")
+	ErrSessionAmbiguous    = errors.New("several sessions are running, choose a session or a task")
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrInvalidWebhookUrl   = errors.New("invalid webhook url")
+	ErrUnknownEventType    = errors.New("unknown event type")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrApiKeyNotFound      = errors.New("api key not found")
+	ErrForbidden           = errors.New("forbidden")
+	ErrInvalidRole         = errors.New("invalid role")
+	ErrInvalidManager      = errors.New("user cannot manage themselves")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidInterval     = errors.New("end time must be after start time")
+	ErrSessionOverlaps     = errors.New("session overlaps another session")
+	ErrOrgRequired         = errors.New("organization is required, set X-Organization-Id")
+	ErrOrgNotFound         = errors.New("organization not found")
+	ErrInvalidOrgName      = errors.New("organization name is required")
+	ErrInvalidTimerPolicy  = errors.New("timer policy must be single or multiple")
+	ErrTeamNotFound        = errors.New("team not found")
+	ErrInvalidTeamName     = errors.New("team name is required")
+	ErrInvalidParentTeam   = errors.New("team cannot be nested under itself or its descendants")
+	ErrMemberNotFound      = errors.New("user is not a member of the team")
+	ErrTimesheetNotFound   = errors.New("timesheet not found")
+	ErrInvalidWeekStart    = errors.New("week start must be a monday")
+	ErrWeekNotOver         = errors.New("the week is not over yet")
+	ErrInvalidTransition   = errors.New("timesheet cannot move to this status")
+	ErrCommentRequired     = errors.New("a comment is required to reject a timesheet")
+	ErrPeriodLocked        = errors.New("period is locked by an approved timesheet")
+	ErrSessionRunning      = errors.New("a session of the week is still running")
+	ErrScheduleNotFound    = errors.New("schedule not found")
+	ErrInvalidSchedule     = errors.New("invalid schedule")
+	ErrNoSchedule          = errors.New("no schedule is assigned to the user or their teams")
+	ErrScheduleNotAssigned = errors.New("schedule is not assigned")
+	ErrInvalidPeriod       = errors.New("invalid report period")
+	ErrInvalidPayRules     = errors.New("invalid pay rules")
+	ErrPayRulesNotFound    = errors.New("pay rules not found")
+	ErrLeaveTypeNotFound   = errors.New("leave type not found")
+	ErrInvalidLeaveType    = errors.New("invalid leave type")
+	ErrLeaveTypeInUse      = errors.New("leave type is in use")
+	ErrLeaveNotFound       = errors.New("leave request not found")
+	ErrInvalidLeave        = errors.New("invalid leave request")
+	ErrLeaveOverlap        = errors.New("leave request overlaps another request")
+	ErrInsufficientBalance = errors.New("insufficient leave balance")
+	ErrCalendarDayNotFound = errors.New("calendar day not found")
+	ErrInvalidCalendarDay  = errors.New("invalid calendar day")
+	ErrInvalidCalendarFile = errors.New("invalid calendar file")
+	ErrClientNotFound      = errors.New("client not found")
+	ErrInvalidClient       = errors.New("invalid client")
+	ErrClientInUse         = errors.New("client has projects")
+	ErrProjectNotFound     = errors.New("project not found")
+	ErrInvalidProject      = errors.New("invalid project")
+	ErrProjectInUse        = errors.New("project has tasks")
+	ErrTaskNotFound        = errors.New("task not found")
+	ErrInvalidTask         = errors.New("invalid task")
+	ErrTaskInUse           = errors.New("task has tracked time")
+	ErrTaskHasSubtasks     = errors.New("task has subtasks")
+	ErrInvalidParentTask   = errors.New("task cannot be nested under itself or its descendants")
+	ErrInvoiceNotFound     = errors.New("invoice not found")
+	ErrInvalidInvoice      = errors.New("invalid invoice")
+	ErrNothingToInvoice    = errors.New("no billable time to invoice")
+	ErrInvoiceNotDraft     = errors.New("invoice is no longer a draft")
+	ErrInvoiceTransition   = errors.New("invoice cannot move to this status")
+	ErrSessionInvoiced     = errors.New("session is invoiced")
+	ErrUnknownFormat       = errors.New("unknown export format")
+	ErrBudgetNotFound      = errors.New("budget not found")
+	ErrInvalidBudget       = errors.New("invalid budget")
+	ErrTagNotFound         = errors.New("tag not found")
+	ErrInvalidTag          = errors.New("invalid tag")
+	ErrInvalidNote         = errors.New("invalid note")
+	ErrFeedNotFound        = errors.New("feed not found")
+	ErrInvalidImportFile   = errors.New("invalid import file")
+	ErrInvalidBackup       = errors.New("invalid backup")
+	ErrSchemaMismatch      = errors.New("schema version mismatch")
+	ErrDatabaseNotEmpty    = errors.New("database is not empty")
 )
@@ -0,0 +1,68 @@
+package domain
+
+import "time"
+
+type EventType string
+
+const (
+	EventActivityStarted EventType = "activity.started"
+	EventActivityStopped EventType = "activity.stopped"
+	EventActivityCreated EventType = "activity.created"
+	EventActivityUpdated EventType = "activity.updated"
+	// EventActivityImported is raised once per import, not per session.
+	EventActivityImported EventType = "activity.imported"
+	EventUserCreated      EventType = "user.created"
+	EventUserUpdated      EventType = "user.updated"
+	EventUserDeleted      EventType = "user.deleted"
+
+	EventTimesheetSubmitted EventType = "timesheet.submitted"
+	EventTimesheetApproved  EventType = "timesheet.approved"
+	EventTimesheetRejected  EventType = "timesheet.rejected"
+	EventLeaveRequested     EventType = "leave.requested"
+	EventLeaveApproved      EventType = "leave.approved"
+	EventLeaveRejected      EventType = "leave.rejected"
+	EventLeaveCancelled     EventType = "leave.cancelled"
+	EventInvoiceCreated     EventType = "invoice.created"
+	EventInvoiceIssued      EventType = "invoice.issued"
+	EventInvoicePaid        EventType = "invoice.paid"
+	EventBudgetThreshold    EventType = "budget.threshold_crossed"
+)
+
+var EventTypes = []EventType{
+	EventActivityStarted,
+	EventActivityStopped,
+	EventActivityCreated,
+	EventActivityUpdated,
+	EventActivityImported,
+	EventUserCreated,
+	EventUserUpdated,
+	EventUserDeleted,
+	EventTimesheetSubmitted,
+	EventTimesheetApproved,
+	EventTimesheetRejected,
+	EventLeaveRequested,
+	EventLeaveApproved,
+	EventLeaveRejected,
+	EventLeaveCancelled,
+	EventInvoiceCreated,
+	EventInvoiceIssued,
+	EventInvoicePaid,
+	EventBudgetThreshold,
+}
+
+func (t EventType) IsKnown() bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	Type       EventType `json:"type"`
+	OrgId      string    `json:"orgId"`
+	UserId     string    `json:"userId"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Data       any       `json:"data,omitempty"`
+}
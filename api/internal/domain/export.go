@@ -0,0 +1,12 @@
+package domain
+
+import "io"
+
+// ExportStream is a document written for download as it is produced, for
+// exports too large to render into an ExportFile.
+type ExportStream struct {
+	Name        string
+	ContentType string
+	// Write writes the document, it is called once.
+	Write func(w io.Writer) error
+}
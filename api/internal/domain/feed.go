@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// Feed publishes the sessions of a user as an iCalendar feed that calendar
+// apps subscribe to. The token in its url is the only credential, the
+// database keeps its hash.
+type Feed struct {
+	Id         string     `json:"id" db:"id"`
+	OrgId      string     `json:"orgId" db:"org_id"`
+	UserId     string     `json:"userId" db:"user_id"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Hash       string     `json:"-" db:"hash"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" db:"last_used_at"`
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ImportSource is the tracker an imported file of time entries was
+// exported from.
+type ImportSource string
+
+const (
+	ImportToggl    ImportSource = "toggl"
+	ImportClockify ImportSource = "clockify"
+)
+
+func (s ImportSource) Validate() error {
+	switch s {
+	case ImportToggl, ImportClockify:
+		return nil
+	}
+	return fmt.Errorf("%w: source must be toggl or clockify", ErrInvalidImportFile)
+}
+
+// ImportRejection is a row of an import file that was not imported.
+type ImportRejection struct {
+	// Row is the line of the row in the file, the header being line 1.
+	Row    int    `json:"row"`
+	User   string `json:"user,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportedTask is a task an import created, or would create on a dry run,
+// for entries on a task missing from its project.
+type ImportedTask struct {
+	Id        string `json:"id,omitempty"`
+	ProjectId string `json:"projectId"`
+	Title     string `json:"title"`
+}
+
+// ImportReport tells what an import of time entries did, or would do when
+// it is a dry run.
+type ImportReport struct {
+	Source   ImportSource `json:"source"`
+	DryRun   bool         `json:"dryRun"`
+	Rows     int          `json:"rows"`
+	Imported int          `json:"imported"`
+	// TotalTime sums the imported entries.
+	TotalTime time.Duration      `json:"totalTime"`
+	Tasks     []*ImportedTask    `json:"tasks"`
+	Rejected  []*ImportRejection `json:"rejected"`
+}
+
+// SessionImport is the data of the event raised by an import.
+type SessionImport struct {
+	Sessions int      `json:"sessions"`
+	UserIds  []string `json:"userIds"`
+}
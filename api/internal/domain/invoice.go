@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceDraft  InvoiceStatus = "draft"
+	InvoiceIssued InvoiceStatus = "issued"
+	InvoicePaid   InvoiceStatus = "paid"
+)
+
+// CanTransition reports whether an invoice may move from s to next. Drafts
+// are issued, issued invoices are paid and paid ones are final.
+func (s InvoiceStatus) CanTransition(next InvoiceStatus) bool {
+	switch s {
+	case InvoiceDraft:
+		return next == InvoiceIssued
+	case InvoiceIssued:
+		return next == InvoicePaid
+	}
+	return false
+}
+
+// InvoiceGrouping decides what the line items of an invoice stand for.
+type InvoiceGrouping string
+
+const (
+	InvoiceByTask InvoiceGrouping = "task"
+	InvoiceByUser InvoiceGrouping = "user"
+)
+
+func (g InvoiceGrouping) Validate() error {
+	switch g {
+	case InvoiceByTask, InvoiceByUser:
+		return nil
+	}
+	return fmt.Errorf("%w: group by must be task or user", ErrInvalidInvoice)
+}
+
+// Invoice bills a client for the finished sessions on the tasks of its
+// billable projects over a period. The sessions of an invoice cannot be
+// edited until the invoice is deleted, which only drafts can be.
+type Invoice struct {
+	Id          string          `json:"id" db:"id"`
+	OrgId       string          `json:"orgId" db:"org_id"`
+	ClientId    string          `json:"clientId" db:"client_id"`
+	Status      InvoiceStatus   `json:"status" db:"status"`
+	GroupBy     InvoiceGrouping `json:"groupBy" db:"group_by"`
+	Currency    string          `json:"currency" db:"currency"`
+	PeriodStart time.Time       `json:"periodStart" db:"period_start"`
+	PeriodEnd   time.Time       `json:"periodEnd" db:"period_end"`
+	TotalTime   time.Duration   `json:"totalTime" db:"total_time"`
+	// Amount is in the minor unit of Currency.
+	Amount    int64      `json:"amount" db:"amount"`
+	CreatedBy string     `json:"createdBy" db:"created_by"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	IssuedAt  *time.Time `json:"issuedAt,omitempty" db:"issued_at"`
+	PaidAt    *time.Time `json:"paidAt,omitempty" db:"paid_at"`
+
+	Lines []*InvoiceLine `json:"lines,omitempty" db:"-"`
+}
+
+// InvoiceLine is the time of a task, or of a user on a project, billed at
+// one hourly rate.
+type InvoiceLine struct {
+	InvoiceId    string        `json:"-" db:"invoice_id"`
+	Position     int           `json:"position" db:"position"`
+	ProjectId    string        `json:"projectId" db:"project_id"`
+	TaskId       *string       `json:"taskId,omitempty" db:"task_id"`
+	UserId       *string       `json:"userId,omitempty" db:"user_id"`
+	Description  string        `json:"description" db:"description"`
+	Duration     time.Duration `json:"duration" db:"duration"`
+	SessionCount int           `json:"sessionCount" db:"session_count"`
+	HourlyRate   int64         `json:"hourlyRate" db:"hourly_rate"`
+	Amount       int64         `json:"amount" db:"amount"`
+}
+
+// BillableItem is the time a user tracked on a task at their hourly rate
+// on its project.
+type BillableItem struct {
+	ProjectId   string        `db:"project_id"`
+	ProjectName string        `db:"project_name"`
+	TaskId      string        `db:"task_id"`
+	TaskTitle   string        `db:"task_title"`
+	UserId      string        `db:"user_id"`
+	UserName    string        `db:"user_name"`
+	HourlyRate  int64         `db:"hourly_rate"`
+	Duration    time.Duration `db:"-"`
+	Count       int           `db:"total_count"`
+}
+
+// Bill replaces the lines of the invoice with items grouped by its
+// grouping and totals them. Lines keep the order items are first seen in.
+// Each line is rounded on its own, so the amount is the sum of the lines.
+func (i *Invoice) Bill(items []*BillableItem) {
+	i.Lines = make([]*InvoiceLine, 0)
+	i.TotalTime, i.Amount = 0, 0
+
+	index := make(map[string]*InvoiceLine)
+	for _, item := range items {
+		var key, description string
+		line := &InvoiceLine{
+			InvoiceId:  i.Id,
+			ProjectId:  item.ProjectId,
+			HourlyRate: item.HourlyRate,
+		}
+
+		switch i.GroupBy {
+		case InvoiceByUser:
+			key = item.ProjectId + "/" + item.UserId
+			description = item.ProjectName + ": " + item.UserName
+			userId := item.UserId
+			line.UserId = &userId
+		default:
+			key = item.TaskId
+			description = item.ProjectName + ": " + item.TaskTitle
+			taskId := item.TaskId
+			line.TaskId = &taskId
+		}
+		key += "/" + strconv.FormatInt(item.HourlyRate, 10)
+
+		if existing, ok := index[key]; ok {
+			line = existing
+		} else {
+			line.Position = len(i.Lines) + 1
+			line.Description = description
+			index[key] = line
+			i.Lines = append(i.Lines, line)
+		}
+
+		line.Duration += item.Duration
+		line.SessionCount += item.Count
+	}
+
+	for _, line := range i.Lines {
+		line.Amount = Amount(line.HourlyRate, line.Duration)
+		i.TotalTime += line.Duration
+		i.Amount += line.Amount
+	}
+}
+
+// currencyExponents lists the ISO 4217 currencies whose minor unit is not
+// a hundredth.
+var currencyExponents = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3,
+	"PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// FormatAmount writes an amount in the minor unit of currency as a decimal
+// number of its major unit, such as 1234 USD as 12.34.
+func FormatAmount(amount int64, currency string) string {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		exponent = 2
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// ExportFile is a document rendered for download.
+type ExportFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
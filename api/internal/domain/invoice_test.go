@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvoiceStatusCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to InvoiceStatus
+		want     bool
+	}{
+		{InvoiceDraft, InvoiceIssued, true},
+		{InvoiceDraft, InvoicePaid, false},
+		{InvoiceDraft, InvoiceDraft, false},
+		{InvoiceIssued, InvoicePaid, true},
+		{InvoiceIssued, InvoiceDraft, false},
+		{InvoicePaid, InvoiceIssued, false},
+		{InvoicePaid, InvoiceDraft, false},
+		{InvoicePaid, InvoicePaid, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%q.CanTransition(%q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{1234, "USD", "12.34"},
+		{5, "EUR", "0.05"},
+		{50, "EUR", "0.50"},
+		{0, "EUR", "0.00"},
+		{100000, "EUR", "1000.00"},
+		{-1234, "USD", "-12.34"},
+		{-5, "USD", "-0.05"},
+		{1234, "JPY", "1234"},
+		{-1234, "KRW", "-1234"},
+		{1234, "KWD", "1.234"},
+		{7, "BHD", "0.007"},
+		{1234, "", "12.34"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatAmount(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("FormatAmount(%d, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestInvoiceBill(t *testing.T) {
+	items := []*BillableItem{
+		{ProjectId: "p1", ProjectName: "Site", TaskId: "t1", TaskTitle: "Design", UserId: "u1", UserName: "Ann", HourlyRate: 6000, Duration: time.Hour, Count: 2},
+		{ProjectId: "p1", ProjectName: "Site", TaskId: "t1", TaskTitle: "Design", UserId: "u2", UserName: "Bob", HourlyRate: 6000, Duration: 30 * time.Minute, Count: 1},
+		{ProjectId: "p1", ProjectName: "Site", TaskId: "t2", TaskTitle: "Build", UserId: "u1", UserName: "Ann", HourlyRate: 6000, Duration: 20 * time.Minute, Count: 1},
+		{ProjectId: "p1", ProjectName: "Site", TaskId: "t1", TaskTitle: "Design", UserId: "u3", UserName: "Cid", HourlyRate: 9000, Duration: 10 * time.Minute, Count: 1},
+	}
+
+	type line struct {
+		description string
+		duration    time.Duration
+		sessions    int
+		amount      int64
+	}
+
+	tests := []struct {
+		groupBy InvoiceGrouping
+		want    []line
+		amount  int64
+	}{
+		{InvoiceByTask, []line{
+			{"Site: Design", 90 * time.Minute, 3, 9000},
+			{"Site: Build", 20 * time.Minute, 1, 2000},
+			{"Site: Design", 10 * time.Minute, 1, 1500},
+		}, 12500},
+		{InvoiceByUser, []line{
+			{"Site: Ann", 80 * time.Minute, 3, 8000},
+			{"Site: Bob", 30 * time.Minute, 1, 3000},
+			{"Site: Cid", 10 * time.Minute, 1, 1500},
+		}, 12500},
+	}
+
+	for _, tt := range tests {
+		invoice := &Invoice{Id: "i1", GroupBy: tt.groupBy, Amount: 1, TotalTime: time.Hour}
+		invoice.Bill(items)
+
+		if len(invoice.Lines) != len(tt.want) {
+			t.Fatalf("%s: Bill() made %d lines, want %d", tt.groupBy, len(invoice.Lines), len(tt.want))
+		}
+		for i, want := range tt.want {
+			got := invoice.Lines[i]
+			if got.Position != i+1 || got.Description != want.description || got.Duration != want.duration ||
+				got.SessionCount != want.sessions || got.Amount != want.amount {
+				t.Errorf("%s: line %d = %+v, want %+v", tt.groupBy, i+1, *got, want)
+			}
+		}
+		if invoice.Amount != tt.amount || invoice.TotalTime != 2*time.Hour {
+			t.Errorf("%s: Bill() totals %d over %v, want %d over 2h", tt.groupBy, invoice.Amount, invoice.TotalTime, tt.amount)
+		}
+	}
+}
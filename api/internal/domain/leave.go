@@ -0,0 +1,78 @@
+package domain
+
+import "time"
+
+type LeaveStatus string
+
+const (
+	LeavePending   LeaveStatus = "pending"
+	LeaveApproved  LeaveStatus = "approved"
+	LeaveRejected  LeaveStatus = "rejected"
+	LeaveCancelled LeaveStatus = "cancelled"
+)
+
+// CanTransition reports whether a leave request may move from s to next.
+// Pending requests are reviewed or withdrawn, approved ones may still be
+// cancelled.
+func (s LeaveStatus) CanTransition(next LeaveStatus) bool {
+	switch s {
+	case LeavePending:
+		return next == LeaveApproved || next == LeaveRejected || next == LeaveCancelled
+	case LeaveApproved:
+		return next == LeaveCancelled
+	}
+	return false
+}
+
+// LeaveType is a kind of leave such as vacation, sick leave or a business
+// trip.
+type LeaveType struct {
+	Id    string `json:"id" db:"id"`
+	OrgId string `json:"orgId" db:"org_id"`
+	Name  string `json:"name" db:"name"`
+	Paid  bool   `json:"paid" db:"paid"`
+	// RequiresBalance limits requests to the yearly allowance of the user.
+	RequiresBalance bool      `json:"requiresBalance" db:"requires_balance"`
+	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
+}
+
+// LeaveBalance is the allowance of a user for a leave type in a year and
+// how much of it is taken. Days are working days.
+type LeaveBalance struct {
+	LeaveTypeId     string `json:"leaveTypeId" db:"leave_type_id"`
+	LeaveType       string `json:"leaveType" db:"leave_type"`
+	RequiresBalance bool   `json:"requiresBalance" db:"requires_balance"`
+	Year            int    `json:"year" db:"year"`
+	AllowanceDays   int    `json:"allowanceDays" db:"allowance_days"`
+	UsedDays        int    `json:"usedDays" db:"used_days"`
+	PendingDays     int    `json:"pendingDays" db:"pending_days"`
+	RemainingDays   int    `json:"remainingDays" db:"-"`
+}
+
+// LeaveRequest asks for leave from StartDate to EndDate, both inclusive.
+type LeaveRequest struct {
+	Id          string      `json:"id" db:"id"`
+	OrgId       string      `json:"orgId" db:"org_id"`
+	UserId      string      `json:"userId" db:"user_id"`
+	LeaveTypeId string      `json:"leaveTypeId" db:"leave_type_id"`
+	StartDate   time.Time   `json:"startDate" db:"start_date"`
+	EndDate     time.Time   `json:"endDate" db:"end_date"`
+	Days        int         `json:"days" db:"days"`
+	Status      LeaveStatus `json:"status" db:"status"`
+	Reason      *string     `json:"reason,omitempty" db:"reason"`
+	Comment     *string     `json:"comment,omitempty" db:"comment"`
+	CreatedAt   time.Time   `json:"createdAt" db:"created_at"`
+	ReviewedBy  *string     `json:"reviewedBy,omitempty" db:"reviewed_by"`
+	ReviewedAt  *time.Time  `json:"reviewedAt,omitempty" db:"reviewed_at"`
+}
+
+// Covers reports whether the leave includes the calendar day date.
+func (r *LeaveRequest) Covers(date string) bool {
+	return date >= r.StartDate.Format("2006-01-02") && date <= r.EndDate.Format("2006-01-02")
+}
+
+// DayOf returns midnight UTC of the calendar day of t, the form leave
+// dates are stored in.
+func DayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// TimerPolicy tells how many sessions a user of an organization may run at
+// once.
+type TimerPolicy string
+
+const (
+	// TimerSingle allows one running session per user.
+	TimerSingle TimerPolicy = "single"
+	// TimerMultiple allows parallel sessions per user, on distinct tasks.
+	TimerMultiple TimerPolicy = "multiple"
+)
+
+func (p TimerPolicy) Validate() error {
+	switch p {
+	case TimerSingle, TimerMultiple:
+		return nil
+	}
+	return ErrInvalidTimerPolicy
+}
+
+type Organization struct {
+	Id          string      `json:"id" db:"id"`
+	Name        string      `json:"name" db:"name"`
+	TimerPolicy TimerPolicy `json:"timerPolicy" db:"timer_policy"`
+	CreatedAt   time.Time   `json:"createdAt" db:"created_at"`
+}
+
+type orgKey struct{}
+
+// WithOrg scopes ctx to an organization. Repositories refuse to touch
+// tenant data without one.
+func WithOrg(ctx context.Context, orgId string) context.Context {
+	return context.WithValue(ctx, orgKey{}, orgId)
+}
+
+func OrgFrom(ctx context.Context) (string, bool) {
+	orgId, ok := ctx.Value(orgKey{}).(string)
+	return orgId, ok && orgId != ""
+}
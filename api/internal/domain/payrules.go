@@ -0,0 +1,239 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+type RoundingMode string
+
+const (
+	RoundingNone    RoundingMode = "none"
+	RoundingNearest RoundingMode = "nearest"
+	RoundingUp      RoundingMode = "up"
+	RoundingDown    RoundingMode = "down"
+)
+
+// RoundingSteps are the allowed rounding increments in minutes.
+var RoundingSteps = []int{5, 15, 30}
+
+// PayRules turn tracked sessions into payable time. Rules of a team apply
+// to its members and descendant teams, the rules without a team to the
+// rest of the organization.
+type PayRules struct {
+	Id     string  `json:"id,omitempty" db:"id"`
+	OrgId  string  `json:"orgId,omitempty" db:"org_id"`
+	TeamId *string `json:"teamId,omitempty" db:"team_id"`
+
+	RoundingMode    RoundingMode `json:"roundingMode" db:"rounding_mode"`
+	RoundingMinutes int          `json:"roundingMinutes" db:"rounding_minutes"`
+	// MinSessionMinutes drops shorter sessions from the computed totals.
+	MinSessionMinutes int `json:"minSessionMinutes" db:"min_session_minutes"`
+
+	// DailyOvertimeMinutes and WeeklyOvertimeMinutes are the thresholds
+	// above which time counts as overtime, nil disables them.
+	DailyOvertimeMinutes  *int    `json:"dailyOvertimeMinutes,omitempty" db:"daily_overtime_minutes"`
+	DailyMultiplier       float64 `json:"dailyMultiplier" db:"daily_multiplier"`
+	WeeklyOvertimeMinutes *int    `json:"weeklyOvertimeMinutes,omitempty" db:"weekly_overtime_minutes"`
+	WeeklyMultiplier      float64 `json:"weeklyMultiplier" db:"weekly_multiplier"`
+
+	// Timezone decides which day and week a session belongs to.
+	Timezone  string    `json:"timezone" db:"timezone"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty" db:"updated_at"`
+}
+
+// DefaultPayRules pay tracked time as is.
+func DefaultPayRules() *PayRules {
+	return &PayRules{
+		RoundingMode:     RoundingNone,
+		DailyMultiplier:  1,
+		WeeklyMultiplier: 1,
+		Timezone:         "UTC",
+	}
+}
+
+func (r *PayRules) Validate() error {
+	switch r.RoundingMode {
+	case RoundingNone:
+		r.RoundingMinutes = 0
+	case RoundingNearest, RoundingUp, RoundingDown:
+		if !slices.Contains(RoundingSteps, r.RoundingMinutes) {
+			return fmt.Errorf("%w: rounding minutes must be one of %v", ErrInvalidPayRules, RoundingSteps)
+		}
+	default:
+		return fmt.Errorf("%w: unknown rounding mode", ErrInvalidPayRules)
+	}
+
+	if r.MinSessionMinutes < 0 {
+		return fmt.Errorf("%w: minimum session length must not be negative", ErrInvalidPayRules)
+	}
+
+	if r.DailyOvertimeMinutes != nil && (*r.DailyOvertimeMinutes <= 0 || *r.DailyOvertimeMinutes > 24*60) {
+		return fmt.Errorf("%w: daily overtime threshold must be within a day", ErrInvalidPayRules)
+	}
+
+	if r.WeeklyOvertimeMinutes != nil && (*r.WeeklyOvertimeMinutes <= 0 || *r.WeeklyOvertimeMinutes > 7*24*60) {
+		return fmt.Errorf("%w: weekly overtime threshold must be within a week", ErrInvalidPayRules)
+	}
+
+	if r.DailyMultiplier < 1 || r.DailyMultiplier >= 100 || r.WeeklyMultiplier < 1 || r.WeeklyMultiplier >= 100 {
+		return fmt.Errorf("%w: multipliers must be between 1 and 100", ErrInvalidPayRules)
+	}
+
+	if r.Timezone == "Local" {
+		return fmt.Errorf("%w: unknown timezone", ErrInvalidPayRules)
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone", ErrInvalidPayRules)
+	}
+
+	return nil
+}
+
+// Round rounds a session duration to the rounding step.
+func (r *PayRules) Round(d time.Duration) time.Duration {
+	step := time.Duration(r.RoundingMinutes) * time.Minute
+	if step <= 0 {
+		return d
+	}
+
+	switch r.RoundingMode {
+	case RoundingNearest:
+		return d.Round(step)
+	case RoundingUp:
+		if rounded := d.Truncate(step); rounded != d {
+			return rounded + step
+		}
+		return d
+	case RoundingDown:
+		return d.Truncate(step)
+	}
+	return d
+}
+
+// ComputedDay is the payable time of one day.
+type ComputedDay struct {
+	Date          string        `json:"date"`
+	Time          time.Duration `json:"time"`
+	DailyOvertime time.Duration `json:"dailyOvertime"`
+	Holiday       bool          `json:"holiday"`
+}
+
+// ComputedTotals is the payable time of a set of sessions under PayRules.
+// Time beyond the daily threshold counts as daily overtime, the remaining
+// time beyond the weekly threshold as weekly overtime, so no time is
+// counted as overtime twice. All time worked on holidays is daily
+// overtime.
+type ComputedTotals struct {
+	TotalTime  time.Duration `json:"totalTime"`
+	TotalCount int           `json:"totalCount"`
+	// DroppedCount is the number of sessions shorter than the minimum.
+	DroppedCount   int           `json:"droppedCount"`
+	RegularTime    time.Duration `json:"regularTime"`
+	DailyOvertime  time.Duration `json:"dailyOvertime"`
+	WeeklyOvertime time.Duration `json:"weeklyOvertime"`
+	// HolidayTime is the time worked on holidays, part of DailyOvertime.
+	HolidayTime time.Duration `json:"holidayTime"`
+	// PayableTime is regular time plus overtime weighted by its
+	// multiplier.
+	PayableTime time.Duration  `json:"payableTime"`
+	Days        []*ComputedDay `json:"days"`
+}
+
+// Compute applies the rules to the finished sessions. A session belongs
+// to the day it started on in the time zone of the rules, the holidays of
+// calendar are looked up by that day.
+func (r *PayRules) Compute(sessions []*Session, calendar Calendar) (*ComputedTotals, error) {
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	minimum := time.Duration(r.MinSessionMinutes) * time.Minute
+	totals := &ComputedTotals{Days: make([]*ComputedDay, 0)}
+	days := make(map[string]*ComputedDay)
+
+	for _, session := range sessions {
+		if session.EndTime == nil {
+			continue
+		}
+
+		raw := session.EndTime.Sub(session.StartTime)
+		if raw < minimum {
+			totals.DroppedCount++
+			continue
+		}
+
+		date := session.StartTime.In(loc).Format("2006-01-02")
+		day, ok := days[date]
+		if !ok {
+			day = &ComputedDay{Date: date}
+			days[date] = day
+			totals.Days = append(totals.Days, day)
+		}
+
+		day.Time += r.Round(raw)
+		totals.TotalCount++
+	}
+
+	slices.SortFunc(totals.Days, func(a, b *ComputedDay) int {
+		if a.Date < b.Date {
+			return -1
+		}
+		if a.Date > b.Date {
+			return 1
+		}
+		return 0
+	})
+
+	// regular time of each ISO week, what is left after daily overtime
+	weeks := make(map[string]time.Duration)
+	for _, day := range totals.Days {
+		totals.TotalTime += day.Time
+
+		date, _ := time.ParseInLocation("2006-01-02", day.Date, loc)
+
+		if holiday := calendar.On(date); holiday != nil && holiday.Kind == CalendarHoliday {
+			day.Holiday = true
+			day.DailyOvertime = day.Time
+			totals.DailyOvertime += day.Time
+			totals.HolidayTime += day.Time
+		} else if r.DailyOvertimeMinutes != nil {
+			if over := day.Time - time.Duration(*r.DailyOvertimeMinutes)*time.Minute; over > 0 {
+				day.DailyOvertime = over
+				totals.DailyOvertime += over
+			}
+		}
+
+		year, week := date.ISOWeek()
+		weeks[fmt.Sprintf("%d-%d", year, week)] += day.Time - day.DailyOvertime
+	}
+
+	if r.WeeklyOvertimeMinutes != nil {
+		threshold := time.Duration(*r.WeeklyOvertimeMinutes) * time.Minute
+		for _, regular := range weeks {
+			if over := regular - threshold; over > 0 {
+				totals.WeeklyOvertime += over
+			}
+		}
+	}
+
+	totals.RegularTime = totals.TotalTime - totals.DailyOvertime - totals.WeeklyOvertime
+	totals.PayableTime = totals.RegularTime +
+		time.Duration(float64(totals.DailyOvertime)*r.DailyMultiplier) +
+		time.Duration(float64(totals.WeeklyOvertime)*r.WeeklyMultiplier)
+
+	return totals, nil
+}
+
+// ComputedSummary puts the computed totals next to the raw summary they
+// were computed from.
+type ComputedSummary struct {
+	Raw      *ActivitySummary `json:"raw"`
+	Rules    *PayRules        `json:"rules"`
+	Computed *ComputedTotals  `json:"computed"`
+	// ExpectedTime is the working time expected by the schedule and
+	// calendar of the user, only known for periods with both bounds.
+	ExpectedTime *time.Duration `json:"expectedTime,omitempty"`
+}
@@ -0,0 +1,263 @@
+package domain
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestPayRulesRound(t *testing.T) {
+	tests := []struct {
+		mode    RoundingMode
+		minutes int
+		in      time.Duration
+		want    time.Duration
+	}{
+		{RoundingNone, 0, 7 * time.Minute, 7 * time.Minute},
+		{RoundingNearest, 15, 7 * time.Minute, 0},
+		{RoundingNearest, 15, 8 * time.Minute, 15 * time.Minute},
+		{RoundingNearest, 5, 62 * time.Minute, 60 * time.Minute},
+		{RoundingUp, 15, 15 * time.Minute, 15 * time.Minute},
+		{RoundingUp, 15, 15*time.Minute + time.Second, 30 * time.Minute},
+		{RoundingDown, 30, 59 * time.Minute, 30 * time.Minute},
+		{RoundingDown, 30, 29 * time.Minute, 0},
+	}
+
+	for _, tt := range tests {
+		rules := &PayRules{RoundingMode: tt.mode, RoundingMinutes: tt.minutes}
+		if got := rules.Round(tt.in); got != tt.want {
+			t.Errorf("%s %d: Round(%v) = %v, want %v", tt.mode, tt.minutes, tt.in, got, tt.want)
+		}
+	}
+}
+
+// workedAt is a session of d starting at start, an RFC 3339 time.
+func workedAt(t *testing.T, start string, d time.Duration) *Session {
+	t.Helper()
+
+	s, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := s.Add(d)
+	return &Session{StartTime: s, EndTime: &end}
+}
+
+// workWeek is a session of d at nine in the morning UTC on each of days
+// consecutive days from monday, 4 March 2024.
+func workWeek(t *testing.T, days int, d time.Duration) []*Session {
+	t.Helper()
+
+	sessions := make([]*Session, 0, days)
+	for i := range days {
+		start := time.Date(2024, 3, 4+i, 9, 0, 0, 0, time.UTC)
+		sessions = append(sessions, workedAt(t, start.Format(time.RFC3339), d))
+	}
+	return sessions
+}
+
+func TestPayRulesCompute(t *testing.T) {
+	minutes := func(m int) *int { return &m }
+	holiday := NewCalendar([]*CalendarDay{{
+		Date: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC),
+		Name: "Holiday",
+		Kind: CalendarHoliday,
+	}})
+	running := &Session{StartTime: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name     string
+		rules    func(r *PayRules)
+		sessions []*Session
+		calendar Calendar
+		want     ComputedTotals
+		dates    []string
+	}{
+		{
+			name: "as tracked",
+			sessions: []*Session{
+				workedAt(t, "2024-03-04T09:00:00Z", 90*time.Minute),
+				workedAt(t, "2024-03-04T13:00:00Z", 30*time.Minute),
+				running,
+			},
+			want: ComputedTotals{
+				TotalTime:   2 * time.Hour,
+				TotalCount:  2,
+				RegularTime: 2 * time.Hour,
+				PayableTime: 2 * time.Hour,
+			},
+			dates: []string{"2024-03-04"},
+		},
+		{
+			name: "short sessions dropped",
+			rules: func(r *PayRules) {
+				r.MinSessionMinutes = 10
+			},
+			sessions: []*Session{
+				workedAt(t, "2024-03-04T09:00:00Z", 5*time.Minute),
+				workedAt(t, "2024-03-05T09:00:00Z", 10*time.Minute),
+			},
+			want: ComputedTotals{
+				TotalTime:    10 * time.Minute,
+				TotalCount:   1,
+				DroppedCount: 1,
+				RegularTime:  10 * time.Minute,
+				PayableTime:  10 * time.Minute,
+			},
+			dates: []string{"2024-03-05"},
+		},
+		{
+			name: "sessions rounded one by one",
+			rules: func(r *PayRules) {
+				r.RoundingMode, r.RoundingMinutes = RoundingUp, 15
+			},
+			sessions: []*Session{
+				workedAt(t, "2024-03-04T09:00:00Z", 20*time.Minute),
+				workedAt(t, "2024-03-04T10:00:00Z", 20*time.Minute),
+			},
+			want: ComputedTotals{
+				TotalTime:   time.Hour,
+				TotalCount:  2,
+				RegularTime: time.Hour,
+				PayableTime: time.Hour,
+			},
+			dates: []string{"2024-03-04"},
+		},
+		{
+			name: "daily overtime",
+			rules: func(r *PayRules) {
+				r.DailyOvertimeMinutes, r.DailyMultiplier = minutes(8*60), 1.5
+			},
+			sessions: []*Session{
+				workedAt(t, "2024-03-04T08:00:00Z", 10*time.Hour),
+				workedAt(t, "2024-03-05T08:00:00Z", 6*time.Hour),
+			},
+			want: ComputedTotals{
+				TotalTime:     16 * time.Hour,
+				TotalCount:    2,
+				RegularTime:   14 * time.Hour,
+				DailyOvertime: 2 * time.Hour,
+				PayableTime:   17 * time.Hour,
+			},
+			dates: []string{"2024-03-04", "2024-03-05"},
+		},
+		{
+			name: "daily overtime is not weekly overtime",
+			rules: func(r *PayRules) {
+				r.DailyOvertimeMinutes, r.DailyMultiplier = minutes(8*60), 1.5
+				r.WeeklyOvertimeMinutes, r.WeeklyMultiplier = minutes(40*60), 2
+			},
+			sessions: workWeek(t, 5, 9*time.Hour),
+			want: ComputedTotals{
+				TotalTime:     45 * time.Hour,
+				TotalCount:    5,
+				RegularTime:   40 * time.Hour,
+				DailyOvertime: 5 * time.Hour,
+				PayableTime:   47*time.Hour + 30*time.Minute,
+			},
+			dates: []string{"2024-03-04", "2024-03-05", "2024-03-06", "2024-03-07", "2024-03-08"},
+		},
+		{
+			name: "weekly overtime",
+			rules: func(r *PayRules) {
+				r.DailyOvertimeMinutes, r.DailyMultiplier = minutes(8*60), 1.5
+				r.WeeklyOvertimeMinutes, r.WeeklyMultiplier = minutes(40*60), 2
+			},
+			sessions: workWeek(t, 6, 8*time.Hour),
+			want: ComputedTotals{
+				TotalTime:      48 * time.Hour,
+				TotalCount:     6,
+				RegularTime:    40 * time.Hour,
+				WeeklyOvertime: 8 * time.Hour,
+				PayableTime:    56 * time.Hour,
+			},
+			dates: []string{"2024-03-04", "2024-03-05", "2024-03-06", "2024-03-07", "2024-03-08", "2024-03-09"},
+		},
+		{
+			name: "weeks apart",
+			rules: func(r *PayRules) {
+				r.WeeklyOvertimeMinutes, r.WeeklyMultiplier = minutes(10*60), 2
+			},
+			sessions: []*Session{
+				workedAt(t, "2024-03-10T08:00:00Z", 8*time.Hour),
+				workedAt(t, "2024-03-11T08:00:00Z", 8*time.Hour),
+			},
+			want: ComputedTotals{
+				TotalTime:   16 * time.Hour,
+				TotalCount:  2,
+				RegularTime: 16 * time.Hour,
+				PayableTime: 16 * time.Hour,
+			},
+			dates: []string{"2024-03-10", "2024-03-11"},
+		},
+		{
+			name: "day of the time zone",
+			rules: func(r *PayRules) {
+				r.Timezone = "Europe/Berlin"
+			},
+			sessions: []*Session{
+				workedAt(t, "2024-03-04T23:30:00Z", time.Hour),
+				workedAt(t, "2024-03-04T22:30:00Z", 30*time.Minute),
+			},
+			want: ComputedTotals{
+				TotalTime:   90 * time.Minute,
+				TotalCount:  2,
+				RegularTime: 90 * time.Minute,
+				PayableTime: 90 * time.Minute,
+			},
+			dates: []string{"2024-03-04", "2024-03-05"},
+		},
+		{
+			name: "holiday",
+			rules: func(r *PayRules) {
+				r.DailyOvertimeMinutes, r.DailyMultiplier = minutes(8*60), 2
+			},
+			sessions: []*Session{
+				workedAt(t, "2024-03-07T09:00:00Z", 4*time.Hour),
+				workedAt(t, "2024-03-08T09:00:00Z", 4*time.Hour),
+			},
+			calendar: holiday,
+			want: ComputedTotals{
+				TotalTime:     8 * time.Hour,
+				TotalCount:    2,
+				RegularTime:   4 * time.Hour,
+				DailyOvertime: 4 * time.Hour,
+				HolidayTime:   4 * time.Hour,
+				PayableTime:   12 * time.Hour,
+			},
+			dates: []string{"2024-03-07", "2024-03-08"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := DefaultPayRules()
+			if tt.rules != nil {
+				tt.rules(rules)
+			}
+			if err := rules.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			got, err := rules.Compute(tt.sessions, tt.calendar)
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+
+			dates := make([]string, 0, len(got.Days))
+			for _, day := range got.Days {
+				dates = append(dates, day.Date)
+			}
+			if !slices.Equal(dates, tt.dates) {
+				t.Fatalf("Compute() days = %v, want %v", dates, tt.dates)
+			}
+
+			got.Days = nil
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Compute() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
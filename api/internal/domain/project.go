@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultCurrency is the currency of projects created without one.
+const DefaultCurrency = "USD"
+
+// Client is a customer the time of projects is billed to.
+type Client struct {
+	Id        string    `json:"id" db:"id"`
+	OrgId     string    `json:"orgId" db:"org_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Project groups tasks, optionally for a client. Time tracked on the tasks
+// of a billable project is billed at the hourly rate of the user on the
+// project, or else the rate of the project.
+type Project struct {
+	Id       string  `json:"id" db:"id"`
+	OrgId    string  `json:"orgId" db:"org_id"`
+	ClientId *string `json:"clientId,omitempty" db:"client_id"`
+	Name     string  `json:"name" db:"name"`
+	Billable bool    `json:"billable" db:"billable"`
+	// HourlyRate is in the minor unit of Currency, such as cents.
+	HourlyRate int64 `json:"hourlyRate" db:"hourly_rate"`
+	// Currency is an ISO 4217 code.
+	Currency  string    `json:"currency" db:"currency"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// ValidateCurrency checks currency looks like an ISO 4217 code.
+func ValidateCurrency(currency string) error {
+	if len(currency) != 3 {
+		return fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidProject)
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidProject)
+		}
+	}
+	return nil
+}
+
+// ProjectRate overrides the hourly rate of a project for one user.
+type ProjectRate struct {
+	ProjectId  string `json:"projectId" db:"project_id"`
+	UserId     string `json:"userId" db:"user_id"`
+	HourlyRate int64  `json:"hourlyRate" db:"hourly_rate"`
+}
+
+// Amount returns the price of d at an hourly rate, rounded to the minor
+// unit.
+func Amount(hourlyRate int64, d time.Duration) int64 {
+	seconds := int64(d / time.Second)
+	return (hourlyRate*seconds + 1800) / 3600
+}
+
+// ProjectUserTotal is the time a user tracked on a project and what it
+// is billed.
+type ProjectUserTotal struct {
+	UserId     string        `json:"userId" db:"user_id"`
+	TotalTime  time.Duration `json:"totalTime" db:"-"`
+	TotalCount int           `json:"totalCount" db:"total_count"`
+	HourlyRate int64         `json:"hourlyRate" db:"-"`
+	Amount     int64         `json:"amount" db:"-"`
+}
+
+// ProjectSummary totals the finished sessions on the tasks of a project
+// over a period. Amounts are in the minor unit of Currency and zero for
+// projects that are not billable.
+type ProjectSummary struct {
+	ProjectId    string              `json:"projectId"`
+	Billable     bool                `json:"billable"`
+	Currency     string              `json:"currency"`
+	Users        []*ProjectUserTotal `json:"users"`
+	TotalTime    time.Duration       `json:"totalTime"`
+	BillableTime time.Duration       `json:"billableTime"`
+	TotalCount   int                 `json:"totalCount"`
+	Amount       int64               `json:"amount"`
+}
+
+// Bill prices the totals of project at the rates of its users, rates
+// overriding the rate of the project.
+func (p *Project) Bill(totals []*ProjectUserTotal, rates []*ProjectRate) *ProjectSummary {
+	summary := &ProjectSummary{
+		ProjectId: p.Id,
+		Billable:  p.Billable,
+		Currency:  p.Currency,
+		Users:     totals,
+	}
+
+	overrides := make(map[string]int64, len(rates))
+	for _, rate := range rates {
+		overrides[rate.UserId] = rate.HourlyRate
+	}
+
+	for _, t := range totals {
+		summary.TotalTime += t.TotalTime
+		summary.TotalCount += t.TotalCount
+
+		if !p.Billable {
+			continue
+		}
+
+		t.HourlyRate = p.HourlyRate
+		if rate, ok := overrides[t.UserId]; ok {
+			t.HourlyRate = rate
+		}
+		t.Amount = Amount(t.HourlyRate, t.TotalTime)
+
+		summary.BillableTime += t.TotalTime
+		summary.Amount += t.Amount
+	}
+
+	return summary
+}
+
+// Cost prices the totals of project at the rates of its users like Bill,
+// whether or not the project is billable.
+func (p *Project) Cost(totals []*ProjectUserTotal, rates []*ProjectRate) int64 {
+	billable := *p
+	billable.Billable = true
+	return billable.Bill(totals, rates).Amount
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAmount(t *testing.T) {
+	tests := []struct {
+		rate int64
+		d    time.Duration
+		want int64
+	}{
+		{6000, time.Hour, 6000},
+		{6000, 90 * time.Minute, 9000},
+		{6000, 0, 0},
+		{0, time.Hour, 0},
+		{3600, time.Second, 1},
+		{1800, time.Second, 1},
+		{1799, time.Second, 0},
+		{3600, 1500 * time.Millisecond, 1},
+		{12345, 8*time.Hour + 20*time.Minute, 102875},
+	}
+
+	for _, tt := range tests {
+		if got := Amount(tt.rate, tt.d); got != tt.want {
+			t.Errorf("Amount(%d, %v) = %d, want %d", tt.rate, tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package domain
+
+type Role string
+
+const (
+	// RoleEmployee tracks and views only their own time.
+	RoleEmployee Role = "employee"
+	// RoleManager additionally views and corrects the time of their reports.
+	RoleManager Role = "manager"
+	// RoleAdmin manages users, keys and integrations and sees passport data.
+	RoleAdmin Role = "admin"
+	// RoleKiosk is an api key only role: it may clock any user in and out
+	// but cannot read their data.
+	RoleKiosk Role = "kiosk"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleEmployee, RoleManager, RoleAdmin, RoleKiosk:
+		return true
+	}
+	return false
+}
+
+// IsUserRole reports whether r can be assigned to a user.
+func (r Role) IsUserRole() bool {
+	return r.IsValid() && r != RoleKiosk
+}
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// clockLayout is the format of the times of day of a schedule.
+const clockLayout = "15:04"
+
+// ScheduleDay is the expected working time of one day of the week.
+type ScheduleDay struct {
+	// Weekday is the ISO day of the week, 1 is monday and 7 is sunday.
+	Weekday      int    `json:"weekday"`
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	BreakMinutes int    `json:"breakMinutes"`
+}
+
+// Bounds returns the expected start and end of the day on date in loc.
+func (d *ScheduleDay) Bounds(date time.Time, loc *time.Location) (start, end time.Time, err error) {
+	s, err := time.Parse(clockLayout, d.Start)
+	if err != nil {
+		return start, end, err
+	}
+	e, err := time.Parse(clockLayout, d.End)
+	if err != nil {
+		return start, end, err
+	}
+
+	y, m, day := date.Date()
+	start = time.Date(y, m, day, s.Hour(), s.Minute(), 0, 0, loc)
+	end = time.Date(y, m, day, e.Hour(), e.Minute(), 0, 0, loc)
+	return start, end, nil
+}
+
+// Duration is the expected working time of the day, without the break.
+func (d *ScheduleDay) Duration() time.Duration {
+	start, end, err := d.Bounds(time.Time{}, time.UTC)
+	if err != nil {
+		return 0
+	}
+	return end.Sub(start) - time.Duration(d.BreakMinutes)*time.Minute
+}
+
+// Validate checks the day is a well formed, positive stretch of work.
+func (d *ScheduleDay) Validate() error {
+	if d.Weekday < 1 || d.Weekday > 7 {
+		return fmt.Errorf("%w: weekday must be between 1 and 7", ErrInvalidSchedule)
+	}
+
+	s, err := time.Parse(clockLayout, d.Start)
+	if err != nil {
+		return fmt.Errorf("%w: start must be HH:MM", ErrInvalidSchedule)
+	}
+	e, err := time.Parse(clockLayout, d.End)
+	if err != nil {
+		return fmt.Errorf("%w: end must be HH:MM", ErrInvalidSchedule)
+	}
+
+	if d.BreakMinutes < 0 || !e.After(s.Add(time.Duration(d.BreakMinutes)*time.Minute)) {
+		return fmt.Errorf("%w: end must be after start plus the break", ErrInvalidSchedule)
+	}
+
+	return nil
+}
+
+// Schedule is the expected working week of the users and teams it is
+// assigned to.
+type Schedule struct {
+	Id       string `json:"id" db:"id"`
+	OrgId    string `json:"orgId" db:"org_id"`
+	Name     string `json:"name" db:"name"`
+	Timezone string `json:"timezone" db:"timezone"`
+	// Region selects the holidays of the calendar that apply on top of
+	// the organization wide ones, empty for none.
+	Region       string        `json:"region" db:"region"`
+	GraceMinutes int           `json:"graceMinutes" db:"grace_minutes"`
+	Days         []ScheduleDay `json:"days" db:"-"`
+	CreatedAt    time.Time     `json:"createdAt" db:"created_at"`
+}
+
+// Day returns the schedule of weekday, nil when it is a day off.
+func (s *Schedule) Day(weekday time.Weekday) *ScheduleDay {
+	iso := int(weekday)
+	if iso == 0 {
+		iso = 7
+	}
+
+	for i := range s.Days {
+		if s.Days[i].Weekday == iso {
+			return &s.Days[i]
+		}
+	}
+	return nil
+}
+
+func (s *Schedule) Grace() time.Duration {
+	return time.Duration(s.GraceMinutes) * time.Minute
+}
+
+type AttendanceFlag string
+
+const (
+	AttendanceLate       AttendanceFlag = "late"
+	AttendanceEarlyLeave AttendanceFlag = "early_leave"
+	AttendanceAbsent     AttendanceFlag = "absent"
+	AttendanceOvertime   AttendanceFlag = "overtime"
+	AttendanceLeave      AttendanceFlag = "leave"
+	AttendanceHoliday    AttendanceFlag = "holiday"
+)
+
+// AttendanceDay compares the sessions of one day against its schedule.
+type AttendanceDay struct {
+	Date          string        `json:"date"`
+	Scheduled     bool          `json:"scheduled"`
+	ExpectedStart *time.Time    `json:"expectedStart,omitempty"`
+	ExpectedEnd   *time.Time    `json:"expectedEnd,omitempty"`
+	Expected      time.Duration `json:"expected"`
+	FirstStart    *time.Time    `json:"firstStart,omitempty"`
+	LastEnd       *time.Time    `json:"lastEnd,omitempty"`
+	Worked        time.Duration `json:"worked"`
+	Late          time.Duration `json:"late"`
+	EarlyLeave    time.Duration `json:"earlyLeave"`
+	Overtime      time.Duration `json:"overtime"`
+	// LeaveTypeId is set on days of approved leave, which are credited
+	// with the expected time instead of counting as absent.
+	LeaveTypeId *string       `json:"leaveTypeId,omitempty"`
+	Credited    time.Duration `json:"credited"`
+	// Holiday is the name of the holiday on date, nothing is expected.
+	Holiday *string          `json:"holiday,omitempty"`
+	Flags   []AttendanceFlag `json:"flags"`
+}
+
+// AttendanceReport compares the expected working time of a period with
+// the time worked and credited for leave.
+type AttendanceReport struct {
+	UserId       string           `json:"userId"`
+	ScheduleId   string           `json:"scheduleId"`
+	Days         []*AttendanceDay `json:"days"`
+	ExpectedTime time.Duration    `json:"expectedTime"`
+	WorkedTime   time.Duration    `json:"workedTime"`
+	CreditedTime time.Duration    `json:"creditedTime"`
+	// Balance is the time worked and credited beyond the expected time,
+	// negative when short of it.
+	Balance time.Duration `json:"balance"`
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxNoteLength is the longest note a session may carry, in characters.
+const MaxNoteLength = 2000
+
+// Tag labels sessions, such as "meeting" or "on-call". Tags are a
+// vocabulary managed per organization, names are unique regardless of
+// case.
+type Tag struct {
+	Id        string    `json:"id" db:"id"`
+	OrgId     string    `json:"orgId" db:"org_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// TagName trims name and checks it is a usable tag name.
+func TagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidTag)
+	}
+	if utf8.RuneCountInString(name) > 64 {
+		return "", fmt.Errorf("%w: name is longer than 64 characters", ErrInvalidTag)
+	}
+	return name, nil
+}
+
+// SessionNote trims note and checks its length. An empty note clears the
+// note of a session.
+func SessionNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxNoteLength {
+		return "", fmt.Errorf("%w: note is longer than %d characters", ErrInvalidNote, MaxNoteLength)
+	}
+	return note, nil
+}
+
+// TagTotal totals finished sessions with a tag. Sessions with several tags
+// count towards each of them. TagId is nil for untagged sessions.
+type TagTotal struct {
+	TagId      *string       `json:"tagId" db:"tag_id"`
+	Name       string        `json:"name" db:"name"`
+	TotalTime  time.Duration `json:"totalTime" db:"-"`
+	TotalCount int           `json:"totalCount" db:"total_count"`
+}
@@ -1,6 +1,192 @@
 package domain
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type TaskStatus string
+
+const (
+	TaskTodo       TaskStatus = "todo"
+	TaskInProgress TaskStatus = "in_progress"
+	TaskDone       TaskStatus = "done"
+)
+
+func (s TaskStatus) Validate() error {
+	switch s {
+	case TaskTodo, TaskInProgress, TaskDone:
+		return nil
+	}
+	return fmt.Errorf("%w: status must be todo, in_progress or done", ErrInvalidTask)
+}
+
+// Task is a piece of work of a project that time is tracked against.
 type Task struct {
-	Id    string `json:"id"`
-	Title string `json:"title"`
+	Id        string `json:"id" db:"id"`
+	OrgId     string `json:"orgId" db:"org_id"`
+	ProjectId string `json:"projectId" db:"project_id"`
+	// ParentId is the task this one is a subtask of, of the same project.
+	ParentId *string `json:"parentId,omitempty" db:"parent_id"`
+	Title    string  `json:"title" db:"title"`
+	// EstimateMinutes is the planned working time, nil when not estimated.
+	EstimateMinutes *int       `json:"estimateMinutes,omitempty" db:"estimate_minutes"`
+	Status          TaskStatus `json:"status" db:"status"`
+	DueDate         *time.Time `json:"dueDate,omitempty" db:"due_date"`
+	// AssigneeIds are the users the task is assigned to, sorted.
+	AssigneeIds []string  `json:"assigneeIds" db:"-"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Estimate returns the estimate of the task, nil when not estimated.
+func (t *Task) Estimate() *time.Duration {
+	if t.EstimateMinutes == nil {
+		return nil
+	}
+	estimate := time.Duration(*t.EstimateMinutes) * time.Minute
+	return &estimate
+}
+
+// TaskUserTotal totals the finished sessions of a user on a task.
+type TaskUserTotal struct {
+	TaskId     string        `json:"taskId" db:"task_id"`
+	UserId     string        `json:"userId" db:"user_id"`
+	TotalTime  time.Duration `json:"totalTime" db:"-"`
+	TotalCount int           `json:"totalCount" db:"total_count"`
+}
+
+// TaskUserTime is the time a user tracked on a task itself and on its
+// whole subtree.
+type TaskUserTime struct {
+	UserId     string        `json:"userId" db:"user_id"`
+	DirectTime time.Duration `json:"directTime" db:"-"`
+	TotalTime  time.Duration `json:"totalTime" db:"-"`
+}
+
+// TaskTime is the time tracked on a task itself and, rolled up, on the
+// task and all of its subtasks.
+type TaskTime struct {
+	TaskId     string          `json:"taskId"`
+	DirectTime time.Duration   `json:"directTime"`
+	TotalTime  time.Duration   `json:"totalTime"`
+	Users      []*TaskUserTime `json:"users"`
+}
+
+// EstimateComparison compares estimated with actually tracked time.
+type EstimateComparison struct {
+	EstimatedTime time.Duration `json:"estimatedTime"`
+	ActualTime    time.Duration `json:"actualTime"`
+	// Variance is the actual less the estimated time, positive when over
+	// the estimate.
+	Variance time.Duration `json:"variance"`
+	// Ratio is the actual over the estimated time, nil without estimate.
+	Ratio *float64 `json:"ratio,omitempty"`
+}
+
+func (c *EstimateComparison) compare() {
+	c.Variance = c.ActualTime - c.EstimatedTime
+	c.Ratio = nil
+	if c.EstimatedTime > 0 {
+		ratio := float64(c.ActualTime) / float64(c.EstimatedTime)
+		c.Ratio = &ratio
+	}
+}
+
+// TaskEstimate compares the estimate of a task with the time tracked on
+// it by anyone.
+type TaskEstimate struct {
+	TaskId      string     `json:"taskId"`
+	ProjectId   string     `json:"projectId"`
+	Title       string     `json:"title"`
+	Status      TaskStatus `json:"status"`
+	AssigneeIds []string   `json:"assigneeIds"`
+	// Estimated is false for tasks without estimate, whose estimated time
+	// is zero then.
+	Estimated bool `json:"estimated"`
+	EstimateComparison
+}
+
+// AssigneeEstimate compares, over the estimated tasks assigned to a user,
+// the share of the user in their estimates with the time the user tracked
+// on them. The estimate of a task is shared evenly among its assignees.
+type AssigneeEstimate struct {
+	UserId    string `json:"userId"`
+	TaskCount int    `json:"taskCount"`
+	EstimateComparison
+}
+
+// EstimateReport compares estimated and tracked time per task and per
+// assignee. Its totals cover estimated tasks only.
+type EstimateReport struct {
+	Tasks     []*TaskEstimate     `json:"tasks"`
+	Assignees []*AssigneeEstimate `json:"assignees"`
+	EstimateComparison
+}
+
+// CompareEstimates builds the estimate report of tasks from the time users
+// tracked on them.
+func CompareEstimates(tasks []*Task, totals []*TaskUserTotal) *EstimateReport {
+	// time tracked per task and per user on the task
+	tracked := make(map[string]map[string]time.Duration, len(tasks))
+	for _, t := range totals {
+		if tracked[t.TaskId] == nil {
+			tracked[t.TaskId] = make(map[string]time.Duration)
+		}
+		tracked[t.TaskId][t.UserId] += t.TotalTime
+	}
+
+	report := &EstimateReport{
+		Tasks:     make([]*TaskEstimate, 0, len(tasks)),
+		Assignees: make([]*AssigneeEstimate, 0),
+	}
+	assignees := make(map[string]*AssigneeEstimate)
+
+	for _, task := range tasks {
+		row := &TaskEstimate{
+			TaskId:      task.Id,
+			ProjectId:   task.ProjectId,
+			Title:       task.Title,
+			Status:      task.Status,
+			AssigneeIds: task.AssigneeIds,
+		}
+		for _, d := range tracked[task.Id] {
+			row.ActualTime += d
+		}
+
+		estimate := task.Estimate()
+		if estimate != nil {
+			row.Estimated = true
+			row.EstimatedTime = *estimate
+
+			report.EstimatedTime += row.EstimatedTime
+			report.ActualTime += row.ActualTime
+
+			for _, userId := range task.AssigneeIds {
+				a, ok := assignees[userId]
+				if !ok {
+					a = &AssigneeEstimate{UserId: userId}
+					assignees[userId] = a
+					report.Assignees = append(report.Assignees, a)
+				}
+				a.TaskCount++
+				a.EstimatedTime += row.EstimatedTime / time.Duration(len(task.AssigneeIds))
+				a.ActualTime += tracked[task.Id][userId]
+			}
+		}
+
+		row.compare()
+		report.Tasks = append(report.Tasks, row)
+	}
+
+	for _, a := range report.Assignees {
+		a.compare()
+	}
+	slices.SortFunc(report.Assignees, func(a, b *AssigneeEstimate) int {
+		return strings.Compare(a.UserId, b.UserId)
+	})
+	report.compare()
+
+	return report
 }
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// Team is a node of the department and team tree of an organization.
+type Team struct {
+	Id         string    `json:"id" db:"id"`
+	OrgId      string    `json:"orgId" db:"org_id"`
+	Name       string    `json:"name" db:"name"`
+	ParentId   *string   `json:"parentId,omitempty" db:"parent_id"`
+	ManagerId  *string   `json:"managerId,omitempty" db:"manager_id"`
+	ScheduleId *string   `json:"scheduleId,omitempty" db:"schedule_id"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+type TimesheetStatus string
+
+const (
+	TimesheetSubmitted TimesheetStatus = "submitted"
+	TimesheetApproved  TimesheetStatus = "approved"
+	TimesheetRejected  TimesheetStatus = "rejected"
+)
+
+// CanTransition reports whether a timesheet may move from s to next.
+// Submitted timesheets are reviewed, rejected ones may be submitted again
+// and approved ones are final.
+func (s TimesheetStatus) CanTransition(next TimesheetStatus) bool {
+	switch s {
+	case TimesheetSubmitted:
+		return next == TimesheetApproved || next == TimesheetRejected
+	case TimesheetRejected:
+		return next == TimesheetSubmitted
+	}
+	return false
+}
+
+// TimesheetPeriod is the length of the period a timesheet covers.
+const TimesheetPeriod = 7 * 24 * time.Hour
+
+// Timesheet is a week of a user's activity submitted for approval. Once
+// approved, the sessions of the week are locked.
+type Timesheet struct {
+	Id          string          `json:"id" db:"id"`
+	OrgId       string          `json:"orgId" db:"org_id"`
+	UserId      string          `json:"userId" db:"user_id"`
+	WeekStart   time.Time       `json:"weekStart" db:"week_start"`
+	Status      TimesheetStatus `json:"status" db:"status"`
+	Comment     *string         `json:"comment,omitempty" db:"comment"`
+	SubmittedAt time.Time       `json:"submittedAt" db:"submitted_at"`
+	ReviewedBy  *string         `json:"reviewedBy,omitempty" db:"reviewed_by"`
+	ReviewedAt  *time.Time      `json:"reviewedAt,omitempty" db:"reviewed_at"`
+
+	Sessions  []*Session    `json:"sessions,omitempty" db:"-"`
+	TotalTime time.Duration `json:"totalTime" db:"-"`
+}
+
+func (t *Timesheet) WeekEnd() time.Time {
+	return t.WeekStart.Add(TimesheetPeriod)
+}
+
+// WeekStartOf returns midnight UTC of the Monday of the week t falls in.
+func WeekStartOf(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
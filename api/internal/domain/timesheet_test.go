@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimesheetStatusCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to TimesheetStatus
+		want     bool
+	}{
+		{TimesheetSubmitted, TimesheetApproved, true},
+		{TimesheetSubmitted, TimesheetRejected, true},
+		{TimesheetSubmitted, TimesheetSubmitted, false},
+		{TimesheetRejected, TimesheetSubmitted, true},
+		{TimesheetRejected, TimesheetApproved, false},
+		{TimesheetRejected, TimesheetRejected, false},
+		{TimesheetApproved, TimesheetSubmitted, false},
+		{TimesheetApproved, TimesheetRejected, false},
+		{TimesheetApproved, TimesheetApproved, false},
+		{"", TimesheetApproved, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%q.CanTransition(%q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestWeekStartOf(t *testing.T) {
+	monday := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	berlin := time.FixedZone("CET", 60*60)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+	}{
+		{"monday midnight", monday, monday},
+		{"wednesday", time.Date(2024, 3, 6, 15, 30, 0, 0, time.UTC), monday},
+		{"sunday night", time.Date(2024, 3, 10, 23, 59, 59, 0, time.UTC), monday},
+		{"next monday", time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), monday.AddDate(0, 0, 7)},
+		{"across a month", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC)},
+		{"ahead of utc", time.Date(2024, 3, 4, 0, 30, 0, 0, berlin), monday.AddDate(0, 0, -7)},
+	}
+
+	for _, tt := range tests {
+		if got := WeekStartOf(tt.at); !got.Equal(tt.want) {
+			t.Errorf("%s: WeekStartOf(%v) = %v, want %v", tt.name, tt.at, got, tt.want)
+		}
+	}
+}
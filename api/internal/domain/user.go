@@ -1,11 +1,23 @@
 package domain
 
 type User struct {
-	Id             string `json:"id" db:"id"`
-	Name           string `json:"name" db:"name"`
-	Surname        string `json:"surname" db:"surname"`
-	Patronymic     string `json:"patronymic" db:"patronymic"`
-	Address        string `json:"address" db:"address"`
-	PassportSerie  string `json:"passportSerie" db:"passport_serie"`
-	PassportNumber string `json:"passportNumber" db:"passport_number"`
+	Id             string  `json:"id" db:"id"`
+	OrgId          string  `json:"orgId" db:"org_id"`
+	Name           string  `json:"name" db:"name"`
+	Surname        string  `json:"surname" db:"surname"`
+	Patronymic     string  `json:"patronymic" db:"patronymic"`
+	Address        string  `json:"address" db:"address"`
+	PassportSerie  string  `json:"passportSerie" db:"passport_serie"`
+	PassportNumber string  `json:"passportNumber" db:"passport_number"`
+	Role           Role    `json:"role" db:"role"`
+	ManagerId      *string `json:"managerId,omitempty" db:"manager_id"`
+	ScheduleId     *string `json:"scheduleId,omitempty" db:"schedule_id"`
+}
+
+// WithoutPassport returns a copy of the user with passport data removed.
+func (u *User) WithoutPassport() *User {
+	c := *u
+	c.PassportSerie = ""
+	c.PassportNumber = ""
+	return &c
 }
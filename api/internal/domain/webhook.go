@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+type Webhook struct {
+	Id        string      `json:"id" db:"id"`
+	OrgId     string      `json:"orgId" db:"org_id"`
+	Url       string      `json:"url" db:"url"`
+	Secret    string      `json:"secret,omitempty" db:"secret"`
+	Events    []EventType `json:"events" db:"-"`
+	Active    bool        `json:"active" db:"active"`
+	CreatedAt time.Time   `json:"createdAt" db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	Id            int64                     `json:"id" db:"id"`
+	WebhookId     string                    `json:"webhookId" db:"webhook_id"`
+	OutboxId      int64                     `json:"eventId" db:"outbox_id"`
+	EventType     EventType                 `json:"eventType" db:"event_type"`
+	Status        WebhookDeliveryStatus     `json:"status" db:"status"`
+	Attempts      int                       `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time                 `json:"nextAttemptAt" db:"next_attempt_at"`
+	CreatedAt     time.Time                 `json:"createdAt" db:"created_at"`
+	DeliveredAt   *time.Time                `json:"deliveredAt,omitempty" db:"delivered_at"`
+	Log           []*WebhookDeliveryAttempt `json:"log,omitempty" db:"-"`
+}
+
+type WebhookDeliveryAttempt struct {
+	Attempt    int       `json:"attempt" db:"attempt"`
+	StatusCode *int      `json:"statusCode,omitempty" db:"status_code"`
+	Error      *string   `json:"error,omitempty" db:"error"`
+	DurationMs int64     `json:"durationMs" db:"duration_ms"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
+
+// PendingDelivery is a delivery claimed by the dispatcher together with
+// everything needed to send it.
+type PendingDelivery struct {
+	Id        int64     `db:"id"`
+	WebhookId string    `db:"webhook_id"`
+	OutboxId  int64     `db:"outbox_id"`
+	Url       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	EventType EventType `db:"event_type"`
+	Payload   []byte    `db:"payload"`
+	Attempts  int       `db:"attempts"`
+}
@@ -1,13 +1,71 @@
 package dto
 
-import "time"
+import (
+	"em-test/internal/domain"
+	"time"
+)
 
 type SaveActivity struct {
 	UserId    string
+	TaskId    *string
 	StartTime time.Time
+	// EndTime is set for manually entered, already finished sessions.
+	EndTime *time.Time
+	Note    *string
+	TagIds  []string
+}
+
+// ImportActivity is an imported, finished session. Task is set rather than
+// TaskId when the session is on a task the import creates.
+type ImportActivity struct {
+	SaveActivity
+	Task *domain.ImportedTask
 }
 
 type StopActivityDto struct {
-	UserId  string
-	EndTime time.Time
+	UserId string
+	// SessionId or else TaskId picks the running session to finish, one of
+	// them is needed when several are running.
+	SessionId *int64
+	TaskId    *string
+	EndTime   time.Time
+	// Note and TagIds replace those of the session when not nil.
+	Note   *string
+	TagIds []string
+}
+
+// SwitchActivityDto finishes the running session of UserId and starts one
+// on TaskId at the same instant.
+type SwitchActivityDto struct {
+	UserId string
+	// SessionId picks the running session to finish, it is needed when
+	// several are running.
+	SessionId *int64
+	TaskId    *string
+	At        time.Time
+	// Note and TagIds are those of the new session.
+	Note   *string
+	TagIds []string
+}
+
+type UpdateSessionDto struct {
+	Id        int64
+	StartTime *time.Time
+	EndTime   *time.Time
+	// TaskId moves the session to another task when not nil.
+	TaskId *string
+	// Note replaces the note when not nil, an empty note clears it.
+	Note *string
+	// TagIds replaces the tags when not nil.
+	TagIds *[]string
+}
+
+// ImportTimeDto carries a CSV export of time entries of another tracker.
+// Source is guessed from the header of Data when empty. Timezone is the
+// IANA zone the times of the file are in, UTC when empty.
+type ImportTimeDto struct {
+	Source   domain.ImportSource
+	Timezone string
+	DryRun   bool
+	Data     []byte
 }
@@ -0,0 +1,22 @@
+package dto
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type SaveApiKeyDto struct {
+	Name      string
+	Prefix    string
+	Hash      string
+	UserId    *string
+	Role      domain.Role
+	ExpiresAt *time.Time
+}
+
+type CreateApiKeyDto struct {
+	Name      string
+	UserId    *string
+	Role      domain.Role
+	ExpiresAt *time.Time
+}
@@ -0,0 +1,11 @@
+package dto
+
+// SaveBudgetDto sets the budget of a project or, when TaskId is set, of a
+// task.
+type SaveBudgetDto struct {
+	ProjectId   *string
+	TaskId      *string
+	TimeMinutes *int
+	Amount      *int64
+	Thresholds  []int
+}
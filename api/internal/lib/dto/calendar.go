@@ -0,0 +1,22 @@
+package dto
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type SaveCalendarDayDto struct {
+	Region    string
+	Date      time.Time
+	Name      string
+	Kind      domain.CalendarDayKind
+	AsWeekday *int
+}
+
+// ImportCalendarDto carries an iCalendar or CSV file of calendar days for
+// Region. Format is "ics" or "csv", it is guessed from Data when empty.
+type ImportCalendarDto struct {
+	Region string
+	Format string
+	Data   []byte
+}
@@ -0,0 +1,7 @@
+package dto
+
+type SaveFeedDto struct {
+	UserId string
+	Prefix string
+	Hash   string
+}
@@ -0,0 +1,17 @@
+package dto
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type CreateInvoiceDto struct {
+	ClientId string
+	// Currency selects the projects of the client billed in it.
+	Currency string
+	GroupBy  domain.InvoiceGrouping
+	// PeriodStart and PeriodEnd are the first and the last day billed.
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	CreatedBy   string
+}
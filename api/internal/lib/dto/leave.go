@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type SaveLeaveTypeDto struct {
+	Name            string
+	Paid            bool
+	RequiresBalance bool
+}
+
+type UpdateLeaveTypeDto struct {
+	Name            *string
+	Paid            *bool
+	RequiresBalance *bool
+}
+
+type SetAllowanceDto struct {
+	UserId        string
+	LeaveTypeId   string
+	Year          int
+	AllowanceDays int
+}
+
+type RequestLeaveDto struct {
+	UserId      string
+	LeaveTypeId string
+	StartDate   time.Time
+	EndDate     time.Time
+	Reason      *string
+	// Days is the number of working days requested, filled in by the
+	// service.
+	Days int
+	// RequiresBalance checks the request against the allowance of the
+	// user, filled in by the service.
+	RequiresBalance bool
+}
+
+type ReviewLeaveDto struct {
+	Id     string
+	Status domain.LeaveStatus
+	// ReviewerId is nil when users withdraw their own requests.
+	ReviewerId *string
+	Comment    *string
+}
@@ -0,0 +1,13 @@
+package dto
+
+import "em-test/internal/domain"
+
+type SaveOrganizationDto struct {
+	Name string
+}
+
+// UpdateOrganizationDto changes the settings of an organization, fields
+// left nil are kept.
+type UpdateOrganizationDto struct {
+	TimerPolicy *domain.TimerPolicy
+}
@@ -0,0 +1,10 @@
+package dto
+
+import "em-test/internal/domain"
+
+// SavePayRulesDto replaces the rules of a team, or the organization wide
+// rules when TeamId is nil.
+type SavePayRulesDto struct {
+	TeamId *string
+	Rules  *domain.PayRules
+}
@@ -0,0 +1,27 @@
+package dto
+
+type SaveClientDto struct {
+	Name string
+}
+
+type SaveProjectDto struct {
+	ClientId   *string
+	Name       string
+	Billable   bool
+	HourlyRate int64
+	Currency   string
+}
+
+type UpdateProjectDto struct {
+	ClientId   *string
+	Name       *string
+	Billable   *bool
+	HourlyRate *int64
+	Currency   *string
+}
+
+type SetRateDto struct {
+	ProjectId  string
+	UserId     string
+	HourlyRate int64
+}
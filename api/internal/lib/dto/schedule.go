@@ -0,0 +1,20 @@
+package dto
+
+import "em-test/internal/domain"
+
+type SaveScheduleDto struct {
+	Name         string
+	Timezone     string
+	Region       string
+	GraceMinutes int
+	Days         []domain.ScheduleDay
+}
+
+type UpdateScheduleDto struct {
+	Name         *string
+	Timezone     *string
+	Region       *string
+	GraceMinutes *int
+	// Days replaces the working days when not nil.
+	Days []domain.ScheduleDay
+}
@@ -0,0 +1,5 @@
+package dto
+
+type SaveTagDto struct {
+	Name string
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type SaveTaskDto struct {
+	ProjectId       string
+	ParentId        *string
+	Title           string
+	EstimateMinutes *int
+	Status          domain.TaskStatus
+	DueDate         *time.Time
+	AssigneeIds     []string
+}
+
+type UpdateTaskDto struct {
+	ProjectId *string
+	// ParentId of "" makes the task a top level one.
+	ParentId *string
+	Title    *string
+	// EstimateMinutes of 0 clears the estimate.
+	EstimateMinutes *int
+	Status          *domain.TaskStatus
+	// DueDate of the zero time clears the due date.
+	DueDate *time.Time
+	// AssigneeIds replaces the assignees when not nil.
+	AssigneeIds *[]string
+}
@@ -0,0 +1,15 @@
+package dto
+
+type SaveTeamDto struct {
+	Name      string
+	ParentId  *string
+	ManagerId *string
+}
+
+type UpdateTeamDto struct {
+	Name *string
+	// ParentId moves the team, an empty string makes it a root team.
+	ParentId *string
+	// ManagerId reassigns the manager, an empty string removes it.
+	ManagerId *string
+}
@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type SubmitTimesheetDto struct {
+	UserId    string
+	WeekStart time.Time
+}
+
+type ReviewTimesheetDto struct {
+	Id         string
+	Status     domain.TimesheetStatus
+	ReviewerId string
+	Comment    *string
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "em-test/internal/domain"
+
 type AddUserDto struct {
 	PassportSerie  int
 	PassportNumber int
@@ -16,3 +18,9 @@ type SaveUserDto struct {
 	*AddUserDto
 	*UserInfoDto
 }
+
+type UpdateUserDto struct {
+	Role *domain.Role
+	// ManagerId reassigns the manager, an empty string removes it.
+	ManagerId *string
+}
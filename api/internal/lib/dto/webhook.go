@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type SaveWebhookDto struct {
+	Url    string
+	Secret string
+	Events []domain.EventType
+}
+
+type UpdateWebhookDto struct {
+	Url    *string
+	Events []domain.EventType
+	Active *bool
+}
+
+type WebhookAttemptDto struct {
+	DeliveryId int64
+	Attempt    int
+	StatusCode *int
+	Error      *string
+	Duration   time.Duration
+	// Status is the delivery status after this attempt; NextAttemptAt is
+	// only meaningful while it stays pending.
+	Status        domain.WebhookDeliveryStatus
+	NextAttemptAt time.Time
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"em-test/internal/config"
+	"em-test/internal/domain"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+var ErrSlowConsumer = errors.New("subscriber is too slow, events dropped")
+
+// Filter decides whether an event is delivered to a subscriber.
+// A nil filter accepts every event.
+type Filter func(e *domain.Event) bool
+
+// Bus is an in-process publish/subscribe hub. Publishing never blocks:
+// a subscriber whose buffer is full is disconnected with ErrSlowConsumer
+// and is expected to resubscribe.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	buffer int
+}
+
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	ch     chan domain.Event
+	closed bool
+	err    error
+}
+
+func NewBus(cfg *config.Config) *Bus {
+	buffer := cfg.Events.BufferSize
+	if buffer <= 0 {
+		buffer = 1
+	}
+
+	return &Bus{
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+func (b *Bus) Publish(e domain.Event) {
+	fn := "Bus.Publish"
+	logger := slog.With(slog.String("fn", fn), slog.String("type", string(e.Type)))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs {
+		if s.filter != nil && !s.filter(&e) {
+			continue
+		}
+
+		select {
+		case s.ch <- e:
+		default:
+			logger.Warn("dropping slow subscriber")
+			b.remove(s, ErrSlowConsumer)
+		}
+	}
+}
+
+func (b *Bus) Subscribe(filter Filter) *Subscription {
+	s := &Subscription{
+		bus:    b,
+		filter: filter,
+		ch:     make(chan domain.Event, b.buffer),
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+// remove must be called with b.mu held.
+func (b *Bus) remove(s *Subscription, err error) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	delete(b.subs, s)
+	close(s.ch)
+}
+
+// Events returns the channel of delivered events. It is closed when the
+// subscription is closed or dropped.
+func (s *Subscription) Events() <-chan domain.Event {
+	return s.ch
+}
+
+// Err reports why the subscription was closed by the bus, if it was.
+func (s *Subscription) Err() error {
+	s.bus.mu.RLock()
+	defer s.bus.mu.RUnlock()
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s, nil)
+}
@@ -6,4 +6,36 @@ type Activity struct {
 	UserId    string
 	StartTime *time.Time
 	EndTime   *time.Time
+	// TagIds limits to sessions with any of the tags.
+	TagIds []string
+	// GroupByTag totals the sessions per tag as well.
+	GroupByTag bool
+}
+
+// ActivityReport selects the users a report totals activity for.
+type ActivityReport struct {
+	// TeamId limits the report to members of the team and its descendant
+	// teams.
+	TeamId *string
+	// UserIds limits the report to the given users, nil means no limit.
+	UserIds   []string
+	StartTime *time.Time
+	EndTime   *time.Time
+	// TagIds limits the report to sessions with any of the tags.
+	TagIds []string
+	// GroupByTag totals the sessions per tag as well.
+	GroupByTag bool
+}
+
+// Overlap selects the sessions of a user intersecting [Start, End).
+type Overlap struct {
+	UserId string
+	Start  time.Time
+	// End is nil for an interval that is still open.
+	End *time.Time
+	// ExcludeId leaves out the session being corrected.
+	ExcludeId int64
+	// SameTask limits to the sessions on TaskId, nil meaning no task.
+	SameTask bool
+	TaskId   *string
 }
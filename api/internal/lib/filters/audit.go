@@ -0,0 +1,13 @@
+package filters
+
+import "time"
+
+type Audit struct {
+	Entity    *string
+	EntityId  *string
+	ActorId   *string
+	StartTime *time.Time
+	EndTime   *time.Time
+	Limit     int
+	Offset    int
+}
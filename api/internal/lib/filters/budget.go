@@ -0,0 +1,6 @@
+package filters
+
+type Budgets struct {
+	ProjectId *string
+	TaskId    *string
+}
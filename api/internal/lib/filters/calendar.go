@@ -0,0 +1,12 @@
+package filters
+
+import "time"
+
+type Calendar struct {
+	// Regions limits days to the given regions, the empty region being
+	// the whole organization. nil means no limit.
+	Regions []string
+	// From and To are the first and last date, both inclusive.
+	From *time.Time
+	To   *time.Time
+}
@@ -0,0 +1,8 @@
+package filters
+
+import "em-test/internal/domain"
+
+type Invoices struct {
+	ClientId *string
+	Status   *domain.InvoiceStatus
+}
@@ -0,0 +1,19 @@
+package filters
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type Leave struct {
+	UserId *string
+	Status *domain.LeaveStatus
+	// UserIds limits requests to the given users, nil means no limit.
+	UserIds []string
+	// TeamId limits requests to members of the team and its descendant
+	// teams.
+	TeamId *string
+	// From and To select requests overlapping the days between them.
+	From *time.Time
+	To   *time.Time
+}
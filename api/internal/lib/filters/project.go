@@ -0,0 +1,36 @@
+package filters
+
+import (
+	"em-test/internal/domain"
+	"time"
+)
+
+type Projects struct {
+	ClientId *string
+}
+
+type Tasks struct {
+	ProjectId *string
+	// ParentId selects the subtasks of a task, "" selects top level tasks.
+	ParentId   *string
+	Status     *domain.TaskStatus
+	AssigneeId *string
+}
+
+// TaskTotals selects the finished sessions on tasks.
+type TaskTotals struct {
+	TaskIds []string
+	// UserIds limits the totals to the given users, nil means no limit.
+	UserIds []string
+}
+
+// ProjectSummary selects the sessions on the tasks of a project.
+type ProjectSummary struct {
+	ProjectId string
+	// TaskId limits the summary to one task of the project.
+	TaskId *string
+	// UserIds limits the summary to the given users, nil means no limit.
+	UserIds   []string
+	StartTime *time.Time
+	EndTime   *time.Time
+}
@@ -0,0 +1,10 @@
+package filters
+
+import "em-test/internal/domain"
+
+type Timesheets struct {
+	UserId *string
+	Status *domain.TimesheetStatus
+	// UserIds limits timesheets to the given users, nil means no limit.
+	UserIds []string
+}
@@ -7,4 +7,9 @@ type UsersFilters struct {
 	Name       *string
 	Patronymic *string
 	Address    *string
+	ManagerId  *string
+	// Ids limits users to the given ids, nil means no limit.
+	Ids []string
+	// TeamId limits users to members of the team and its descendant teams.
+	TeamId *string
 }
@@ -0,0 +1,221 @@
+// Package xlsx writes Office Open XML workbooks row by row, so that large
+// sheets are streamed rather than held in memory. It covers what exports
+// need: inline text, numbers, date-times and durations.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// styles of cells, indexes into cellXfs of styles.xml
+const (
+	styleDefault = iota
+	styleDateTime
+	styleDuration
+)
+
+// epoch is day zero of spreadsheet date-times.
+var epoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+var errSheetClosed = errors.New("xlsx: sheet is closed")
+
+// Cell is a value of a row.
+type Cell struct {
+	value string
+	kind  byte // 's' text, 'n' number, 0 empty
+	style int
+}
+
+// String is a text cell.
+func String(s string) Cell {
+	return Cell{value: s, kind: 's'}
+}
+
+// Int is a number cell.
+func Int(n int) Cell {
+	return Cell{value: strconv.Itoa(n), kind: 'n'}
+}
+
+// Number is a number cell.
+func Number(f float64) Cell {
+	return Cell{value: strconv.FormatFloat(f, 'f', -1, 64), kind: 'n'}
+}
+
+// Time is a date-time cell, shown in the date format of the reader's
+// locale.
+func Time(t time.Time) Cell {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	days := float64(t.Sub(epoch)) / float64(24*time.Hour)
+	return Cell{value: strconv.FormatFloat(days, 'f', -1, 64), kind: 'n', style: styleDateTime}
+}
+
+// Duration is a duration cell, shown as hours, minutes and seconds.
+func Duration(d time.Duration) Cell {
+	days := math.Round(d.Seconds()) / (24 * 60 * 60)
+	return Cell{value: strconv.FormatFloat(days, 'f', -1, 64), kind: 'n', style: styleDuration}
+}
+
+// Empty is an empty cell.
+func Empty() Cell {
+	return Cell{}
+}
+
+// Writer writes a workbook. Sheets are written one after the other, a new
+// sheet closes the previous one.
+type Writer struct {
+	zip    *zip.Writer
+	sheets []string
+	sheet  *Sheet
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zip: zip.NewWriter(w)}
+}
+
+// Sheet starts a new sheet named name.
+func (w *Writer) Sheet(name string) (*Sheet, error) {
+	if err := w.closeSheet(); err != nil {
+		return nil, err
+	}
+
+	w.sheets = append(w.sheets, name)
+	part, err := w.zip.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(w.sheets)))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(part, xml.Header+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	w.sheet = &Sheet{w: part}
+	return w.sheet, nil
+}
+
+func (w *Writer) closeSheet() error {
+	if w.sheet == nil {
+		return nil
+	}
+
+	sheet := w.sheet
+	w.sheet = nil
+	sheet.closed = true
+	_, err := io.WriteString(sheet.w, `</sheetData></worksheet>`)
+	return err
+}
+
+// Close writes the workbook parts that list the sheets and finishes the
+// archive. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.closeSheet(); err != nil {
+		return err
+	}
+
+	var types, sheets, rels strings.Builder
+	for i, name := range w.sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	stylesId := len(w.sheets) + 1
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() +
+			fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, stylesId) +
+			`</Relationships>`},
+		// 22 is the built-in locale date-time format, 164 the first custom one
+		{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<numFmts count="1"><numFmt numFmtId="164" formatCode="[h]:mm:ss"/></numFmts>` +
+			`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="3">` +
+			`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`</cellXfs></styleSheet>`},
+	}
+
+	for _, p := range parts {
+		part, err := w.zip.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(part, xml.Header+p.content); err != nil {
+			return err
+		}
+	}
+
+	return w.zip.Close()
+}
+
+// Sheet writes the rows of a sheet.
+type Sheet struct {
+	w      io.Writer
+	rows   int
+	closed bool
+}
+
+// Row appends a row of cells.
+func (s *Sheet) Row(cells ...Cell) error {
+	if s.closed {
+		return errSheetClosed
+	}
+
+	s.rows++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, s.rows)
+	for i, c := range cells {
+		ref := column(i) + strconv.Itoa(s.rows)
+		switch c.kind {
+		case 's':
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(c.value))
+		case 'n':
+			fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, c.style, c.value)
+		}
+	}
+	b.WriteString(`</row>`)
+
+	_, err := io.WriteString(s.w, b.String())
+	return err
+}
+
+// column returns the letters of the zero based column i, A to Z, AA and on.
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	// EscapeText only fails on writer errors, which a builder has none of
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
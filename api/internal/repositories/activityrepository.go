@@ -1,16 +1,21 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"em-test/internal/domain"
 	"em-test/internal/lib/dto"
 	"em-test/internal/lib/filters"
 	"errors"
 	"log/slog"
+	"slices"
+	"strconv"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/rcmonitor/pginterval"
 )
 
@@ -20,98 +25,513 @@ type ActivityRepository struct {
 	db *sqlx.DB
 }
 
-func (a *ActivityRepository) Create(activity *dto.SaveActivity) error {
+// sessionRow scans the tags of a session, which the domain keeps as a
+// slice.
+type sessionRow struct {
+	domain.Session
+	TagIds pq.StringArray `db:"tag_ids"`
+}
+
+func (r *sessionRow) toDomain() *domain.Session {
+	s := r.Session
+	if len(r.TagIds) > 0 {
+		s.TagIds = []string(r.TagIds)
+	}
+	return &s
+}
+
+// sessionTagIds selects the sorted tag ids of the sessions of table alias
+// as tag_ids.
+func sessionTagIds(alias string) string {
+	return "ARRAY(SELECT tag_id FROM " + ACTIVITY_TAGS_TABLE + " WHERE activity_id = " + alias + ".id ORDER BY tag_id) AS tag_ids"
+}
+
+// taggedWith matches the sessions of table alias with any of tagIds.
+func taggedWith(alias string, tagIds []string) sq.Sqlizer {
+	return sq.Expr("EXISTS (SELECT 1 FROM "+ACTIVITY_TAGS_TABLE+" WHERE activity_id = "+alias+".id AND tag_id = ANY(?))", pq.Array(tagIds))
+}
+
+// noteValue stores an empty note as NULL.
+func noteValue(note *string) *string {
+	if note == nil || *note == "" {
+		return nil
+	}
+	return note
+}
+
+// setSessionTags replaces the tags of a session and returns them sorted.
+// It fails with ErrTagNotFound unless all tags are of the organization.
+func setSessionTags(ctx context.Context, tx *sqlx.Tx, orgId string, sessionId int64, tagIds []string) ([]string, error) {
+	fn := "setSessionTags"
+	logger := slog.With(slog.String("fn", fn), slog.Int64("sessionId", sessionId))
+
+	query, args, err := sq.Delete(ACTIVITY_TAGS_TABLE).
+		Where(sq.Eq{"activity_id": sessionId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	tagIds = slices.Clone(tagIds)
+	slices.Sort(tagIds)
+	tagIds = slices.Compact(tagIds)
+	if len(tagIds) == 0 {
+		return nil, nil
+	}
+
+	query, args, err = sq.Insert(ACTIVITY_TAGS_TABLE).
+		Columns("activity_id", "tag_id").
+		Select(sq.Select().
+			Column("?::integer", sessionId).
+			Column("id").
+			From(TAGS_TABLE).
+			Where(sq.Eq{"org_id": orgId}).
+			Where("id = ANY(?)", pq.Array(tagIds))).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if int(affected) != len(tagIds) {
+		return nil, domain.ErrTagNotFound
+	}
+
+	return tagIds, nil
+}
+
+// lockSession reads the session matching where for update, it is the
+// before state of audited changes.
+func lockSession(ctx context.Context, tx *sqlx.Tx, where sq.Eq) (*domain.Session, error) {
+	fn := "lockSession"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Select("id", "user_id", "task_id", "start_time", "end_time", "invoice_id", "note", sessionTagIds("a")).
+		From(ACTIVITY_TABLE + " a").
+		Where(where).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	var row sessionRow
+	if err := tx.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrSessionNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return row.toDomain(), nil
+}
+
+func (a *ActivityRepository) Create(ctx context.Context, activity *dto.SaveActivity) (*domain.Session, error) {
 
 	fn := "ActivityRepository.Create"
 	logger := slog.With(slog.String("fn", fn))
 
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	sql, args, err := sq.Insert(ACTIVITY_TABLE).
-		Columns("user_id", "start_time").
-		Values(activity.UserId, activity.StartTime).
+		Columns("org_id", "user_id", "task_id", "start_time", "end_time", "note").
+		Values(orgId, activity.UserId, activity.TaskId, activity.StartTime, activity.EndTime, noteValue(activity.Note)).
+		Suffix("RETURNING id, user_id, task_id, start_time, end_time, invoice_id, note").
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
 		logger.Error("failed to build sql", slog.String("err", err.Error()))
-		return err
+		return nil, err
 	}
 
 	logger.Debug("executing query", slog.String("sql", sql), slog.Any("args", args))
 
-	if _, err := a.db.Exec(sql, args...); err != nil {
-		logger.Error("failed to execute query", slog.String("err", err.Error()))
-		return err
+	var session domain.Session
+	err = withTx(ctx, a.db, func(tx *sqlx.Tx) error {
+		if err := tx.Get(&session, sql, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		tagIds, err := setSessionTags(ctx, tx, orgId, session.Id, activity.TagIds)
+		if err != nil {
+			return err
+		}
+		session.TagIds = tagIds
+
+		if err := recordAudit(ctx, tx, domain.AuditCreate, domain.AuditEntityActivity, strconv.FormatInt(session.Id, 10), nil, &session); err != nil {
+			return err
+		}
+
+		event := &domain.Event{
+			Type:       domain.EventActivityStarted,
+			OrgId:      orgId,
+			UserId:     activity.UserId,
+			OccurredAt: session.StartTime,
+			Data:       &session,
+		}
+		if activity.EndTime != nil {
+			event.Type = domain.EventActivityCreated
+			event.OccurredAt = time.Now()
+		}
+
+		return enqueueEvent(tx, event)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &session, nil
 }
 
-func (a *ActivityRepository) IsActive(userId string) (bool, error) {
-	fn := "ActivityRepository.IsActive"
-	logger := slog.With(slog.String("fn", fn))
+// importBatchSize bounds the rows of one insert of CreateMany, keeping its
+// parameters well below the limit of Postgres.
+const importBatchSize = 500
 
-	query, args, err := sq.
-		Select(`a.id, a.start_time, a.end_time, u.id as "user.id", u.surname as "user.surname", u.name as "user.name", u.patronymic as "user.patronymic", u.passport_serie as "user.passport_serie", u.passport_number as "user.passport_number"`).
-		From(ACTIVITY_TABLE + " a").
-		Join("users u ON u.id = a.user_id").
-		Where(sq.And{
-			sq.Eq{"a.user_id": userId},
-			sq.Eq{"a.end_time": nil},
-		}).
+// CreateMany saves finished sessions, such as imported ones, and the tasks
+// created for them in one transaction, filling in the ids of the tasks.
+// The tasks hold past work, they are done already. Each session is
+// audited, while a single event tells about all of them.
+func (a *ActivityRepository) CreateMany(ctx context.Context, tasks []*domain.ImportedTask, activities []*dto.ImportActivity) (int, error) {
+	fn := "ActivityRepository.CreateMany"
+	logger := slog.With(slog.String("fn", fn), slog.Int("count", len(activities)))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	userIds := make([]string, 0)
+	err = withTx(ctx, a.db, func(tx *sqlx.Tx) error {
+		for _, task := range tasks {
+			query, args, err := sq.Insert(TASKS_TABLE).
+				Columns("id", "org_id", "project_id", "title", "status").
+				Values(uuid.New().String(), orgId, task.ProjectId, task.Title, domain.TaskDone).
+				Suffix("RETURNING id").
+				PlaceholderFormat(sq.Dollar).
+				ToSql()
+			if err != nil {
+				logger.Error("failed to build sql", slog.String("err", err.Error()))
+				return err
+			}
+
+			if err := tx.GetContext(ctx, &task.Id, query, args...); err != nil {
+				logger.Error("failed to execute query", slog.String("err", err.Error()))
+				if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+					return domain.ErrProjectNotFound
+				}
+				return err
+			}
+		}
+
+		for start := 0; start < len(activities); start += importBatchSize {
+			batch := activities[start:min(start+importBatchSize, len(activities))]
+			builder := sq.Insert(ACTIVITY_TABLE).
+				Columns("org_id", "user_id", "task_id", "start_time", "end_time", "note").
+				Suffix("RETURNING id, user_id, task_id, start_time, end_time, invoice_id, note").
+				PlaceholderFormat(sq.Dollar)
+			for _, activity := range batch {
+				taskId := activity.TaskId
+				if activity.Task != nil {
+					taskId = &activity.Task.Id
+				}
+				builder = builder.Values(orgId, activity.UserId, taskId, activity.StartTime, activity.EndTime, noteValue(activity.Note))
+			}
+
+			query, args, err := builder.ToSql()
+			if err != nil {
+				logger.Error("failed to build sql", slog.String("err", err.Error()))
+				return err
+			}
+
+			logger.Debug("executing query", slog.Int("rows", len(batch)))
+
+			sessions := make([]*domain.Session, 0, len(batch))
+			if err := tx.SelectContext(ctx, &sessions, query, args...); err != nil {
+				logger.Error("failed to execute query", slog.String("err", err.Error()))
+				return err
+			}
+
+			for _, session := range sessions {
+				if err := recordAudit(ctx, tx, domain.AuditCreate, domain.AuditEntityActivity, strconv.FormatInt(session.Id, 10), nil, session); err != nil {
+					return err
+				}
+				userIds = append(userIds, session.UserId)
+			}
+		}
+
+		slices.Sort(userIds)
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventActivityImported,
+			OrgId:      orgId,
+			OccurredAt: time.Now(),
+			Data:       &domain.SessionImport{Sessions: len(userIds), UserIds: slices.Compact(slices.Clone(userIds))},
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(userIds), nil
+}
+
+// GetRunning returns the unfinished sessions of the user, oldest first.
+func (a *ActivityRepository) GetRunning(ctx context.Context, userId string) ([]*domain.Session, error) {
+	fn := "ActivityRepository.GetRunning"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("id", "user_id", "task_id", "start_time", "end_time", "invoice_id", "note", sessionTagIds("a")).
+		From(ACTIVITY_TABLE+" a").
+		Where(sq.Eq{"org_id": orgId, "user_id": userId, "end_time": nil}).
+		OrderBy("start_time ASC", "id ASC").
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
 		logger.Error("failed to build sql", slog.String("err", err.Error()))
-		return false, err
+		return nil, err
 	}
 
 	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
 
-	var res domain.ActivityRecord
-	if err := a.db.Get(&res, query, args...); err != nil {
+	var rows []*sessionRow
+	if err := a.db.SelectContext(ctx, &rows, query, args...); err != nil {
 		logger.Error("failed to execute query", slog.String("err", err.Error()))
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
-		}
-		return false, err
+		return nil, err
 	}
 
-	return true, err
+	res := make([]*domain.Session, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row.toDomain())
+	}
+
+	return res, nil
 }
 
-func (a *ActivityRepository) PatchEndTime(d *dto.StopActivityDto) error {
+func (a *ActivityRepository) PatchEndTime(ctx context.Context, d *dto.StopActivityDto) (*domain.Session, error) {
 	fn := "ActivityRepository.PatchEndTime"
 	logger := slog.With(slog.String("fn", fn))
 
-	sql, args, err := sq.Update(ACTIVITY_TABLE+" a").
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	running := sq.Eq{"org_id": orgId, "user_id": d.UserId, "end_time": nil}
+	if d.SessionId != nil {
+		running["id"] = *d.SessionId
+	}
+
+	builder := sq.Update(ACTIVITY_TABLE+" a").
 		Set("end_time", d.EndTime).
-		Where(sq.And{
-			sq.Eq{"a.user_id": d.UserId},
-			sq.Eq{"a.end_time": nil},
-		}).
-		PlaceholderFormat(sq.Dollar).
-		ToSql()
+		Where(running).
+		Suffix("RETURNING a.id, a.user_id, a.task_id, a.start_time, a.end_time, a.note, " + sessionTagIds("a")).
+		PlaceholderFormat(sq.Dollar)
+
+	if d.Note != nil {
+		builder = builder.Set("note", noteValue(d.Note))
+	}
+
+	sql, args, err := builder.ToSql()
 	if err != nil {
 		logger.Error("failed to build sql", slog.String("err", err.Error()))
-		return err
+		return nil, err
 	}
 
 	logger.Debug("executing query", slog.String("sql", sql), slog.Any("args", args))
 
-	if _, err := a.db.Exec(sql, args...); err != nil {
-		logger.Error("failed to execute query", slog.String("err", err.Error()))
-		return err
+	var session *domain.Session
+	err = withTx(ctx, a.db, func(tx *sqlx.Tx) error {
+		before, err := lockSession(ctx, tx, running)
+		if err != nil {
+			return err
+		}
+
+		if d.TagIds != nil {
+			if _, err := setSessionTags(ctx, tx, orgId, before.Id, d.TagIds); err != nil {
+				return err
+			}
+		}
+
+		var row sessionRow
+		if err := tx.Get(&row, sql, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+		session = row.toDomain()
+
+		if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityActivity, strconv.FormatInt(session.Id, 10), before, session); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventActivityStopped,
+			OrgId:      orgId,
+			UserId:     d.UserId,
+			OccurredAt: d.EndTime,
+			Data:       session,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Switch finishes the running session of d.UserId, the one of d.SessionId
+// when set, and starts one on d.TaskId at d.At in one transaction, so that
+// no time falls between the two.
+func (a *ActivityRepository) Switch(ctx context.Context, d *dto.SwitchActivityDto) (stopped, started *domain.Session, err error) {
+	fn := "ActivityRepository.Switch"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	running := sq.Eq{"org_id": orgId, "user_id": d.UserId, "end_time": nil}
+	if d.SessionId != nil {
+		running["id"] = *d.SessionId
+	}
+
+	err = withTx(ctx, a.db, func(tx *sqlx.Tx) error {
+		before, err := lockSession(ctx, tx, running)
+		if err != nil {
+			if errors.Is(err, domain.ErrSessionNotFound) {
+				return domain.ErrUserNotWorking
+			}
+			return err
+		}
+
+		if before.OnTask(d.TaskId) {
+			return domain.ErrAlreadyOnTask
+		}
+
+		if !d.At.After(before.StartTime) {
+			return domain.ErrInvalidInterval
+		}
+
+		query, args, err := sq.Update(ACTIVITY_TABLE+" a").
+			Set("end_time", d.At).
+			Where(sq.Eq{"a.id": before.Id}).
+			Suffix("RETURNING a.id, a.user_id, a.task_id, a.start_time, a.end_time, a.invoice_id, a.note, " + sessionTagIds("a")).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			logger.Error("failed to build sql", slog.String("err", err.Error()))
+			return err
+		}
+
+		logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+		var row sessionRow
+		if err := tx.GetContext(ctx, &row, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+		stopped = row.toDomain()
+
+		query, args, err = sq.Insert(ACTIVITY_TABLE).
+			Columns("org_id", "user_id", "task_id", "start_time", "note").
+			Values(orgId, d.UserId, d.TaskId, d.At, noteValue(d.Note)).
+			Suffix("RETURNING id, user_id, task_id, start_time, end_time, invoice_id, note").
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			logger.Error("failed to build sql", slog.String("err", err.Error()))
+			return err
+		}
+
+		logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+		started = new(domain.Session)
+		if err := tx.GetContext(ctx, started, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if started.TagIds, err = setSessionTags(ctx, tx, orgId, started.Id, d.TagIds); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityActivity, strconv.FormatInt(stopped.Id, 10), before, stopped); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditCreate, domain.AuditEntityActivity, strconv.FormatInt(started.Id, 10), nil, started); err != nil {
+			return err
+		}
+
+		if err := enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventActivityStopped,
+			OrgId:      orgId,
+			UserId:     d.UserId,
+			OccurredAt: d.At,
+			Data:       stopped,
+		}); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventActivityStarted,
+			OrgId:      orgId,
+			UserId:     d.UserId,
+			OccurredAt: d.At,
+			Data:       started,
+		})
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
+	return stopped, started, nil
 }
 
-func (a *ActivityRepository) GetSessions(f *filters.Activity) ([]*domain.Session, error) {
+func (a *ActivityRepository) GetSessions(ctx context.Context, f *filters.Activity) ([]*domain.Session, error) {
 	fn := "ActivityRepository.GetSessions"
 	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
 
-	builder := sq.Select("start_time", "end_time").
-		From(ACTIVITY_TABLE).
-		Where(sq.Eq{"user_id": f.UserId}).
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("id", "task_id", "start_time", "end_time", "invoice_id", "note", sessionTagIds("a")).
+		From(ACTIVITY_TABLE + " a").
+		Where(sq.Eq{"org_id": orgId, "user_id": f.UserId}).
 		PlaceholderFormat(sq.Dollar)
 
 	if f.StartTime != nil {
@@ -122,6 +542,10 @@ func (a *ActivityRepository) GetSessions(f *filters.Activity) ([]*domain.Session
 		builder = builder.Where(sq.LtOrEq{"end_time": f.EndTime})
 	}
 
+	if len(f.TagIds) > 0 {
+		builder = builder.Where(taggedWith("a", f.TagIds))
+	}
+
 	query, args, err := builder.ToSql()
 	if err != nil {
 		logger.Error("failed to build sql", slog.String("err", err.Error()))
@@ -130,22 +554,32 @@ func (a *ActivityRepository) GetSessions(f *filters.Activity) ([]*domain.Session
 
 	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
 
-	var res []*domain.Session
-	if err := a.db.Select(&res, query, args...); err != nil {
+	var rows []*sessionRow
+	if err := a.db.SelectContext(ctx, &rows, query, args...); err != nil {
 		logger.Error("failed to execute query", slog.String("err", err.Error()))
 		return nil, err
 	}
 
+	res := make([]*domain.Session, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row.toDomain())
+	}
+
 	return res, nil
 }
 
-func (a *ActivityRepository) GetSummary(f *filters.Activity) (time.Duration, int, error) {
+func (a *ActivityRepository) GetSummary(ctx context.Context, f *filters.Activity) (time.Duration, int, error) {
 	fn := "ActivityRepository.GetSummary"
 	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
 
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
 	builder := sq.Select("SUM(end_time - start_time) as duration, COUNT(*) as total").
 		From(ACTIVITY_TABLE).
-		Where(sq.Eq{"user_id": f.UserId}).
+		Where(sq.Eq{"org_id": orgId, "user_id": f.UserId}).
 		PlaceholderFormat(sq.Dollar)
 
 	if f.StartTime != nil {
@@ -156,6 +590,10 @@ func (a *ActivityRepository) GetSummary(f *filters.Activity) (time.Duration, int
 		builder = builder.Where(sq.LtOrEq{"end_time": f.EndTime})
 	}
 
+	if len(f.TagIds) > 0 {
+		builder = builder.Where(taggedWith(ACTIVITY_TABLE, f.TagIds))
+	}
+
 	query, args, err := builder.ToSql()
 	if err != nil {
 		logger.Error("failed to build sql", slog.String("err", err.Error()))
@@ -164,10 +602,10 @@ func (a *ActivityRepository) GetSummary(f *filters.Activity) (time.Duration, int
 
 	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
 
-	var durationString string
+	var durationString sql.NullString
 	var total int
 
-	if err := a.db.QueryRow(query, args...).Scan(
+	if err := a.db.QueryRowContext(ctx, query, args...).Scan(
 		&durationString,
 		&total,
 	); err != nil {
@@ -175,17 +613,471 @@ func (a *ActivityRepository) GetSummary(f *filters.Activity) (time.Duration, int
 		return 0, 0, err
 	}
 
-	duration, err := pginterval.FParse(durationString)
+	// SUM over no finished sessions is NULL
+	if !durationString.Valid {
+		return 0, total, nil
+	}
+
+	duration, err := pginterval.FParse(durationString.String)
 	if err != nil {
 		logger.Error("failed to parse duration", slog.String("err", err.Error()))
 		return 0, 0, err
 	}
 
-	logger.Debug("row scanned", slog.Any("duration", duration), slog.Int("total", total), slog.String("durationString", durationString))
+	logger.Debug("row scanned", slog.Any("duration", duration), slog.Int("total", total), slog.String("durationString", durationString.String))
 
 	return *duration, total, nil
 }
 
+// EachSession calls each with every finished session of f, by user and start
+// time, reading them one at a time so that large periods are not held in
+// memory. It stops at the first error of each.
+func (a *ActivityRepository) EachSession(ctx context.Context, f *filters.ActivityReport, each func(*domain.Session) error) error {
+	fn := "ActivityRepository.EachSession"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	builder := sq.Select("id", "user_id", "task_id", "start_time", "end_time", "invoice_id", "note", sessionTagIds("a")).
+		From(ACTIVITY_TABLE+" a").
+		Where(sq.Eq{"org_id": orgId}).
+		Where(sq.NotEq{"end_time": nil}).
+		OrderBy("user_id ASC", "start_time ASC", "id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.TeamId != nil {
+		builder = builder.Where(inTeam("user_id", orgId, *f.TeamId))
+	}
+
+	if f.UserIds != nil {
+		builder = builder.Where(sq.Eq{"user_id": f.UserIds})
+	}
+
+	if f.StartTime != nil {
+		builder = builder.Where(sq.GtOrEq{"start_time": f.StartTime})
+	}
+
+	if f.EndTime != nil {
+		builder = builder.Where(sq.LtOrEq{"end_time": f.EndTime})
+	}
+
+	if len(f.TagIds) > 0 {
+		builder = builder.Where(taggedWith("a", f.TagIds))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	rows, err := a.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row sessionRow
+		if err := rows.StructScan(&row); err != nil {
+			logger.Error("failed to scan row", slog.String("err", err.Error()))
+			return err
+		}
+
+		if err := each(row.toDomain()); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (a *ActivityRepository) ReadRecord(ctx context.Context, id int64) (*domain.ActivityRecord, error) {
+	fn := "ActivityRepository.ReadRecord"
+	logger := slog.With(slog.String("fn", fn), slog.Int64("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.
+		Select(`a.id, a.task_id, a.start_time, a.end_time, u.id as "user.id", u.surname as "user.surname", u.name as "user.name", u.patronymic as "user.patronymic", u.role as "user.role", u.manager_id as "user.manager_id"`).
+		From(ACTIVITY_TABLE + " a").
+		Join("users u ON u.id = a.user_id").
+		Where(sq.Eq{"a.id": id, "a.org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var res domain.ActivityRecord
+	if err := a.db.GetContext(ctx, &res, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (a *ActivityRepository) Update(ctx context.Context, userId string, d *dto.UpdateSessionDto) (*domain.Session, error) {
+	fn := "ActivityRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.Int64("id", d.Id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(ACTIVITY_TABLE).
+		Where(sq.Eq{"id": d.Id, "org_id": orgId}).
+		Suffix("RETURNING id, user_id, task_id, start_time, end_time, invoice_id, note, " + sessionTagIds(ACTIVITY_TABLE)).
+		PlaceholderFormat(sq.Dollar)
+
+	// a change of tags alone leaves the row as it is
+	changed := false
+
+	if d.StartTime != nil {
+		builder = builder.Set("start_time", *d.StartTime)
+		changed = true
+	}
+
+	if d.EndTime != nil {
+		builder = builder.Set("end_time", *d.EndTime)
+		changed = true
+	}
+
+	if d.TaskId != nil {
+		builder = builder.Set("task_id", *d.TaskId)
+		changed = true
+	}
+
+	if d.Note != nil {
+		builder = builder.Set("note", noteValue(d.Note))
+		changed = true
+	}
+
+	var session *domain.Session
+	err = withTx(ctx, a.db, func(tx *sqlx.Tx) error {
+		before, err := lockSession(ctx, tx, sq.Eq{"id": d.Id, "org_id": orgId})
+		if err != nil {
+			return err
+		}
+
+		if before.InvoiceId != nil {
+			return domain.ErrSessionInvoiced
+		}
+
+		if d.TagIds != nil {
+			if _, err := setSessionTags(ctx, tx, orgId, d.Id, *d.TagIds); err != nil {
+				return err
+			}
+		}
+
+		if changed {
+			query, args, err := builder.ToSql()
+			if err != nil {
+				logger.Error("failed to build sql", slog.String("err", err.Error()))
+				return err
+			}
+
+			logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+			var row sessionRow
+			if err := tx.Get(&row, query, args...); err != nil {
+				logger.Error("failed to execute query", slog.String("err", err.Error()))
+				if errors.Is(err, sql.ErrNoRows) {
+					return domain.ErrSessionNotFound
+				}
+				return err
+			}
+			session = row.toDomain()
+		} else if session, err = lockSession(ctx, tx, sq.Eq{"id": d.Id, "org_id": orgId}); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityActivity, strconv.FormatInt(session.Id, 10), before, session); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventActivityUpdated,
+			OrgId:      orgId,
+			UserId:     userId,
+			OccurredAt: time.Now(),
+			Data:       session,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// HasOverlap reports whether any session of the user of f intersects the
+// interval of f.
+func (a *ActivityRepository) HasOverlap(ctx context.Context, f *filters.Overlap) (bool, error) {
+	fn := "ActivityRepository.HasOverlap"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", f.UserId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	builder := sq.Select("COUNT(*) > 0").
+		From(ACTIVITY_TABLE).
+		Where(sq.And{
+			sq.Eq{"org_id": orgId},
+			sq.Eq{"user_id": f.UserId},
+			sq.NotEq{"id": f.ExcludeId},
+			sq.Expr("COALESCE(end_time, 'infinity'::timestamp) > ?", f.Start),
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	if f.End != nil {
+		builder = builder.Where(sq.Lt{"start_time": *f.End})
+	}
+
+	if f.SameTask {
+		if f.TaskId != nil {
+			builder = builder.Where(sq.Eq{"task_id": *f.TaskId})
+		} else {
+			builder = builder.Where(sq.Eq{"task_id": nil})
+		}
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var overlaps bool
+	if err := a.db.GetContext(ctx, &overlaps, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	return overlaps, nil
+}
+
 func NewActivityRepository(db *sqlx.DB) *ActivityRepository {
 	return &ActivityRepository{db: db}
 }
+
+// GetReport totals finished sessions per user. Users without sessions in
+// the period are left out.
+func (a *ActivityRepository) GetReport(ctx context.Context, f *filters.ActivityReport) ([]*domain.UserActivityTotal, error) {
+	fn := "ActivityRepository.GetReport"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select(
+		"user_id",
+		"COALESCE(EXTRACT(EPOCH FROM SUM(end_time - start_time)), 0) AS total_seconds",
+		"COUNT(*) AS total_count",
+	).
+		From(ACTIVITY_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		Where(sq.NotEq{"end_time": nil}).
+		GroupBy("user_id").
+		OrderBy("user_id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.TeamId != nil {
+		builder = builder.Where(inTeam("user_id", orgId, *f.TeamId))
+	}
+
+	if f.UserIds != nil {
+		builder = builder.Where(sq.Eq{"user_id": f.UserIds})
+	}
+
+	if f.StartTime != nil {
+		builder = builder.Where(sq.GtOrEq{"start_time": f.StartTime})
+	}
+
+	if f.EndTime != nil {
+		builder = builder.Where(sq.LtOrEq{"end_time": f.EndTime})
+	}
+
+	if len(f.TagIds) > 0 {
+		builder = builder.Where(taggedWith(ACTIVITY_TABLE, f.TagIds))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []struct {
+		UserId       string  `db:"user_id"`
+		TotalSeconds float64 `db:"total_seconds"`
+		TotalCount   int     `db:"total_count"`
+	}
+	if err := a.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	totals := make([]*domain.UserActivityTotal, 0, len(rows))
+	for _, row := range rows {
+		totals = append(totals, &domain.UserActivityTotal{
+			UserId:     row.UserId,
+			TotalTime:  time.Duration(row.TotalSeconds * float64(time.Second)),
+			TotalCount: row.TotalCount,
+		})
+	}
+
+	return totals, nil
+}
+
+// GetTagTotals totals finished sessions per tag, untagged sessions last.
+// Filtering by tags totals only those tags.
+func (a *ActivityRepository) GetTagTotals(ctx context.Context, f *filters.ActivityReport) ([]*domain.TagTotal, error) {
+	fn := "ActivityRepository.GetTagTotals"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select(
+		"t.id AS tag_id",
+		"COALESCE(t.name, '') AS name",
+		"COALESCE(EXTRACT(EPOCH FROM SUM(a.end_time - a.start_time)), 0) AS total_seconds",
+		"COUNT(*) AS total_count",
+	).
+		From(ACTIVITY_TABLE+" a").
+		LeftJoin(ACTIVITY_TAGS_TABLE+" st ON st.activity_id = a.id").
+		LeftJoin(TAGS_TABLE+" t ON t.id = st.tag_id").
+		Where(sq.Eq{"a.org_id": orgId}).
+		Where(sq.NotEq{"a.end_time": nil}).
+		GroupBy("t.id", "t.name").
+		OrderBy("t.name ASC NULLS LAST").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.TeamId != nil {
+		builder = builder.Where(inTeam("a.user_id", orgId, *f.TeamId))
+	}
+
+	if f.UserIds != nil {
+		builder = builder.Where(sq.Eq{"a.user_id": f.UserIds})
+	}
+
+	if f.StartTime != nil {
+		builder = builder.Where(sq.GtOrEq{"a.start_time": f.StartTime})
+	}
+
+	if f.EndTime != nil {
+		builder = builder.Where(sq.LtOrEq{"a.end_time": f.EndTime})
+	}
+
+	if len(f.TagIds) > 0 {
+		builder = builder.Where("st.tag_id = ANY(?)", pq.Array(f.TagIds))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []struct {
+		domain.TagTotal
+		TotalSeconds float64 `db:"total_seconds"`
+	}
+	if err := a.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	totals := make([]*domain.TagTotal, 0, len(rows))
+	for _, row := range rows {
+		total := row.TagTotal
+		total.TotalTime = time.Duration(row.TotalSeconds * float64(time.Second))
+		totals = append(totals, &total)
+	}
+
+	return totals, nil
+}
+
+// billableItems totals the sessions of an invoice per task, user and
+// hourly rate, ordered by project, task and user. A rate of the user on
+// the project overrides the rate of the project.
+func billableItems(ctx context.Context, q sqlx.QueryerContext, invoiceId string) ([]*domain.BillableItem, error) {
+	fn := "billableItems"
+	logger := slog.With(slog.String("fn", fn), slog.String("invoiceId", invoiceId))
+
+	query, args, err := sq.Select(
+		"p.id AS project_id",
+		"p.name AS project_name",
+		"t.id AS task_id",
+		"t.title AS task_title",
+		"a.user_id",
+		"TRIM(u.name || ' ' || u.surname) AS user_name",
+		"COALESCE(r.hourly_rate, p.hourly_rate) AS hourly_rate",
+		"COALESCE(EXTRACT(EPOCH FROM SUM(a.end_time - a.start_time)), 0) AS total_seconds",
+		"COUNT(*) AS total_count",
+	).
+		From(ACTIVITY_TABLE+" a").
+		Join(TASKS_TABLE+" t ON t.id = a.task_id").
+		Join(PROJECTS_TABLE+" p ON p.id = t.project_id").
+		Join(USERS_TABLE+" u ON u.id = a.user_id").
+		LeftJoin(PROJECT_RATES_TABLE+" r ON r.project_id = p.id AND r.user_id = a.user_id").
+		Where(sq.Eq{"a.invoice_id": invoiceId}).
+		GroupBy("p.id", "p.name", "t.id", "t.title", "a.user_id", "u.name", "u.surname", "r.hourly_rate").
+		OrderBy("p.name ASC", "t.title ASC", "user_name ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []struct {
+		domain.BillableItem
+		TotalSeconds float64 `db:"total_seconds"`
+	}
+	if err := sqlx.SelectContext(ctx, q, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	items := make([]*domain.BillableItem, 0, len(rows))
+	for _, row := range rows {
+		item := row.BillableItem
+		item.Duration = time.Duration(row.TotalSeconds) * time.Second
+		items = append(items, &item)
+	}
+
+	return items, nil
+}
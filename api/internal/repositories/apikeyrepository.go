@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// var _ services.ApiKeyRepository = (*ApiKeyRepository)(nil)
+
+type ApiKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewApiKeyRepository(db *sqlx.DB) *ApiKeyRepository {
+	return &ApiKeyRepository{db: db}
+}
+
+func (r *ApiKeyRepository) Create(ctx context.Context, d *dto.SaveApiKeyDto) (*domain.ApiKey, error) {
+	fn := "ApiKeyRepository.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(API_KEYS_TABLE).
+		Columns("id", "org_id", "name", "prefix", "hash", "user_id", "role", "expires_at").
+		Values(uuid.New().String(), orgId, d.Name, d.Prefix, d.Hash, d.UserId, d.Role, d.ExpiresAt).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var key domain.ApiKey
+	if err := r.db.GetContext(ctx, &key, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// ReadByHash looks a key up across all organizations, it is how the
+// organization of a request is found in the first place.
+func (r *ApiKeyRepository) ReadByHash(ctx context.Context, hash string) (*domain.ApiKey, error) {
+	fn := "ApiKeyRepository.ReadByHash"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Select("*").
+		From(API_KEYS_TABLE).
+		Where(sq.Eq{"hash": hash}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var key domain.ApiKey
+	if err := r.db.GetContext(ctx, &key, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrApiKeyNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *ApiKeyRepository) ReadAll(ctx context.Context) ([]*domain.ApiKey, error) {
+	fn := "ApiKeyRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(API_KEYS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("created_at ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	keys := make([]*domain.ApiKey, 0)
+	if err := r.db.SelectContext(ctx, &keys, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *ApiKeyRepository) Revoke(ctx context.Context, id string) error {
+	fn := "ApiKeyRepository.Revoke"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Update(API_KEYS_TABLE).
+		Set("revoked_at", sq.Expr("NOW()")).
+		Where(sq.And{
+			sq.Eq{"id": id, "org_id": orgId},
+			sq.Eq{"revoked_at": nil},
+		}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrApiKeyNotFound
+	}
+
+	return nil
+}
+
+func (r *ApiKeyRepository) TouchLastUsed(ctx context.Context, id string) error {
+	fn := "ApiKeyRepository.TouchLastUsed"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	query, args, err := sq.Update(API_KEYS_TABLE).
+		Set("last_used_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	return nil
+}
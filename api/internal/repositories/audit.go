@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/filters"
+	"encoding/json"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// var _ services.AuditRepository = (*AuditRepository)(nil)
+
+// auditJSON marshals a before or after state, nil stays NULL.
+func auditJSON(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// recordAudit appends a change made by the principal of ctx to the audit
+// log. It must run in the same transaction as the change itself.
+func recordAudit(ctx context.Context, tx *sqlx.Tx, action domain.AuditAction, entity domain.AuditEntity, entityId string, before, after any) error {
+	fn := "recordAudit"
+	logger := slog.With(slog.String("fn", fn), slog.String("entity", string(entity)), slog.String("entityId", entityId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	actorKind, actorId := domain.ActorSystem, ""
+	if p, ok := domain.PrincipalFrom(ctx); ok && p != nil {
+		actorKind, actorId = p.Kind, p.Id
+	}
+
+	var requestId *string
+	if id, ok := domain.RequestIdFrom(ctx); ok {
+		requestId = &id
+	}
+
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		logger.Error("failed to marshal before", slog.String("err", err.Error()))
+		return err
+	}
+
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		logger.Error("failed to marshal after", slog.String("err", err.Error()))
+		return err
+	}
+
+	query, args, err := sq.Insert(AUDIT_LOG_TABLE).
+		Columns("org_id", "actor_kind", "actor_id", "action", "entity", "entity_id", "before", "after", "request_id").
+		Values(orgId, actorKind, actorId, action, entity, entityId, beforeJSON, afterJSON, requestId).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// auditRow scans before and after as []byte, which database/sql copies out
+// of the driver buffer, unlike json.RawMessage.
+type auditRow struct {
+	domain.AuditEntry
+	Before []byte `db:"before"`
+	After  []byte `db:"after"`
+}
+
+func (r *auditRow) toDomain() *domain.AuditEntry {
+	e := r.AuditEntry
+	e.Before = r.Before
+	e.After = r.After
+	return &e
+}
+
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) ReadMany(ctx context.Context, f *filters.Audit) ([]*domain.AuditEntry, error) {
+	fn := "AuditRepository.ReadMany"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("*").
+		From(AUDIT_LOG_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("id DESC").
+		Limit(uint64(f.Limit)).
+		Offset(uint64(f.Offset)).
+		PlaceholderFormat(sq.Dollar)
+
+	if f.Entity != nil {
+		builder = builder.Where(sq.Eq{"entity": *f.Entity})
+	}
+
+	if f.EntityId != nil {
+		builder = builder.Where(sq.Eq{"entity_id": *f.EntityId})
+	}
+
+	if f.ActorId != nil {
+		builder = builder.Where(sq.Eq{"actor_id": *f.ActorId})
+	}
+
+	if f.StartTime != nil {
+		builder = builder.Where(sq.GtOrEq{"created_at": f.StartTime})
+	}
+
+	if f.EndTime != nil {
+		builder = builder.Where(sq.Lt{"created_at": f.EndTime})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []*auditRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	entries := make([]*domain.AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, row.toDomain())
+	}
+
+	return entries, nil
+}
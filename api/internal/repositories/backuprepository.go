@@ -0,0 +1,400 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.BackupRepository = (*BackupRepository)(nil)
+
+// restoreBatchSize is how many rows of a table one insert of a restore
+// takes.
+const restoreBatchSize = 500
+
+// backupTable is a table of backups, its rows ordered by its key. Columns
+// referencing rows of the same table are restored once all of its rows are
+// in, as a row may come before the one it references.
+type backupTable struct {
+	name     string
+	key      string
+	deferred []string
+	// serial tables take their ids from a sequence, which is moved past
+	// the restored ids.
+	serial bool
+}
+
+// backupTables are the tables of backups, each after those it references.
+// The outbox and the webhook deliveries are left out, they are events in
+// flight rather than data.
+var backupTables = []backupTable{
+	{name: ORGANIZATIONS_TABLE, key: "id"},
+	{name: SCHEDULES_TABLE, key: "id"},
+	{name: USERS_TABLE, key: "id", deferred: []string{"manager_id"}},
+	{name: API_KEYS_TABLE, key: "id"},
+	{name: TEAMS_TABLE, key: "id", deferred: []string{"parent_id"}},
+	{name: TEAM_MEMBERS_TABLE, key: "team_id, user_id"},
+	{name: AUDIT_LOG_TABLE, key: "id", serial: true},
+	{name: TIMESHEETS_TABLE, key: "id"},
+	{name: PAY_RULES_TABLE, key: "id"},
+	{name: LEAVE_TYPES_TABLE, key: "id"},
+	{name: LEAVE_BALANCES_TABLE, key: "user_id, leave_type_id, year"},
+	{name: LEAVE_REQUESTS_TABLE, key: "id"},
+	{name: CALENDAR_DAYS_TABLE, key: "id"},
+	{name: CLIENTS_TABLE, key: "id"},
+	{name: PROJECTS_TABLE, key: "id"},
+	{name: PROJECT_RATES_TABLE, key: "project_id, user_id"},
+	{name: TASKS_TABLE, key: "id", deferred: []string{"parent_id"}},
+	{name: TASK_ASSIGNEES_TABLE, key: "task_id, user_id"},
+	{name: INVOICES_TABLE, key: "id"},
+	{name: INVOICE_LINES_TABLE, key: "invoice_id, position"},
+	{name: ACTIVITY_TABLE, key: "id", serial: true},
+	{name: BUDGETS_TABLE, key: "id"},
+	{name: TAGS_TABLE, key: "id"},
+	{name: ACTIVITY_TAGS_TABLE, key: "activity_id, tag_id"},
+	{name: FEEDS_TABLE, key: "id"},
+	{name: WEBHOOKS_TABLE, key: "id"},
+}
+
+// BackupRepository reads and restores the data of all organizations at
+// once, below the tenant scoping of the other repositories.
+type BackupRepository struct {
+	db *sqlx.DB
+}
+
+func NewBackupRepository(db *sqlx.DB) *BackupRepository {
+	return &BackupRepository{db: db}
+}
+
+// schemaVersion reads the version golang-migrate migrated the database to.
+func schemaVersion(ctx context.Context, tx *sqlx.Tx) (uint, bool, error) {
+	fn := "schemaVersion"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Select("version", "dirty").
+		From(SCHEMA_MIGRATIONS_TABLE).
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return 0, false, err
+	}
+
+	var version struct {
+		Version uint `db:"version"`
+		Dirty   bool `db:"dirty"`
+	}
+	if err := tx.GetContext(ctx, &version, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, fmt.Errorf("%w: database is not migrated", domain.ErrSchemaMismatch)
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return 0, false, err
+	}
+
+	return version.Version, version.Dirty, nil
+}
+
+// requireSchema fails with ErrSchemaMismatch unless a backup of version
+// fits a database at current.
+func requireSchema(version, current uint, dirty bool) error {
+	if dirty {
+		return fmt.Errorf("%w: database is dirty at version %d", domain.ErrSchemaMismatch, current)
+	}
+	if current != version {
+		return fmt.Errorf("%w: backup is of version %d, database is at %d", domain.ErrSchemaMismatch, version, current)
+	}
+	return nil
+}
+
+// nextBackupTable finds the backup table name from position on, as the
+// tables of a backup come in the order of the backup tables.
+func nextBackupTable(position int, name string) (int, error) {
+	i := slices.IndexFunc(backupTables[position:], func(t backupTable) bool {
+		return t.name == name
+	})
+	if i < 0 {
+		return 0, fmt.Errorf("%w: table %q is unknown or out of order", domain.ErrInvalidBackup, name)
+	}
+	return position + i, nil
+}
+
+// Export reads all rows of the backup tables as JSON objects from one
+// snapshot. schema gets the schema version of the snapshot before the
+// first row.
+func (r *BackupRepository) Export(ctx context.Context, schema func(version uint, dirty bool) error, row func(table string, data json.RawMessage) error) error {
+	fn := "BackupRepository.Export"
+	logger := slog.With(slog.String("fn", fn))
+
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	// nothing is written, the snapshot is only let go of
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			logger.Error("failed to rollback transaction", slog.String("err", err.Error()))
+		}
+	}()
+
+	version, dirty, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := schema(version, dirty); err != nil {
+		return err
+	}
+
+	for _, table := range backupTables {
+		query := fmt.Sprintf(`SELECT row_to_json(t) FROM %q t ORDER BY %s`, table.name, table.key)
+
+		logger.Debug("executing query", slog.String("sql", query))
+
+		rows, err := tx.QueryxContext(ctx, query)
+		if err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		for rows.Next() {
+			var data []byte
+			if err := rows.Scan(&data); err != nil {
+				rows.Close()
+				return err
+			}
+			if err := row(table.name, data); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore inserts the rows next returns until io.EOF in one transaction,
+// keeping their ids. It fails with ErrSchemaMismatch unless the database is
+// at version, and with ErrDatabaseNotEmpty unless the backup tables are
+// empty but for organizations, such as the one migrations create, which
+// the backup replaces. Rows come table by table in the order of the backup
+// tables.
+func (r *BackupRepository) Restore(ctx context.Context, version uint, next func() (string, json.RawMessage, error)) (map[string]int, error) {
+	fn := "BackupRepository.Restore"
+	logger := slog.With(slog.String("fn", fn))
+
+	counts := make(map[string]int)
+	err := withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		current, dirty, err := schemaVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if err := requireSchema(version, current, dirty); err != nil {
+			return err
+		}
+
+		if err := requireEmpty(ctx, tx); err != nil {
+			return err
+		}
+
+		var (
+			table    *backupTable
+			position int
+			batch    []json.RawMessage
+			// pending keeps the rows of the table whose deferred columns
+			// are set once all of them are in
+			pending []json.RawMessage
+		)
+
+		finish := func() error {
+			if table == nil {
+				return nil
+			}
+			if err := restoreRows(ctx, tx, table, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+
+			for start := 0; start < len(pending); start += restoreBatchSize {
+				if err := restoreDeferred(ctx, tx, table, pending[start:min(start+restoreBatchSize, len(pending))]); err != nil {
+					return err
+				}
+			}
+			pending = pending[:0]
+			return nil
+		}
+
+		for {
+			name, data, err := next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if table == nil || name != table.name {
+				if err := finish(); err != nil {
+					return err
+				}
+
+				i, err := nextBackupTable(position, name)
+				if err != nil {
+					return err
+				}
+				table, position = &backupTables[i], i+1
+				logger.Debug("restoring table", slog.String("table", name))
+			}
+
+			batch = append(batch, data)
+			if len(table.deferred) > 0 {
+				pending = append(pending, data)
+			}
+			counts[name]++
+
+			if len(batch) == restoreBatchSize {
+				if err := restoreRows(ctx, tx, table, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+
+		if err := finish(); err != nil {
+			return err
+		}
+
+		for _, table := range backupTables {
+			if !table.serial {
+				continue
+			}
+
+			query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX("id"), 0) + 1, false) FROM %q`, table.name, table.name)
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				logger.Error("failed to execute query", slog.String("err", err.Error()))
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// requireEmpty fails with ErrDatabaseNotEmpty unless the backup tables hold
+// no rows but organizations, then deletes those.
+func requireEmpty(ctx context.Context, tx *sqlx.Tx) error {
+	fn := "requireEmpty"
+	logger := slog.With(slog.String("fn", fn))
+
+	for _, table := range backupTables {
+		if table.name == ORGANIZATIONS_TABLE {
+			continue
+		}
+
+		var exists bool
+		query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %q)`, table.name)
+		if err := tx.GetContext(ctx, &exists, query); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: table %s has rows", domain.ErrDatabaseNotEmpty, table.name)
+		}
+	}
+
+	query, args, err := sq.Delete(ORGANIZATIONS_TABLE).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// restoreError tells rows that break a constraint, such as a reference to
+// a row missing from the backup, apart from failures of the database.
+func restoreError(table string, err error) error {
+	if e, ok := err.(*pq.Error); ok && e.Code.Class() == "23" {
+		return fmt.Errorf("%w: %s: %s", domain.ErrInvalidBackup, table, e.Message)
+	}
+	return err
+}
+
+// restoreRows inserts rows of table, its deferred columns left empty.
+func restoreRows(ctx context.Context, tx *sqlx.Tx, table *backupTable, rows []json.RawMessage) error {
+	fn := "restoreRows"
+	logger := slog.With(slog.String("fn", fn), slog.String("table", table.name))
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	// the rows take the type of the table, so columns go in its order
+	query := fmt.Sprintf(`INSERT INTO %[1]q SELECT (jsonb_populate_record(NULL::%[1]q, e - $2::text[])).* FROM jsonb_array_elements($1::jsonb) e`, table.name)
+
+	if _, err := tx.ExecContext(ctx, query, string(data), pq.Array(table.deferred)); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return restoreError(table.name, err)
+	}
+
+	return nil
+}
+
+// restoreDeferred sets the deferred columns of restored rows of table.
+func restoreDeferred(ctx context.Context, tx *sqlx.Tx, table *backupTable, rows []json.RawMessage) error {
+	fn := "restoreDeferred"
+	logger := slog.With(slog.String("fn", fn), slog.String("table", table.name))
+
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	sets := make([]string, 0, len(table.deferred))
+	for _, column := range table.deferred {
+		sets = append(sets, fmt.Sprintf(`%[1]q = r.%[1]q`, column))
+	}
+
+	query := fmt.Sprintf(`UPDATE %[1]q t SET %[2]s FROM jsonb_populate_recordset(NULL::%[1]q, $1::jsonb) r WHERE t."id" = r."id"`, table.name, strings.Join(sets, ", "))
+
+	if _, err := tx.ExecContext(ctx, query, string(data)); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return restoreError(table.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"em-test/internal/domain"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRequireSchema(t *testing.T) {
+	tests := []struct {
+		version, current uint
+		dirty            bool
+		wantErr          bool
+	}{
+		{20, 20, false, false},
+		{20, 20, true, true},
+		{19, 20, false, true},
+		{20, 19, false, true},
+		{0, 0, false, false},
+	}
+
+	for _, tt := range tests {
+		err := requireSchema(tt.version, tt.current, tt.dirty)
+		if tt.wantErr != errors.Is(err, domain.ErrSchemaMismatch) || (!tt.wantErr && err != nil) {
+			t.Errorf("requireSchema(%d, %d, %t) = %v, want error %t", tt.version, tt.current, tt.dirty, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNextBackupTable(t *testing.T) {
+	last := len(backupTables) - 1
+
+	tests := []struct {
+		position int
+		name     string
+		want     int
+		wantErr  bool
+	}{
+		{0, ORGANIZATIONS_TABLE, 0, false},
+		{0, USERS_TABLE, 2, false},
+		{3, TEAMS_TABLE, 4, false},
+		{0, WEBHOOKS_TABLE, last, false},
+		{3, USERS_TABLE, 0, true},
+		{last + 1, WEBHOOKS_TABLE, 0, true},
+		{0, OUTBOX_TABLE, 0, true},
+		{0, SCHEMA_MIGRATIONS_TABLE, 0, true},
+		{0, "", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := nextBackupTable(tt.position, tt.name)
+		if tt.wantErr {
+			if !errors.Is(err, domain.ErrInvalidBackup) {
+				t.Errorf("nextBackupTable(%d, %q) error = %v, want %v", tt.position, tt.name, err, domain.ErrInvalidBackup)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("nextBackupTable(%d, %q) = %d, %v, want %d", tt.position, tt.name, got, err, tt.want)
+		}
+	}
+}
+
+var (
+	migrationTable     = regexp.MustCompile(`(?:CREATE TABLE(?: IF NOT EXISTS)?|ALTER TABLE) "(\w+)"`)
+	migrationReference = regexp.MustCompile(`"(\w+)"\)?[^"]*REFERENCES "(\w+)"`)
+)
+
+// TestBackupTablesOrder holds the backup tables to the migrations: each
+// table comes after those it references, references within a table are
+// deferred and no table is left out but those in flight.
+func TestBackupTablesOrder(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	slices.Sort(files)
+
+	index := func(name string) int {
+		return slices.IndexFunc(backupTables, func(t backupTable) bool { return t.name == name })
+	}
+	inFlight := []string{OUTBOX_TABLE, WEBHOOK_DELIVERIES_TABLE, WEBHOOK_DELIVERY_ATTEMPTS_TABLE}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a reference belongs to the table of the last statement naming one
+		table := ""
+		for _, line := range strings.Split(string(data), "\n") {
+			if m := migrationTable.FindStringSubmatch(line); m != nil {
+				table = m[1]
+				if !slices.Contains(inFlight, table) && index(table) < 0 {
+					t.Errorf("%s: table %q is not backed up", filepath.Base(file), table)
+				}
+			}
+
+			m := migrationReference.FindStringSubmatch(line)
+			if m == nil || slices.Contains(inFlight, table) {
+				continue
+			}
+			column, referenced := m[1], m[2]
+
+			i := index(table)
+			if i < 0 {
+				continue
+			}
+			if referenced == table {
+				if !slices.Contains(backupTables[i].deferred, column) {
+					t.Errorf("%s: %s.%s references its own table and is not deferred", filepath.Base(file), table, column)
+				}
+				continue
+			}
+			if j := index(referenced); j < 0 || j > i {
+				t.Errorf("%s: %s.%s references %q, which is not backed up before it", filepath.Base(file), table, column, referenced)
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package repositories
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"log/slog"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.BudgetRepository = (*BudgetRepository)(nil)
+
+type BudgetRepository struct {
+	db *sqlx.DB
+}
+
+func NewBudgetRepository(db *sqlx.DB) *BudgetRepository {
+	return &BudgetRepository{db: db}
+}
+
+// budgetRow scans the thresholds array, which the domain keeps as ints.
+type budgetRow struct {
+	domain.Budget
+	Thresholds pq.Int64Array `db:"thresholds"`
+}
+
+func (r *budgetRow) toDomain() *domain.Budget {
+	b := r.Budget
+	b.Thresholds = make([]int, 0, len(r.Thresholds))
+	for _, t := range r.Thresholds {
+		b.Thresholds = append(b.Thresholds, int(t))
+	}
+	return &b
+}
+
+// Save sets the budget of a project or a task. Changing a budget starts
+// its notifications over.
+func (r *BudgetRepository) Save(ctx context.Context, d *dto.SaveBudgetDto) (*domain.Budget, error) {
+	fn := "BudgetRepository.Save"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target := "project_id"
+	if d.TaskId != nil {
+		target = "task_id"
+	}
+
+	thresholds := make(pq.Int64Array, 0, len(d.Thresholds))
+	for _, t := range d.Thresholds {
+		thresholds = append(thresholds, int64(t))
+	}
+
+	query, args, err := sq.Insert(BUDGETS_TABLE).
+		Columns("id", "org_id", "project_id", "task_id", "time_minutes", "amount", "thresholds").
+		Values(uuid.New().String(), orgId, d.ProjectId, d.TaskId, d.TimeMinutes, d.Amount, thresholds).
+		Suffix(`ON CONFLICT ("` + target + `") DO UPDATE SET
+			time_minutes = EXCLUDED.time_minutes,
+			amount = EXCLUDED.amount,
+			thresholds = EXCLUDED.thresholds,
+			notified_percent = 0,
+			updated_at = NOW()
+			RETURNING *`).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var row budgetRow
+	if err := r.db.GetContext(ctx, &row, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *BudgetRepository) ReadMany(ctx context.Context, f *filters.Budgets) ([]*domain.Budget, error) {
+	fn := "BudgetRepository.ReadMany"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("*").
+		From(BUDGETS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("updated_at DESC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.ProjectId != nil {
+		builder = builder.Where(sq.Eq{"project_id": *f.ProjectId})
+	}
+
+	if f.TaskId != nil {
+		builder = builder.Where(sq.Eq{"task_id": *f.TaskId})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []*budgetRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	budgets := make([]*domain.Budget, 0, len(rows))
+	for _, row := range rows {
+		budgets = append(budgets, row.toDomain())
+	}
+
+	return budgets, nil
+}
+
+// Delete removes the budget of the project or the task of f.
+func (r *BudgetRepository) Delete(ctx context.Context, f *filters.Budgets) error {
+	fn := "BudgetRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	builder := sq.Delete(BUDGETS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		PlaceholderFormat(sq.Dollar)
+
+	if f.TaskId != nil {
+		builder = builder.Where(sq.Eq{"task_id": *f.TaskId})
+	} else {
+		builder = builder.Where(sq.Eq{"project_id": f.ProjectId})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrBudgetNotFound
+	}
+
+	return nil
+}
+
+// MarkNotified records that the budget of alert reached its threshold and
+// raises the event. It reports false when a higher or the same threshold
+// was already recorded, such as by a concurrent stop.
+func (r *BudgetRepository) MarkNotified(ctx context.Context, alert *domain.BudgetAlert, userId string) (bool, error) {
+	fn := "BudgetRepository.MarkNotified"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", alert.Burn.Budget.Id), slog.Int("threshold", alert.Threshold))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query, args, err := sq.Update(BUDGETS_TABLE).
+		Set("notified_percent", alert.Threshold).
+		Where(sq.Eq{"id": alert.Burn.Budget.Id, "org_id": orgId}).
+		Where(sq.Lt{"notified_percent": alert.Threshold}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var marked bool
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return nil
+		}
+		marked = true
+		alert.Burn.Budget.NotifiedPercent = alert.Threshold
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventBudgetThreshold,
+			OrgId:      orgId,
+			UserId:     userId,
+			OccurredAt: time.Now(),
+			Data:       alert,
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return marked, nil
+}
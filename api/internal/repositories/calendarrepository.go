@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// var _ services.CalendarRepository = (*CalendarRepository)(nil)
+
+type CalendarRepository struct {
+	db *sqlx.DB
+}
+
+func NewCalendarRepository(db *sqlx.DB) *CalendarRepository {
+	return &CalendarRepository{db: db}
+}
+
+// Save stores days, replacing the days already stored for the same region
+// and date. days must not repeat a region and date.
+func (r *CalendarRepository) Save(ctx context.Context, days []*dto.SaveCalendarDayDto) ([]*domain.CalendarDay, error) {
+	fn := "CalendarRepository.Save"
+	logger := slog.With(slog.String("fn", fn), slog.Int("days", len(days)))
+
+	saved := make([]*domain.CalendarDay, 0, len(days))
+	if len(days) == 0 {
+		return saved, nil
+	}
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Insert(CALENDAR_DAYS_TABLE).
+		Columns("id", "org_id", "region", "date", "name", "kind", "as_weekday").
+		Suffix(`ON CONFLICT ("org_id", "region", "date") DO UPDATE SET
+			"name" = EXCLUDED."name",
+			"kind" = EXCLUDED."kind",
+			"as_weekday" = EXCLUDED."as_weekday"
+		RETURNING *`).
+		PlaceholderFormat(sq.Dollar)
+
+	for _, d := range days {
+		builder = builder.Values(uuid.New().String(), orgId, d.Region, domain.DayOf(d.Date), d.Name, d.Kind, d.AsWeekday)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	if err := r.db.SelectContext(ctx, &saved, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+func (r *CalendarRepository) ReadMany(ctx context.Context, f *filters.Calendar) ([]*domain.CalendarDay, error) {
+	fn := "CalendarRepository.ReadMany"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("*").
+		From(CALENDAR_DAYS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("date ASC", "region ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.Regions != nil {
+		builder = builder.Where(sq.Eq{"region": f.Regions})
+	}
+
+	if f.From != nil {
+		builder = builder.Where(sq.GtOrEq{"date": domain.DayOf(*f.From)})
+	}
+
+	if f.To != nil {
+		builder = builder.Where(sq.LtOrEq{"date": domain.DayOf(*f.To)})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	days := make([]*domain.CalendarDay, 0)
+	if err := r.db.SelectContext(ctx, &days, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return days, nil
+}
+
+func (r *CalendarRepository) Delete(ctx context.Context, id string) error {
+	fn := "CalendarRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(CALENDAR_DAYS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrCalendarDayNotFound
+	}
+
+	return nil
+}
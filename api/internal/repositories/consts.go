@@ -1,6 +1,34 @@
 package repositories
 
 const (
-	USERS_TABLE    = "users"
-	ACTIVITY_TABLE = "activity"
+	USERS_TABLE                     = "users"
+	ACTIVITY_TABLE                  = "activity"
+	OUTBOX_TABLE                    = "outbox"
+	WEBHOOKS_TABLE                  = "webhooks"
+	WEBHOOK_DELIVERIES_TABLE        = "webhook_deliveries"
+	WEBHOOK_DELIVERY_ATTEMPTS_TABLE = "webhook_delivery_attempts"
+	API_KEYS_TABLE                  = "api_keys"
+	ORGANIZATIONS_TABLE             = "organizations"
+	TEAMS_TABLE                     = "teams"
+	TEAM_MEMBERS_TABLE              = "team_members"
+	AUDIT_LOG_TABLE                 = "audit_log"
+	TIMESHEETS_TABLE                = "timesheets"
+	SCHEDULES_TABLE                 = "schedules"
+	PAY_RULES_TABLE                 = "pay_rules"
+	LEAVE_TYPES_TABLE               = "leave_types"
+	LEAVE_BALANCES_TABLE            = "leave_balances"
+	LEAVE_REQUESTS_TABLE            = "leave_requests"
+	CALENDAR_DAYS_TABLE             = "calendar_days"
+	CLIENTS_TABLE                   = "clients"
+	PROJECTS_TABLE                  = "projects"
+	PROJECT_RATES_TABLE             = "project_rates"
+	TASKS_TABLE                     = "tasks"
+	TASK_ASSIGNEES_TABLE            = "task_assignees"
+	INVOICES_TABLE                  = "invoices"
+	INVOICE_LINES_TABLE             = "invoice_lines"
+	BUDGETS_TABLE                   = "budgets"
+	TAGS_TABLE                      = "tags"
+	ACTIVITY_TAGS_TABLE             = "activity_tags"
+	FEEDS_TABLE                     = "feeds"
+	SCHEMA_MIGRATIONS_TABLE         = "schema_migrations"
 )
@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// var _ services.FeedRepository = (*FeedRepository)(nil)
+
+type FeedRepository struct {
+	db *sqlx.DB
+}
+
+func NewFeedRepository(db *sqlx.DB) *FeedRepository {
+	return &FeedRepository{db: db}
+}
+
+// Save creates the feed of a user or replaces its token, which stops the
+// previous token from working.
+func (r *FeedRepository) Save(ctx context.Context, d *dto.SaveFeedDto) (*domain.Feed, error) {
+	fn := "FeedRepository.Save"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(FEEDS_TABLE).
+		Columns("id", "org_id", "user_id", "prefix", "hash").
+		Values(uuid.New().String(), orgId, d.UserId, d.Prefix, d.Hash).
+		Suffix(`ON CONFLICT ("user_id") DO UPDATE SET
+			prefix = EXCLUDED.prefix,
+			hash = EXCLUDED.hash,
+			created_at = NOW(),
+			last_used_at = NULL
+			RETURNING *`).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var feed domain.Feed
+	if err := r.db.GetContext(ctx, &feed, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &feed, nil
+}
+
+func (r *FeedRepository) Read(ctx context.Context, userId string) (*domain.Feed, error) {
+	fn := "FeedRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(FEEDS_TABLE).
+		Where(sq.Eq{"org_id": orgId, "user_id": userId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var feed domain.Feed
+	if err := r.db.GetContext(ctx, &feed, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrFeedNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &feed, nil
+}
+
+// ReadByHash looks a feed up across all organizations, feed requests carry
+// nothing else to find the organization by.
+func (r *FeedRepository) ReadByHash(ctx context.Context, hash string) (*domain.Feed, error) {
+	fn := "FeedRepository.ReadByHash"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Select("*").
+		From(FEEDS_TABLE).
+		Where(sq.Eq{"hash": hash}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var feed domain.Feed
+	if err := r.db.GetContext(ctx, &feed, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrFeedNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &feed, nil
+}
+
+func (r *FeedRepository) Delete(ctx context.Context, userId string) error {
+	fn := "FeedRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(FEEDS_TABLE).
+		Where(sq.Eq{"org_id": orgId, "user_id": userId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrFeedNotFound
+	}
+
+	return nil
+}
+
+func (r *FeedRepository) TouchLastUsed(ctx context.Context, id string) error {
+	fn := "FeedRepository.TouchLastUsed"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	query, args, err := sq.Update(FEEDS_TABLE).
+		Set("last_used_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	return nil
+}
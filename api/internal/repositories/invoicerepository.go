@@ -0,0 +1,409 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.InvoiceRepository = (*InvoiceRepository)(nil)
+
+type InvoiceRepository struct {
+	db *sqlx.DB
+}
+
+func NewInvoiceRepository(db *sqlx.DB) *InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+func invoiceEvent(status domain.InvoiceStatus) domain.EventType {
+	switch status {
+	case domain.InvoiceIssued:
+		return domain.EventInvoiceIssued
+	case domain.InvoicePaid:
+		return domain.EventInvoicePaid
+	}
+	return domain.EventInvoiceCreated
+}
+
+// lockInvoice reads the invoice matching where for update, it is the
+// before state of audited changes.
+func lockInvoice(ctx context.Context, tx *sqlx.Tx, where sq.Eq) (*domain.Invoice, error) {
+	fn := "lockInvoice"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Select("*").
+		From(INVOICES_TABLE).
+		Where(where).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	var invoice domain.Invoice
+	if err := tx.GetContext(ctx, &invoice, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrInvoiceNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+// Create drafts an invoice for the finished sessions of the period on the
+// tasks of the billable projects of the client in the currency. Sessions
+// already invoiced are skipped, the others are claimed by the invoice and
+// audited in the same transaction.
+func (r *InvoiceRepository) Create(ctx context.Context, d *dto.CreateInvoiceDto) (*domain.Invoice, error) {
+	fn := "InvoiceRepository.Create"
+	logger := slog.With(slog.String("fn", fn), slog.String("clientId", d.ClientId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(INVOICES_TABLE).
+		Columns("id", "org_id", "client_id", "status", "group_by", "currency", "period_start", "period_end", "created_by").
+		Values(uuid.New().String(), orgId, d.ClientId, domain.InvoiceDraft, d.GroupBy, d.Currency, d.PeriodStart, d.PeriodEnd, d.CreatedBy).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	tasks, taskArgs, err := sq.Select("t.id").
+		From(TASKS_TABLE + " t").
+		Join(PROJECTS_TABLE + " p ON p.id = t.project_id").
+		Where(sq.Eq{"p.org_id": orgId, "p.client_id": d.ClientId, "p.billable": true, "p.currency": d.Currency}).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	var invoice domain.Invoice
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+		if err := tx.GetContext(ctx, &invoice, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+				return domain.ErrClientNotFound
+			}
+			return err
+		}
+
+		// the period ends with the last day billed
+		claim, claimArgs, err := sq.Update(ACTIVITY_TABLE).
+			Set("invoice_id", invoice.Id).
+			Where(sq.Eq{"org_id": orgId, "invoice_id": nil}).
+			Where(sq.NotEq{"end_time": nil}).
+			Where(sq.GtOrEq{"start_time": d.PeriodStart}).
+			Where(sq.LtOrEq{"end_time": d.PeriodEnd.AddDate(0, 0, 1)}).
+			Where("task_id IN ("+tasks+")", taskArgs...).
+			Suffix("RETURNING id, user_id, task_id, start_time, end_time, invoice_id, note, " + sessionTagIds(ACTIVITY_TABLE)).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			logger.Error("failed to build sql", slog.String("err", err.Error()))
+			return err
+		}
+
+		logger.Debug("executing query", slog.String("sql", claim), slog.Any("args", claimArgs))
+
+		var claimed []sessionRow
+		if err := tx.SelectContext(ctx, &claimed, claim, claimArgs...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if len(claimed) == 0 {
+			return domain.ErrNothingToInvoice
+		}
+
+		// a claimed session can no longer be edited, each claim is a change
+		// of the session
+		for _, row := range claimed {
+			session := row.toDomain()
+			before := *session
+			before.InvoiceId = nil
+			if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityActivity, strconv.FormatInt(session.Id, 10), &before, session); err != nil {
+				return err
+			}
+		}
+
+		items, err := billableItems(ctx, tx, invoice.Id)
+		if err != nil {
+			return err
+		}
+
+		invoice.Bill(items)
+
+		lines := sq.Insert(INVOICE_LINES_TABLE).
+			Columns("invoice_id", "position", "project_id", "task_id", "user_id", "description", "duration", "session_count", "hourly_rate", "amount").
+			PlaceholderFormat(sq.Dollar)
+		for _, line := range invoice.Lines {
+			lines = lines.Values(invoice.Id, line.Position, line.ProjectId, line.TaskId, line.UserId, line.Description, int64(line.Duration), line.SessionCount, line.HourlyRate, line.Amount)
+		}
+
+		linesQuery, linesArgs, err := lines.ToSql()
+		if err != nil {
+			logger.Error("failed to build sql", slog.String("err", err.Error()))
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, linesQuery, linesArgs...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		totals, totalsArgs, err := sq.Update(INVOICES_TABLE).
+			Set("total_time", int64(invoice.TotalTime)).
+			Set("amount", invoice.Amount).
+			Where(sq.Eq{"id": invoice.Id}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			logger.Error("failed to build sql", slog.String("err", err.Error()))
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, totals, totalsArgs...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditCreate, domain.AuditEntityInvoice, invoice.Id, nil, &invoice); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventInvoiceCreated,
+			OrgId:      orgId,
+			OccurredAt: time.Now(),
+			Data:       &invoice,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+// Read returns an invoice with its lines.
+func (r *InvoiceRepository) Read(ctx context.Context, id string) (*domain.Invoice, error) {
+	fn := "InvoiceRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(INVOICES_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var invoice domain.Invoice
+	if err := r.db.GetContext(ctx, &invoice, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrInvoiceNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	query, args, err = sq.Select("*").
+		From(INVOICE_LINES_TABLE).
+		Where(sq.Eq{"invoice_id": id}).
+		OrderBy("position ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	invoice.Lines = make([]*domain.InvoiceLine, 0)
+	if err := r.db.SelectContext(ctx, &invoice.Lines, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+// ReadMany returns invoices without their lines, newest first.
+func (r *InvoiceRepository) ReadMany(ctx context.Context, f *filters.Invoices) ([]*domain.Invoice, error) {
+	fn := "InvoiceRepository.ReadMany"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("*").
+		From(INVOICES_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("created_at DESC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.ClientId != nil {
+		builder = builder.Where(sq.Eq{"client_id": *f.ClientId})
+	}
+
+	if f.Status != nil {
+		builder = builder.Where(sq.Eq{"status": *f.Status})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	invoices := make([]*domain.Invoice, 0)
+	if err := r.db.SelectContext(ctx, &invoices, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return invoices, nil
+}
+
+// SetStatus issues a draft or marks an issued invoice as paid.
+func (r *InvoiceRepository) SetStatus(ctx context.Context, id string, status domain.InvoiceStatus) (*domain.Invoice, error) {
+	fn := "InvoiceRepository.SetStatus"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id), slog.String("status", string(status)))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(INVOICES_TABLE).
+		Set("status", status).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	switch status {
+	case domain.InvoiceIssued:
+		builder = builder.Set("issued_at", sq.Expr("NOW()"))
+	case domain.InvoicePaid:
+		builder = builder.Set("paid_at", sq.Expr("NOW()"))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var invoice domain.Invoice
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := lockInvoice(ctx, tx, sq.Eq{"id": id, "org_id": orgId})
+		if err != nil {
+			return err
+		}
+
+		if !before.Status.CanTransition(status) {
+			return domain.ErrInvoiceTransition
+		}
+
+		if err := tx.GetContext(ctx, &invoice, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityInvoice, invoice.Id, before, &invoice); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       invoiceEvent(invoice.Status),
+			OrgId:      orgId,
+			OccurredAt: time.Now(),
+			Data:       &invoice,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+// Delete removes a draft invoice, its sessions can be edited and invoiced
+// again.
+func (r *InvoiceRepository) Delete(ctx context.Context, id string) error {
+	fn := "InvoiceRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(INVOICES_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	return withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := lockInvoice(ctx, tx, sq.Eq{"id": id, "org_id": orgId})
+		if err != nil {
+			return err
+		}
+
+		if before.Status != domain.InvoiceDraft {
+			return domain.ErrInvoiceNotDraft
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		return recordAudit(ctx, tx, domain.AuditDelete, domain.AuditEntityInvoice, id, before, nil)
+	})
+}
@@ -0,0 +1,662 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.LeaveRepository = (*LeaveRepository)(nil)
+
+type LeaveRepository struct {
+	db *sqlx.DB
+}
+
+func NewLeaveRepository(db *sqlx.DB) *LeaveRepository {
+	return &LeaveRepository{db: db}
+}
+
+func leaveEvent(status domain.LeaveStatus) domain.EventType {
+	switch status {
+	case domain.LeaveApproved:
+		return domain.EventLeaveApproved
+	case domain.LeaveRejected:
+		return domain.EventLeaveRejected
+	case domain.LeaveCancelled:
+		return domain.EventLeaveCancelled
+	}
+	return domain.EventLeaveRequested
+}
+
+// lockLeave reads the leave request matching where for update, it is the
+// before state of audited changes.
+func lockLeave(ctx context.Context, tx *sqlx.Tx, where sq.Eq) (*domain.LeaveRequest, error) {
+	fn := "lockLeave"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Select("*").
+		From(LEAVE_REQUESTS_TABLE).
+		Where(where).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	var request domain.LeaveRequest
+	if err := tx.GetContext(ctx, &request, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrLeaveNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// remainingDays returns the allowance of userId for a leave type in year
+// less the days of their requests in statuses, leaving out request
+// excludeId.
+func remainingDays(ctx context.Context, tx *sqlx.Tx, userId, leaveTypeId string, year int, statuses []domain.LeaveStatus, excludeId string) (int, error) {
+	fn := "remainingDays"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	query, args, err := sq.Select().
+		Column(sq.Expr(
+			"COALESCE((SELECT allowance_days FROM "+LEAVE_BALANCES_TABLE+" WHERE user_id = ? AND leave_type_id = ? AND year = ?), 0)",
+			userId, leaveTypeId, year,
+		)).
+		Column(sq.Expr(
+			"COALESCE((SELECT SUM(days) FROM "+LEAVE_REQUESTS_TABLE+
+				" WHERE user_id = ? AND leave_type_id = ? AND EXTRACT(YEAR FROM start_date) = ? AND status = ANY(?) AND id <> ?), 0)",
+			userId, leaveTypeId, year, pq.Array(statuses), excludeId,
+		)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return 0, err
+	}
+
+	var allowance, taken int
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&allowance, &taken); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return 0, err
+	}
+
+	return allowance - taken, nil
+}
+
+func (r *LeaveRepository) CreateType(ctx context.Context, d *dto.SaveLeaveTypeDto) (*domain.LeaveType, error) {
+	fn := "LeaveRepository.CreateType"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(LEAVE_TYPES_TABLE).
+		Columns("id", "org_id", "name", "paid", "requires_balance").
+		Values(uuid.New().String(), orgId, d.Name, d.Paid, d.RequiresBalance).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var leaveType domain.LeaveType
+	if err := r.db.GetContext(ctx, &leaveType, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if e, ok := err.(*pq.Error); ok && e.Code == "23505" {
+			return nil, domain.ErrInvalidLeaveType
+		}
+		return nil, err
+	}
+
+	return &leaveType, nil
+}
+
+func (r *LeaveRepository) ReadType(ctx context.Context, id string) (*domain.LeaveType, error) {
+	fn := "LeaveRepository.ReadType"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(LEAVE_TYPES_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var leaveType domain.LeaveType
+	if err := r.db.GetContext(ctx, &leaveType, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrLeaveTypeNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &leaveType, nil
+}
+
+func (r *LeaveRepository) ReadTypes(ctx context.Context) ([]*domain.LeaveType, error) {
+	fn := "LeaveRepository.ReadTypes"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(LEAVE_TYPES_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("name ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	types := make([]*domain.LeaveType, 0)
+	if err := r.db.SelectContext(ctx, &types, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return types, nil
+}
+
+func (r *LeaveRepository) UpdateType(ctx context.Context, id string, d *dto.UpdateLeaveTypeDto) (*domain.LeaveType, error) {
+	fn := "LeaveRepository.UpdateType"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if d.Name == nil && d.Paid == nil && d.RequiresBalance == nil {
+		return r.ReadType(ctx, id)
+	}
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(LEAVE_TYPES_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if d.Name != nil {
+		builder = builder.Set("name", *d.Name)
+	}
+
+	if d.Paid != nil {
+		builder = builder.Set("paid", *d.Paid)
+	}
+
+	if d.RequiresBalance != nil {
+		builder = builder.Set("requires_balance", *d.RequiresBalance)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var leaveType domain.LeaveType
+	if err := r.db.GetContext(ctx, &leaveType, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrLeaveTypeNotFound
+		}
+		if e, ok := err.(*pq.Error); ok && e.Code == "23505" {
+			return nil, domain.ErrInvalidLeaveType
+		}
+		return nil, err
+	}
+
+	return &leaveType, nil
+}
+
+// DeleteType removes a leave type that was never requested.
+func (r *LeaveRepository) DeleteType(ctx context.Context, id string) error {
+	fn := "LeaveRepository.DeleteType"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(LEAVE_TYPES_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+			return domain.ErrLeaveTypeInUse
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrLeaveTypeNotFound
+	}
+
+	return nil
+}
+
+// SetAllowance sets the days of a leave type a user may take in a year.
+func (r *LeaveRepository) SetAllowance(ctx context.Context, d *dto.SetAllowanceDto) error {
+	fn := "LeaveRepository.SetAllowance"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	// selecting the pair guards against mixing users and leave types of
+	// different organizations
+	query, args, err := sq.Insert(LEAVE_BALANCES_TABLE).
+		Columns("user_id", "leave_type_id", "year", "allowance_days").
+		Select(sq.Select("u.id", "t.id").
+			Column(sq.Expr("?::INTEGER", d.Year)).
+			Column(sq.Expr("?::INTEGER", d.AllowanceDays)).
+			From(USERS_TABLE + " u").
+			Join(LEAVE_TYPES_TABLE + " t ON t.org_id = u.org_id").
+			Where(sq.Eq{"u.id": d.UserId, "u.org_id": orgId, "t.id": d.LeaveTypeId})).
+		Suffix("ON CONFLICT (user_id, leave_type_id, year) DO UPDATE SET allowance_days = EXCLUDED.allowance_days").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// the leave type is checked before, so it is the user that is missing
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ReadBalances returns the balance of every leave type of the organization
+// for userId in year.
+func (r *LeaveRepository) ReadBalances(ctx context.Context, userId string, year int) ([]*domain.LeaveBalance, error) {
+	fn := "LeaveRepository.ReadBalances"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := "COALESCE((SELECT SUM(r.days) FROM " + LEAVE_REQUESTS_TABLE + " r" +
+		" WHERE r.user_id = ? AND r.leave_type_id = t.id AND EXTRACT(YEAR FROM r.start_date) = ? AND r.status = ?), 0)"
+
+	query, args, err := sq.Select(
+		"t.id AS leave_type_id",
+		"t.name AS leave_type",
+		"t.requires_balance",
+		"COALESCE(b.allowance_days, 0) AS allowance_days",
+	).
+		Column(sq.Expr("?::INTEGER AS year", year)).
+		Column(sq.Expr(taken+" AS used_days", userId, year, domain.LeaveApproved)).
+		Column(sq.Expr(taken+" AS pending_days", userId, year, domain.LeavePending)).
+		From(LEAVE_TYPES_TABLE+" t").
+		LeftJoin(LEAVE_BALANCES_TABLE+" b ON b.leave_type_id = t.id AND b.user_id = ? AND b.year = ?", userId, year).
+		Where(sq.Eq{"t.org_id": orgId}).
+		OrderBy("t.name ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	balances := make([]*domain.LeaveBalance, 0)
+	if err := r.db.SelectContext(ctx, &balances, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	for _, b := range balances {
+		b.RemainingDays = b.AllowanceDays - b.UsedDays - b.PendingDays
+	}
+
+	return balances, nil
+}
+
+// Request files a pending leave request. It fails when the user already
+// asked for any of the days, or when the request requires a balance the
+// user does not have left.
+func (r *LeaveRepository) Request(ctx context.Context, d *dto.RequestLeaveDto) (*domain.LeaveRequest, error) {
+	fn := "LeaveRepository.Request"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	overlapQuery, overlapArgs, err := sq.Select("COUNT(*) > 0").
+		From(LEAVE_REQUESTS_TABLE).
+		Where(sq.Eq{"user_id": d.UserId, "status": []domain.LeaveStatus{domain.LeavePending, domain.LeaveApproved}}).
+		Where(sq.LtOrEq{"start_date": d.EndDate}).
+		Where(sq.GtOrEq{"end_date": d.StartDate}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(LEAVE_REQUESTS_TABLE).
+		Columns("id", "org_id", "user_id", "leave_type_id", "start_date", "end_date", "days", "status", "reason").
+		Values(uuid.New().String(), orgId, d.UserId, d.LeaveTypeId, d.StartDate, d.EndDate, d.Days, domain.LeavePending, d.Reason).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var request domain.LeaveRequest
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		// locking the user serializes the checks below against other
+		// requests of the same user
+		if _, err := lockUser(ctx, tx, orgId, d.UserId); err != nil {
+			return err
+		}
+
+		var overlaps bool
+		if err := tx.GetContext(ctx, &overlaps, overlapQuery, overlapArgs...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+		if overlaps {
+			return domain.ErrLeaveOverlap
+		}
+
+		if d.RequiresBalance {
+			remaining, err := remainingDays(ctx, tx, d.UserId, d.LeaveTypeId, d.StartDate.Year(),
+				[]domain.LeaveStatus{domain.LeavePending, domain.LeaveApproved}, "")
+			if err != nil {
+				return err
+			}
+			if remaining < d.Days {
+				return domain.ErrInsufficientBalance
+			}
+		}
+
+		if err := tx.GetContext(ctx, &request, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditCreate, domain.AuditEntityLeave, request.Id, nil, &request); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventLeaveRequested,
+			OrgId:      orgId,
+			UserId:     request.UserId,
+			OccurredAt: time.Now(),
+			Data:       &request,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// Review moves a leave request to d.Status. Approving checks the balance
+// once more, as allowances may have changed since the request was filed.
+func (r *LeaveRepository) Review(ctx context.Context, d *dto.ReviewLeaveDto) (*domain.LeaveRequest, error) {
+	fn := "LeaveRepository.Review"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", d.Id), slog.String("status", string(d.Status)))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(LEAVE_REQUESTS_TABLE).
+		Set("status", d.Status).
+		Where(sq.Eq{"id": d.Id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if d.ReviewerId != nil {
+		builder = builder.
+			Set("comment", d.Comment).
+			Set("reviewed_by", *d.ReviewerId).
+			Set("reviewed_at", sq.Expr("NOW()"))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var request domain.LeaveRequest
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := lockLeave(ctx, tx, sq.Eq{"id": d.Id, "org_id": orgId})
+		if err != nil {
+			return err
+		}
+
+		if !before.Status.CanTransition(d.Status) {
+			return domain.ErrInvalidTransition
+		}
+
+		if d.Status == domain.LeaveApproved {
+			if _, err := lockUser(ctx, tx, orgId, before.UserId); err != nil {
+				return err
+			}
+
+			var requiresBalance bool
+			if err := tx.GetContext(ctx, &requiresBalance,
+				"SELECT requires_balance FROM "+LEAVE_TYPES_TABLE+" WHERE id = $1", before.LeaveTypeId,
+			); err != nil {
+				logger.Error("failed to execute query", slog.String("err", err.Error()))
+				return err
+			}
+
+			if requiresBalance {
+				remaining, err := remainingDays(ctx, tx, before.UserId, before.LeaveTypeId, before.StartDate.Year(),
+					[]domain.LeaveStatus{domain.LeaveApproved}, before.Id)
+				if err != nil {
+					return err
+				}
+				if remaining < before.Days {
+					return domain.ErrInsufficientBalance
+				}
+			}
+		}
+
+		if err := tx.GetContext(ctx, &request, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityLeave, request.Id, before, &request); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       leaveEvent(request.Status),
+			OrgId:      orgId,
+			UserId:     request.UserId,
+			OccurredAt: time.Now(),
+			Data:       &request,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func (r *LeaveRepository) Read(ctx context.Context, id string) (*domain.LeaveRequest, error) {
+	fn := "LeaveRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(LEAVE_REQUESTS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var request domain.LeaveRequest
+	if err := r.db.GetContext(ctx, &request, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrLeaveNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func (r *LeaveRepository) ReadMany(ctx context.Context, f *filters.Leave) ([]*domain.LeaveRequest, error) {
+	fn := "LeaveRepository.ReadMany"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("*").
+		From(LEAVE_REQUESTS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("start_date DESC", "user_id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.UserId != nil {
+		builder = builder.Where(sq.Eq{"user_id": *f.UserId})
+	}
+
+	if f.UserIds != nil {
+		builder = builder.Where(sq.Eq{"user_id": f.UserIds})
+	}
+
+	if f.TeamId != nil {
+		builder = builder.Where(inTeam("user_id", orgId, *f.TeamId))
+	}
+
+	if f.Status != nil {
+		builder = builder.Where(sq.Eq{"status": *f.Status})
+	}
+
+	if f.From != nil {
+		builder = builder.Where(sq.GtOrEq{"end_date": *f.From})
+	}
+
+	if f.To != nil {
+		builder = builder.Where(sq.LtOrEq{"start_date": *f.To})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	requests := make([]*domain.LeaveRequest, 0)
+	if err := r.db.SelectContext(ctx, &requests, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return requests, nil
+}
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// var _ services.OrganizationRepository = (*OrganizationRepository)(nil)
+
+type OrganizationRepository struct {
+	db *sqlx.DB
+}
+
+func NewOrganizationRepository(db *sqlx.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+func (r *OrganizationRepository) Create(ctx context.Context, d *dto.SaveOrganizationDto) (*domain.Organization, error) {
+	fn := "OrganizationRepository.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Insert(ORGANIZATIONS_TABLE).
+		Columns("id", "name").
+		Values(uuid.New().String(), d.Name).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var org domain.Organization
+	if err := r.db.GetContext(ctx, &org, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (r *OrganizationRepository) Read(ctx context.Context, id string) (*domain.Organization, error) {
+	fn := "OrganizationRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	query, args, err := sq.Select("*").
+		From(ORGANIZATIONS_TABLE).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var org domain.Organization
+	if err := r.db.GetContext(ctx, &org, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrOrgNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (r *OrganizationRepository) ReadAll(ctx context.Context) ([]*domain.Organization, error) {
+	fn := "OrganizationRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Select("*").
+		From(ORGANIZATIONS_TABLE).
+		OrderBy("created_at ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	orgs := make([]*domain.Organization, 0)
+	if err := r.db.SelectContext(ctx, &orgs, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// Update changes the settings of d that are set.
+func (r *OrganizationRepository) Update(ctx context.Context, id string, d *dto.UpdateOrganizationDto) (*domain.Organization, error) {
+	fn := "OrganizationRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if d.TimerPolicy == nil {
+		return r.Read(ctx, id)
+	}
+
+	query, args, err := sq.Update(ORGANIZATIONS_TABLE).
+		Set("timer_policy", *d.TimerPolicy).
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var org domain.Organization
+	if err := r.db.GetContext(ctx, &org, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrOrgNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &org, nil
+}
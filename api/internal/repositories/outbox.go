@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"em-test/internal/domain"
+	"encoding/json"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// enqueueEvent writes e to the transactional outbox. It must run in the
+// same transaction as the change the event describes.
+func enqueueEvent(tx *sqlx.Tx, e *domain.Event) error {
+	fn := "enqueueEvent"
+	logger := slog.With(slog.String("fn", fn), slog.String("type", string(e.Type)))
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		logger.Error("failed to marshal event", slog.String("err", err.Error()))
+		return err
+	}
+
+	query, args, err := sq.Insert(OUTBOX_TABLE).
+		Columns("org_id", "event_type", "payload").
+		Values(e.OrgId, e.Type, payload).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.PayRulesRepository = (*PayRulesRepository)(nil)
+
+// payRulesUpsert overwrites every rule of the conflicting row.
+const payRulesUpsert = `DO UPDATE SET
+	rounding_mode = EXCLUDED.rounding_mode,
+	rounding_minutes = EXCLUDED.rounding_minutes,
+	min_session_minutes = EXCLUDED.min_session_minutes,
+	daily_overtime_minutes = EXCLUDED.daily_overtime_minutes,
+	daily_multiplier = EXCLUDED.daily_multiplier,
+	weekly_overtime_minutes = EXCLUDED.weekly_overtime_minutes,
+	weekly_multiplier = EXCLUDED.weekly_multiplier,
+	timezone = EXCLUDED.timezone,
+	updated_at = NOW()
+RETURNING *`
+
+type PayRulesRepository struct {
+	db *sqlx.DB
+}
+
+func NewPayRulesRepository(db *sqlx.DB) *PayRulesRepository {
+	return &PayRulesRepository{db: db}
+}
+
+func (r *PayRulesRepository) ReadAll(ctx context.Context) ([]*domain.PayRules, error) {
+	fn := "PayRulesRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(PAY_RULES_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("team_id ASC NULLS FIRST").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	rules := make([]*domain.PayRules, 0)
+	if err := r.db.SelectContext(ctx, &rules, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Save creates or replaces the rules of d.TeamId, or of the organization
+// when it is nil.
+func (r *PayRulesRepository) Save(ctx context.Context, d *dto.SavePayRulesDto) (*domain.PayRules, error) {
+	fn := "PayRulesRepository.Save"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conflict := `ON CONFLICT ("org_id") WHERE "team_id" IS NULL `
+	if d.TeamId != nil {
+		conflict = `ON CONFLICT ("team_id") WHERE "team_id" IS NOT NULL `
+	}
+
+	rules := d.Rules
+	query, args, err := sq.Insert(PAY_RULES_TABLE).
+		Columns(
+			"id", "org_id", "team_id", "rounding_mode", "rounding_minutes", "min_session_minutes",
+			"daily_overtime_minutes", "daily_multiplier", "weekly_overtime_minutes", "weekly_multiplier", "timezone",
+		).
+		Values(
+			uuid.New().String(), orgId, d.TeamId, rules.RoundingMode, rules.RoundingMinutes, rules.MinSessionMinutes,
+			rules.DailyOvertimeMinutes, rules.DailyMultiplier, rules.WeeklyOvertimeMinutes, rules.WeeklyMultiplier, rules.Timezone,
+		).
+		Suffix(conflict + payRulesUpsert).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var saved domain.PayRules
+	if err := r.db.GetContext(ctx, &saved, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+			return nil, domain.ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+// Delete removes the rules of teamId, or the organization wide rules when
+// it is nil.
+func (r *PayRulesRepository) Delete(ctx context.Context, teamId *string) error {
+	fn := "PayRulesRepository.Delete"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(PAY_RULES_TABLE).
+		Where(sq.Eq{"org_id": orgId, "team_id": teamId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrPayRulesNotFound
+	}
+
+	return nil
+}
+
+// ReadEffective returns the rules that apply to userId: the ones of the
+// nearest team up the tree from the teams they are a member of, or else
+// the organization wide rules.
+func (r *PayRulesRepository) ReadEffective(ctx context.Context, userId string) (*domain.PayRules, error) {
+	fn := "PayRulesRepository.ReadEffective"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("r.*").
+		Prefix(teamAncestorsCTE, userId, orgId).
+		From(PAY_RULES_TABLE+" r").
+		LeftJoin("ancestors a ON a.id = r.team_id").
+		Where(sq.Eq{"r.org_id": orgId}).
+		Where(sq.Or{sq.Eq{"r.team_id": nil}, sq.NotEq{"a.id": nil}}).
+		OrderBy("a.depth ASC NULLS LAST", "r.team_id ASC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rules domain.PayRules
+	if err := r.db.GetContext(ctx, &rules, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrPayRulesNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &rules, nil
+}
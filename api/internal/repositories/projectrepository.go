@@ -0,0 +1,592 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.ProjectRepository = (*ProjectRepository)(nil)
+
+type ProjectRepository struct {
+	db *sqlx.DB
+}
+
+func NewProjectRepository(db *sqlx.DB) *ProjectRepository {
+	return &ProjectRepository{db: db}
+}
+
+// projectError maps constraint violations of clients and projects.
+func projectError(err error, unique, foreignKey error) error {
+	if e, ok := err.(*pq.Error); ok {
+		switch e.Code {
+		case "23505":
+			return unique
+		case "23503":
+			return foreignKey
+		}
+	}
+	return err
+}
+
+func (r *ProjectRepository) CreateClient(ctx context.Context, d *dto.SaveClientDto) (*domain.Client, error) {
+	fn := "ProjectRepository.CreateClient"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(CLIENTS_TABLE).
+		Columns("id", "org_id", "name").
+		Values(uuid.New().String(), orgId, d.Name).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var client domain.Client
+	if err := r.db.GetContext(ctx, &client, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, projectError(err, domain.ErrInvalidClient, err)
+	}
+
+	return &client, nil
+}
+
+func (r *ProjectRepository) ReadClient(ctx context.Context, id string) (*domain.Client, error) {
+	fn := "ProjectRepository.ReadClient"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(CLIENTS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var client domain.Client
+	if err := r.db.GetContext(ctx, &client, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrClientNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+func (r *ProjectRepository) ReadClients(ctx context.Context) ([]*domain.Client, error) {
+	fn := "ProjectRepository.ReadClients"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(CLIENTS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("name ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	clients := make([]*domain.Client, 0)
+	if err := r.db.SelectContext(ctx, &clients, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+func (r *ProjectRepository) UpdateClient(ctx context.Context, id string, d *dto.SaveClientDto) (*domain.Client, error) {
+	fn := "ProjectRepository.UpdateClient"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Update(CLIENTS_TABLE).
+		Set("name", d.Name).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var client domain.Client
+	if err := r.db.GetContext(ctx, &client, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrClientNotFound
+		}
+		return nil, projectError(err, domain.ErrInvalidClient, err)
+	}
+
+	return &client, nil
+}
+
+// DeleteClient removes a client without projects.
+func (r *ProjectRepository) DeleteClient(ctx context.Context, id string) error {
+	fn := "ProjectRepository.DeleteClient"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(CLIENTS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return projectError(err, err, domain.ErrClientInUse)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrClientNotFound
+	}
+
+	return nil
+}
+
+func (r *ProjectRepository) Create(ctx context.Context, d *dto.SaveProjectDto) (*domain.Project, error) {
+	fn := "ProjectRepository.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(PROJECTS_TABLE).
+		Columns("id", "org_id", "client_id", "name", "billable", "hourly_rate", "currency").
+		Values(uuid.New().String(), orgId, d.ClientId, d.Name, d.Billable, d.HourlyRate, d.Currency).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var project domain.Project
+	if err := r.db.GetContext(ctx, &project, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, projectError(err, domain.ErrInvalidProject, domain.ErrClientNotFound)
+	}
+
+	return &project, nil
+}
+
+func (r *ProjectRepository) Read(ctx context.Context, id string) (*domain.Project, error) {
+	fn := "ProjectRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(PROJECTS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var project domain.Project
+	if err := r.db.GetContext(ctx, &project, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrProjectNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &project, nil
+}
+
+func (r *ProjectRepository) ReadAll(ctx context.Context, f *filters.Projects) ([]*domain.Project, error) {
+	fn := "ProjectRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("*").
+		From(PROJECTS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("name ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.ClientId != nil {
+		builder = builder.Where(sq.Eq{"client_id": *f.ClientId})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	projects := make([]*domain.Project, 0)
+	if err := r.db.SelectContext(ctx, &projects, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+func (r *ProjectRepository) Update(ctx context.Context, id string, d *dto.UpdateProjectDto) (*domain.Project, error) {
+	fn := "ProjectRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if d.ClientId == nil && d.Name == nil && d.Billable == nil && d.HourlyRate == nil && d.Currency == nil {
+		return r.Read(ctx, id)
+	}
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(PROJECTS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if d.ClientId != nil {
+		if *d.ClientId == "" {
+			builder = builder.Set("client_id", nil)
+		} else {
+			builder = builder.Set("client_id", *d.ClientId)
+		}
+	}
+
+	if d.Name != nil {
+		builder = builder.Set("name", *d.Name)
+	}
+
+	if d.Billable != nil {
+		builder = builder.Set("billable", *d.Billable)
+	}
+
+	if d.HourlyRate != nil {
+		builder = builder.Set("hourly_rate", *d.HourlyRate)
+	}
+
+	if d.Currency != nil {
+		builder = builder.Set("currency", *d.Currency)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var project domain.Project
+	if err := r.db.GetContext(ctx, &project, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrProjectNotFound
+		}
+		return nil, projectError(err, domain.ErrInvalidProject, domain.ErrClientNotFound)
+	}
+
+	return &project, nil
+}
+
+// Delete removes a project without tasks.
+func (r *ProjectRepository) Delete(ctx context.Context, id string) error {
+	fn := "ProjectRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(PROJECTS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return projectError(err, err, domain.ErrProjectInUse)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrProjectNotFound
+	}
+
+	return nil
+}
+
+// SetRate overrides the hourly rate of a project for a user.
+func (r *ProjectRepository) SetRate(ctx context.Context, d *dto.SetRateDto) (*domain.ProjectRate, error) {
+	fn := "ProjectRepository.SetRate"
+	logger := slog.With(slog.String("fn", fn), slog.String("projectId", d.ProjectId), slog.String("userId", d.UserId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// selecting the pair guards against mixing users and projects of
+	// different organizations
+	query, args, err := sq.Insert(PROJECT_RATES_TABLE).
+		Columns("project_id", "user_id", "hourly_rate").
+		Select(sq.Select("p.id", "u.id").
+			Column(sq.Expr("?::BIGINT", d.HourlyRate)).
+			From(PROJECTS_TABLE + " p").
+			Join(USERS_TABLE + " u ON u.org_id = p.org_id").
+			Where(sq.Eq{"p.id": d.ProjectId, "p.org_id": orgId, "u.id": d.UserId})).
+		Suffix("ON CONFLICT (project_id, user_id) DO UPDATE SET hourly_rate = EXCLUDED.hourly_rate RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rate domain.ProjectRate
+	if err := r.db.GetContext(ctx, &rate, query, args...); err != nil {
+		// the project is checked before, so it is the user that is missing
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func (r *ProjectRepository) DeleteRate(ctx context.Context, projectId, userId string) error {
+	fn := "ProjectRepository.DeleteRate"
+	logger := slog.With(slog.String("fn", fn), slog.String("projectId", projectId), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(PROJECT_RATES_TABLE).
+		Where(sq.Eq{"project_id": projectId, "user_id": userId}).
+		Where(sq.Expr("project_id IN (SELECT id FROM "+PROJECTS_TABLE+" WHERE org_id = ?)", orgId)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *ProjectRepository) ReadRates(ctx context.Context, projectId string) ([]*domain.ProjectRate, error) {
+	fn := "ProjectRepository.ReadRates"
+	logger := slog.With(slog.String("fn", fn), slog.String("projectId", projectId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("r.*").
+		From(PROJECT_RATES_TABLE + " r").
+		Join(PROJECTS_TABLE + " p ON p.id = r.project_id").
+		Where(sq.Eq{"r.project_id": projectId, "p.org_id": orgId}).
+		OrderBy("r.user_id ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	rates := make([]*domain.ProjectRate, 0)
+	if err := r.db.SelectContext(ctx, &rates, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// ReadTotals totals the finished sessions on the tasks of a project per
+// user. Users without sessions in the period are left out.
+func (r *ProjectRepository) ReadTotals(ctx context.Context, f *filters.ProjectSummary) ([]*domain.ProjectUserTotal, error) {
+	fn := "ProjectRepository.ReadTotals"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select(
+		"a.user_id",
+		"COALESCE(EXTRACT(EPOCH FROM SUM(a.end_time - a.start_time)), 0) AS total_seconds",
+		"COUNT(*) AS total_count",
+	).
+		From(ACTIVITY_TABLE + " a").
+		Join(TASKS_TABLE + " t ON t.id = a.task_id").
+		Where(sq.Eq{"a.org_id": orgId, "t.project_id": f.ProjectId}).
+		Where(sq.NotEq{"a.end_time": nil}).
+		GroupBy("a.user_id").
+		OrderBy("a.user_id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.TaskId != nil {
+		builder = builder.Where(sq.Eq{"t.id": *f.TaskId})
+	}
+
+	if f.UserIds != nil {
+		builder = builder.Where(sq.Eq{"a.user_id": f.UserIds})
+	}
+
+	if f.StartTime != nil {
+		builder = builder.Where(sq.GtOrEq{"a.start_time": f.StartTime})
+	}
+
+	if f.EndTime != nil {
+		builder = builder.Where(sq.LtOrEq{"a.end_time": f.EndTime})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []struct {
+		UserId       string  `db:"user_id"`
+		TotalSeconds float64 `db:"total_seconds"`
+		TotalCount   int     `db:"total_count"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	totals := make([]*domain.ProjectUserTotal, 0, len(rows))
+	for _, row := range rows {
+		totals = append(totals, &domain.ProjectUserTotal{
+			UserId:     row.UserId,
+			TotalTime:  time.Duration(row.TotalSeconds * float64(time.Second)),
+			TotalCount: row.TotalCount,
+		})
+	}
+
+	return totals, nil
+}
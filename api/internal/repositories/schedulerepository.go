@@ -0,0 +1,415 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// var _ services.ScheduleRepository = (*ScheduleRepository)(nil)
+
+// teamChainCTE walks up the team tree from every team userId is a member
+// of, stopping at the first team of each branch that has a schedule. depth
+// is the distance from the team the user is a member of.
+const teamChainCTE = `WITH RECURSIVE chain AS (
+	SELECT t.id, t.parent_id, t.schedule_id, 1 AS depth
+	FROM teams t JOIN team_members m ON m.team_id = t.id
+	WHERE m.user_id = ? AND t.org_id = ?
+	UNION ALL
+	SELECT p.id, p.parent_id, p.schedule_id, c.depth + 1
+	FROM teams p JOIN chain c ON p.id = c.parent_id
+	WHERE c.schedule_id IS NULL AND c.depth < 64
+)`
+
+type scheduleRow struct {
+	domain.Schedule
+	Days []byte `db:"days"`
+}
+
+func (r *scheduleRow) toDomain() (*domain.Schedule, error) {
+	s := r.Schedule
+	s.Days = make([]domain.ScheduleDay, 0)
+	if err := json.Unmarshal(r.Days, &s.Days); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+type ScheduleRepository struct {
+	db *sqlx.DB
+}
+
+func NewScheduleRepository(db *sqlx.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+func (r *ScheduleRepository) get(ctx context.Context, query string, args []any) (*domain.Schedule, error) {
+	var row scheduleRow
+	if err := r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	return row.toDomain()
+}
+
+func (r *ScheduleRepository) Create(ctx context.Context, d *dto.SaveScheduleDto) (*domain.Schedule, error) {
+	fn := "ScheduleRepository.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := json.Marshal(d.Days)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(SCHEDULES_TABLE).
+		Columns("id", "org_id", "name", "timezone", "region", "grace_minutes", "days").
+		Values(uuid.New().String(), orgId, d.Name, d.Timezone, d.Region, d.GraceMinutes, string(days)).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	schedule, err := r.get(ctx, query, args)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (r *ScheduleRepository) Read(ctx context.Context, id string) (*domain.Schedule, error) {
+	fn := "ScheduleRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(SCHEDULES_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	schedule, err := r.get(ctx, query, args)
+	if err != nil && !errors.Is(err, domain.ErrScheduleNotFound) {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+	}
+
+	return schedule, err
+}
+
+func (r *ScheduleRepository) ReadAll(ctx context.Context) ([]*domain.Schedule, error) {
+	fn := "ScheduleRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(SCHEDULES_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("created_at ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var rows []scheduleRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	schedules := make([]*domain.Schedule, 0, len(rows))
+	for i := range rows {
+		schedule, err := rows[i].toDomain()
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+func (r *ScheduleRepository) Update(ctx context.Context, id string, d *dto.UpdateScheduleDto) (*domain.Schedule, error) {
+	fn := "ScheduleRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if d.Name == nil && d.Timezone == nil && d.Region == nil && d.GraceMinutes == nil && d.Days == nil {
+		return r.Read(ctx, id)
+	}
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(SCHEDULES_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if d.Name != nil {
+		builder = builder.Set("name", *d.Name)
+	}
+
+	if d.Timezone != nil {
+		builder = builder.Set("timezone", *d.Timezone)
+	}
+
+	if d.Region != nil {
+		builder = builder.Set("region", *d.Region)
+	}
+
+	if d.GraceMinutes != nil {
+		builder = builder.Set("grace_minutes", *d.GraceMinutes)
+	}
+
+	if d.Days != nil {
+		days, err := json.Marshal(d.Days)
+		if err != nil {
+			return nil, err
+		}
+		builder = builder.Set("days", string(days))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	schedule, err := r.get(ctx, query, args)
+	if err != nil && !errors.Is(err, domain.ErrScheduleNotFound) {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+	}
+
+	return schedule, err
+}
+
+// Delete removes a schedule, its users and teams are left without one.
+func (r *ScheduleRepository) Delete(ctx context.Context, id string) error {
+	fn := "ScheduleRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(SCHEDULES_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+// AssignUser sets the schedule of a user. A nil scheduleId clears the
+// schedule when it is currently from, the user then falls back to the
+// schedule of their teams.
+func (r *ScheduleRepository) AssignUser(ctx context.Context, userId string, scheduleId *string, from string) (*domain.User, error) {
+	fn := "ScheduleRepository.AssignUser"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(USERS_TABLE).
+		Set("schedule_id", scheduleId).
+		Where(sq.Eq{"id": userId, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if scheduleId == nil {
+		builder = builder.Where(sq.Eq{"schedule_id": from})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var user domain.User
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := lockUser(ctx, tx, orgId, userId)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.GetContext(ctx, &user, query, args...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.ErrScheduleNotAssigned
+			}
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityUser, user.Id, before, &user); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventUserUpdated,
+			OrgId:      orgId,
+			UserId:     user.Id,
+			OccurredAt: time.Now(),
+			Data:       user.WithoutPassport(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// AssignTeam sets the schedule of a team, members and descendant teams
+// without a schedule of their own inherit it. A nil scheduleId clears the
+// schedule when it is currently from.
+func (r *ScheduleRepository) AssignTeam(ctx context.Context, teamId string, scheduleId *string, from string) (*domain.Team, error) {
+	fn := "ScheduleRepository.AssignTeam"
+	logger := slog.With(slog.String("fn", fn), slog.String("teamId", teamId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(TEAMS_TABLE).
+		Set("schedule_id", scheduleId).
+		Where(sq.Eq{"id": teamId, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if scheduleId == nil {
+		builder = builder.Where(sq.Eq{"schedule_id": from})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var team domain.Team
+	if err := r.db.GetContext(ctx, &team, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrScheduleNotAssigned
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// ReadEffective returns the schedule that applies to userId: their own, or
+// else the one of the nearest team up the tree from the teams they are a
+// member of.
+func (r *ScheduleRepository) ReadEffective(ctx context.Context, userId string) (*domain.Schedule, error) {
+	fn := "ScheduleRepository.ReadEffective"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("s.*").
+		Prefix(teamChainCTE, userId, orgId).
+		From(SCHEDULES_TABLE + " s").
+		Where(sq.Eq{"s.org_id": orgId}).
+		Where(sq.Expr(
+			"s.id = COALESCE("+
+				"(SELECT schedule_id FROM "+USERS_TABLE+" WHERE id = ? AND org_id = ?), "+
+				"(SELECT schedule_id FROM chain WHERE schedule_id IS NOT NULL ORDER BY depth ASC, id ASC LIMIT 1))",
+			userId, orgId,
+		)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	schedule, err := r.get(ctx, query, args)
+	if err != nil {
+		if errors.Is(err, domain.ErrScheduleNotFound) {
+			return nil, domain.ErrNoSchedule
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return schedule, nil
+}
@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.TagRepository = (*TagRepository)(nil)
+
+type TagRepository struct {
+	db *sqlx.DB
+}
+
+func NewTagRepository(db *sqlx.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+var errTagNameTaken = fmt.Errorf("%w: name is taken", domain.ErrInvalidTag)
+
+func (r *TagRepository) Create(ctx context.Context, d *dto.SaveTagDto) (*domain.Tag, error) {
+	fn := "TagRepository.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(TAGS_TABLE).
+		Columns("id", "org_id", "name").
+		Values(uuid.New().String(), orgId, d.Name).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var tag domain.Tag
+	if err := r.db.GetContext(ctx, &tag, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if e, ok := err.(*pq.Error); ok && e.Code == "23505" {
+			return nil, errTagNameTaken
+		}
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+func (r *TagRepository) ReadAll(ctx context.Context) ([]*domain.Tag, error) {
+	fn := "TagRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(TAGS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("name ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	tags := make([]*domain.Tag, 0)
+	if err := r.db.SelectContext(ctx, &tags, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Update renames a tag, the sessions it labels keep it.
+func (r *TagRepository) Update(ctx context.Context, id string, d *dto.SaveTagDto) (*domain.Tag, error) {
+	fn := "TagRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Update(TAGS_TABLE).
+		Set("name", d.Name).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var tag domain.Tag
+	if err := r.db.GetContext(ctx, &tag, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrTagNotFound
+		}
+		if e, ok := err.(*pq.Error); ok && e.Code == "23505" {
+			return nil, errTagNameTaken
+		}
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// Delete removes a tag and takes it off the sessions it labels.
+func (r *TagRepository) Delete(ctx context.Context, id string) error {
+	fn := "TagRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(TAGS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrTagNotFound
+	}
+
+	return nil
+}
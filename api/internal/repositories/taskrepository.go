@@ -0,0 +1,590 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.TaskRepository = (*TaskRepository)(nil)
+
+type TaskRepository struct {
+	db *sqlx.DB
+}
+
+func NewTaskRepository(db *sqlx.DB) *TaskRepository {
+	return &TaskRepository{db: db}
+}
+
+// taskSubtreeCTE selects a task and all of its subtasks into subtree.
+var taskSubtreeCTE = subtreeCTE(TASKS_TABLE, "parent_id")
+
+// taskRow scans the assignees of a task, which the domain keeps as a
+// slice.
+type taskRow struct {
+	domain.Task
+	AssigneeIds pq.StringArray `db:"assignee_ids"`
+}
+
+func (r *taskRow) toDomain() *domain.Task {
+	t := r.Task
+	t.AssigneeIds = []string(r.AssigneeIds)
+	if t.AssigneeIds == nil {
+		t.AssigneeIds = []string{}
+	}
+	return &t
+}
+
+// taskAssigneeIds selects the sorted assignees of the tasks of table alias
+// as assignee_ids.
+func taskAssigneeIds(alias string) string {
+	return "ARRAY(SELECT user_id FROM " + TASK_ASSIGNEES_TABLE + " WHERE task_id = " + alias + ".id ORDER BY user_id) AS assignee_ids"
+}
+
+// setTaskAssignees replaces the assignees of a task and returns them
+// sorted. It fails with ErrUserNotFound unless all users are of the
+// organization.
+func setTaskAssignees(ctx context.Context, tx *sqlx.Tx, orgId, taskId string, userIds []string) ([]string, error) {
+	fn := "setTaskAssignees"
+	logger := slog.With(slog.String("fn", fn), slog.String("taskId", taskId))
+
+	query, args, err := sq.Delete(TASK_ASSIGNEES_TABLE).
+		Where(sq.Eq{"task_id": taskId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	userIds = slices.Clone(userIds)
+	slices.Sort(userIds)
+	userIds = slices.Compact(userIds)
+	if len(userIds) == 0 {
+		return []string{}, nil
+	}
+
+	query, args, err = sq.Insert(TASK_ASSIGNEES_TABLE).
+		Columns("task_id", "user_id").
+		Select(sq.Select().
+			Column("?", taskId).
+			Column("id").
+			From(USERS_TABLE).
+			Where(sq.Eq{"org_id": orgId}).
+			Where("id = ANY(?)", pq.Array(userIds))).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if int(affected) != len(userIds) {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return userIds, nil
+}
+
+func (r *TaskRepository) Create(ctx context.Context, d *dto.SaveTaskDto) (*domain.Task, error) {
+	fn := "TaskRepository.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(TASKS_TABLE).
+		Columns("id", "org_id", "project_id", "parent_id", "title", "estimate_minutes", "status", "due_date").
+		Values(uuid.New().String(), orgId, d.ProjectId, d.ParentId, d.Title, d.EstimateMinutes, d.Status, d.DueDate).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var task domain.Task
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		if err := tx.GetContext(ctx, &task, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+				return domain.ErrProjectNotFound
+			}
+			return err
+		}
+
+		assigneeIds, err := setTaskAssignees(ctx, tx, orgId, task.Id, d.AssigneeIds)
+		if err != nil {
+			return err
+		}
+		task.AssigneeIds = assigneeIds
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+func (r *TaskRepository) Read(ctx context.Context, id string) (*domain.Task, error) {
+	fn := "TaskRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("t.*", taskAssigneeIds("t")).
+		From(TASKS_TABLE + " t").
+		Where(sq.Eq{"t.id": id, "t.org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var row taskRow
+	if err := r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrTaskNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *TaskRepository) ReadAll(ctx context.Context, f *filters.Tasks) ([]*domain.Task, error) {
+	fn := "TaskRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("t.*", taskAssigneeIds("t")).
+		From(TASKS_TABLE + " t").
+		Where(sq.Eq{"t.org_id": orgId}).
+		OrderBy("t.created_at ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.ProjectId != nil {
+		builder = builder.Where(sq.Eq{"t.project_id": *f.ProjectId})
+	}
+
+	if f.ParentId != nil {
+		if *f.ParentId == "" {
+			builder = builder.Where(sq.Eq{"t.parent_id": nil})
+		} else {
+			builder = builder.Where(sq.Eq{"t.parent_id": *f.ParentId})
+		}
+	}
+
+	if f.Status != nil {
+		builder = builder.Where(sq.Eq{"t.status": *f.Status})
+	}
+
+	if f.AssigneeId != nil {
+		builder = builder.Where(sq.Expr("EXISTS (SELECT 1 FROM "+TASK_ASSIGNEES_TABLE+" WHERE task_id = t.id AND user_id = ?)", *f.AssigneeId))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []*taskRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	tasks := make([]*domain.Task, 0, len(rows))
+	for _, row := range rows {
+		tasks = append(tasks, row.toDomain())
+	}
+
+	return tasks, nil
+}
+
+func (r *TaskRepository) Update(ctx context.Context, id string, d *dto.UpdateTaskDto) (*domain.Task, error) {
+	fn := "TaskRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(TASKS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *, " + taskAssigneeIds(TASKS_TABLE)).
+		PlaceholderFormat(sq.Dollar)
+
+	// a change of assignees alone leaves the row as it is
+	changed := false
+
+	if d.ProjectId != nil {
+		builder = builder.Set("project_id", *d.ProjectId)
+		changed = true
+	}
+
+	if d.ParentId != nil {
+		if *d.ParentId == "" {
+			builder = builder.Set("parent_id", nil)
+		} else {
+			builder = builder.Set("parent_id", *d.ParentId)
+		}
+		changed = true
+	}
+
+	if d.Title != nil {
+		builder = builder.Set("title", *d.Title)
+		changed = true
+	}
+
+	if d.EstimateMinutes != nil {
+		var estimate *int
+		if *d.EstimateMinutes != 0 {
+			estimate = d.EstimateMinutes
+		}
+		builder = builder.Set("estimate_minutes", estimate)
+		changed = true
+	}
+
+	if d.Status != nil {
+		builder = builder.Set("status", *d.Status)
+		changed = true
+	}
+
+	if d.DueDate != nil {
+		var due *time.Time
+		if !d.DueDate.IsZero() {
+			due = d.DueDate
+		}
+		builder = builder.Set("due_date", due)
+		changed = true
+	}
+
+	if !changed && d.AssigneeIds == nil {
+		return r.Read(ctx, id)
+	}
+
+	var task *domain.Task
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		if d.AssigneeIds != nil {
+			// the task must be of the organization before its assignees change
+			var exists bool
+			if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM "+TASKS_TABLE+" WHERE id = $1 AND org_id = $2)", id, orgId); err != nil {
+				logger.Error("failed to execute query", slog.String("err", err.Error()))
+				return err
+			}
+			if !exists {
+				return domain.ErrTaskNotFound
+			}
+
+			if _, err := setTaskAssignees(ctx, tx, orgId, id, *d.AssigneeIds); err != nil {
+				return err
+			}
+		}
+
+		var (
+			query string
+			args  []any
+			err   error
+		)
+		if changed {
+			query, args, err = builder.ToSql()
+		} else {
+			query, args, err = sq.Select("t.*", taskAssigneeIds("t")).
+				From(TASKS_TABLE + " t").
+				Where(sq.Eq{"t.id": id, "t.org_id": orgId}).
+				PlaceholderFormat(sq.Dollar).
+				ToSql()
+		}
+		if err != nil {
+			logger.Error("failed to build sql", slog.String("err", err.Error()))
+			return err
+		}
+
+		logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+		var row taskRow
+		if err := tx.GetContext(ctx, &row, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.ErrTaskNotFound
+			}
+			if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+				return domain.ErrProjectNotFound
+			}
+			return err
+		}
+		task = row.toDomain()
+
+		if d.ProjectId == nil {
+			return nil
+		}
+
+		// subtasks move along to the project of their parent
+		query, args, err = sq.Update(TASKS_TABLE).
+			Set("project_id", *d.ProjectId).
+			Where(sq.Expr("id IN ("+taskSubtreeCTE+" SELECT id FROM subtree)", id, orgId)).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			logger.Error("failed to build sql", slog.String("err", err.Error()))
+			return err
+		}
+
+		logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// Delete removes a task without subtasks no time was tracked on.
+func (r *TaskRepository) Delete(ctx context.Context, id string) error {
+	fn := "TaskRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(TASKS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+			if e.Constraint == "tasks_parent_id_fkey" {
+				return domain.ErrTaskHasSubtasks
+			}
+			return domain.ErrTaskInUse
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrTaskNotFound
+	}
+
+	return nil
+}
+
+// ReadTotals totals the finished sessions per task and user.
+func (r *TaskRepository) ReadTotals(ctx context.Context, f *filters.TaskTotals) ([]*domain.TaskUserTotal, error) {
+	fn := "TaskRepository.ReadTotals"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select(
+		"task_id",
+		"user_id",
+		"COALESCE(EXTRACT(EPOCH FROM SUM(end_time - start_time)), 0) AS total_seconds",
+		"COUNT(*) AS total_count",
+	).
+		From(ACTIVITY_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		Where(sq.NotEq{"end_time": nil}).
+		Where("task_id = ANY(?)", pq.Array(f.TaskIds)).
+		GroupBy("task_id", "user_id").
+		OrderBy("task_id ASC", "user_id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.UserIds != nil {
+		builder = builder.Where(sq.Eq{"user_id": f.UserIds})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []struct {
+		domain.TaskUserTotal
+		TotalSeconds float64 `db:"total_seconds"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	totals := make([]*domain.TaskUserTotal, 0, len(rows))
+	for _, row := range rows {
+		total := row.TaskUserTotal
+		total.TotalTime = time.Duration(row.TotalSeconds * float64(time.Second))
+		totals = append(totals, &total)
+	}
+
+	return totals, nil
+}
+
+// IsInSubtree reports whether candidateId is taskId or one of its
+// subtasks.
+func (r *TaskRepository) IsInSubtree(ctx context.Context, taskId, candidateId string) (bool, error) {
+	fn := "TaskRepository.IsInSubtree"
+	logger := slog.With(slog.String("fn", fn), slog.String("taskId", taskId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query, args, err := sq.Select("COUNT(*) > 0").
+		Prefix(taskSubtreeCTE, taskId, orgId).
+		From("subtree").
+		Where(sq.Eq{"id": candidateId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var found bool
+	if err := r.db.GetContext(ctx, &found, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	return found, nil
+}
+
+// ReadTime totals the finished sessions per user on a task itself and on
+// the task and all of its subtasks. userIds limits the totals to the given
+// users, nil means no limit.
+func (r *TaskRepository) ReadTime(ctx context.Context, id string, userIds []string) ([]*domain.TaskUserTime, error) {
+	fn := "TaskRepository.ReadTime"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("a.user_id").
+		Column(sq.Expr("COALESCE(EXTRACT(EPOCH FROM SUM(a.end_time - a.start_time) FILTER (WHERE a.task_id = ?)), 0) AS direct_seconds", id)).
+		Column("COALESCE(EXTRACT(EPOCH FROM SUM(a.end_time - a.start_time)), 0) AS total_seconds").
+		Prefix(taskSubtreeCTE, id, orgId).
+		From(ACTIVITY_TABLE + " a").
+		Join("subtree s ON s.id = a.task_id").
+		Where(sq.Eq{"a.org_id": orgId}).
+		Where(sq.NotEq{"a.end_time": nil}).
+		GroupBy("a.user_id").
+		OrderBy("a.user_id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if userIds != nil {
+		builder = builder.Where(sq.Eq{"a.user_id": userIds})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var rows []struct {
+		UserId        string  `db:"user_id"`
+		DirectSeconds float64 `db:"direct_seconds"`
+		TotalSeconds  float64 `db:"total_seconds"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	times := make([]*domain.TaskUserTime, 0, len(rows))
+	for _, row := range rows {
+		times = append(times, &domain.TaskUserTime{
+			UserId:     row.UserId,
+			DirectTime: time.Duration(row.DirectSeconds * float64(time.Second)),
+			TotalTime:  time.Duration(row.TotalSeconds * float64(time.Second)),
+		})
+	}
+
+	return times, nil
+}
@@ -0,0 +1,381 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// var _ services.TeamRepository = (*TeamRepository)(nil)
+
+// teamSubtreeCTE selects a team and all of its descendants into subtree.
+var teamSubtreeCTE = subtreeCTE(TEAMS_TABLE, "parent_id")
+
+// teamAncestorsCTE selects every team userId is a member of and all of
+// their ancestors into ancestors, depth is the distance from the team the
+// user is a member of.
+const teamAncestorsCTE = `WITH RECURSIVE ancestors AS (
+	SELECT t.id, t.parent_id, 1 AS depth
+	FROM teams t JOIN team_members m ON m.team_id = t.id
+	WHERE m.user_id = ? AND t.org_id = ?
+	UNION ALL
+	SELECT p.id, p.parent_id, a.depth + 1
+	FROM teams p JOIN ancestors a ON p.id = a.parent_id
+	WHERE a.depth < 64
+)`
+
+// inTeam restricts column to the members of teamId and its descendant teams.
+func inTeam(column, orgId, teamId string) sq.Sqlizer {
+	return sq.Expr(
+		column+" IN ("+teamSubtreeCTE+" SELECT m.user_id FROM team_members m JOIN subtree s ON s.id = m.team_id)",
+		teamId, orgId,
+	)
+}
+
+type TeamRepository struct {
+	db *sqlx.DB
+}
+
+func NewTeamRepository(db *sqlx.DB) *TeamRepository {
+	return &TeamRepository{db: db}
+}
+
+func (r *TeamRepository) Create(ctx context.Context, d *dto.SaveTeamDto) (*domain.Team, error) {
+	fn := "TeamRepository.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(TEAMS_TABLE).
+		Columns("id", "org_id", "name", "parent_id", "manager_id").
+		Values(uuid.New().String(), orgId, d.Name, d.ParentId, d.ManagerId).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var team domain.Team
+	if err := r.db.GetContext(ctx, &team, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (r *TeamRepository) Read(ctx context.Context, id string) (*domain.Team, error) {
+	fn := "TeamRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(TEAMS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var team domain.Team
+	if err := r.db.GetContext(ctx, &team, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrTeamNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (r *TeamRepository) ReadAll(ctx context.Context) ([]*domain.Team, error) {
+	fn := "TeamRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(TEAMS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("created_at ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	teams := make([]*domain.Team, 0)
+	if err := r.db.SelectContext(ctx, &teams, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+func (r *TeamRepository) Update(ctx context.Context, id string, d *dto.UpdateTeamDto) (*domain.Team, error) {
+	fn := "TeamRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if d.Name == nil && d.ParentId == nil && d.ManagerId == nil {
+		return r.Read(ctx, id)
+	}
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(TEAMS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if d.Name != nil {
+		builder = builder.Set("name", *d.Name)
+	}
+
+	if d.ParentId != nil {
+		if *d.ParentId == "" {
+			builder = builder.Set("parent_id", nil)
+		} else {
+			builder = builder.Set("parent_id", *d.ParentId)
+		}
+	}
+
+	if d.ManagerId != nil {
+		if *d.ManagerId == "" {
+			builder = builder.Set("manager_id", nil)
+		} else {
+			builder = builder.Set("manager_id", *d.ManagerId)
+		}
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var team domain.Team
+	if err := r.db.GetContext(ctx, &team, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// Delete removes a team together with its descendant teams.
+func (r *TeamRepository) Delete(ctx context.Context, id string) error {
+	fn := "TeamRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(TEAMS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrTeamNotFound
+	}
+
+	return nil
+}
+
+// IsInSubtree reports whether candidateId is teamId or one of its
+// descendants.
+func (r *TeamRepository) IsInSubtree(ctx context.Context, teamId, candidateId string) (bool, error) {
+	fn := "TeamRepository.IsInSubtree"
+	logger := slog.With(slog.String("fn", fn), slog.String("teamId", teamId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query, args, err := sq.Select("COUNT(*) > 0").
+		Prefix(teamSubtreeCTE, teamId, orgId).
+		From("subtree").
+		Where(sq.Eq{"id": candidateId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var found bool
+	if err := r.db.GetContext(ctx, &found, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	return found, nil
+}
+
+func (r *TeamRepository) AddMember(ctx context.Context, teamId, userId string) error {
+	fn := "TeamRepository.AddMember"
+	logger := slog.With(slog.String("fn", fn), slog.String("teamId", teamId), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	// selecting the pair guards against linking a user of another
+	// organization to the team
+	query, args, err := sq.Insert(TEAM_MEMBERS_TABLE).
+		Columns("team_id", "user_id").
+		Select(sq.Select("t.id", "u.id").
+			From(TEAMS_TABLE + " t").
+			Join(USERS_TABLE + " u ON u.org_id = t.org_id").
+			Where(sq.Eq{"t.id": teamId, "t.org_id": orgId, "u.id": userId})).
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (r *TeamRepository) RemoveMember(ctx context.Context, teamId, userId string) error {
+	fn := "TeamRepository.RemoveMember"
+	logger := slog.With(slog.String("fn", fn), slog.String("teamId", teamId), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(TEAM_MEMBERS_TABLE).
+		Where(sq.Eq{"team_id": teamId, "user_id": userId}).
+		Where(sq.Expr("team_id IN (SELECT id FROM "+TEAMS_TABLE+" WHERE org_id = ?)", orgId)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrMemberNotFound
+	}
+
+	return nil
+}
+
+// ReadMemberIds returns ids of the members of teamId and its descendant
+// teams.
+func (r *TeamRepository) ReadMemberIds(ctx context.Context, teamId string) ([]string, error) {
+	fn := "TeamRepository.ReadMemberIds"
+	logger := slog.With(slog.String("fn", fn), slog.String("teamId", teamId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("id").
+		From(USERS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		Where(inTeam("id", orgId, teamId)).
+		OrderBy("id ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	ids := make([]string, 0)
+	if err := r.db.SelectContext(ctx, &ids, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return ids, nil
+}
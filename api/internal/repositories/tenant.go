@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+	"em-test/internal/domain"
+)
+
+// tenant returns the organization ctx is scoped to. Every query on tenant
+// data must be filtered by it.
+func tenant(ctx context.Context) (string, error) {
+	orgId, ok := domain.OrgFrom(ctx)
+	if !ok {
+		return "", domain.ErrOrgRequired
+	}
+	return orgId, nil
+}
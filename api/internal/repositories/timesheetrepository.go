@@ -0,0 +1,326 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.TimesheetRepository = (*TimesheetRepository)(nil)
+
+type TimesheetRepository struct {
+	db *sqlx.DB
+}
+
+func NewTimesheetRepository(db *sqlx.DB) *TimesheetRepository {
+	return &TimesheetRepository{db: db}
+}
+
+func timesheetEvent(status domain.TimesheetStatus) domain.EventType {
+	switch status {
+	case domain.TimesheetApproved:
+		return domain.EventTimesheetApproved
+	case domain.TimesheetRejected:
+		return domain.EventTimesheetRejected
+	}
+	return domain.EventTimesheetSubmitted
+}
+
+// lockTimesheet reads the timesheet matching where for update, it is the
+// before state of audited changes.
+func lockTimesheet(ctx context.Context, tx *sqlx.Tx, where sq.Eq) (*domain.Timesheet, error) {
+	fn := "lockTimesheet"
+	logger := slog.With(slog.String("fn", fn))
+
+	query, args, err := sq.Select("*").
+		From(TIMESHEETS_TABLE).
+		Where(where).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	var timesheet domain.Timesheet
+	if err := tx.GetContext(ctx, &timesheet, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrTimesheetNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &timesheet, nil
+}
+
+// Submit submits the week of a user for approval, either for the first time
+// or again after it was rejected.
+func (r *TimesheetRepository) Submit(ctx context.Context, d *dto.SubmitTimesheetDto) (*domain.Timesheet, error) {
+	fn := "TimesheetRepository.Submit"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var timesheet domain.Timesheet
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := lockTimesheet(ctx, tx, sq.Eq{"org_id": orgId, "user_id": d.UserId, "week_start": d.WeekStart})
+		if err != nil && !errors.Is(err, domain.ErrTimesheetNotFound) {
+			return err
+		}
+
+		var (
+			query  string
+			args   []any
+			action = domain.AuditCreate
+		)
+
+		if before == nil {
+			query, args, err = sq.Insert(TIMESHEETS_TABLE).
+				Columns("id", "org_id", "user_id", "week_start", "status").
+				Values(uuid.New().String(), orgId, d.UserId, d.WeekStart, domain.TimesheetSubmitted).
+				Suffix("RETURNING *").
+				PlaceholderFormat(sq.Dollar).
+				ToSql()
+		} else {
+			if !before.Status.CanTransition(domain.TimesheetSubmitted) {
+				return domain.ErrInvalidTransition
+			}
+
+			action = domain.AuditUpdate
+			query, args, err = sq.Update(TIMESHEETS_TABLE).
+				Set("status", domain.TimesheetSubmitted).
+				Set("comment", nil).
+				Set("reviewed_by", nil).
+				Set("reviewed_at", nil).
+				Set("submitted_at", sq.Expr("NOW()")).
+				Where(sq.Eq{"id": before.Id}).
+				Suffix("RETURNING *").
+				PlaceholderFormat(sq.Dollar).
+				ToSql()
+		}
+		if err != nil {
+			logger.Error("failed to build sql", slog.String("err", err.Error()))
+			return err
+		}
+
+		logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+		if err := tx.GetContext(ctx, &timesheet, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			// a concurrent submission of the same week won the race
+			if e, ok := err.(*pq.Error); ok && e.Code == "23505" {
+				return domain.ErrInvalidTransition
+			}
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, action, domain.AuditEntityTimesheet, timesheet.Id, before, &timesheet); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventTimesheetSubmitted,
+			OrgId:      orgId,
+			UserId:     timesheet.UserId,
+			OccurredAt: time.Now(),
+			Data:       &timesheet,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &timesheet, nil
+}
+
+// Review approves or rejects a submitted timesheet.
+func (r *TimesheetRepository) Review(ctx context.Context, d *dto.ReviewTimesheetDto) (*domain.Timesheet, error) {
+	fn := "TimesheetRepository.Review"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", d.Id), slog.String("status", string(d.Status)))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Update(TIMESHEETS_TABLE).
+		Set("status", d.Status).
+		Set("comment", d.Comment).
+		Set("reviewed_by", d.ReviewerId).
+		Set("reviewed_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": d.Id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var timesheet domain.Timesheet
+	err = withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := lockTimesheet(ctx, tx, sq.Eq{"id": d.Id, "org_id": orgId})
+		if err != nil {
+			return err
+		}
+
+		if !before.Status.CanTransition(d.Status) {
+			return domain.ErrInvalidTransition
+		}
+
+		if err := tx.GetContext(ctx, &timesheet, query, args...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityTimesheet, timesheet.Id, before, &timesheet); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       timesheetEvent(timesheet.Status),
+			OrgId:      orgId,
+			UserId:     timesheet.UserId,
+			OccurredAt: time.Now(),
+			Data:       &timesheet,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &timesheet, nil
+}
+
+func (r *TimesheetRepository) Read(ctx context.Context, id string) (*domain.Timesheet, error) {
+	fn := "TimesheetRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(TIMESHEETS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var timesheet domain.Timesheet
+	if err := r.db.GetContext(ctx, &timesheet, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrTimesheetNotFound
+		}
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &timesheet, nil
+}
+
+func (r *TimesheetRepository) ReadMany(ctx context.Context, f *filters.Timesheets) ([]*domain.Timesheet, error) {
+	fn := "TimesheetRepository.ReadMany"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Select("*").
+		From(TIMESHEETS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("week_start DESC", "user_id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.UserId != nil {
+		builder = builder.Where(sq.Eq{"user_id": *f.UserId})
+	}
+
+	if f.UserIds != nil {
+		builder = builder.Where(sq.Eq{"user_id": f.UserIds})
+	}
+
+	if f.Status != nil {
+		builder = builder.Where(sq.Eq{"status": *f.Status})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	timesheets := make([]*domain.Timesheet, 0)
+	if err := r.db.SelectContext(ctx, &timesheets, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return timesheets, nil
+}
+
+// IsLocked reports whether [start, end) touches a week of an approved
+// timesheet of userId. A nil end leaves the interval open, as that of a
+// running session.
+func (r *TimesheetRepository) IsLocked(ctx context.Context, userId string, start time.Time, end *time.Time) (bool, error) {
+	fn := "TimesheetRepository.IsLocked"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	builder := sq.Select("COUNT(*) > 0").
+		From(TIMESHEETS_TABLE).
+		Where(sq.Eq{"org_id": orgId, "user_id": userId, "status": domain.TimesheetApproved}).
+		Where(sq.Expr("week_start + INTERVAL '7 days' > ?", start)).
+		PlaceholderFormat(sq.Dollar)
+
+	if end != nil {
+		builder = builder.Where(sq.Lt{"week_start": *end})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var locked bool
+	if err := r.db.GetContext(ctx, &locked, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	return locked, nil
+}
@@ -0,0 +1,13 @@
+package repositories
+
+// subtreeCTE selects into subtree the row of table whose id and org_id are
+// bound to its two placeholders and every row below it, following the
+// parent column. UNION rather than UNION ALL keeps the recursion finite
+// should a cycle ever slip into the tree.
+func subtreeCTE(table, parent string) string {
+	return `WITH RECURSIVE subtree AS (
+	SELECT id FROM ` + table + ` WHERE id = ? AND org_id = ?
+	UNION
+	SELECT t.id FROM ` + table + ` t JOIN subtree s ON t.` + parent + ` = s.id
+)`
+}
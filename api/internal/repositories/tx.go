@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// withTx runs fn inside a transaction, committing when fn succeeds and
+// rolling back otherwise.
+func withTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			slog.Error("failed to rollback transaction", slog.String("err", rbErr.Error()))
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
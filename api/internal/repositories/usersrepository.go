@@ -1,10 +1,14 @@
 package repositories
 
 import (
+	"context"
+	"database/sql"
 	"em-test/internal/domain"
 	"em-test/internal/lib/dto"
 	"em-test/internal/lib/filters"
+	"errors"
 	"log/slog"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
@@ -24,15 +28,49 @@ func NewUsersRepository(db *sqlx.DB) *UsersRepository {
 	}
 }
 
-func (u *UsersRepository) Add(dto dto.SaveUserDto) (*domain.User, error) {
+// lockUser reads a user for update, it is the before state of audited
+// changes.
+func lockUser(ctx context.Context, tx *sqlx.Tx, orgId, id string) (*domain.User, error) {
+	fn := "lockUser"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	query, args, err := sq.Select("*").
+		From(USERS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("error formatting query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	var user domain.User
+	if err := tx.GetContext(ctx, &user, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		logger.Error("error executing query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (u *UsersRepository) Add(ctx context.Context, dto dto.SaveUserDto) (*domain.User, error) {
 	fn := "UsersRepository.Add"
 	logger := slog.With(slog.String("fn", fn))
 
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	id := uuid.New()
 
 	query, args, err := sq.Insert(USERS_TABLE).
-		Columns("id", "surname", "name", "patronymic", "address", "passport_serie", "passport_number").
-		Values(id.String(), dto.Surname, dto.Name, dto.Patronymic, dto.Address, dto.PassportSerie, dto.PassportNumber).
+		Columns("id", "org_id", "surname", "name", "patronymic", "address", "passport_serie", "passport_number").
+		Values(id.String(), orgId, dto.Surname, dto.Name, dto.Patronymic, dto.Address, dto.PassportSerie, dto.PassportNumber).
 		Suffix("RETURNING *").
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
@@ -44,26 +82,48 @@ func (u *UsersRepository) Add(dto dto.SaveUserDto) (*domain.User, error) {
 	logger.Debug("executing query", slog.String("query", query), slog.Any("args", args))
 
 	var user domain.User
-	if err = u.db.Get(&user, query, args...); err != nil {
-		slog.Error("error executing query", slog.String("err", err.Error()))
-		if e, ok := err.(*pq.Error); ok {
-			if e.Code == "23505" {
-				return nil, domain.ErrUserAlreadyExists
+	err = withTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := tx.Get(&user, query, args...); err != nil {
+			slog.Error("error executing query", slog.String("err", err.Error()))
+			if e, ok := err.(*pq.Error); ok {
+				if e.Code == "23505" {
+					return domain.ErrUserAlreadyExists
+				}
 			}
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditCreate, domain.AuditEntityUser, user.Id, nil, &user); err != nil {
+			return err
 		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventUserCreated,
+			OrgId:      orgId,
+			UserId:     user.Id,
+			OccurredAt: time.Now(),
+			Data:       user.WithoutPassport(),
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
 
-func (u *UsersRepository) Read(id string) (*domain.User, error) {
+func (u *UsersRepository) Read(ctx context.Context, id string) (*domain.User, error) {
 	fn := "UsersRepository.Read"
 	logger := slog.With(slog.String("fn", fn))
 
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query, args, err := sq.Select("*").
 		From(USERS_TABLE).
-		Where(sq.Eq{"id": id}).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
@@ -74,40 +134,35 @@ func (u *UsersRepository) Read(id string) (*domain.User, error) {
 	logger.Debug("executing query", slog.String("query", query), slog.Any("args", args))
 
 	var users domain.User
-	if err = u.db.Get(&users, query, args...); err != nil {
+	if err = u.db.GetContext(ctx, &users, query, args...); err != nil {
 		slog.Error("error executing query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
 		return nil, err
 	}
 
 	return &users, nil
 }
 
-func (u *UsersRepository) ReadMany(filters *filters.UsersFilters) ([]*domain.User, int64, error) {
+func (u *UsersRepository) ReadMany(ctx context.Context, filters *filters.UsersFilters) ([]*domain.User, int64, error) {
 	fn := "UsersRepository.ReadMany"
 	logger := slog.With(slog.String("fn", fn))
 
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	builder := sq.Select("*").
 		From(USERS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
 		OrderBy("id ASC").
 		PlaceholderFormat(sq.Dollar)
 
-	if filters != nil {
-		if filters.Surname != nil {
-			builder = builder.Where(sq.ILike{"surname": *filters.Surname + "%"})
-		}
-
-		if filters.Name != nil {
-			builder = builder.Where(sq.ILike{"name": *filters.Name + "%"})
-		}
-
-		if filters.Patronymic != nil {
-			builder = builder.Where(sq.ILike{"patronymic": *filters.Patronymic + "%"})
-		}
-
-		if filters.Address != nil {
-			builder = builder.Where(sq.ILike{"address": *filters.Address + "%"})
-		}
+	builder = applyUsersFilters(builder, orgId, filters)
 
+	if filters != nil {
 		if filters.Limit != nil {
 			builder = builder.Limit(uint64(*filters.Limit))
 		} else {
@@ -128,12 +183,12 @@ func (u *UsersRepository) ReadMany(filters *filters.UsersFilters) ([]*domain.Use
 	logger.Debug("executing query", slog.String("query", sql), slog.Any("args", args))
 
 	users := make([]*domain.User, 0)
-	if err = u.db.Select(&users, sql, args...); err != nil {
+	if err = u.db.SelectContext(ctx, &users, sql, args...); err != nil {
 		logger.Error("error executing query", slog.String("err", err.Error()))
 		return nil, 0, err
 	}
 
-	total, err := u.Count(filters)
+	total, err := u.Count(ctx, filters)
 	if err != nil {
 		logger.Error("error with couning records in db by filters")
 		return nil, 0, err
@@ -142,45 +197,267 @@ func (u *UsersRepository) ReadMany(filters *filters.UsersFilters) ([]*domain.Use
 	return users, total, nil
 }
 
-func (u *UsersRepository) Count(filters *filters.UsersFilters) (int64, error) {
+func applyUsersFilters(builder sq.SelectBuilder, orgId string, filters *filters.UsersFilters) sq.SelectBuilder {
+	if filters == nil {
+		return builder
+	}
+
+	if filters.Surname != nil {
+		builder = builder.Where(sq.ILike{"surname": *filters.Surname + "%"})
+	}
+
+	if filters.Name != nil {
+		builder = builder.Where(sq.ILike{"name": *filters.Name + "%"})
+	}
+
+	if filters.Patronymic != nil {
+		builder = builder.Where(sq.ILike{"patronymic": *filters.Patronymic + "%"})
+	}
+
+	if filters.Address != nil {
+		builder = builder.Where(sq.ILike{"address": *filters.Address + "%"})
+	}
+
+	if filters.ManagerId != nil {
+		builder = builder.Where(sq.Eq{"manager_id": *filters.ManagerId})
+	}
+
+	if filters.Ids != nil {
+		builder = builder.Where(sq.Eq{"id": filters.Ids})
+	}
+
+	if filters.TeamId != nil {
+		builder = builder.Where(inTeam("id", orgId, *filters.TeamId))
+	}
+
+	return builder
+}
+
+func (u *UsersRepository) Count(ctx context.Context, filters *filters.UsersFilters) (int64, error) {
 	fn := "UsersRepository.Count"
 	logger := slog.With(slog.String("fn", fn))
 
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	builder := sq.Select("COUNT(*)").
 		From(USERS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
 		PlaceholderFormat(sq.Dollar)
 
-	if filters != nil {
-		if filters.Surname != nil {
-			builder = builder.Where(sq.ILike{"surname": *filters.Surname + "%"})
+	builder = applyUsersFilters(builder, orgId, filters)
+
+	sql, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("error formatting query", slog.String("err", err.Error()))
+		return 0, err
+	}
+
+	logger.Debug("executing query", slog.String("query", sql), slog.Any("args", args))
+
+	var total int64
+	if err := u.db.GetContext(ctx, &total, sql, args...); err != nil {
+		logger.Error("error executing query", slog.String("err", err.Error()))
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (u *UsersRepository) Update(ctx context.Context, id string, d *dto.UpdateUserDto) (*domain.User, error) {
+	fn := "UsersRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if d.Role == nil && d.ManagerId == nil {
+		return u.Read(ctx, id)
+	}
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(USERS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if d.Role != nil {
+		builder = builder.Set("role", *d.Role)
+	}
+
+	if d.ManagerId != nil {
+		if *d.ManagerId == "" {
+			builder = builder.Set("manager_id", nil)
+		} else {
+			builder = builder.Set("manager_id", *d.ManagerId)
 		}
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("error formatting query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("query", query), slog.Any("args", args))
 
-		if filters.Name != nil {
-			builder = builder.Where(sq.ILike{"name": *filters.Name + "%"})
+	var user domain.User
+	err = withTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		before, err := lockUser(ctx, tx, orgId, id)
+		if err != nil {
+			return err
 		}
 
-		if filters.Patronymic != nil {
-			builder = builder.Where(sq.ILike{"patronymic": *filters.Patronymic + "%"})
+		if err := tx.Get(&user, query, args...); err != nil {
+			logger.Error("error executing query", slog.String("err", err.Error()))
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.ErrUserNotFound
+			}
+			if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+				return domain.ErrUserNotFound
+			}
+			return err
 		}
 
-		if filters.Address != nil {
-			builder = builder.Where(sq.ILike{"address": *filters.Address + "%"})
+		if err := recordAudit(ctx, tx, domain.AuditUpdate, domain.AuditEntityUser, user.Id, before, &user); err != nil {
+			return err
 		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventUserUpdated,
+			OrgId:      orgId,
+			UserId:     user.Id,
+			OccurredAt: time.Now(),
+			Data:       user.WithoutPassport(),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	sql, args, err := builder.ToSql()
+	return &user, nil
+}
+
+func (u *UsersRepository) Delete(ctx context.Context, id string) error {
+	fn := "UsersRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(USERS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
 	if err != nil {
 		logger.Error("error formatting query", slog.String("err", err.Error()))
-		return 0, err
+		return err
 	}
 
-	logger.Debug("executing query", slog.String("query", sql), slog.Any("args", args))
+	logger.Debug("executing query", slog.String("query", query), slog.Any("args", args))
 
-	var total int64
-	if err := u.db.Get(&total, sql, args...); err != nil {
+	return withTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		before, err := lockUser(ctx, tx, orgId, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			logger.Error("error executing query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, domain.AuditDelete, domain.AuditEntityUser, id, before, nil); err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, &domain.Event{
+			Type:       domain.EventUserDeleted,
+			OrgId:      orgId,
+			UserId:     id,
+			OccurredAt: time.Now(),
+		})
+	})
+}
+
+// ReadReportIds returns ids of the users directly managed by managerId and
+// of the members of the teams managerId manages, including descendant teams.
+func (u *UsersRepository) ReadReportIds(ctx context.Context, managerId string) ([]string, error) {
+	fn := "UsersRepository.ReadReportIds"
+	logger := slog.With(slog.String("fn", fn), slog.String("managerId", managerId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("id").
+		From(USERS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		Where(sq.Or{
+			sq.Eq{"manager_id": managerId},
+			sq.Expr(`id IN (
+				WITH RECURSIVE managed AS (
+					SELECT id FROM teams WHERE manager_id = ? AND org_id = ?
+					UNION
+					SELECT t.id FROM teams t JOIN managed m ON t.parent_id = m.id
+				)
+				SELECT tm.user_id FROM team_members tm JOIN managed m ON m.id = tm.team_id
+			)`, managerId, orgId),
+		}).
+		Where(sq.NotEq{"id": managerId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("error formatting query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("query", query), slog.Any("args", args))
+
+	ids := make([]string, 0)
+	if err := u.db.SelectContext(ctx, &ids, query, args...); err != nil {
 		logger.Error("error executing query", slog.String("err", err.Error()))
-		return 0, err
+		return nil, err
 	}
 
-	return total, nil
+	return ids, nil
+}
+
+// IsInReports reports whether candidateId is userId or one of the users
+// below userId in the chain of managers.
+func (u *UsersRepository) IsInReports(ctx context.Context, userId, candidateId string) (bool, error) {
+	fn := "UsersRepository.IsInReports"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query, args, err := sq.Select("COUNT(*) > 0").
+		Prefix(subtreeCTE(USERS_TABLE, "manager_id"), userId, orgId).
+		From("subtree").
+		Where(sq.Eq{"id": candidateId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("error formatting query", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	logger.Debug("executing query", slog.String("query", query), slog.Any("args", args))
+
+	var found bool
+	if err := u.db.GetContext(ctx, &found, query, args...); err != nil {
+		logger.Error("error executing query", slog.String("err", err.Error()))
+		return false, err
+	}
+
+	return found, nil
 }
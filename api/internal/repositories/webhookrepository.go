@@ -0,0 +1,418 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// var _ services.WebhookRepository = (*WebhookRepository)(nil)
+
+type WebhookRepository struct {
+	db *sqlx.DB
+}
+
+func NewWebhookRepository(db *sqlx.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+type webhookRow struct {
+	domain.Webhook
+	Events pq.StringArray `db:"events"`
+}
+
+func (r *webhookRow) toDomain() *domain.Webhook {
+	w := r.Webhook
+	w.Events = make([]domain.EventType, 0, len(r.Events))
+	for _, e := range r.Events {
+		w.Events = append(w.Events, domain.EventType(e))
+	}
+	return &w
+}
+
+func eventsArray(events []domain.EventType) pq.StringArray {
+	arr := make(pq.StringArray, 0, len(events))
+	for _, e := range events {
+		arr = append(arr, string(e))
+	}
+	return arr
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, d *dto.SaveWebhookDto) (*domain.Webhook, error) {
+	fn := "WebhookRepository.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Insert(WEBHOOKS_TABLE).
+		Columns("id", "org_id", "url", "secret", "events").
+		Values(uuid.New().String(), orgId, d.Url, d.Secret, eventsArray(d.Events)).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var row webhookRow
+	if err := r.db.GetContext(ctx, &row, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *WebhookRepository) Read(ctx context.Context, id string) (*domain.Webhook, error) {
+	fn := "WebhookRepository.Read"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(WEBHOOKS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var row webhookRow
+	if err := r.db.GetContext(ctx, &row, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *WebhookRepository) ReadAll(ctx context.Context) ([]*domain.Webhook, error) {
+	fn := "WebhookRepository.ReadAll"
+	logger := slog.With(slog.String("fn", fn))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("*").
+		From(WEBHOOKS_TABLE).
+		Where(sq.Eq{"org_id": orgId}).
+		OrderBy("created_at ASC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var rows []*webhookRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	webhooks := make([]*domain.Webhook, 0, len(rows))
+	for _, row := range rows {
+		webhooks = append(webhooks, row.toDomain())
+	}
+
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, id string, d *dto.UpdateWebhookDto) (*domain.Webhook, error) {
+	fn := "WebhookRepository.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sq.Update(WEBHOOKS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		Suffix("RETURNING *").
+		PlaceholderFormat(sq.Dollar)
+
+	if d.Url == nil && d.Events == nil && d.Active == nil {
+		return r.Read(ctx, id)
+	}
+
+	if d.Url != nil {
+		builder = builder.Set("url", *d.Url)
+	}
+
+	if d.Events != nil {
+		builder = builder.Set("events", eventsArray(d.Events))
+	}
+
+	if d.Active != nil {
+		builder = builder.Set("active", *d.Active)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query))
+
+	var row webhookRow
+	if err := r.db.GetContext(ctx, &row, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, id string) error {
+	fn := "WebhookRepository.Delete"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Delete(WEBHOOKS_TABLE).
+		Where(sq.Eq{"id": id, "org_id": orgId}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) ReadDeliveries(ctx context.Context, webhookId string, limit int) ([]*domain.WebhookDelivery, error) {
+	fn := "WebhookRepository.ReadDeliveries"
+	logger := slog.With(slog.String("fn", fn), slog.String("webhookId", webhookId))
+
+	orgId, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.Select("d.id", "d.webhook_id", "d.outbox_id", "o.event_type", "d.status", "d.attempts", "d.next_attempt_at", "d.created_at", "d.delivered_at").
+		From(WEBHOOK_DELIVERIES_TABLE + " d").
+		Join(OUTBOX_TABLE + " o ON o.id = d.outbox_id").
+		Join(WEBHOOKS_TABLE + " w ON w.id = d.webhook_id").
+		Where(sq.Eq{"d.webhook_id": webhookId, "w.org_id": orgId}).
+		OrderBy("d.id DESC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	if err := r.db.SelectContext(ctx, &deliveries, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if len(deliveries) == 0 {
+		return deliveries, nil
+	}
+
+	ids := make([]int64, 0, len(deliveries))
+	byId := make(map[int64]*domain.WebhookDelivery, len(deliveries))
+	for _, d := range deliveries {
+		ids = append(ids, d.Id)
+		byId[d.Id] = d
+	}
+
+	query, args, err = sq.Select("delivery_id", "attempt", "status_code", "error", "duration_ms", "created_at").
+		From(WEBHOOK_DELIVERY_ATTEMPTS_TABLE).
+		Where(sq.Eq{"delivery_id": ids}).
+		OrderBy("delivery_id", "attempt").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug("executing query", slog.String("sql", query), slog.Any("args", args))
+
+	var attempts []*struct {
+		DeliveryId int64 `db:"delivery_id"`
+		domain.WebhookDeliveryAttempt
+	}
+	if err := r.db.SelectContext(ctx, &attempts, query, args...); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	for _, a := range attempts {
+		d := byId[a.DeliveryId]
+		attempt := a.WebhookDeliveryAttempt
+		d.Log = append(d.Log, &attempt)
+	}
+
+	return deliveries, nil
+}
+
+const fanOutQuery = `
+WITH batch AS (
+	SELECT id, org_id, event_type FROM outbox
+	WHERE processed_at IS NULL
+	ORDER BY id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+), deliveries AS (
+	INSERT INTO webhook_deliveries (webhook_id, outbox_id)
+	SELECT w.id, b.id FROM batch b
+	JOIN webhooks w ON w.active AND w.org_id = b.org_id AND b.event_type = ANY(w.events)
+)
+UPDATE outbox o SET processed_at = NOW()
+FROM batch b
+WHERE o.id = b.id`
+
+// FanOut turns up to batch unprocessed outbox events into pending deliveries
+// for every active webhook of the same organization subscribed to them.
+func (r *WebhookRepository) FanOut(ctx context.Context, batch int) (int64, error) {
+	fn := "WebhookRepository.FanOut"
+	logger := slog.With(slog.String("fn", fn))
+
+	res, err := r.db.ExecContext(ctx, fanOutQuery, batch)
+	if err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+const claimQuery = `
+WITH due AS (
+	SELECT id FROM webhook_deliveries
+	WHERE status = 'pending' AND next_attempt_at <= NOW()
+	ORDER BY next_attempt_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries d SET next_attempt_at = NOW() + make_interval(secs => $2)
+FROM due, webhooks w, outbox o
+WHERE d.id = due.id AND w.id = d.webhook_id AND o.id = d.outbox_id
+RETURNING d.id, d.webhook_id, d.outbox_id, w.url, w.secret, o.event_type, o.payload, d.attempts`
+
+// ClaimDue leases up to batch due deliveries for lease, so concurrent
+// dispatchers never send the same delivery twice at once.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, batch int, lease time.Duration) ([]*domain.PendingDelivery, error) {
+	fn := "WebhookRepository.ClaimDue"
+	logger := slog.With(slog.String("fn", fn))
+
+	deliveries := make([]*domain.PendingDelivery, 0)
+	if err := r.db.SelectContext(ctx, &deliveries, claimQuery, batch, lease.Seconds()); err != nil {
+		logger.Error("failed to execute query", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, d *dto.WebhookAttemptDto) error {
+	fn := "WebhookRepository.RecordAttempt"
+	logger := slog.With(slog.String("fn", fn), slog.Int64("deliveryId", d.DeliveryId))
+
+	insert, insertArgs, err := sq.Insert(WEBHOOK_DELIVERY_ATTEMPTS_TABLE).
+		Columns("delivery_id", "attempt", "status_code", "error", "duration_ms").
+		Values(d.DeliveryId, d.Attempt, d.StatusCode, d.Error, d.Duration.Milliseconds()).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	builder := sq.Update(WEBHOOK_DELIVERIES_TABLE).
+		Set("status", d.Status).
+		Set("attempts", d.Attempt).
+		Where(sq.Eq{"id": d.DeliveryId}).
+		PlaceholderFormat(sq.Dollar)
+
+	switch d.Status {
+	case domain.WebhookDeliveryDelivered:
+		builder = builder.Set("delivered_at", sq.Expr("NOW()"))
+	case domain.WebhookDeliveryPending:
+		builder = builder.Set("next_attempt_at", d.NextAttemptAt)
+	}
+
+	update, updateArgs, err := builder.ToSql()
+	if err != nil {
+		logger.Error("failed to build sql", slog.String("err", err.Error()))
+		return err
+	}
+
+	logger.Debug("executing queries", slog.String("insert", insert), slog.String("update", update))
+
+	return withTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(insert, insertArgs...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		if _, err := tx.Exec(update, updateArgs...); err != nil {
+			logger.Error("failed to execute query", slog.String("err", err.Error()))
+			return err
+		}
+
+		return nil
+	})
+}
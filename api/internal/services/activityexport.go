@@ -0,0 +1,304 @@
+package services
+
+import (
+	"em-test/internal/domain"
+	"em-test/internal/lib/xlsx"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportLocale writes numbers the way a language does. Languages writing
+// decimals with a comma separate CSV fields with a semicolon, which is
+// what their spreadsheets expect.
+type exportLocale struct {
+	decimal byte
+	comma   rune
+}
+
+// decimalCommaLanguages are the languages writing decimals with a comma.
+var decimalCommaLanguages = []string{
+	"bg", "cs", "da", "de", "el", "es", "et", "fi", "fr", "hr", "hu", "id", "it", "lt", "lv",
+	"nb", "nl", "nn", "no", "pl", "pt", "ro", "ru", "sk", "sl", "sr", "sv", "tr", "uk", "vi",
+}
+
+// localeOf returns the export locale of a language tag such as de-CH,
+// English for unknown or empty tags.
+func localeOf(tag string) exportLocale {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	if slices.Contains(decimalCommaLanguages, lang) {
+		return exportLocale{decimal: ',', comma: ';'}
+	}
+	return exportLocale{decimal: '.', comma: ','}
+}
+
+// hours writes d as a decimal number of hours.
+func (l exportLocale) hours(d time.Duration) string {
+	s := hours(d)
+	if l.decimal != '.' {
+		s = strings.Replace(s, ".", string(l.decimal), 1)
+	}
+	return s
+}
+
+// clock writes d as hours, minutes and seconds, H:MM:SS, hours going past
+// a day.
+func clock(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	sign := ""
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	return fmt.Sprintf("%s%d:%02d:%02d", sign, seconds/3600, seconds/60%60, seconds%60)
+}
+
+// exportTimeLayout writes the start and end of sessions in CSV exports.
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// exportTotal totals the exported sessions of a user.
+type exportTotal struct {
+	userId   string
+	count    int
+	total    time.Duration
+	timeline domain.Timeline
+	// credited and expected are only known for reports.
+	credited *time.Duration
+	expected *time.Duration
+}
+
+func (t *exportTotal) add(s *domain.Session) {
+	t.count++
+	t.total += s.EndTime.Sub(s.StartTime)
+	t.timeline.Add(s)
+}
+
+// sessionSink writes exported sessions, then the totals per user.
+type sessionSink interface {
+	session(s *domain.Session) error
+	totals(totals []*exportTotal, report bool) error
+	close() error
+}
+
+var (
+	sessionHeader = []string{"user_id", "session_id", "task_id", "start_time", "end_time", "duration", "hours", "note", "tag_ids"}
+	totalsHeader  = []string{"user_id", "sessions", "total", "total_hours", "wall_clock", "wall_clock_hours"}
+	reportHeader  = []string{"credited", "credited_hours", "expected", "expected_hours"}
+)
+
+// newSessionSink returns the sink of format, csv or xlsx, writing to w.
+func newSessionSink(format string, w io.Writer, locale exportLocale) (sessionSink, error) {
+	switch format {
+	case "csv":
+		return newCSVSink(w, locale), nil
+	case "xlsx":
+		return newXLSXSink(w), nil
+	}
+	return nil, fmt.Errorf("%w %q, use csv or xlsx", domain.ErrUnknownFormat, format)
+}
+
+// exportFile names the export of format and tells its content type.
+func exportFile(name, format string) (string, string, error) {
+	switch format {
+	case "csv":
+		return name + ".csv", "text/csv; charset=utf-8", nil
+	case "xlsx":
+		return name + ".xlsx", xlsx.ContentType, nil
+	}
+	return "", "", fmt.Errorf("%w %q, use csv or xlsx", domain.ErrUnknownFormat, format)
+}
+
+func optional(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// csvSink writes sessions as rows and the totals as a second table below
+// them, after an empty row.
+type csvSink struct {
+	w      *csv.Writer
+	locale exportLocale
+	header bool
+}
+
+func newCSVSink(w io.Writer, locale exportLocale) *csvSink {
+	cw := csv.NewWriter(w)
+	cw.Comma = locale.comma
+	return &csvSink{w: cw, locale: locale}
+}
+
+func (s *csvSink) session(session *domain.Session) error {
+	if !s.header {
+		s.header = true
+		if err := s.w.Write(sessionHeader); err != nil {
+			return err
+		}
+	}
+
+	duration := session.EndTime.Sub(session.StartTime)
+	return s.w.Write([]string{
+		session.UserId,
+		strconv.FormatInt(session.Id, 10),
+		optional(session.TaskId),
+		session.StartTime.Format(exportTimeLayout),
+		session.EndTime.Format(exportTimeLayout),
+		clock(duration),
+		s.locale.hours(duration),
+		optional(session.Note),
+		strings.Join(session.TagIds, " "),
+	})
+}
+
+func (s *csvSink) durations(d time.Duration) []string {
+	return []string{clock(d), s.locale.hours(d)}
+}
+
+func (s *csvSink) totals(totals []*exportTotal, report bool) error {
+	if !s.header {
+		s.header = true
+		if err := s.w.Write(sessionHeader); err != nil {
+			return err
+		}
+	}
+
+	header := totalsHeader
+	if report {
+		header = append(slices.Clone(totalsHeader), reportHeader...)
+	}
+	if err := s.w.Write(nil); err != nil {
+		return err
+	}
+	if err := s.w.Write(header); err != nil {
+		return err
+	}
+
+	for _, t := range totals {
+		row := append([]string{t.userId, strconv.Itoa(t.count)}, s.durations(t.total)...)
+		row = append(row, s.durations(t.timeline.Total())...)
+		if report {
+			for _, d := range []*time.Duration{t.credited, t.expected} {
+				if d == nil {
+					row = append(row, "", "")
+					continue
+				}
+				row = append(row, s.durations(*d)...)
+			}
+		}
+		if err := s.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *csvSink) close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// xlsxSink writes sessions to a Sessions sheet and the totals to a Summary
+// sheet. Durations are duration cells, which spreadsheets show in the
+// reader's locale.
+type xlsxSink struct {
+	w     *xlsx.Writer
+	sheet *xlsx.Sheet
+}
+
+func newXLSXSink(w io.Writer) *xlsxSink {
+	return &xlsxSink{w: xlsx.NewWriter(w)}
+}
+
+func header(names []string) []xlsx.Cell {
+	cells := make([]xlsx.Cell, 0, len(names))
+	for _, name := range names {
+		cells = append(cells, xlsx.String(name))
+	}
+	return cells
+}
+
+// sessions starts the Sessions sheet unless it is started already.
+func (s *xlsxSink) sessions() error {
+	if s.sheet != nil {
+		return nil
+	}
+
+	sheet, err := s.w.Sheet("Sessions")
+	if err != nil {
+		return err
+	}
+	s.sheet = sheet
+	return s.sheet.Row(header(sessionHeader)...)
+}
+
+func (s *xlsxSink) session(session *domain.Session) error {
+	if err := s.sessions(); err != nil {
+		return err
+	}
+
+	duration := session.EndTime.Sub(session.StartTime)
+	return s.sheet.Row(
+		xlsx.String(session.UserId),
+		xlsx.Number(float64(session.Id)),
+		xlsx.String(optional(session.TaskId)),
+		xlsx.Time(session.StartTime),
+		xlsx.Time(*session.EndTime),
+		xlsx.Duration(duration),
+		xlsx.Number(duration.Hours()),
+		xlsx.String(optional(session.Note)),
+		xlsx.String(strings.Join(session.TagIds, " ")),
+	)
+}
+
+func (s *xlsxSink) totals(totals []*exportTotal, report bool) error {
+	if err := s.sessions(); err != nil {
+		return err
+	}
+
+	sheet, err := s.w.Sheet("Summary")
+	if err != nil {
+		return err
+	}
+
+	names := totalsHeader
+	if report {
+		names = append(slices.Clone(totalsHeader), reportHeader...)
+	}
+	if err := sheet.Row(header(names)...); err != nil {
+		return err
+	}
+
+	for _, t := range totals {
+		wallClock := t.timeline.Total()
+		row := []xlsx.Cell{
+			xlsx.String(t.userId),
+			xlsx.Int(t.count),
+			xlsx.Duration(t.total),
+			xlsx.Number(t.total.Hours()),
+			xlsx.Duration(wallClock),
+			xlsx.Number(wallClock.Hours()),
+		}
+		if report {
+			for _, d := range []*time.Duration{t.credited, t.expected} {
+				if d == nil {
+					row = append(row, xlsx.Empty(), xlsx.Empty())
+					continue
+				}
+				row = append(row, xlsx.Duration(*d), xlsx.Number(d.Hours()))
+			}
+		}
+		if err := sheet.Row(row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *xlsxSink) close() error {
+	return s.w.Close()
+}
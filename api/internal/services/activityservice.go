@@ -1,111 +1,759 @@
 package services
 
 import (
+	"context"
 	"em-test/internal/domain"
 	"em-test/internal/lib/dto"
 	"em-test/internal/lib/filters"
+	"io"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 )
 
 type ActivityRepository interface {
-	Create(*dto.SaveActivity) error
-	IsActive(userId string) (bool, error)
-	PatchEndTime(*dto.StopActivityDto) error
+	Create(context.Context, *dto.SaveActivity) (*domain.Session, error)
+	GetRunning(ctx context.Context, userId string) ([]*domain.Session, error)
+	PatchEndTime(context.Context, *dto.StopActivityDto) (*domain.Session, error)
+	Switch(context.Context, *dto.SwitchActivityDto) (stopped, started *domain.Session, err error)
 
-	GetSessions(*filters.Activity) ([]*domain.Session, error)
-	GetSummary(f *filters.Activity) (duration time.Duration, total int, err error)
+	GetSessions(context.Context, *filters.Activity) ([]*domain.Session, error)
+	GetSummary(ctx context.Context, f *filters.Activity) (duration time.Duration, total int, err error)
+	GetReport(ctx context.Context, f *filters.ActivityReport) ([]*domain.UserActivityTotal, error)
+	GetTagTotals(ctx context.Context, f *filters.ActivityReport) ([]*domain.TagTotal, error)
+	EachSession(ctx context.Context, f *filters.ActivityReport, each func(*domain.Session) error) error
+
+	ReadRecord(ctx context.Context, id int64) (*domain.ActivityRecord, error)
+	Update(ctx context.Context, userId string, d *dto.UpdateSessionDto) (*domain.Session, error)
+	HasOverlap(ctx context.Context, f *filters.Overlap) (bool, error)
+}
+
+// PeriodLocks tells whether time of a user falls into a period locked by an
+// approved timesheet.
+type PeriodLocks interface {
+	IsLocked(ctx context.Context, userId string, start time.Time, end *time.Time) (bool, error)
+}
+
+// LeaveCredits totals the time credited for approved leave per user.
+type LeaveCredits interface {
+	Credits(ctx context.Context, f *filters.Leave) (map[string]time.Duration, error)
+}
+
+// TaskReader reads the tasks sessions are tracked against.
+type TaskReader interface {
+	Read(ctx context.Context, id string) (*domain.Task, error)
+}
+
+// BudgetWatcher raises the events of budgets a finished session pushed
+// over a threshold.
+type BudgetWatcher interface {
+	Check(ctx context.Context, session *domain.Session) error
+}
+
+// OrganizationReader reads the organization of a tenant, whose settings
+// govern tracking.
+type OrganizationReader interface {
+	Read(ctx context.Context, id string) (*domain.Organization, error)
+}
+
+// ExpectedHours totals the working time users are expected to work.
+type ExpectedHours interface {
+	Expected(ctx context.Context, userIds []string, from, to time.Time) (map[string]time.Duration, error)
 }
 
 type ActivityService struct {
 	activityRepository ActivityRepository
+	locks              PeriodLocks
+	credits            LeaveCredits
+	expected           ExpectedHours
+	tasks              TaskReader
+	budgets            BudgetWatcher
+	orgs               OrganizationReader
+	publisher          EventPublisher
+	policy             *Policy
 }
 
-func NewActivityService(activityRepository ActivityRepository) *ActivityService {
+func NewActivityService(activityRepository ActivityRepository, locks PeriodLocks, credits LeaveCredits, expected ExpectedHours, tasks TaskReader, budgets BudgetWatcher, orgs OrganizationReader, publisher EventPublisher, policy *Policy) *ActivityService {
 	return &ActivityService{
 		activityRepository: activityRepository,
+		locks:              locks,
+		credits:            credits,
+		expected:           expected,
+		tasks:              tasks,
+		budgets:            budgets,
+		orgs:               orgs,
+		publisher:          publisher,
+		policy:             policy,
 	}
 }
 
-func (s *ActivityService) Start(userId string) error {
+func (s *ActivityService) requireUnlocked(ctx context.Context, userId string, start time.Time, end *time.Time) error {
+	locked, err := s.locks.IsLocked(ctx, userId, start, end)
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		return domain.ErrPeriodLocked
+	}
+	return nil
+}
+
+// requireTask checks taskId, when set, is a task of the organization.
+func (s *ActivityService) requireTask(ctx context.Context, taskId *string) error {
+	if taskId == nil {
+		return nil
+	}
+
+	_, err := s.tasks.Read(ctx, *taskId)
+	return err
+}
+
+// timerPolicy returns the timer policy of the organization of ctx.
+func (s *ActivityService) timerPolicy(ctx context.Context) (domain.TimerPolicy, error) {
+	org, err := s.orgs.Read(ctx, orgOf(ctx))
+	if err != nil {
+		return "", err
+	}
+	return org.TimerPolicy, nil
+}
+
+// overlap selects the sessions a session of userId on taskId may not
+// intersect: any other under the single timer policy, those on the same
+// task under the multiple one.
+func (s *ActivityService) overlap(ctx context.Context, userId string, taskId *string) (*filters.Overlap, error) {
+	policy, err := s.timerPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filters.Overlap{
+		UserId:   userId,
+		SameTask: policy == domain.TimerMultiple,
+		TaskId:   taskId,
+	}, nil
+}
+
+// runningSession picks among running sessions the one of sessionId, or
+// else the one on taskId, or else the only one.
+func runningSession(running []*domain.Session, sessionId *int64, taskId *string) (*domain.Session, error) {
+	if len(running) == 0 {
+		return nil, domain.ErrUserNotWorking
+	}
+
+	for _, session := range running {
+		if sessionId != nil && session.Id == *sessionId {
+			return session, nil
+		}
+		if sessionId == nil && taskId != nil && session.OnTask(taskId) {
+			return session, nil
+		}
+	}
+
+	if sessionId != nil || taskId != nil {
+		return nil, domain.ErrSessionNotFound
+	}
+
+	if len(running) > 1 {
+		return nil, domain.ErrSessionAmbiguous
+	}
+	return running[0], nil
+}
+
+// normalizeNote trims note, when set, and checks its length.
+func normalizeNote(note *string) (*string, error) {
+	if note == nil {
+		return nil, nil
+	}
+
+	normalized, err := domain.SessionNote(*note)
+	if err != nil {
+		return nil, err
+	}
+	return &normalized, nil
+}
+
+// Start begins a session for d.UserId, on d.TaskId unless it is nil, with
+// the note and tags of d. Under the multiple timer policy it runs next to
+// the running sessions on other tasks.
+func (s *ActivityService) Start(ctx context.Context, d *dto.SaveActivity) error {
 
 	fn := "ActivityService.Start"
+	userId := d.UserId
 	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
 
+	if err := s.policy.CanTrack(ctx, userId); err != nil {
+		return err
+	}
+
 	logger.Debug("checking active record")
-	isActive, err := s.activityRepository.IsActive(userId)
+	running, err := s.activityRepository.GetRunning(ctx, userId)
 	if err != nil {
 		logger.Error("checking activity error", slog.String("err", err.Error()))
 		return err
 	}
 
-	if isActive {
-		logger.Debug("found not finished activity")
-		return domain.ErrUserAlreadyWorking
+	if len(running) > 0 {
+		policy, err := s.timerPolicy(ctx)
+		if err != nil {
+			return err
+		}
+
+		if policy != domain.TimerMultiple {
+			logger.Debug("found not finished activity")
+			return domain.ErrUserAlreadyWorking
+		}
+
+		for _, session := range running {
+			if session.OnTask(d.TaskId) {
+				return domain.ErrAlreadyOnTask
+			}
+		}
+	}
+
+	now := time.Now()
+	if err := s.requireUnlocked(ctx, userId, now, nil); err != nil {
+		return err
+	}
+
+	if err := s.requireTask(ctx, d.TaskId); err != nil {
+		return err
+	}
+
+	note, err := normalizeNote(d.Note)
+	if err != nil {
+		return err
 	}
 
 	saveDto := &dto.SaveActivity{
 		UserId:    userId,
-		StartTime: time.Now(),
+		TaskId:    d.TaskId,
+		StartTime: now,
+		Note:      note,
+		TagIds:    d.TagIds,
 	}
 	logger.Debug("creating activity", slog.Any("dto", saveDto))
-	return s.activityRepository.Create(saveDto)
+	session, err := s.activityRepository.Create(ctx, saveDto)
+	if err != nil {
+		return err
+	}
+
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventActivityStarted,
+		UserId:     userId,
+		OccurredAt: session.StartTime,
+		Data:       session,
+	})
+
+	return nil
 }
 
-func (s *ActivityService) Stop(userId string) error {
+// Stop finishes the running session of d.UserId, the one of d.SessionId or
+// d.TaskId when several are running. The note and tags of d replace those
+// of the session when set.
+func (s *ActivityService) Stop(ctx context.Context, d *dto.StopActivityDto) error {
 
 	fn := "ActivityService.Stop"
+	userId := d.UserId
 	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
 
-	isActive, err := s.activityRepository.IsActive(userId)
+	if err := s.policy.CanTrack(ctx, userId); err != nil {
+		return err
+	}
+
+	running, err := s.activityRepository.GetRunning(ctx, userId)
 	if err != nil {
 		logger.Error("checking activity error", slog.String("err", err.Error()))
 		return err
 	}
 
-	if !isActive {
-		logger.Debug("user not working at this moment")
-		return domain.ErrUserNotWorking
+	target, err := runningSession(running, d.SessionId, d.TaskId)
+	if err != nil {
+		logger.Debug("no session to stop", slog.String("err", err.Error()))
+		return err
 	}
+	d.SessionId = &target.Id
 
-	d := &dto.StopActivityDto{
-		UserId:  userId,
-		EndTime: time.Now(),
+	note, err := normalizeNote(d.Note)
+	if err != nil {
+		return err
 	}
+	d.Note = note
+	d.EndTime = time.Now()
+
 	logger.Debug("patching end time", slog.Any("dto", d))
-	return s.activityRepository.PatchEndTime(d)
+	session, err := s.activityRepository.PatchEndTime(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventActivityStopped,
+		UserId:     userId,
+		OccurredAt: d.EndTime,
+		Data:       session,
+	})
+
+	// the session is stopped either way, missed alerts are only logged
+	if err := s.budgets.Check(ctx, session); err != nil {
+		logger.Error("checking budgets error", slog.String("err", err.Error()))
+	}
+
+	return nil
 }
 
-func (s *ActivityService) GetSummary(f *filters.Activity) (*domain.ActivitySummary, error) {
+// Switch finishes the running session of d.UserId, the one of d.SessionId
+// when several are running, and starts one on d.TaskId at the same
+// instant, atomically. The note and tags of d are those of the new session.
+func (s *ActivityService) Switch(ctx context.Context, d *dto.SwitchActivityDto) (*domain.Session, error) {
+	fn := "ActivityService.Switch"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	if err := s.policy.CanTrack(ctx, d.UserId); err != nil {
+		return nil, err
+	}
+
+	if err := s.requireTask(ctx, d.TaskId); err != nil {
+		return nil, err
+	}
+
+	running, err := s.activityRepository.GetRunning(ctx, d.UserId)
+	if err != nil {
+		logger.Error("checking activity error", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	from, err := runningSession(running, d.SessionId, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.SessionId = &from.Id
+
+	// the new task may not be running in a parallel session already
+	for _, session := range running {
+		if session.Id != from.Id && session.OnTask(d.TaskId) {
+			return nil, domain.ErrAlreadyOnTask
+		}
+	}
+
+	note, err := normalizeNote(d.Note)
+	if err != nil {
+		return nil, err
+	}
+	d.Note = note
+	d.At = time.Now()
+
+	if err := s.requireUnlocked(ctx, d.UserId, d.At, nil); err != nil {
+		return nil, err
+	}
+
+	logger.Debug("switching activity", slog.Any("dto", d))
+	stopped, started, err := s.activityRepository.Switch(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventActivityStopped,
+		UserId:     d.UserId,
+		OccurredAt: d.At,
+		Data:       stopped,
+	})
+
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventActivityStarted,
+		UserId:     d.UserId,
+		OccurredAt: d.At,
+		Data:       started,
+	})
+
+	// the switch is done either way, missed alerts are only logged
+	if err := s.budgets.Check(ctx, stopped); err != nil {
+		logger.Error("checking budgets error", slog.String("err", err.Error()))
+	}
+
+	return started, nil
+}
+
+func (s *ActivityService) GetSummary(ctx context.Context, f *filters.Activity) (*domain.ActivitySummary, error) {
 	fn := "ActivityService.GetSummary"
 	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
 
-	isActive, err := s.activityRepository.IsActive(f.UserId)
+	if err := s.policy.CanView(ctx, f.UserId); err != nil {
+		return nil, err
+	}
+
+	running, err := s.activityRepository.GetRunning(ctx, f.UserId)
 	if err != nil {
 		logger.Error("checking activity error", slog.String("err", err.Error()))
 		return nil, err
 	}
 
-	sessions, err := s.activityRepository.GetSessions(f)
+	sessions, err := s.activityRepository.GetSessions(ctx, f)
 	if err != nil {
 		logger.Error("getting sessions error", slog.String("err", err.Error()))
 		return nil, err
 	}
 
-	duration, total, err := s.activityRepository.GetSummary(f)
+	duration, total, err := s.activityRepository.GetSummary(ctx, f)
 	if err != nil {
 		logger.Error("getting summary error", slog.String("err", err.Error()))
 		return nil, err
 	}
 
 	summary := &domain.ActivitySummary{
-		UserId:      f.UserId,
-		IsActiveNow: isActive,
-		Sessions:    sessions,
-		TotalTime:   duration,
-		TotalCount:  total,
+		UserId:        f.UserId,
+		IsActiveNow:   len(running) > 0,
+		Sessions:      sessions,
+		TotalTime:     duration,
+		WallClockTime: domain.WallClock(sessions),
+		TotalCount:    total,
+	}
+
+	if f.GroupByTag {
+		summary.Tags, err = s.activityRepository.GetTagTotals(ctx, &filters.ActivityReport{
+			UserIds:   []string{f.UserId},
+			StartTime: f.StartTime,
+			EndTime:   f.EndTime,
+			TagIds:    f.TagIds,
+		})
+		if err != nil {
+			logger.Error("getting tag totals error", slog.String("err", err.Error()))
+			return nil, err
+		}
 	}
 
 	logger.Debug("calculated summary", slog.Any("summary", summary))
 	return summary, nil
 }
+
+// GetReport totals activity per user, optionally for a team and its
+// descendant teams. Only users visible to the caller are included.
+func (s *ActivityService) GetReport(ctx context.Context, f *filters.ActivityReport) (*domain.ActivityReport, error) {
+	fn := "ActivityService.GetReport"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	all, visible, err := s.policy.VisibleUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !all {
+		f.UserIds = visible
+	}
+
+	totals, err := s.activityRepository.GetReport(ctx, f)
+	if err != nil {
+		logger.Error("getting report error", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	credits, err := s.credits.Credits(ctx, &filters.Leave{
+		UserIds: f.UserIds,
+		TeamId:  f.TeamId,
+		From:    f.StartTime,
+		To:      f.EndTime,
+	})
+	if err != nil {
+		logger.Error("getting leave credits error", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	for _, t := range totals {
+		t.CreditedTime = credits[t.UserId]
+		delete(credits, t.UserId)
+	}
+
+	// users on leave for the whole period have no sessions to total
+	for userId, credited := range credits {
+		if credited == 0 {
+			continue
+		}
+		totals = append(totals, &domain.UserActivityTotal{
+			UserId:       userId,
+			CreditedTime: credited,
+		})
+	}
+	slices.SortFunc(totals, func(a, b *domain.UserActivityTotal) int {
+		return strings.Compare(a.UserId, b.UserId)
+	})
+
+	report := &domain.ActivityReport{
+		TeamId: f.TeamId,
+		Users:  totals,
+	}
+	for _, t := range totals {
+		report.TotalTime += t.TotalTime
+		report.TotalCount += t.TotalCount
+		report.CreditedTime += t.CreditedTime
+	}
+
+	if f.GroupByTag {
+		report.Tags, err = s.activityRepository.GetTagTotals(ctx, f)
+		if err != nil {
+			logger.Error("getting tag totals error", slog.String("err", err.Error()))
+			return nil, err
+		}
+	}
+
+	// expected time is only meaningful for a bounded period
+	if f.StartTime != nil && f.EndTime != nil {
+		userIds := make([]string, 0, len(totals))
+		for _, t := range totals {
+			userIds = append(userIds, t.UserId)
+		}
+
+		expected, err := s.expected.Expected(ctx, userIds, *f.StartTime, *f.EndTime)
+		if err != nil {
+			logger.Error("getting expected time error", slog.String("err", err.Error()))
+			return nil, err
+		}
+
+		var sum time.Duration
+		for _, t := range totals {
+			e := expected[t.UserId]
+			t.ExpectedTime = &e
+			sum += e
+		}
+		report.ExpectedTime = &sum
+	}
+
+	return report, nil
+}
+
+// ExportSummary exports the finished sessions of f.UserId with their totals
+// in format, csv or xlsx, formatting numbers for the language tag locale.
+// The sessions are read as the export is written.
+func (s *ActivityService) ExportSummary(ctx context.Context, f *filters.Activity, format, locale string) (*domain.ExportStream, error) {
+	if err := s.policy.CanView(ctx, f.UserId); err != nil {
+		return nil, err
+	}
+
+	return s.exportSessions(ctx, "activity-"+f.UserId, &filters.ActivityReport{
+		UserIds:   []string{f.UserId},
+		StartTime: f.StartTime,
+		EndTime:   f.EndTime,
+		TagIds:    f.TagIds,
+	}, nil, format, locale)
+}
+
+// ExportReport exports the finished sessions of the report of f with the
+// totals, credited and expected time per user in format, csv or xlsx,
+// formatting numbers for the language tag locale.
+func (s *ActivityService) ExportReport(ctx context.Context, f *filters.ActivityReport, format, locale string) (*domain.ExportStream, error) {
+	f.GroupByTag = false
+	report, err := s.GetReport(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.exportSessions(ctx, "activity-report", f, report, format, locale)
+}
+
+// exportSessions streams the sessions of f followed by the totals per user,
+// which take credited and expected time from report when set.
+func (s *ActivityService) exportSessions(ctx context.Context, name string, f *filters.ActivityReport, report *domain.ActivityReport, format, locale string) (*domain.ExportStream, error) {
+	format = strings.ToLower(format)
+	fileName, contentType, err := exportFile(name, format)
+	if err != nil {
+		return nil, err
+	}
+
+	write := func(w io.Writer) error {
+		sink, err := newSessionSink(format, w, localeOf(locale))
+		if err != nil {
+			return err
+		}
+
+		totals := make(map[string]*exportTotal)
+		var order []*exportTotal
+		total := func(userId string) *exportTotal {
+			t, ok := totals[userId]
+			if !ok {
+				t = &exportTotal{userId: userId}
+				totals[userId] = t
+				order = append(order, t)
+			}
+			return t
+		}
+
+		if err := s.activityRepository.EachSession(ctx, f, func(session *domain.Session) error {
+			total(session.UserId).add(session)
+			return sink.session(session)
+		}); err != nil {
+			return err
+		}
+
+		if report != nil {
+			for _, u := range report.Users {
+				t := total(u.UserId)
+				credited := u.CreditedTime
+				t.credited = &credited
+				t.expected = u.ExpectedTime
+			}
+		}
+		slices.SortFunc(order, func(a, b *exportTotal) int {
+			return strings.Compare(a.userId, b.userId)
+		})
+
+		if err := sink.totals(order, report != nil); err != nil {
+			return err
+		}
+		return sink.close()
+	}
+
+	return &domain.ExportStream{Name: fileName, ContentType: contentType, Write: write}, nil
+}
+
+// AddManual records an already finished session on behalf of a user.
+func (s *ActivityService) AddManual(ctx context.Context, d *dto.SaveActivity) (*domain.Session, error) {
+	fn := "ActivityService.AddManual"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	if err := s.policy.CanCorrect(ctx, d.UserId); err != nil {
+		return nil, err
+	}
+
+	if d.EndTime == nil || !d.EndTime.After(d.StartTime) {
+		return nil, domain.ErrInvalidInterval
+	}
+
+	if err := s.requireUnlocked(ctx, d.UserId, d.StartTime, d.EndTime); err != nil {
+		return nil, err
+	}
+
+	if err := s.requireTask(ctx, d.TaskId); err != nil {
+		return nil, err
+	}
+
+	note, err := normalizeNote(d.Note)
+	if err != nil {
+		return nil, err
+	}
+	d.Note = note
+
+	overlap, err := s.overlap(ctx, d.UserId, d.TaskId)
+	if err != nil {
+		return nil, err
+	}
+	overlap.Start, overlap.End = d.StartTime, d.EndTime
+
+	overlaps, err := s.activityRepository.HasOverlap(ctx, overlap)
+	if err != nil {
+		logger.Error("checking overlap error", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if overlaps {
+		return nil, domain.ErrSessionOverlaps
+	}
+
+	session, err := s.activityRepository.Create(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("manual session created", slog.Any("session", session))
+
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventActivityCreated,
+		UserId:     d.UserId,
+		OccurredAt: time.Now(),
+		Data:       session,
+	})
+
+	return session, nil
+}
+
+// UpdateSession corrects the start or end time, the task, the note or the
+// tags of an existing session.
+func (s *ActivityService) UpdateSession(ctx context.Context, d *dto.UpdateSessionDto) (*domain.Session, error) {
+	fn := "ActivityService.UpdateSession"
+	logger := slog.With(slog.String("fn", fn), slog.Int64("id", d.Id))
+
+	record, err := s.activityRepository.ReadRecord(ctx, d.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	userId := record.User.Id
+	if err := s.policy.CanCorrect(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	start := record.StartTime
+	if d.StartTime != nil {
+		start = *d.StartTime
+	}
+
+	end := record.EndTime
+	if d.EndTime != nil {
+		end = d.EndTime
+	}
+
+	if end != nil && !end.After(start) {
+		return nil, domain.ErrInvalidInterval
+	}
+
+	// neither the current nor the corrected time may touch a locked period
+	if err := s.requireUnlocked(ctx, userId, record.StartTime, record.EndTime); err != nil {
+		return nil, err
+	}
+	if err := s.requireUnlocked(ctx, userId, start, end); err != nil {
+		return nil, err
+	}
+
+	if err := s.requireTask(ctx, d.TaskId); err != nil {
+		return nil, err
+	}
+
+	note, err := normalizeNote(d.Note)
+	if err != nil {
+		return nil, err
+	}
+	d.Note = note
+
+	taskId := record.TaskId
+	if d.TaskId != nil {
+		taskId = d.TaskId
+	}
+
+	overlap, err := s.overlap(ctx, userId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	overlap.Start, overlap.End, overlap.ExcludeId = start, end, d.Id
+
+	overlaps, err := s.activityRepository.HasOverlap(ctx, overlap)
+	if err != nil {
+		logger.Error("checking overlap error", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if overlaps {
+		return nil, domain.ErrSessionOverlaps
+	}
+
+	session, err := s.activityRepository.Update(ctx, userId, d)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("session updated", slog.Any("session", session))
+
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventActivityUpdated,
+		UserId:     userId,
+		OccurredAt: time.Now(),
+		Data:       session,
+	})
+
+	return session, nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/filters"
+	"log/slog"
+)
+
+type AuditRepository interface {
+	ReadMany(ctx context.Context, f *filters.Audit) ([]*domain.AuditEntry, error)
+}
+
+// AuditService exposes the audit log of an organization to its admins.
+type AuditService struct {
+	repository AuditRepository
+	policy     *Policy
+}
+
+func NewAuditService(repository AuditRepository, policy *Policy) *AuditService {
+	return &AuditService{
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+func (s *AuditService) List(ctx context.Context, f *filters.Audit) ([]*domain.AuditEntry, error) {
+	const fn = "AuditService.List"
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repository.ReadMany(ctx, f)
+	if err != nil {
+		slog.Error("cannot read audit log", slog.String("fn", fn), slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"em-test/internal/config"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apiKeyPrefix     = "tt_"
+	bootstrapKeyId   = "bootstrap"
+	accessTokenType  = "Bearer"
+	signingAlgorithm = "HS256"
+)
+
+type ApiKeyRepository interface {
+	Create(ctx context.Context, d *dto.SaveApiKeyDto) (*domain.ApiKey, error)
+	ReadByHash(ctx context.Context, hash string) (*domain.ApiKey, error)
+	ReadAll(ctx context.Context) ([]*domain.ApiKey, error)
+	Revoke(ctx context.Context, id string) error
+	TouchLastUsed(ctx context.Context, id string) error
+}
+
+// tokenClaims binds an access token to the organization it was issued in.
+type tokenClaims struct {
+	Org string `json:"org"`
+	jwt.RegisteredClaims
+}
+
+type AuthService struct {
+	apiKeys ApiKeyRepository
+	users   UserRepository
+	orgs    OrganizationRepository
+	policy  *Policy
+
+	signingKid  string
+	signingKeys map[string][]byte
+	issuer      string
+	tokenTTL    time.Duration
+	apiKeyTTL   time.Duration
+	bootstrap   string
+}
+
+func NewAuthService(cfg *config.Config, apiKeys ApiKeyRepository, users UserRepository, orgs OrganizationRepository, policy *Policy) (*AuthService, error) {
+	s := &AuthService{
+		apiKeys:     apiKeys,
+		users:       users,
+		orgs:        orgs,
+		policy:      policy,
+		signingKeys: make(map[string][]byte),
+		issuer:      cfg.Auth.JwtIssuer,
+		tokenTTL:    cfg.Auth.AccessTokenTTL,
+		apiKeyTTL:   cfg.Auth.ApiKeyTTL,
+		bootstrap:   cfg.Auth.BootstrapKey,
+	}
+
+	for _, pair := range cfg.Auth.JwtSigningKeys {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("malformed jwt signing key %q, expected kid:secret", kid)
+		}
+
+		if s.signingKid == "" {
+			s.signingKid = kid
+		}
+		s.signingKeys[kid] = []byte(secret)
+	}
+
+	if s.signingKid == "" {
+		return nil, errors.New("no jwt signing keys configured")
+	}
+
+	return s, nil
+}
+
+func hashApiKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateApiKey generates a new api key. The plaintext key is returned only
+// once, the database keeps its sha256 hash.
+func (s *AuthService) CreateApiKey(ctx context.Context, d *dto.CreateApiKeyDto) (*domain.ApiKey, string, error) {
+	const fn = "AuthService.CreateApiKey"
+	logger := slog.With(slog.String("fn", fn), slog.String("name", d.Name))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, "", err
+	}
+
+	if d.Role == "" {
+		d.Role = domain.RoleKiosk
+	}
+
+	if !d.Role.IsValid() {
+		return nil, "", domain.ErrInvalidRole
+	}
+
+	if d.UserId != nil {
+		if _, err := s.users.Read(ctx, *d.UserId); err != nil {
+			logger.Debug("api key owner not found", slog.String("userId", *d.UserId))
+			return nil, "", err
+		}
+	}
+
+	prefix := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	visible := apiKeyPrefix + hex.EncodeToString(prefix)
+	raw := visible + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	expiresAt := d.ExpiresAt
+	if expiresAt == nil && s.apiKeyTTL > 0 {
+		t := time.Now().Add(s.apiKeyTTL)
+		expiresAt = &t
+	}
+
+	key, err := s.apiKeys.Create(ctx, &dto.SaveApiKeyDto{
+		Name:      d.Name,
+		Prefix:    visible,
+		Hash:      hashApiKey(raw),
+		UserId:    d.UserId,
+		Role:      d.Role,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		logger.Error("cannot save api key", slog.String("err", err.Error()))
+		return nil, "", err
+	}
+
+	logger.Info("api key created", slog.String("id", key.Id), slog.String("prefix", key.Prefix))
+	return key, raw, nil
+}
+
+func (s *AuthService) ListApiKeys(ctx context.Context) ([]*domain.ApiKey, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.apiKeys.ReadAll(ctx)
+}
+
+func (s *AuthService) RevokeApiKey(ctx context.Context, id string) error {
+	const fn = "AuthService.RevokeApiKey"
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	slog.Info("revoking api key", slog.String("fn", fn), slog.String("id", id))
+
+	return s.apiKeys.Revoke(ctx, id)
+}
+
+func (s *AuthService) AuthenticateApiKey(ctx context.Context, raw string) (*domain.Principal, error) {
+	const fn = "AuthService.AuthenticateApiKey"
+	logger := slog.With(slog.String("fn", fn))
+
+	if s.bootstrap != "" && subtle.ConstantTimeCompare([]byte(raw), []byte(s.bootstrap)) == 1 {
+		return &domain.Principal{
+			Kind: domain.PrincipalApiKey,
+			Id:   bootstrapKeyId,
+			Role: domain.RoleAdmin,
+		}, nil
+	}
+
+	key, err := s.apiKeys.ReadByHash(ctx, hashApiKey(raw))
+	if err != nil {
+		if errors.Is(err, domain.ErrApiKeyNotFound) {
+			return nil, domain.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if !key.IsUsable(time.Now()) {
+		logger.Debug("api key revoked or expired", slog.String("id", key.Id))
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if err := s.apiKeys.TouchLastUsed(ctx, key.Id); err != nil {
+		logger.Warn("cannot update api key usage", slog.String("err", err.Error()))
+	}
+
+	principal := &domain.Principal{
+		Kind:  domain.PrincipalApiKey,
+		Id:    key.Id,
+		Role:  key.Role,
+		OrgId: key.OrgId,
+	}
+	if key.UserId != nil {
+		principal.UserId = *key.UserId
+	}
+
+	return principal, nil
+}
+
+// IssueToken exchanges an api key principal for a short lived JWT acting as
+// userId. Keys bound to a user can only issue tokens for that user, other
+// keys need the kiosk or admin role, and kiosk keys only for employees.
+func (s *AuthService) IssueToken(ctx context.Context, userId string) (*domain.AccessToken, error) {
+	const fn = "AuthService.IssueToken"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	caller, ok := domain.PrincipalFrom(ctx)
+	if !ok {
+		return nil, domain.ErrUnauthorized
+	}
+
+	if caller.Kind != domain.PrincipalApiKey {
+		return nil, domain.ErrForbidden
+	}
+
+	if caller.UserId != "" && caller.UserId != userId {
+		logger.Debug("api key is bound to another user", slog.String("keyId", caller.Id))
+		return nil, domain.ErrForbidden
+	}
+
+	if caller.UserId == "" && !caller.Is(domain.RoleKiosk, domain.RoleAdmin) {
+		return nil, domain.ErrForbidden
+	}
+
+	orgId, ok := domain.OrgFrom(ctx)
+	if !ok {
+		return nil, domain.ErrOrgRequired
+	}
+
+	user, err := s.users.Read(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token carries the role of the user, a kiosk key must not gain
+	// more than clocking employees in and out
+	if caller.UserId == "" && caller.Is(domain.RoleKiosk) && user.Role != domain.RoleEmployee {
+		logger.Debug("kiosk key cannot act as user", slog.String("keyId", caller.Id), slog.String("role", string(user.Role)))
+		return nil, domain.ErrForbidden
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.tokenTTL)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
+		Org: orgId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	token.Header["kid"] = s.signingKid
+
+	signed, err := token.SignedString(s.signingKeys[s.signingKid])
+	if err != nil {
+		logger.Error("cannot sign token", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return &domain.AccessToken{
+		Token:     signed,
+		TokenType: accessTokenType,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *AuthService) AuthenticateToken(ctx context.Context, raw string) (*domain.Principal, error) {
+	const fn = "AuthService.AuthenticateToken"
+	logger := slog.With(slog.String("fn", fn))
+
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := s.signingKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{signingAlgorithm}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		logger.Debug("invalid token", slog.String("err", err.Error()))
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if claims.Subject == "" || claims.Org == "" {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	// the role is read on every request so that role changes and deleted
+	// users take effect before the token expires
+	user, err := s.users.Read(domain.WithOrg(ctx, claims.Org), claims.Subject)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	return &domain.Principal{
+		Kind:   domain.PrincipalUser,
+		Id:     user.Id,
+		UserId: user.Id,
+		Role:   user.Role,
+		OrgId:  user.OrgId,
+	}, nil
+}
+
+// ResolveOrg picks the organization a request acts in. Principals bound to
+// an organization always act in it, platform principals choose one with
+// requested and act in none when it is empty.
+func (s *AuthService) ResolveOrg(ctx context.Context, principal *domain.Principal, requested string) (string, error) {
+	const fn = "AuthService.ResolveOrg"
+	logger := slog.With(slog.String("fn", fn), slog.String("principal", principal.Id))
+
+	if !principal.IsPlatform() {
+		if requested != "" && requested != principal.OrgId {
+			logger.Debug("organization mismatch", slog.String("requested", requested))
+			return "", domain.ErrForbidden
+		}
+		return principal.OrgId, nil
+	}
+
+	if requested == "" {
+		return "", nil
+	}
+
+	org, err := s.orgs.Read(ctx, requested)
+	if err != nil {
+		return "", err
+	}
+
+	return org.Id, nil
+}
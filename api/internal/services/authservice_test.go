@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/config"
+	"em-test/internal/domain"
+	"errors"
+	"testing"
+	"time"
+)
+
+// usersStub serves users from a map, the other methods are not expected to
+// be called.
+type usersStub struct {
+	UserRepository
+	users map[string]*domain.User
+}
+
+func (r *usersStub) Read(_ context.Context, id string) (*domain.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func newTestAuthService(t *testing.T, users map[string]*domain.User) *AuthService {
+	t.Helper()
+
+	var cfg config.Config
+	cfg.Auth.JwtSigningKeys = []string{"test:secret"}
+	cfg.Auth.JwtIssuer = "test"
+	cfg.Auth.AccessTokenTTL = time.Minute
+
+	s, err := NewAuthService(&cfg, nil, &usersStub{users: users}, nil, NewPolicy(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestIssueToken(t *testing.T) {
+	const orgId = "org"
+	users := map[string]*domain.User{
+		"employee": {Id: "employee", OrgId: orgId, Role: domain.RoleEmployee},
+		"manager":  {Id: "manager", OrgId: orgId, Role: domain.RoleManager},
+		"admin":    {Id: "admin", OrgId: orgId, Role: domain.RoleAdmin},
+	}
+
+	kiosk := &domain.Principal{Kind: domain.PrincipalApiKey, Id: "kiosk", Role: domain.RoleKiosk, OrgId: orgId}
+	admin := &domain.Principal{Kind: domain.PrincipalApiKey, Id: "admin-key", Role: domain.RoleAdmin, OrgId: orgId}
+	bound := &domain.Principal{Kind: domain.PrincipalApiKey, Id: "bound", UserId: "manager", Role: domain.RoleManager, OrgId: orgId}
+	user := &domain.Principal{Kind: domain.PrincipalUser, Id: "employee", UserId: "employee", Role: domain.RoleEmployee, OrgId: orgId}
+
+	tests := []struct {
+		name    string
+		caller  *domain.Principal
+		userId  string
+		wantErr error
+	}{
+		{"kiosk for employee", kiosk, "employee", nil},
+		{"kiosk for manager", kiosk, "manager", domain.ErrForbidden},
+		{"kiosk for admin", kiosk, "admin", domain.ErrForbidden},
+		{"admin key for admin", admin, "admin", nil},
+		{"bound key for its user", bound, "manager", nil},
+		{"bound key for another user", bound, "employee", domain.ErrForbidden},
+		{"user token", user, "employee", domain.ErrForbidden},
+		{"unknown user", kiosk, "nobody", domain.ErrUserNotFound},
+	}
+
+	s := newTestAuthService(t, users)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := domain.WithOrg(domain.WithPrincipal(context.Background(), tt.caller), orgId)
+
+			token, err := s.IssueToken(ctx, tt.userId)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IssueToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			principal, err := s.AuthenticateToken(ctx, token.Token)
+			if err != nil {
+				t.Fatalf("AuthenticateToken() error = %v", err)
+			}
+			if principal.UserId != tt.userId || principal.Role != users[tt.userId].Role {
+				t.Errorf("AuthenticateToken() = %+v, want user %s", principal, tt.userId)
+			}
+		})
+	}
+}
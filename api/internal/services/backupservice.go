@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+type BackupRepository interface {
+	Export(ctx context.Context, schema func(version uint, dirty bool) error, row func(table string, data json.RawMessage) error) error
+	Restore(ctx context.Context, version uint, next func() (string, json.RawMessage, error)) (map[string]int, error)
+}
+
+// BackupService exports the data of all organizations as a backup in JSON
+// Lines and restores such backups into an empty database, for platform
+// admins only.
+type BackupService struct {
+	repository BackupRepository
+	policy     *Policy
+}
+
+func NewBackupService(repository BackupRepository, policy *Policy) *BackupService {
+	return &BackupService{
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+// Export streams a backup: the header with the schema version golang-migrate
+// migrated the database to, the rows of all tables from one snapshot and
+// the end with their number. A dirty schema is not exported, its version
+// does not tell what the tables are like.
+func (s *BackupService) Export(ctx context.Context) (*domain.ExportStream, error) {
+	const fn = "BackupService.Export"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequirePlatform(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	write := func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+
+		rows := 0
+		err := s.repository.Export(ctx,
+			func(version uint, dirty bool) error {
+				if dirty {
+					return fmt.Errorf("%w: database is dirty at version %d", domain.ErrSchemaMismatch, version)
+				}
+				return encoder.Encode(&domain.BackupRecord{
+					Type:          domain.BackupHeader,
+					Format:        domain.BackupFormat,
+					SchemaVersion: version,
+					CreatedAt:     &now,
+				})
+			},
+			func(table string, data json.RawMessage) error {
+				rows++
+				return encoder.Encode(&domain.BackupRecord{Type: domain.BackupRow, Table: table, Data: data})
+			},
+		)
+		if err != nil {
+			logger.Error("cannot export backup", slog.String("err", err.Error()))
+			return err
+		}
+
+		logger.Info("backup exported", slog.Int("rows", rows))
+		return encoder.Encode(&domain.BackupRecord{Type: domain.BackupEnd, Rows: &rows})
+	}
+
+	return &domain.ExportStream{
+		Name:        "backup-" + now.Format("20060102T150405Z") + ".jsonl",
+		ContentType: "application/x-ndjson",
+		Write:       write,
+	}, nil
+}
+
+// Restore reads a backup from r into the database, which must be at the
+// schema version of the backup and empty. Nothing is restored unless the
+// whole backup is, up to its end.
+func (s *BackupService) Restore(ctx context.Context, r io.Reader) (*domain.RestoreReport, error) {
+	const fn = "BackupService.Restore"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequirePlatform(ctx); err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(r)
+
+	var header domain.BackupRecord
+	if err := decoder.Decode(&header); err != nil || header.Type != domain.BackupHeader {
+		return nil, fmt.Errorf("%w: the header is missing", domain.ErrInvalidBackup)
+	}
+	if header.Format != domain.BackupFormat {
+		return nil, fmt.Errorf("%w: format %d is not supported", domain.ErrInvalidBackup, header.Format)
+	}
+
+	rows := 0
+	next := func() (string, json.RawMessage, error) {
+		var record domain.BackupRecord
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", nil, fmt.Errorf("%w: the backup is truncated", domain.ErrInvalidBackup)
+			}
+			return "", nil, fmt.Errorf("%w: %s", domain.ErrInvalidBackup, err.Error())
+		}
+
+		switch record.Type {
+		case domain.BackupRow:
+			if record.Table == "" || len(record.Data) == 0 {
+				return "", nil, fmt.Errorf("%w: row %d lacks its table or data", domain.ErrInvalidBackup, rows+1)
+			}
+			rows++
+			return record.Table, record.Data, nil
+		case domain.BackupEnd:
+			if record.Rows == nil || *record.Rows != rows {
+				return "", nil, fmt.Errorf("%w: %d rows were read, the end tells otherwise", domain.ErrInvalidBackup, rows)
+			}
+			if decoder.More() {
+				return "", nil, fmt.Errorf("%w: records follow the end", domain.ErrInvalidBackup)
+			}
+			return "", nil, io.EOF
+		}
+		return "", nil, fmt.Errorf("%w: unexpected %q record", domain.ErrInvalidBackup, record.Type)
+	}
+
+	tables, err := s.repository.Restore(ctx, header.SchemaVersion, next)
+	if err != nil {
+		logger.Error("cannot restore backup", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Info("backup restored", slog.Uint64("schemaVersion", uint64(header.SchemaVersion)), slog.Int("rows", rows))
+
+	return &domain.RestoreReport{
+		SchemaVersion: header.SchemaVersion,
+		Tables:        tables,
+		Rows:          rows,
+	}, nil
+}
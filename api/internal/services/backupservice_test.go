@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"em-test/internal/domain"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type backupRow struct {
+	table string
+	data  string
+}
+
+// backupStub exports rows at version and keeps the rows a restore reads.
+type backupStub struct {
+	version  uint
+	dirty    bool
+	rows     []backupRow
+	restored []backupRow
+}
+
+func (r *backupStub) Export(_ context.Context, schema func(version uint, dirty bool) error, row func(table string, data json.RawMessage) error) error {
+	if err := schema(r.version, r.dirty); err != nil {
+		return err
+	}
+	for _, rw := range r.rows {
+		if err := row(rw.table, json.RawMessage(rw.data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *backupStub) Restore(_ context.Context, version uint, next func() (string, json.RawMessage, error)) (map[string]int, error) {
+	if version != r.version {
+		return nil, domain.ErrSchemaMismatch
+	}
+
+	counts := make(map[string]int)
+	for {
+		table, data, err := next()
+		if errors.Is(err, io.EOF) {
+			return counts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.restored = append(r.restored, backupRow{table, string(data)})
+		counts[table]++
+	}
+}
+
+func platformContext() context.Context {
+	admin := &domain.Principal{Kind: domain.PrincipalUser, Id: "root", Role: domain.RoleAdmin}
+	return domain.WithPrincipal(context.Background(), admin)
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	ctx := platformContext()
+	rows := []backupRow{
+		{"organizations", `{"id":"org","name":"Acme"}`},
+		{"users", `{"id":"ann","org_id":"org","manager_id":null}`},
+		{"users", `{"id":"bob","org_id":"org","manager_id":"ann"}`},
+	}
+
+	source := &backupStub{version: 20, rows: rows}
+	stream, err := NewBackupService(source, NewPolicy(nil)).Export(ctx)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := stream.Write(&buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(rows)+2 {
+		t.Errorf("backup has %d lines, want %d", lines, len(rows)+2)
+	}
+
+	target := &backupStub{version: 20}
+	report, err := NewBackupService(target, NewPolicy(nil)).Restore(ctx, &buf)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	want := &domain.RestoreReport{SchemaVersion: 20, Tables: map[string]int{"organizations": 1, "users": 2}, Rows: 3}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Restore() = %+v, want %+v", report, want)
+	}
+	if !reflect.DeepEqual(target.restored, rows) {
+		t.Errorf("Restore() restored %v, want %v", target.restored, rows)
+	}
+}
+
+func TestBackupExportDirty(t *testing.T) {
+	stream, err := NewBackupService(&backupStub{version: 20, dirty: true}, NewPolicy(nil)).Export(platformContext())
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := stream.Write(&buf); !errors.Is(err, domain.ErrSchemaMismatch) {
+		t.Errorf("Write() error = %v, want %v", err, domain.ErrSchemaMismatch)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("dirty export wrote %q", buf.String())
+	}
+}
+
+func TestBackupForbidden(t *testing.T) {
+	admin := &domain.Principal{Kind: domain.PrincipalUser, Id: "admin", UserId: "admin", Role: domain.RoleAdmin, OrgId: "org"}
+	ctx := domain.WithOrg(domain.WithPrincipal(context.Background(), admin), admin.OrgId)
+	s := NewBackupService(&backupStub{version: 20}, NewPolicy(nil))
+
+	if _, err := s.Export(ctx); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("Export() error = %v, want %v", err, domain.ErrForbidden)
+	}
+	if _, err := s.Restore(ctx, strings.NewReader("")); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("Restore() error = %v, want %v", err, domain.ErrForbidden)
+	}
+}
+
+func TestBackupRestoreRejects(t *testing.T) {
+	const (
+		header = `{"type":"header","format":1,"schemaVersion":20}`
+		row    = `{"type":"row","table":"organizations","data":{"id":"org"}}`
+	)
+
+	tests := []struct {
+		name    string
+		lines   []string
+		wantErr error
+	}{
+		{"empty", nil, domain.ErrInvalidBackup},
+		{"header missing", []string{row, `{"type":"end","rows":1}`}, domain.ErrInvalidBackup},
+		{"format unsupported", []string{`{"type":"header","format":2,"schemaVersion":20}`}, domain.ErrInvalidBackup},
+		{"truncated", []string{header, row}, domain.ErrInvalidBackup},
+		{"end missing its count", []string{header, row, `{"type":"end"}`}, domain.ErrInvalidBackup},
+		{"end of another count", []string{header, row, `{"type":"end","rows":2}`}, domain.ErrInvalidBackup},
+		{"records after the end", []string{header, row, `{"type":"end","rows":1}`, row}, domain.ErrInvalidBackup},
+		{"row without table", []string{header, `{"type":"row","data":{"id":"org"}}`, `{"type":"end","rows":1}`}, domain.ErrInvalidBackup},
+		{"row without data", []string{header, `{"type":"row","table":"organizations"}`, `{"type":"end","rows":1}`}, domain.ErrInvalidBackup},
+		{"second header", []string{header, header, `{"type":"end","rows":0}`}, domain.ErrInvalidBackup},
+		{"unknown record", []string{header, `{"type":"comment"}`, `{"type":"end","rows":0}`}, domain.ErrInvalidBackup},
+		{"garbage", []string{header, `{"type":"row",`}, domain.ErrInvalidBackup},
+		{"other version", []string{`{"type":"header","format":1,"schemaVersion":19}`, `{"type":"end","rows":0}`}, domain.ErrSchemaMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := strings.Join(tt.lines, "\n")
+			_, err := NewBackupService(&backupStub{version: 20}, NewPolicy(nil)).Restore(platformContext(), strings.NewReader(data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Restore() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
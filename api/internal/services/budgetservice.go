@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+type BudgetRepository interface {
+	Save(ctx context.Context, d *dto.SaveBudgetDto) (*domain.Budget, error)
+	ReadMany(ctx context.Context, f *filters.Budgets) ([]*domain.Budget, error)
+	Delete(ctx context.Context, f *filters.Budgets) error
+	MarkNotified(ctx context.Context, alert *domain.BudgetAlert, userId string) (bool, error)
+}
+
+// ProjectCosts reads the time tracked on projects and the rates it is
+// priced at.
+type ProjectCosts interface {
+	Read(ctx context.Context, id string) (*domain.Project, error)
+	ReadTotals(ctx context.Context, f *filters.ProjectSummary) ([]*domain.ProjectUserTotal, error)
+	ReadRates(ctx context.Context, projectId string) ([]*domain.ProjectRate, error)
+}
+
+// BudgetService keeps the time and money budgets of projects and tasks and
+// raises an event when their burn reaches a threshold. Admins and managers
+// maintain and watch budgets.
+type BudgetService struct {
+	repository BudgetRepository
+	projects   ProjectCosts
+	tasks      TaskReader
+	publisher  EventPublisher
+	policy     *Policy
+}
+
+func NewBudgetService(repository BudgetRepository, projects ProjectCosts, tasks TaskReader, publisher EventPublisher, policy *Policy) *BudgetService {
+	return &BudgetService{
+		repository: repository,
+		projects:   projects,
+		tasks:      tasks,
+		publisher:  publisher,
+		policy:     policy,
+	}
+}
+
+// Save sets the budget of a project, or of a task when d.TaskId is set.
+func (s *BudgetService) Save(ctx context.Context, d *dto.SaveBudgetDto) (*domain.Budget, error) {
+	const fn = "BudgetService.Save"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	if d.TaskId != nil {
+		d.ProjectId = nil
+		if _, err := s.tasks.Read(ctx, *d.TaskId); err != nil {
+			return nil, err
+		}
+	} else if _, err := s.projects.Read(ctx, *d.ProjectId); err != nil {
+		return nil, err
+	}
+
+	budget := domain.Budget{TimeMinutes: d.TimeMinutes, Amount: d.Amount, Thresholds: d.Thresholds}
+	if err := budget.Validate(); err != nil {
+		return nil, err
+	}
+	d.Thresholds = budget.Thresholds
+
+	saved, err := s.repository.Save(ctx, d)
+	if err != nil {
+		logger.Error("cannot save budget", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+func (s *BudgetService) List(ctx context.Context, f *filters.Budgets) ([]*domain.Budget, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadMany(ctx, f)
+}
+
+// Delete removes the budget of the project or the task of f.
+func (s *BudgetService) Delete(ctx context.Context, f *filters.Budgets) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, f)
+}
+
+// Burn returns how much of the budget of the project or the task of f is
+// spent and when the rest runs out.
+func (s *BudgetService) Burn(ctx context.Context, f *filters.Budgets) (*domain.BudgetBurn, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	budgets, err := s.repository.ReadMany(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(budgets) == 0 {
+		return nil, domain.ErrBudgetNotFound
+	}
+
+	return s.burn(ctx, budgets[0], time.Now())
+}
+
+// burn totals the finished sessions on the project or the task of budget
+// over all time and over the burn window before now.
+func (s *BudgetService) burn(ctx context.Context, budget *domain.Budget, now time.Time) (*domain.BudgetBurn, error) {
+	f := &filters.ProjectSummary{TaskId: budget.TaskId}
+	if budget.TaskId != nil {
+		task, err := s.tasks.Read(ctx, *budget.TaskId)
+		if err != nil {
+			return nil, err
+		}
+		f.ProjectId = task.ProjectId
+	} else {
+		f.ProjectId = *budget.ProjectId
+	}
+
+	project, err := s.projects.Read(ctx, f.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := s.projects.ReadRates(ctx, f.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := s.projects.ReadTotals(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	windowStart := now.Add(-domain.BurnWindow)
+	window := *f
+	window.StartTime = &windowStart
+
+	windowTotals, err := s.projects.ReadTotals(ctx, &window)
+	if err != nil {
+		return nil, err
+	}
+
+	var spent, spentLately time.Duration
+	for _, t := range totals {
+		spent += t.TotalTime
+	}
+	for _, t := range windowTotals {
+		spentLately += t.TotalTime
+	}
+
+	burn := budget.Burn(spent, project.Cost(totals, rates), spentLately, project.Cost(windowTotals, rates), now)
+	burn.Currency = project.Currency
+
+	return burn, nil
+}
+
+// Check raises an event for each budget of the task of a just finished
+// session, and of its project, that reached a threshold it has not
+// reached before.
+func (s *BudgetService) Check(ctx context.Context, session *domain.Session) error {
+	const fn = "BudgetService.Check"
+	logger := slog.With(slog.String("fn", fn), slog.Int64("sessionId", session.Id))
+
+	if session.TaskId == nil {
+		return nil
+	}
+
+	task, err := s.tasks.Read(ctx, *session.TaskId)
+	if err != nil {
+		return err
+	}
+
+	taskBudgets, err := s.repository.ReadMany(ctx, &filters.Budgets{TaskId: &task.Id})
+	if err != nil {
+		return err
+	}
+
+	projectBudgets, err := s.repository.ReadMany(ctx, &filters.Budgets{ProjectId: &task.ProjectId})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, budget := range append(taskBudgets, projectBudgets...) {
+		burn, err := s.burn(ctx, budget, time.Now())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		crossed := burn.Crossed()
+		if crossed <= budget.NotifiedPercent {
+			continue
+		}
+
+		alert := &domain.BudgetAlert{Threshold: crossed, Burn: burn}
+		marked, err := s.repository.MarkNotified(ctx, alert, session.UserId)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !marked {
+			continue
+		}
+
+		logger.Info("budget reached threshold", slog.String("budgetId", budget.Id), slog.Int("threshold", crossed))
+
+		s.publisher.Publish(domain.Event{
+			OrgId:      orgOf(ctx),
+			Type:       domain.EventBudgetThreshold,
+			UserId:     session.UserId,
+			OccurredAt: time.Now(),
+			Data:       alert,
+		})
+	}
+
+	return errors.Join(errs...)
+}
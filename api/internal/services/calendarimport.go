@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxEventDays bounds how many days a single iCalendar event may span.
+const maxEventDays = 31
+
+// parseCalendarFile reads the days of an iCalendar or CSV file, format is
+// guessed from data when empty.
+func parseCalendarFile(format string, data []byte) ([]*dto.SaveCalendarDayDto, error) {
+	if format == "" {
+		format = "csv"
+		if bytes.Contains(data, []byte("BEGIN:VCALENDAR")) {
+			format = "ics"
+		}
+	}
+
+	switch format {
+	case "ics", "ical":
+		return parseICal(data)
+	case "csv":
+		return parseCalendarCSV(data)
+	}
+	return nil, fmt.Errorf("%w: unknown format %q", domain.ErrInvalidCalendarFile, format)
+}
+
+// parseICal reads the events of an iCalendar file as holidays. All day
+// events end the day before DTEND, other events on the day of DTEND.
+// Recurring events are rejected rather than read as their first
+// occurrence.
+func parseICal(data []byte) ([]*dto.SaveCalendarDayDto, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	// long lines are folded by starting the continuation with a blank
+	text = strings.ReplaceAll(text, "\n ", "")
+	text = strings.ReplaceAll(text, "\n\t", "")
+
+	days := make([]*dto.SaveCalendarDayDto, 0)
+
+	var (
+		inEvent         bool
+		summary         string
+		start, end      string
+		recurring       bool
+		calendarStarted bool
+		events          int
+	)
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		name, value, ok := splitICalLine(line)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not a property", domain.ErrInvalidCalendarFile, line)
+		}
+		property, _, _ := strings.Cut(name, ";")
+
+		switch strings.ToUpper(property) {
+		case "BEGIN":
+			switch strings.ToUpper(value) {
+			case "VCALENDAR":
+				calendarStarted = true
+			case "VEVENT":
+				inEvent = true
+				events++
+				summary, start, end, recurring = "", "", "", false
+			}
+		case "END":
+			if !inEvent || strings.ToUpper(value) != "VEVENT" {
+				continue
+			}
+			inEvent = false
+
+			if recurring {
+				return nil, fmt.Errorf("%w: event %d recurs, list its dates instead", domain.ErrInvalidCalendarFile, events)
+			}
+
+			event, err := icalEvent(summary, start, end)
+			if err != nil {
+				return nil, fmt.Errorf("%w: event %d: %s", domain.ErrInvalidCalendarFile, events, err.Error())
+			}
+			days = append(days, event...)
+		case "SUMMARY":
+			summary = unescapeICal(value)
+		case "DTSTART":
+			start = value
+		case "DTEND":
+			end = value
+		case "RRULE", "RDATE":
+			recurring = true
+		}
+	}
+
+	if !calendarStarted {
+		return nil, fmt.Errorf("%w: missing BEGIN:VCALENDAR", domain.ErrInvalidCalendarFile)
+	}
+
+	return days, nil
+}
+
+// splitICalLine splits a content line at the colon that ends its name and
+// parameters, colons within quoted parameter values are skipped.
+func splitICalLine(line string) (name, value string, ok bool) {
+	quoted := false
+	for i, r := range line {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				return line[:i], line[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func unescapeICal(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// icalEvent returns a holiday for every day of an event.
+func icalEvent(summary, start, end string) ([]*dto.SaveCalendarDayDto, error) {
+	if start == "" {
+		return nil, errors.New("DTSTART is missing")
+	}
+
+	first, err := icalDate(start)
+	if err != nil {
+		return nil, err
+	}
+
+	last := first
+	if end != "" {
+		if last, err = icalDate(end); err != nil {
+			return nil, err
+		}
+		// the end of an all day event is exclusive
+		if len(end) == len("20060102") && last.After(first) {
+			last = last.AddDate(0, 0, -1)
+		}
+	}
+
+	if last.Before(first) || last.Sub(first) >= maxEventDays*24*time.Hour {
+		return nil, fmt.Errorf("events must span 1 to %d days", maxEventDays)
+	}
+
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		summary = "Holiday"
+	}
+
+	days := make([]*dto.SaveCalendarDayDto, 0, 1)
+	for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+		days = append(days, &dto.SaveCalendarDayDto{
+			Date: date,
+			Name: summary,
+			Kind: domain.CalendarHoliday,
+		})
+	}
+	return days, nil
+}
+
+// icalDate reads the date of a DATE or DATE-TIME value as written, the
+// time and its zone are ignored.
+func icalDate(value string) (time.Time, error) {
+	if len(value) < len("20060102") {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	date, err := time.Parse("20060102", value[:len("20060102")])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return date, nil
+}
+
+// parseCalendarCSV reads rows of date, name and optionally kind and the
+// weekday a working day follows. Dates are YYYY-MM-DD, kind defaults to
+// holiday. A first row that does not start with a date is a header.
+func parseCalendarCSV(data []byte) ([]*dto.SaveCalendarDayDto, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	days := make([]*dto.SaveCalendarDayDto, 0)
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", domain.ErrInvalidCalendarFile, err.Error())
+		}
+		row, _ := reader.FieldPos(0)
+
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff")))
+		if err != nil {
+			if first {
+				continue
+			}
+			return nil, fmt.Errorf("%w: row %d: date must be YYYY-MM-DD", domain.ErrInvalidCalendarFile, row)
+		}
+
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%w: row %d: name is missing", domain.ErrInvalidCalendarFile, row)
+		}
+
+		day := &dto.SaveCalendarDayDto{
+			Date: date,
+			Name: strings.TrimSpace(record[1]),
+			Kind: domain.CalendarHoliday,
+		}
+
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			day.Kind = domain.CalendarDayKind(strings.TrimSpace(record[2]))
+		}
+
+		if len(record) > 3 && strings.TrimSpace(record[3]) != "" {
+			weekday, err := strconv.Atoi(strings.TrimSpace(record[3]))
+			if err != nil {
+				return nil, fmt.Errorf("%w: row %d: weekday must be a number", domain.ErrInvalidCalendarFile, row)
+			}
+			day.AsWeekday = &weekday
+		}
+
+		days = append(days, day)
+	}
+
+	return days, nil
+}
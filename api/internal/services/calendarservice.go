@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// maxImportDays bounds the number of days read from one calendar file.
+const maxImportDays = 1000
+
+type CalendarRepository interface {
+	Save(ctx context.Context, days []*dto.SaveCalendarDayDto) ([]*domain.CalendarDay, error)
+	ReadMany(ctx context.Context, f *filters.Calendar) ([]*domain.CalendarDay, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type ScheduleResolver interface {
+	ReadEffective(ctx context.Context, userId string) (*domain.Schedule, error)
+}
+
+// CalendarService manages the holidays and transferred working days of an
+// organization. Days without a region apply to everyone, days of a region
+// to the users whose schedule is in that region. Together with schedules
+// they decide the expected working time of a user.
+type CalendarService struct {
+	repository CalendarRepository
+	schedules  ScheduleResolver
+	policy     *Policy
+}
+
+func NewCalendarService(repository CalendarRepository, schedules ScheduleResolver, policy *Policy) *CalendarService {
+	return &CalendarService{
+		repository: repository,
+		schedules:  schedules,
+		policy:     policy,
+	}
+}
+
+// Save stores a calendar day, replacing the day of its region and date.
+func (s *CalendarService) Save(ctx context.Context, d *dto.SaveCalendarDayDto) (*domain.CalendarDay, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	days, err := s.save(ctx, []*dto.SaveCalendarDayDto{d})
+	if err != nil {
+		return nil, err
+	}
+
+	return days[0], nil
+}
+
+// Import stores the days of an iCalendar or CSV file in a region. The file
+// is stored as a whole or not at all.
+func (s *CalendarService) Import(ctx context.Context, d *dto.ImportCalendarDto) ([]*domain.CalendarDay, error) {
+	const fn = "CalendarService.Import"
+	logger := slog.With(slog.String("fn", fn), slog.String("region", d.Region))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	days, err := parseCalendarFile(strings.ToLower(d.Format), d.Data)
+	if err != nil {
+		logger.Debug("cannot parse calendar", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if len(days) == 0 {
+		return nil, fmt.Errorf("%w: no days found", domain.ErrInvalidCalendarFile)
+	}
+	if len(days) > maxImportDays {
+		return nil, fmt.Errorf("%w: more than %d days", domain.ErrInvalidCalendarFile, maxImportDays)
+	}
+
+	// a later line for the same date wins, as it would when saved one by one
+	unique := make([]*dto.SaveCalendarDayDto, 0, len(days))
+	index := make(map[string]int, len(days))
+	for _, day := range days {
+		day.Region = d.Region
+
+		date := day.Date.Format("2006-01-02")
+		if i, ok := index[date]; ok {
+			unique[i] = day
+			continue
+		}
+		index[date] = len(unique)
+		unique = append(unique, day)
+	}
+
+	saved, err := s.save(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("imported calendar", slog.Int("days", len(saved)))
+
+	return saved, nil
+}
+
+func (s *CalendarService) save(ctx context.Context, days []*dto.SaveCalendarDayDto) ([]*domain.CalendarDay, error) {
+	const fn = "CalendarService.save"
+	logger := slog.With(slog.String("fn", fn))
+
+	for _, d := range days {
+		d.Region = strings.TrimSpace(d.Region)
+		d.Name = strings.TrimSpace(d.Name)
+		if d.Kind == "" {
+			d.Kind = domain.CalendarHoliday
+		}
+
+		day := domain.CalendarDay{Name: d.Name, Kind: d.Kind, AsWeekday: d.AsWeekday}
+		if err := day.Validate(); err != nil {
+			return nil, fmt.Errorf("%w (%s)", err, d.Date.Format("2006-01-02"))
+		}
+	}
+
+	saved, err := s.repository.Save(ctx, days)
+	if err != nil {
+		logger.Error("cannot save calendar days", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+// List returns the calendar days of f, ordered by date.
+func (s *CalendarService) List(ctx context.Context, f *filters.Calendar) ([]*domain.CalendarDay, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadMany(ctx, f)
+}
+
+func (s *CalendarService) Delete(ctx context.Context, id string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, id)
+}
+
+// Calendar returns the days of region and of the whole organization from
+// from to to. Callers check access themselves.
+func (s *CalendarService) Calendar(ctx context.Context, region string, from, to time.Time) (domain.Calendar, error) {
+	days, err := s.repository.ReadMany(ctx, &filters.Calendar{
+		Regions: []string{"", region},
+		From:    &from,
+		To:      &to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewCalendar(days), nil
+}
+
+// ForUser returns the schedule of userId, nil when they have none, and the
+// calendar of its region from from to to. Callers check access themselves.
+func (s *CalendarService) ForUser(ctx context.Context, userId string, from, to time.Time) (*domain.Schedule, domain.Calendar, error) {
+	schedule, err := s.schedule(ctx, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	calendar, err := s.Calendar(ctx, regionOf(schedule), from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return schedule, calendar, nil
+}
+
+// schedule returns the schedule of userId, nil when they have none.
+func (s *CalendarService) schedule(ctx context.Context, userId string) (*domain.Schedule, error) {
+	schedule, err := s.schedules.ReadEffective(ctx, userId)
+	if errors.Is(err, domain.ErrNoSchedule) {
+		return nil, nil
+	}
+	return schedule, err
+}
+
+func regionOf(schedule *domain.Schedule) string {
+	if schedule == nil {
+		return ""
+	}
+	return schedule.Region
+}
+
+// Expected returns the expected working time of each of userIds over the
+// days from from to to that have already begun. Callers check access
+// themselves.
+func (s *CalendarService) Expected(ctx context.Context, userIds []string, from, to time.Time) (map[string]time.Duration, error) {
+	if now := time.Now(); to.After(now) {
+		to = now
+	}
+
+	expected := make(map[string]time.Duration, len(userIds))
+	if to.Before(from) {
+		return expected, nil
+	}
+
+	calendars := make(map[string]domain.Calendar)
+	for _, userId := range userIds {
+		schedule, err := s.schedule(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		region := regionOf(schedule)
+		calendar, ok := calendars[region]
+		if !ok {
+			if calendar, err = s.Calendar(ctx, region, from, to); err != nil {
+				return nil, err
+			}
+			calendars[region] = calendar
+		}
+
+		expected[userId] = calendar.ExpectedTime(schedule, from, to)
+	}
+
+	return expected, nil
+}
@@ -0,0 +1,16 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+)
+
+type EventPublisher interface {
+	Publish(e domain.Event)
+}
+
+// orgOf returns the organization events published for ctx belong to.
+func orgOf(ctx context.Context) string {
+	orgId, _ := domain.OrgFrom(ctx)
+	return orgId
+}
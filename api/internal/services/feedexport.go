@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bytes"
+	"em-test/internal/domain"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icalTimeLayout writes UTC date-times of iCalendar properties.
+const icalTimeLayout = "20060102T150405Z"
+
+// icalLineLength is the octet length past which lines are folded.
+const icalLineLength = 75
+
+// sessionCalendar writes sessions as the events of an iCalendar, summarized
+// by the titles of their tasks and described by their notes. The running
+// session ends at now.
+func sessionCalendar(feed *domain.Feed, sessions []*domain.Session, titles map[string]string, now time.Time) []byte {
+	var buf bytes.Buffer
+	line := func(name, value string) {
+		writeICalLine(&buf, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//time-tracker//sessions//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", "Work sessions")
+	// how often subscribed calendars should poll
+	line("REFRESH-INTERVAL;VALUE=DURATION", "PT15M")
+	line("X-PUBLISHED-TTL", "PT15M")
+
+	stamp := now.UTC().Format(icalTimeLayout)
+	for _, session := range sessions {
+		summary := "Work"
+		if session.TaskId != nil {
+			if title := titles[*session.TaskId]; title != "" {
+				summary = title
+			} else {
+				summary = "Task " + *session.TaskId
+			}
+		}
+
+		end := now
+		if session.EndTime != nil {
+			end = *session.EndTime
+		} else {
+			summary += " (running)"
+		}
+
+		line("BEGIN", "VEVENT")
+		line("UID", fmt.Sprintf("session-%d@%s", session.Id, feed.OrgId))
+		line("DTSTAMP", stamp)
+		line("DTSTART", session.StartTime.UTC().Format(icalTimeLayout))
+		line("DTEND", end.UTC().Format(icalTimeLayout))
+		line("SUMMARY", escapeICalText(summary))
+		if session.Note != nil {
+			line("DESCRIPTION", escapeICalText(*session.Note))
+		}
+		line("TRANSP", "OPAQUE")
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+// escapeICalText escapes a TEXT value.
+func escapeICalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeICalLine writes a content line ended by CRLF, folded into lines of
+// at most icalLineLength octets without splitting characters.
+func writeICalLine(buf *bytes.Buffer, line string) {
+	limit := icalLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with the folding space
+		limit = icalLineLength - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
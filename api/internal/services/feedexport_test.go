@@ -0,0 +1,109 @@
+package services
+
+import (
+	"bytes"
+	"em-test/internal/domain"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeICalText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Design review", "Design review"},
+		{"a,b;c", `a\,b\;c`},
+		{`C:\temp`, `C:\\temp`},
+		{"one\ntwo", `one\ntwo`},
+		{"one\r\ntwo\rthree", `one\ntwo\nthree`},
+		{`\,`, `\\\,`},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := escapeICalText(tt.in); got != tt.want {
+			t.Errorf("escapeICalText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteICalLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"short", "SUMMARY:Work", "SUMMARY:Work\r\n"},
+		{"at the limit", strings.Repeat("a", 75), strings.Repeat("a", 75) + "\r\n"},
+		{"past the limit", strings.Repeat("a", 76), strings.Repeat("a", 75) + "\r\n a\r\n"},
+		{
+			"continuations hold the folding space",
+			strings.Repeat("a", 75+74+1),
+			strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n a\r\n",
+		},
+		{"characters kept whole", strings.Repeat("a", 74) + "é", strings.Repeat("a", 74) + "\r\n é\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeICalLine(&buf, tt.line)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeICalLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteICalLineUnfolds(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("Überstunden für das Projekt, ", 20)
+
+	var buf bytes.Buffer
+	writeICalLine(&buf, line)
+
+	folded := strings.TrimSuffix(buf.String(), "\r\n")
+	for _, physical := range strings.Split(folded, "\r\n") {
+		if len(physical) > icalLineLength {
+			t.Errorf("line of %d octets exceeds %d", len(physical), icalLineLength)
+		}
+		if !utf8.ValidString(physical) {
+			t.Errorf("line %q splits a character", physical)
+		}
+	}
+
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != line {
+		t.Errorf("unfolded line = %q, want %q", unfolded, line)
+	}
+}
+
+func TestSessionCalendar(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	now := start.Add(5 * time.Hour)
+	taskId := "t1"
+	note := "Fixed bugs, wrote docs"
+
+	sessions := []*domain.Session{
+		{Id: 1, TaskId: &taskId, StartTime: start, EndTime: &end, Note: &note},
+		{Id: 2, StartTime: start.Add(4 * time.Hour)},
+	}
+	titles := map[string]string{"t1": "Release; v2"}
+
+	got := string(sessionCalendar(&domain.Feed{OrgId: "org"}, sessions, titles, now))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:session-1@org\r\nDTSTAMP:20240304T140000Z\r\nDTSTART:20240304T090000Z\r\nDTEND:20240304T103000Z\r\nSUMMARY:Release\\; v2\r\nDESCRIPTION:Fixed bugs\\, wrote docs\r\n",
+		"UID:session-2@org\r\nDTSTAMP:20240304T140000Z\r\nDTSTART:20240304T130000Z\r\nDTEND:20240304T140000Z\r\nSUMMARY:Work (running)\r\nTRANSP:OPAQUE\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("sessionCalendar() lacks %q in\n%s", want, got)
+		}
+	}
+
+	if !strings.HasSuffix(got, "END:VEVENT\r\nEND:VCALENDAR\r\n") || strings.Count(got, "BEGIN:VEVENT") != 2 {
+		t.Errorf("sessionCalendar() = %q", got)
+	}
+}
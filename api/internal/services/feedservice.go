@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const feedTokenPrefix = "ttf_"
+
+// feedPeriod is how far back feeds reach. Calendar apps poll them every few
+// minutes, all time would be too much to render each time.
+const feedPeriod = 90 * 24 * time.Hour
+
+type FeedRepository interface {
+	Save(ctx context.Context, d *dto.SaveFeedDto) (*domain.Feed, error)
+	Read(ctx context.Context, userId string) (*domain.Feed, error)
+	ReadByHash(ctx context.Context, hash string) (*domain.Feed, error)
+	Delete(ctx context.Context, userId string) error
+	TouchLastUsed(ctx context.Context, id string) error
+}
+
+// FeedService publishes the sessions of users as iCalendar feeds. Users
+// manage their own feed, the token in its url authenticates the calendar
+// apps reading it.
+type FeedService struct {
+	repository FeedRepository
+	sessions   SessionReader
+	tasks      TaskReader
+	policy     *Policy
+}
+
+func NewFeedService(repository FeedRepository, sessions SessionReader, tasks TaskReader, policy *Policy) *FeedService {
+	return &FeedService{
+		repository: repository,
+		sessions:   sessions,
+		tasks:      tasks,
+		policy:     policy,
+	}
+}
+
+// Create generates the feed token of userId, replacing the previous one.
+// The plaintext token is returned only once, the database keeps its hash
+// like for api keys.
+func (s *FeedService) Create(ctx context.Context, userId string) (*domain.Feed, string, error) {
+	const fn = "FeedService.Create"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	if err := s.policy.CanView(ctx, userId); err != nil {
+		return nil, "", err
+	}
+
+	prefix := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	// hex keeps the token free of the dot that separates the extension
+	visible := feedTokenPrefix + hex.EncodeToString(prefix)
+	raw := visible + "_" + hex.EncodeToString(secret)
+
+	feed, err := s.repository.Save(ctx, &dto.SaveFeedDto{
+		UserId: userId,
+		Prefix: visible,
+		Hash:   hashApiKey(raw),
+	})
+	if err != nil {
+		logger.Error("cannot save feed", slog.String("err", err.Error()))
+		return nil, "", err
+	}
+
+	logger.Info("feed created", slog.String("id", feed.Id), slog.String("prefix", feed.Prefix))
+	return feed, raw, nil
+}
+
+func (s *FeedService) Get(ctx context.Context, userId string) (*domain.Feed, error) {
+	if err := s.policy.CanView(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	return s.repository.Read(ctx, userId)
+}
+
+// Delete revokes the feed of userId.
+func (s *FeedService) Delete(ctx context.Context, userId string) error {
+	if err := s.policy.CanView(ctx, userId); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, userId)
+}
+
+// Render writes the calendar of the feed of token: the sessions of its user
+// over the feed period, the running one ending now.
+func (s *FeedService) Render(ctx context.Context, token string) (*domain.ExportFile, error) {
+	const fn = "FeedService.Render"
+	logger := slog.With(slog.String("fn", fn))
+
+	feed, err := s.repository.ReadByHash(ctx, hashApiKey(token))
+	if err != nil {
+		return nil, err
+	}
+	ctx = domain.WithOrg(ctx, feed.OrgId)
+
+	if err := s.repository.TouchLastUsed(ctx, feed.Id); err != nil {
+		logger.Warn("cannot update feed usage", slog.String("err", err.Error()))
+	}
+
+	now := time.Now()
+	since := now.Add(-feedPeriod)
+	sessions, err := s.sessions.GetSessions(ctx, &filters.Activity{
+		UserId:    feed.UserId,
+		StartTime: &since,
+	})
+	if err != nil {
+		logger.Error("getting sessions error", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	titles := make(map[string]string)
+	for _, session := range sessions {
+		if session.TaskId == nil {
+			continue
+		}
+		if _, ok := titles[*session.TaskId]; ok {
+			continue
+		}
+
+		task, err := s.tasks.Read(ctx, *session.TaskId)
+		if err != nil {
+			if !errors.Is(err, domain.ErrTaskNotFound) {
+				return nil, err
+			}
+			// the event is still worth showing without its title
+			titles[*session.TaskId] = ""
+			continue
+		}
+		titles[task.Id] = task.Title
+	}
+
+	return &domain.ExportFile{
+		Name:        "sessions.ics",
+		ContentType: "text/calendar; charset=utf-8",
+		Data:        sessionCalendar(feed, sessions, titles, now),
+	}, nil
+}
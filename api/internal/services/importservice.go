@@ -0,0 +1,473 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// importTaskTitle is the task of entries on a project without a task, time
+// is tracked against tasks only.
+const importTaskTitle = "General"
+
+// UserLister lists the users imported entries are mapped to.
+type UserLister interface {
+	ReadMany(ctx context.Context, f *filters.UsersFilters) ([]*domain.User, int64, error)
+}
+
+// ProjectLister lists the projects and clients imported entries are mapped
+// to by name.
+type ProjectLister interface {
+	ReadAll(ctx context.Context, f *filters.Projects) ([]*domain.Project, error)
+	ReadClients(ctx context.Context) ([]*domain.Client, error)
+}
+
+// TaskLister lists the tasks of projects imported entries are mapped to by
+// title.
+type TaskLister interface {
+	ReadAll(ctx context.Context, f *filters.Tasks) ([]*domain.Task, error)
+}
+
+// SessionImporter saves imported sessions together with the tasks they are
+// on but are missing.
+type SessionImporter interface {
+	HasOverlap(ctx context.Context, f *filters.Overlap) (bool, error)
+	CreateMany(ctx context.Context, tasks []*domain.ImportedTask, sessions []*dto.ImportActivity) (int, error)
+}
+
+// ImportService imports the time entries of other trackers, mapping their
+// people, projects and tasks to those of the organization.
+type ImportService struct {
+	users     UserLister
+	projects  ProjectLister
+	tasks     TaskLister
+	sessions  SessionImporter
+	locks     PeriodLocks
+	orgs      OrganizationReader
+	publisher EventPublisher
+	policy    *Policy
+}
+
+func NewImportService(users UserLister, projects ProjectLister, tasks TaskLister, sessions SessionImporter, locks PeriodLocks, orgs OrganizationReader, publisher EventPublisher, policy *Policy) *ImportService {
+	return &ImportService{
+		users:     users,
+		projects:  projects,
+		tasks:     tasks,
+		sessions:  sessions,
+		locks:     locks,
+		orgs:      orgs,
+		publisher: publisher,
+		policy:    policy,
+	}
+}
+
+// importSession is an entry mapped to a user and a task. A task missing
+// from its project is pending until the import creates it.
+type importSession struct {
+	entry   *importEntry
+	userId  string
+	taskId  *string
+	pending *domain.ImportedTask
+	note    *string
+}
+
+// taskKey tells the task of a session apart, pending ones included.
+func (s *importSession) taskKey() string {
+	if s.pending != nil {
+		return "new:" + s.pending.ProjectId + "/" + nameKey(s.pending.Title)
+	}
+	if s.taskId != nil {
+		return *s.taskId
+	}
+	return ""
+}
+
+// Import saves the entries of a Toggl or Clockify CSV export as finished
+// sessions. Entries are rejected, and listed in the report, when their user,
+// project or task cannot be told, when they fall into a locked period or
+// when they overlap sessions of the organization or earlier entries of the
+// file as the timer policy forbids. Importing a file twice thus rejects all
+// of its entries the second time. A dry run saves nothing.
+func (s *ImportService) Import(ctx context.Context, d *dto.ImportTimeDto) (*domain.ImportReport, error) {
+	const fn = "ImportService.Import"
+	logger := slog.With(slog.String("fn", fn), slog.String("source", string(d.Source)), slog.Bool("dryRun", d.DryRun))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.Source != "" {
+		if err := d.Source.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	loc := time.UTC
+	if d.Timezone != "" {
+		var err error
+		// Local is whatever zone the server runs in, not that of the file
+		if loc, err = time.LoadLocation(d.Timezone); err != nil || d.Timezone == "Local" {
+			return nil, fmt.Errorf("%w: unknown timezone", domain.ErrInvalidImportFile)
+		}
+	}
+
+	source, entries, rejected, err := parseTimeEntries(d.Source, d.Data, loc)
+	if err != nil {
+		logger.Debug("cannot parse import file", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if len(entries) == 0 && len(rejected) == 0 {
+		return nil, fmt.Errorf("%w: no time entries found", domain.ErrInvalidImportFile)
+	}
+
+	report := &domain.ImportReport{
+		Source:   source,
+		DryRun:   d.DryRun,
+		Rows:     len(entries) + len(rejected),
+		Tasks:    make([]*domain.ImportedTask, 0),
+		Rejected: rejected,
+	}
+	reject := func(entry *importEntry, reason string) {
+		report.Rejected = append(report.Rejected, &domain.ImportRejection{Row: entry.row, User: entry.person, Reason: reason})
+	}
+
+	resolver, err := s.resolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*importSession, 0, len(entries))
+	for _, entry := range entries {
+		session, err := resolver.resolve(ctx, entry)
+		if err != nil {
+			if _, ok := err.(importReason); !ok {
+				return nil, err
+			}
+			reject(entry, err.Error())
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	org, err := s.orgs.Read(ctx, orgOf(ctx))
+	if err != nil {
+		return nil, err
+	}
+	sameTask := org.TimerPolicy == domain.TimerMultiple
+
+	// sorted by start, an entry overlaps an earlier one of the file exactly
+	// when it starts before the latest end of them
+	slices.SortStableFunc(sessions, func(a, b *importSession) int {
+		return cmp.Or(strings.Compare(a.userId, b.userId), a.entry.start.Compare(b.entry.start))
+	})
+
+	latest := make(map[string]time.Time)
+	accepted := make([]*importSession, 0, len(sessions))
+	for _, session := range sessions {
+		entry := session.entry
+
+		key := session.userId
+		if sameTask {
+			key += "/" + session.taskKey()
+		}
+		if end, ok := latest[key]; ok && entry.start.Before(end) {
+			reject(entry, "overlaps an earlier entry of the file")
+			continue
+		}
+
+		locked, err := s.locks.IsLocked(ctx, session.userId, entry.start, &entry.end)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			reject(entry, domain.ErrPeriodLocked.Error())
+			continue
+		}
+
+		// nothing is tracked yet on a task the import creates
+		if !sameTask || session.pending == nil {
+			overlaps, err := s.sessions.HasOverlap(ctx, &filters.Overlap{
+				UserId:   session.userId,
+				Start:    entry.start,
+				End:      &entry.end,
+				SameTask: sameTask,
+				TaskId:   session.taskId,
+			})
+			if err != nil {
+				logger.Error("checking overlap error", slog.String("err", err.Error()))
+				return nil, err
+			}
+			if overlaps {
+				reject(entry, domain.ErrSessionOverlaps.Error())
+				continue
+			}
+		}
+
+		if end, ok := latest[key]; !ok || entry.end.After(end) {
+			latest[key] = entry.end
+		}
+		accepted = append(accepted, session)
+		report.TotalTime += entry.end.Sub(entry.start)
+	}
+
+	for _, session := range accepted {
+		if session.pending != nil && !slices.Contains(report.Tasks, session.pending) {
+			report.Tasks = append(report.Tasks, session.pending)
+		}
+	}
+
+	slices.SortFunc(report.Rejected, func(a, b *domain.ImportRejection) int {
+		return a.Row - b.Row
+	})
+	report.Imported = len(accepted)
+
+	if d.DryRun || len(accepted) == 0 {
+		return report, nil
+	}
+
+	saves := make([]*dto.ImportActivity, 0, len(accepted))
+	userIds := make([]string, 0)
+	for _, session := range accepted {
+		end := session.entry.end
+		saves = append(saves, &dto.ImportActivity{
+			SaveActivity: dto.SaveActivity{
+				UserId:    session.userId,
+				TaskId:    session.taskId,
+				StartTime: session.entry.start,
+				EndTime:   &end,
+				Note:      session.note,
+			},
+			Task: session.pending,
+		})
+		userIds = append(userIds, session.userId)
+	}
+	userIds = slices.Compact(userIds)
+
+	// the tasks are created along with the sessions, a failed import leaves
+	// none of them behind
+	imported, err := s.sessions.CreateMany(ctx, report.Tasks, saves)
+	if err != nil {
+		logger.Error("cannot save sessions", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Info("time entries imported", slog.Int("sessions", imported), slog.Int("rejected", len(report.Rejected)))
+
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventActivityImported,
+		OccurredAt: time.Now(),
+		Data:       &domain.SessionImport{Sessions: imported, UserIds: userIds},
+	})
+
+	return report, nil
+}
+
+// importReason is why an entry cannot be imported, as opposed to errors
+// that fail the whole import.
+type importReason string
+
+func (r importReason) Error() string {
+	return string(r)
+}
+
+// importResolver maps the people, projects and tasks of entries to those of
+// the organization.
+type importResolver struct {
+	users    map[string][]*domain.User
+	projects map[string][]*domain.Project
+	clients  map[string]string
+	tasks    TaskLister
+	// titles holds the tasks of a project by lower cased title, read when
+	// the first entry on the project comes.
+	titles  map[string]map[string][]*domain.Task
+	pending map[string]*domain.ImportedTask
+}
+
+func (s *ImportService) resolver(ctx context.Context) (*importResolver, error) {
+	users, _, err := s.users.ReadMany(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	projects, err := s.projects.ReadAll(ctx, &filters.Projects{})
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := s.projects.ReadClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &importResolver{
+		users:    make(map[string][]*domain.User),
+		projects: make(map[string][]*domain.Project),
+		clients:  make(map[string]string, len(clients)),
+		tasks:    s.tasks,
+		titles:   make(map[string]map[string][]*domain.Task),
+		pending:  make(map[string]*domain.ImportedTask),
+	}
+
+	for _, user := range users {
+		keys := []string{
+			nameKey(user.Name + " " + user.Surname),
+			nameKey(user.Surname + " " + user.Name),
+			nameKey(user.Surname + " " + user.Name + " " + user.Patronymic),
+			nameKey(user.Name + " " + user.Patronymic + " " + user.Surname),
+		}
+		if passport := passportKey(user.PassportSerie + user.PassportNumber); passport != "" {
+			keys = append(keys, passport)
+		}
+
+		// without a patronymic some of the names are the same
+		slices.Sort(keys)
+		for _, key := range slices.Compact(keys) {
+			r.users[key] = append(r.users[key], user)
+		}
+	}
+
+	for _, project := range projects {
+		key := nameKey(project.Name)
+		r.projects[key] = append(r.projects[key], project)
+	}
+	for _, client := range clients {
+		r.clients[client.Id] = nameKey(client.Name)
+	}
+
+	return r, nil
+}
+
+// nameKey folds the case and blanks of a name.
+func nameKey(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// passportKey returns the digits of a passport serie and number, or "" when
+// value holds other than digits and blanks.
+func passportKey(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+		default:
+			return ""
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "passport:" + b.String()
+}
+
+// resolve maps entry to a user and task, failing with an importReason
+// unless they can be told.
+func (r *importResolver) resolve(ctx context.Context, entry *importEntry) (*importSession, error) {
+	key := passportKey(entry.person)
+	if key == "" {
+		key = nameKey(entry.person)
+	}
+
+	users := r.users[key]
+	switch len(users) {
+	case 0:
+		return nil, importReason(fmt.Sprintf("user %q not found", entry.person))
+	case 1:
+	default:
+		return nil, importReason(fmt.Sprintf("user %q matches %d users", entry.person, len(users)))
+	}
+
+	session := &importSession{entry: entry, userId: users[0].Id}
+
+	if entry.note != "" {
+		note, err := domain.SessionNote(entry.note)
+		if err != nil {
+			return nil, importReason(err.Error())
+		}
+		session.note = &note
+	}
+
+	if entry.project == "" {
+		if entry.task != "" {
+			return nil, importReason(fmt.Sprintf("task %q has no project", entry.task))
+		}
+		return session, nil
+	}
+
+	project, err := r.project(entry.project, entry.client)
+	if err != nil {
+		return nil, err
+	}
+
+	title := entry.task
+	if title == "" {
+		title = importTaskTitle
+	}
+
+	titles, ok := r.titles[project.Id]
+	if !ok {
+		tasks, err := r.tasks.ReadAll(ctx, &filters.Tasks{ProjectId: &project.Id})
+		if err != nil {
+			return nil, err
+		}
+
+		titles = make(map[string][]*domain.Task, len(tasks))
+		for _, task := range tasks {
+			key := nameKey(task.Title)
+			titles[key] = append(titles[key], task)
+		}
+		r.titles[project.Id] = titles
+	}
+
+	tasks := titles[nameKey(title)]
+	switch len(tasks) {
+	case 0:
+		key := project.Id + "/" + nameKey(title)
+		pending, ok := r.pending[key]
+		if !ok {
+			pending = &domain.ImportedTask{ProjectId: project.Id, Title: title}
+			r.pending[key] = pending
+		}
+		session.pending = pending
+	case 1:
+		session.taskId = &tasks[0].Id
+	default:
+		return nil, importReason(fmt.Sprintf("task %q matches %d tasks of project %q", title, len(tasks), project.Name))
+	}
+
+	return session, nil
+}
+
+// project finds a project by name, and by the name of its client when
+// several projects have the name.
+func (r *importResolver) project(name, client string) (*domain.Project, error) {
+	projects := r.projects[nameKey(name)]
+	if len(projects) > 1 && client != "" {
+		matching := make([]*domain.Project, 0, 1)
+		for _, project := range projects {
+			if project.ClientId != nil && r.clients[*project.ClientId] == nameKey(client) {
+				matching = append(matching, project)
+			}
+		}
+		projects = matching
+	}
+
+	switch len(projects) {
+	case 0:
+		return nil, importReason(fmt.Sprintf("project %q not found", name))
+	case 1:
+		return projects[0], nil
+	}
+	return nil, importReason(fmt.Sprintf("project %q matches %d projects", name, len(projects)))
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type importUsersStub struct {
+	users []*domain.User
+}
+
+func (r *importUsersStub) ReadMany(context.Context, *filters.UsersFilters) ([]*domain.User, int64, error) {
+	return r.users, int64(len(r.users)), nil
+}
+
+type importProjectsStub struct {
+	projects []*domain.Project
+	clients  []*domain.Client
+}
+
+func (r *importProjectsStub) ReadAll(context.Context, *filters.Projects) ([]*domain.Project, error) {
+	return r.projects, nil
+}
+
+func (r *importProjectsStub) ReadClients(context.Context) ([]*domain.Client, error) {
+	return r.clients, nil
+}
+
+type importTasksStub struct {
+	tasks []*domain.Task
+}
+
+func (r *importTasksStub) ReadAll(_ context.Context, f *filters.Tasks) ([]*domain.Task, error) {
+	tasks := make([]*domain.Task, 0)
+	for _, task := range r.tasks {
+		if f.ProjectId == nil || task.ProjectId == *f.ProjectId {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// importSessionsStub overlaps the sessions of the users in busy and keeps
+// what is saved, failing the save with err when set.
+type importSessionsStub struct {
+	busy     []string
+	err      error
+	tasks    []*domain.ImportedTask
+	sessions []*dto.ImportActivity
+}
+
+func (r *importSessionsStub) HasOverlap(_ context.Context, f *filters.Overlap) (bool, error) {
+	return slices.Contains(r.busy, f.UserId), nil
+}
+
+func (r *importSessionsStub) CreateMany(_ context.Context, tasks []*domain.ImportedTask, sessions []*dto.ImportActivity) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	for i, task := range tasks {
+		task.Id = "new-" + strconv.Itoa(i+1)
+	}
+	r.tasks, r.sessions = tasks, sessions
+	return len(sessions), nil
+}
+
+type locksStub struct {
+	until time.Time
+}
+
+func (r *locksStub) IsLocked(_ context.Context, _ string, start time.Time, _ *time.Time) (bool, error) {
+	return start.Before(r.until), nil
+}
+
+type orgsStub struct {
+	policy domain.TimerPolicy
+}
+
+func (r *orgsStub) Read(_ context.Context, id string) (*domain.Organization, error) {
+	return &domain.Organization{Id: id, TimerPolicy: r.policy}, nil
+}
+
+const importFile = `User,Client,Project,Task,Description,Start date,Start time,End date,End time
+Ann Lee,Acme,Site,Design,Mockups,2024-03-04,09:00,2024-03-04,10:00
+Lee Ann,,,,,2024-03-04,09:30,2024-03-04,11:00
+4510 123456,Acme,Site,Testing,,2024-03-04,11:00,2024-03-04,12:00
+Bob Ray,Globex,Site,,,2024-03-04,09:00,2024-03-04,10:00
+Bob Ray,,Site,,,2024-03-04,09:00,2024-03-04,10:00
+Cid Moe,,,,,2024-03-04,09:00,2024-03-04,10:00
+Dan Poe,,,,,2024-03-04,09:00,2024-03-04,10:00
+Ann Lee,,Unknown,,,2024-03-05,09:00,2024-03-05,10:00
+Ann Lee,,,,,2024-02-26,09:00,2024-02-26,10:00
+Bob Ray,Globex,Site,Testing,,2024-03-05,09:00,2024-03-05,10:00
+`
+
+func newTestImportService(sessions *importSessionsStub, publisher EventPublisher) *ImportService {
+	acme, globex := "acme", "globex"
+	users := &importUsersStub{users: []*domain.User{
+		{Id: "ann", Name: "Ann", Surname: "Lee", PassportSerie: "4510", PassportNumber: "123456"},
+		{Id: "bob", Name: "Bob", Surname: "Ray"},
+		{Id: "cid", Name: "Cid", Surname: "Moe"},
+		{Id: "cid2", Name: "Cid", Surname: "Moe"},
+		{Id: "dan", Name: "Dan", Surname: "Poe"},
+	}}
+	projects := &importProjectsStub{
+		projects: []*domain.Project{
+			{Id: "site-acme", Name: "Site", ClientId: &acme},
+			{Id: "site-globex", Name: "Site", ClientId: &globex},
+		},
+		clients: []*domain.Client{{Id: acme, Name: "Acme"}, {Id: globex, Name: "Globex"}},
+	}
+	tasks := &importTasksStub{tasks: []*domain.Task{
+		{Id: "design", ProjectId: "site-acme", Title: "Design"},
+	}}
+
+	return NewImportService(users, projects, tasks, sessions,
+		&locksStub{until: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		&orgsStub{policy: domain.TimerSingle}, publisher, NewPolicy(nil))
+}
+
+func TestImport(t *testing.T) {
+	admin := &domain.Principal{Kind: domain.PrincipalUser, Id: "admin", UserId: "admin", Role: domain.RoleAdmin, OrgId: "org"}
+	ctx := domain.WithOrg(domain.WithPrincipal(context.Background(), admin), admin.OrgId)
+
+	wantRejected := []int{3, 6, 7, 8, 9, 10}
+
+	t.Run("dry run", func(t *testing.T) {
+		sessions := &importSessionsStub{busy: []string{"dan"}}
+		publisher := &publisherStub{}
+
+		report, err := newTestImportService(sessions, publisher).Import(ctx, &dto.ImportTimeDto{DryRun: true, Data: []byte(importFile)})
+		if err != nil {
+			t.Fatalf("Import() error = %v", err)
+		}
+
+		if report.Source != domain.ImportToggl || report.Rows != 10 || report.Imported != 4 || report.TotalTime != 4*time.Hour {
+			t.Errorf("Import() = %+v", report)
+		}
+
+		rows := make([]int, 0, len(report.Rejected))
+		for _, rejection := range report.Rejected {
+			rows = append(rows, rejection.Row)
+		}
+		if !slices.Equal(rows, wantRejected) {
+			t.Errorf("Import() rejected rows %v, want %v", rows, wantRejected)
+		}
+
+		tasks := make([]domain.ImportedTask, 0, len(report.Tasks))
+		for _, task := range report.Tasks {
+			tasks = append(tasks, *task)
+		}
+		wantTasks := []domain.ImportedTask{
+			{ProjectId: "site-acme", Title: "Testing"},
+			{ProjectId: "site-globex", Title: importTaskTitle},
+			{ProjectId: "site-globex", Title: "Testing"},
+		}
+		if !slices.Equal(tasks, wantTasks) {
+			t.Errorf("Import() tasks = %+v, want %+v", tasks, wantTasks)
+		}
+		if sessions.sessions != nil || len(publisher.events) != 0 {
+			t.Errorf("dry run saved %d sessions", len(sessions.sessions))
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		sessions := &importSessionsStub{busy: []string{"dan"}}
+		publisher := &publisherStub{}
+
+		report, err := newTestImportService(sessions, publisher).Import(ctx, &dto.ImportTimeDto{Data: []byte(importFile)})
+		if err != nil {
+			t.Fatalf("Import() error = %v", err)
+		}
+
+		if len(sessions.tasks) != 3 || report.Tasks[0].Id != "new-1" {
+			t.Fatalf("Import() created tasks %+v", sessions.tasks)
+		}
+
+		got := make([]string, 0, len(sessions.sessions))
+		for _, session := range sessions.sessions {
+			taskId := "-"
+			switch {
+			case session.Task != nil:
+				taskId = session.Task.Id
+			case session.TaskId != nil:
+				taskId = *session.TaskId
+			}
+			got = append(got, session.UserId+"/"+taskId)
+		}
+		slices.Sort(got)
+		if want := []string{"ann/design", "ann/new-1", "bob/new-2", "bob/new-3"}; !slices.Equal(got, want) {
+			t.Errorf("Import() saved %v, want %v", got, want)
+		}
+
+		if len(publisher.events) != 1 || publisher.events[0].Type != domain.EventActivityImported {
+			t.Errorf("Import() published %v", publisher.events)
+		}
+	})
+
+	t.Run("failed save", func(t *testing.T) {
+		sessions := &importSessionsStub{err: domain.ErrSessionOverlaps}
+		publisher := &publisherStub{}
+
+		_, err := newTestImportService(sessions, publisher).Import(ctx, &dto.ImportTimeDto{Data: []byte(importFile)})
+		if !errors.Is(err, domain.ErrSessionOverlaps) {
+			t.Fatalf("Import() error = %v, want %v", err, domain.ErrSessionOverlaps)
+		}
+		if len(publisher.events) != 0 {
+			t.Errorf("failed import published %v", publisher.events)
+		}
+	})
+}
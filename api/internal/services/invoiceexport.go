@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bytes"
+	"em-test/internal/domain"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strconv"
+	"time"
+)
+
+// renderInvoice writes an invoice of client in format, json when empty.
+func renderInvoice(format string, invoice *domain.Invoice, client *domain.Client) (*domain.ExportFile, error) {
+	name := "invoice-" + invoice.Id
+
+	switch format {
+	case "", "json":
+		data, err := json.MarshalIndent(struct {
+			*domain.Invoice
+			Client *domain.Client `json:"client"`
+		}{invoice, client}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &domain.ExportFile{Name: name + ".json", ContentType: "application/json", Data: data}, nil
+	case "csv":
+		data, err := invoiceCSV(invoice)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.ExportFile{Name: name + ".csv", ContentType: "text/csv; charset=utf-8", Data: data}, nil
+	case "html":
+		data, err := invoiceHTML(invoice, client)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.ExportFile{Name: name + ".html", ContentType: "text/html; charset=utf-8", Data: data}, nil
+	}
+	return nil, fmt.Errorf("%w %q, use json, csv or html", domain.ErrUnknownFormat, format)
+}
+
+// hours writes d as a decimal number of hours.
+func hours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', 2, 64)
+}
+
+// invoiceCSV writes a row per line and a last row with the totals. Amounts
+// are in the major unit of the currency.
+func invoiceCSV(invoice *domain.Invoice) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	rows := [][]string{{"position", "description", "project_id", "task_id", "user_id", "sessions", "hours", "hourly_rate", "amount", "currency"}}
+	for _, line := range invoice.Lines {
+		var taskId, userId string
+		if line.TaskId != nil {
+			taskId = *line.TaskId
+		}
+		if line.UserId != nil {
+			userId = *line.UserId
+		}
+
+		rows = append(rows, []string{
+			strconv.Itoa(line.Position),
+			line.Description,
+			line.ProjectId,
+			taskId,
+			userId,
+			strconv.Itoa(line.SessionCount),
+			hours(line.Duration),
+			domain.FormatAmount(line.HourlyRate, invoice.Currency),
+			domain.FormatAmount(line.Amount, invoice.Currency),
+			invoice.Currency,
+		})
+	}
+	rows = append(rows, []string{"", "Total", "", "", "", "", hours(invoice.TotalTime), "", domain.FormatAmount(invoice.Amount, invoice.Currency), invoice.Currency})
+
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var invoiceTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"hours":  hours,
+	"amount": domain.FormatAmount,
+	"date":   func(t time.Time) string { return t.Format("2006-01-02") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Invoice.Id}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ccc; padding: 0.4em; text-align: left; }
+td.number, th.number { text-align: right; }
+tfoot td { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Invoice</h1>
+<p>
+Client: {{.Client.Name}}<br>
+Period: {{date .Invoice.PeriodStart}} to {{date .Invoice.PeriodEnd}}<br>
+Status: {{.Invoice.Status}}{{with .Invoice.IssuedAt}}, issued {{date .}}{{end}}{{with .Invoice.PaidAt}}, paid {{date .}}{{end}}
+</p>
+<table>
+<thead>
+<tr><th>#</th><th>Description</th><th class="number">Hours</th><th class="number">Rate</th><th class="number">Amount</th></tr>
+</thead>
+<tbody>
+{{- range .Invoice.Lines}}
+<tr><td>{{.Position}}</td><td>{{.Description}}</td><td class="number">{{hours .Duration}}</td><td class="number">{{amount .HourlyRate $.Invoice.Currency}}</td><td class="number">{{amount .Amount $.Invoice.Currency}}</td></tr>
+{{- end}}
+</tbody>
+<tfoot>
+<tr><td></td><td>Total</td><td class="number">{{hours .Invoice.TotalTime}}</td><td></td><td class="number">{{amount .Invoice.Amount .Invoice.Currency}} {{.Invoice.Currency}}</td></tr>
+</tfoot>
+</table>
+</body>
+</html>
+`))
+
+func invoiceHTML(invoice *domain.Invoice, client *domain.Client) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := invoiceTemplate.Execute(&buf, struct {
+		Invoice *domain.Invoice
+		Client  *domain.Client
+	}{invoice, client}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+type InvoiceRepository interface {
+	Create(ctx context.Context, d *dto.CreateInvoiceDto) (*domain.Invoice, error)
+	Read(ctx context.Context, id string) (*domain.Invoice, error)
+	ReadMany(ctx context.Context, f *filters.Invoices) ([]*domain.Invoice, error)
+	SetStatus(ctx context.Context, id string, status domain.InvoiceStatus) (*domain.Invoice, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// ClientProjects reads clients and the projects billed to them.
+type ClientProjects interface {
+	ReadClient(ctx context.Context, id string) (*domain.Client, error)
+	ReadAll(ctx context.Context, f *filters.Projects) ([]*domain.Project, error)
+}
+
+// InvoiceService bills clients for the time tracked on their projects.
+// Invoices cover the whole organization, so they are reserved to admins.
+type InvoiceService struct {
+	repository InvoiceRepository
+	clients    ClientProjects
+	publisher  EventPublisher
+	policy     *Policy
+}
+
+func NewInvoiceService(repository InvoiceRepository, clients ClientProjects, publisher EventPublisher, policy *Policy) *InvoiceService {
+	return &InvoiceService{
+		repository: repository,
+		clients:    clients,
+		publisher:  publisher,
+		policy:     policy,
+	}
+}
+
+func (s *InvoiceService) publish(ctx context.Context, invoice *domain.Invoice, eventType domain.EventType) {
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       eventType,
+		OccurredAt: time.Now(),
+		Data:       invoice,
+	})
+}
+
+// Create drafts an invoice of the uninvoiced time of a client over a
+// period. Without a currency the client must be billed in a single one.
+func (s *InvoiceService) Create(ctx context.Context, d *dto.CreateInvoiceDto) (*domain.Invoice, error) {
+	const fn = "InvoiceService.Create"
+	logger := slog.With(slog.String("fn", fn), slog.String("clientId", d.ClientId))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.GroupBy == "" {
+		d.GroupBy = domain.InvoiceByTask
+	}
+	if err := d.GroupBy.Validate(); err != nil {
+		return nil, err
+	}
+
+	if d.PeriodEnd.Before(d.PeriodStart) {
+		return nil, fmt.Errorf("%w: period ends before it starts", domain.ErrInvalidInvoice)
+	}
+
+	if _, err := s.clients.ReadClient(ctx, d.ClientId); err != nil {
+		return nil, err
+	}
+
+	projects, err := s.clients.ReadAll(ctx, &filters.Projects{ClientId: &d.ClientId})
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make([]string, 0, 1)
+	for _, p := range projects {
+		if p.Billable && !slices.Contains(currencies, p.Currency) {
+			currencies = append(currencies, p.Currency)
+		}
+	}
+
+	d.Currency = strings.ToUpper(strings.TrimSpace(d.Currency))
+	switch {
+	case len(currencies) == 0:
+		return nil, domain.ErrNothingToInvoice
+	case d.Currency == "" && len(currencies) > 1:
+		return nil, fmt.Errorf("%w: client is billed in %s, choose a currency", domain.ErrInvalidInvoice, strings.Join(currencies, ", "))
+	case d.Currency == "":
+		d.Currency = currencies[0]
+	case !slices.Contains(currencies, d.Currency):
+		return nil, domain.ErrNothingToInvoice
+	}
+
+	principal, _ := domain.PrincipalFrom(ctx)
+	d.CreatedBy = principal.Id
+
+	invoice, err := s.repository.Create(ctx, d)
+	if err != nil {
+		logger.Error("cannot create invoice", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	s.publish(ctx, invoice, domain.EventInvoiceCreated)
+
+	return invoice, nil
+}
+
+func (s *InvoiceService) Get(ctx context.Context, id string) (*domain.Invoice, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.repository.Read(ctx, id)
+}
+
+func (s *InvoiceService) List(ctx context.Context, f *filters.Invoices) ([]*domain.Invoice, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadMany(ctx, f)
+}
+
+func (s *InvoiceService) Issue(ctx context.Context, id string) (*domain.Invoice, error) {
+	return s.setStatus(ctx, id, domain.InvoiceIssued)
+}
+
+func (s *InvoiceService) Pay(ctx context.Context, id string) (*domain.Invoice, error) {
+	return s.setStatus(ctx, id, domain.InvoicePaid)
+}
+
+func (s *InvoiceService) setStatus(ctx context.Context, id string, status domain.InvoiceStatus) (*domain.Invoice, error) {
+	const fn = "InvoiceService.setStatus"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id), slog.String("status", string(status)))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.SetStatus(ctx, id, status); err != nil {
+		logger.Error("cannot change invoice status", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	invoice, err := s.repository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == domain.InvoiceIssued {
+		s.publish(ctx, invoice, domain.EventInvoiceIssued)
+	} else {
+		s.publish(ctx, invoice, domain.EventInvoicePaid)
+	}
+
+	return invoice, nil
+}
+
+// Delete removes a draft invoice and releases its sessions.
+func (s *InvoiceService) Delete(ctx context.Context, id string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, id)
+}
+
+// Export renders an invoice as json, csv or html.
+func (s *InvoiceService) Export(ctx context.Context, id, format string) (*domain.ExportFile, error) {
+	invoice, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.clients.ReadClient(ctx, invoice.ClientId)
+	if err != nil {
+		return nil, err
+	}
+
+	return renderInvoice(strings.ToLower(format), invoice, client)
+}
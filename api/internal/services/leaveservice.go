@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type LeaveRepository interface {
+	CreateType(ctx context.Context, d *dto.SaveLeaveTypeDto) (*domain.LeaveType, error)
+	ReadType(ctx context.Context, id string) (*domain.LeaveType, error)
+	ReadTypes(ctx context.Context) ([]*domain.LeaveType, error)
+	UpdateType(ctx context.Context, id string, d *dto.UpdateLeaveTypeDto) (*domain.LeaveType, error)
+	DeleteType(ctx context.Context, id string) error
+	SetAllowance(ctx context.Context, d *dto.SetAllowanceDto) error
+	ReadBalances(ctx context.Context, userId string, year int) ([]*domain.LeaveBalance, error)
+	Request(ctx context.Context, d *dto.RequestLeaveDto) (*domain.LeaveRequest, error)
+	Review(ctx context.Context, d *dto.ReviewLeaveDto) (*domain.LeaveRequest, error)
+	Read(ctx context.Context, id string) (*domain.LeaveRequest, error)
+	ReadMany(ctx context.Context, f *filters.Leave) ([]*domain.LeaveRequest, error)
+}
+
+// UserCalendars resolve the schedule of a user and the calendar of its
+// region.
+type UserCalendars interface {
+	ForUser(ctx context.Context, userId string, from, to time.Time) (*domain.Schedule, domain.Calendar, error)
+}
+
+// LeaveService manages leave types, yearly allowances and leave requests.
+// Requests are filed by users for themselves and reviewed like timesheets.
+// Days of approved leave are credited with the expected working time of
+// the user, holidays are neither taken from the allowance nor credited.
+type LeaveService struct {
+	repository LeaveRepository
+	calendars  UserCalendars
+	publisher  EventPublisher
+	policy     *Policy
+}
+
+func NewLeaveService(repository LeaveRepository, calendars UserCalendars, publisher EventPublisher, policy *Policy) *LeaveService {
+	return &LeaveService{
+		repository: repository,
+		calendars:  calendars,
+		publisher:  publisher,
+		policy:     policy,
+	}
+}
+
+func (s *LeaveService) publish(ctx context.Context, r *domain.LeaveRequest, eventType domain.EventType) {
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       eventType,
+		UserId:     r.UserId,
+		OccurredAt: time.Now(),
+		Data:       r,
+	})
+}
+
+func (s *LeaveService) CreateType(ctx context.Context, d *dto.SaveLeaveTypeDto) (*domain.LeaveType, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return nil, domain.ErrInvalidLeaveType
+	}
+
+	return s.repository.CreateType(ctx, d)
+}
+
+func (s *LeaveService) ListTypes(ctx context.Context) ([]*domain.LeaveType, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadTypes(ctx)
+}
+
+func (s *LeaveService) UpdateType(ctx context.Context, id string, d *dto.UpdateLeaveTypeDto) (*domain.LeaveType, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.Name != nil {
+		name := strings.TrimSpace(*d.Name)
+		if name == "" {
+			return nil, domain.ErrInvalidLeaveType
+		}
+		d.Name = &name
+	}
+
+	return s.repository.UpdateType(ctx, id, d)
+}
+
+func (s *LeaveService) DeleteType(ctx context.Context, id string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	return s.repository.DeleteType(ctx, id)
+}
+
+func (s *LeaveService) SetAllowance(ctx context.Context, d *dto.SetAllowanceDto) ([]*domain.LeaveBalance, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.AllowanceDays < 0 || d.Year < 2000 || d.Year > 9999 {
+		return nil, fmt.Errorf("%w: allowance must not be negative", domain.ErrInvalidLeave)
+	}
+
+	if _, err := s.repository.ReadType(ctx, d.LeaveTypeId); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.SetAllowance(ctx, d); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadBalances(ctx, d.UserId, d.Year)
+}
+
+func (s *LeaveService) Balances(ctx context.Context, userId string, year int) ([]*domain.LeaveBalance, error) {
+	if err := s.policy.CanView(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadBalances(ctx, userId, year)
+}
+
+// Request files a leave request. Only working days of the user's schedule
+// that are not holidays count against the allowance.
+func (s *LeaveService) Request(ctx context.Context, d *dto.RequestLeaveDto) (*domain.LeaveRequest, error) {
+	const fn = "LeaveService.Request"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	if err := s.policy.CanSubmit(ctx, d.UserId); err != nil {
+		return nil, err
+	}
+
+	if d.EndDate.Before(d.StartDate) {
+		return nil, fmt.Errorf("%w: end date is before start date", domain.ErrInvalidLeave)
+	}
+
+	// allowances are yearly, requests crossing new year are split up
+	if d.StartDate.Year() != d.EndDate.Year() {
+		return nil, fmt.Errorf("%w: leave must not span years", domain.ErrInvalidLeave)
+	}
+
+	leaveType, err := s.repository.ReadType(ctx, d.LeaveTypeId)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, calendar, err := s.calendars.ForUser(ctx, d.UserId, d.StartDate, d.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Days = 0
+	for date := d.StartDate; !date.After(d.EndDate); date = date.AddDate(0, 0, 1) {
+		if calendar.Expected(schedule, date) > 0 {
+			d.Days++
+		}
+	}
+	if d.Days == 0 {
+		return nil, fmt.Errorf("%w: no working days requested", domain.ErrInvalidLeave)
+	}
+
+	d.RequiresBalance = leaveType.RequiresBalance
+
+	request, err := s.repository.Request(ctx, d)
+	if err != nil {
+		logger.Error("cannot request leave", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	s.publish(ctx, request, domain.EventLeaveRequested)
+
+	return request, nil
+}
+
+func (s *LeaveService) Approve(ctx context.Context, id string, comment *string) (*domain.LeaveRequest, error) {
+	return s.review(ctx, id, domain.LeaveApproved, comment)
+}
+
+func (s *LeaveService) Reject(ctx context.Context, id string, comment *string) (*domain.LeaveRequest, error) {
+	if comment == nil || strings.TrimSpace(*comment) == "" {
+		return nil, domain.ErrCommentRequired
+	}
+
+	return s.review(ctx, id, domain.LeaveRejected, comment)
+}
+
+// Cancel withdraws a request. Users cancel their own requests, reviewers
+// may cancel the requests they could approve.
+func (s *LeaveService) Cancel(ctx context.Context, id string) (*domain.LeaveRequest, error) {
+	const fn = "LeaveService.Cancel"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	request, err := s.repository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.policy.CanSubmit(ctx, request.UserId); err != nil {
+		if err := s.policy.CanReview(ctx, request.UserId); err != nil {
+			return nil, err
+		}
+	}
+
+	if !request.Status.CanTransition(domain.LeaveCancelled) {
+		return nil, domain.ErrInvalidTransition
+	}
+
+	request, err = s.repository.Review(ctx, &dto.ReviewLeaveDto{
+		Id:     id,
+		Status: domain.LeaveCancelled,
+	})
+	if err != nil {
+		logger.Error("cannot cancel leave", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	s.publish(ctx, request, domain.EventLeaveCancelled)
+
+	return request, nil
+}
+
+func (s *LeaveService) review(ctx context.Context, id string, status domain.LeaveStatus, comment *string) (*domain.LeaveRequest, error) {
+	const fn = "LeaveService.review"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id), slog.String("status", string(status)))
+
+	request, err := s.repository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.policy.CanReview(ctx, request.UserId); err != nil {
+		return nil, err
+	}
+
+	if request.Status != domain.LeavePending {
+		return nil, domain.ErrInvalidTransition
+	}
+
+	principal, _ := domain.PrincipalFrom(ctx)
+
+	request, err = s.repository.Review(ctx, &dto.ReviewLeaveDto{
+		Id:         id,
+		Status:     status,
+		ReviewerId: &principal.Id,
+		Comment:    comment,
+	})
+	if err != nil {
+		logger.Error("cannot review leave", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if status == domain.LeaveApproved {
+		s.publish(ctx, request, domain.EventLeaveApproved)
+	} else {
+		s.publish(ctx, request, domain.EventLeaveRejected)
+	}
+
+	return request, nil
+}
+
+func (s *LeaveService) Get(ctx context.Context, id string) (*domain.LeaveRequest, error) {
+	request, err := s.repository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.policy.CanView(ctx, request.UserId); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// List returns leave requests of the users visible to the caller.
+func (s *LeaveService) List(ctx context.Context, f *filters.Leave) ([]*domain.LeaveRequest, error) {
+	all, visible, err := s.policy.VisibleUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !all {
+		f.UserIds = visible
+	}
+
+	return s.repository.ReadMany(ctx, f)
+}
+
+// Credits returns the time credited for approved leave per user, for the
+// days from from to to that have already begun. Either bound may be nil.
+// Callers are expected to have restricted f to the users they may view.
+func (s *LeaveService) Credits(ctx context.Context, f *filters.Leave) (map[string]time.Duration, error) {
+	approved := domain.LeaveApproved
+	f.Status = &approved
+
+	requests, err := s.repository.ReadMany(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// each user's calendar spans all of their requests within the period
+	type span struct{ from, to time.Time }
+	spans := make(map[string]*span)
+	for _, request := range requests {
+		from, to := request.StartDate, request.EndDate
+		if f.From != nil && from.Before(domain.DayOf(*f.From)) {
+			from = domain.DayOf(*f.From)
+		}
+		if f.To != nil && to.After(*f.To) {
+			to = *f.To
+		}
+
+		if sp, ok := spans[request.UserId]; !ok {
+			spans[request.UserId] = &span{from, to}
+		} else {
+			if from.Before(sp.from) {
+				sp.from = from
+			}
+			if to.After(sp.to) {
+				sp.to = to
+			}
+		}
+	}
+
+	type userCalendar struct {
+		schedule *domain.Schedule
+		calendar domain.Calendar
+	}
+	calendars := make(map[string]*userCalendar, len(spans))
+	for userId, sp := range spans {
+		schedule, calendar, err := s.calendars.ForUser(ctx, userId, sp.from, sp.to)
+		if err != nil {
+			return nil, err
+		}
+		calendars[userId] = &userCalendar{schedule, calendar}
+	}
+
+	credits := make(map[string]time.Duration)
+	now := time.Now()
+
+	for _, request := range requests {
+		c := calendars[request.UserId]
+
+		for date := request.StartDate; !date.After(request.EndDate) && date.Before(now); date = date.AddDate(0, 0, 1) {
+			if f.From != nil && date.Before(domain.DayOf(*f.From)) {
+				continue
+			}
+			if f.To != nil && date.After(*f.To) {
+				break
+			}
+			credits[request.UserId] += c.calendar.Expected(c.schedule, date)
+		}
+	}
+
+	return credits, nil
+}
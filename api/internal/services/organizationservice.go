@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"log/slog"
+	"strings"
+)
+
+type OrganizationRepository interface {
+	Create(ctx context.Context, d *dto.SaveOrganizationDto) (*domain.Organization, error)
+	Read(ctx context.Context, id string) (*domain.Organization, error)
+	ReadAll(ctx context.Context) ([]*domain.Organization, error)
+	Update(ctx context.Context, id string, d *dto.UpdateOrganizationDto) (*domain.Organization, error)
+}
+
+// OrganizationService manages tenants, which is reserved to platform admins.
+// Admins of an organization maintain its settings.
+type OrganizationService struct {
+	repository OrganizationRepository
+	policy     *Policy
+}
+
+func NewOrganizationService(repository OrganizationRepository, policy *Policy) *OrganizationService {
+	return &OrganizationService{
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+func (s *OrganizationService) Create(ctx context.Context, d *dto.SaveOrganizationDto) (*domain.Organization, error) {
+	const fn = "OrganizationService.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequirePlatform(ctx); err != nil {
+		return nil, err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return nil, domain.ErrInvalidOrgName
+	}
+
+	org, err := s.repository.Create(ctx, d)
+	if err != nil {
+		logger.Error("cannot save organization", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Info("organization created", slog.String("id", org.Id))
+	return org, nil
+}
+
+func (s *OrganizationService) List(ctx context.Context) ([]*domain.Organization, error) {
+	if err := s.policy.RequirePlatform(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadAll(ctx)
+}
+
+// Current returns the organization of ctx.
+func (s *OrganizationService) Current(ctx context.Context) (*domain.Organization, error) {
+	return s.repository.Read(ctx, orgOf(ctx))
+}
+
+// Update changes the settings of the organization of ctx.
+func (s *OrganizationService) Update(ctx context.Context, d *dto.UpdateOrganizationDto) (*domain.Organization, error) {
+	const fn = "OrganizationService.Update"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.TimerPolicy != nil {
+		if err := d.TimerPolicy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	org, err := s.repository.Update(ctx, orgOf(ctx), d)
+	if err != nil {
+		logger.Error("cannot update organization", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	logger.Info("organization updated", slog.String("id", org.Id), slog.String("timerPolicy", string(org.TimerPolicy)))
+	return org, nil
+}
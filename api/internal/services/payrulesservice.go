@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+type PayRulesRepository interface {
+	ReadAll(ctx context.Context) ([]*domain.PayRules, error)
+	Save(ctx context.Context, d *dto.SavePayRulesDto) (*domain.PayRules, error)
+	Delete(ctx context.Context, teamId *string) error
+	ReadEffective(ctx context.Context, userId string) (*domain.PayRules, error)
+}
+
+type SummaryReader interface {
+	GetSummary(ctx context.Context, f *filters.Activity) (*domain.ActivitySummary, error)
+}
+
+// PayRulesService manages the rounding and overtime rules of an
+// organization and computes payable time from activity summaries.
+type PayRulesService struct {
+	repository PayRulesRepository
+	teams      TeamRepository
+	summaries  SummaryReader
+	calendars  UserCalendars
+	policy     *Policy
+}
+
+func NewPayRulesService(repository PayRulesRepository, teams TeamRepository, summaries SummaryReader, calendars UserCalendars, policy *Policy) *PayRulesService {
+	return &PayRulesService{
+		repository: repository,
+		teams:      teams,
+		summaries:  summaries,
+		calendars:  calendars,
+		policy:     policy,
+	}
+}
+
+func (s *PayRulesService) List(ctx context.Context) ([]*domain.PayRules, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadAll(ctx)
+}
+
+// Save replaces the rules of teamId, or the organization wide rules when
+// it is nil.
+func (s *PayRulesService) Save(ctx context.Context, d *dto.SavePayRulesDto) (*domain.PayRules, error) {
+	const fn = "PayRulesService.Save"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.Rules.Timezone == "" {
+		d.Rules.Timezone = "UTC"
+	}
+	if d.Rules.RoundingMode == "" {
+		d.Rules.RoundingMode = domain.RoundingNone
+	}
+	if d.Rules.DailyMultiplier == 0 {
+		d.Rules.DailyMultiplier = 1
+	}
+	if d.Rules.WeeklyMultiplier == 0 {
+		d.Rules.WeeklyMultiplier = 1
+	}
+
+	if err := d.Rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	if d.TeamId != nil {
+		if _, err := s.teams.Read(ctx, *d.TeamId); err != nil {
+			return nil, err
+		}
+	}
+
+	rules, err := s.repository.Save(ctx, d)
+	if err != nil {
+		logger.Error("cannot save pay rules", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (s *PayRulesService) Delete(ctx context.Context, teamId *string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, teamId)
+}
+
+// Effective returns the rules userId is paid by, tracked time is paid as
+// is when neither their teams nor the organization have rules.
+func (s *PayRulesService) Effective(ctx context.Context, userId string) (*domain.PayRules, error) {
+	if err := s.policy.CanView(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	rules, err := s.repository.ReadEffective(ctx, userId)
+	if errors.Is(err, domain.ErrPayRulesNotFound) {
+		return domain.DefaultPayRules(), nil
+	}
+	return rules, err
+}
+
+// Compute returns the activity summary of f together with the totals
+// computed from its sessions by the rules of the user, and the time the
+// user was expected to work when f has both bounds.
+func (s *PayRulesService) Compute(ctx context.Context, f *filters.Activity) (*domain.ComputedSummary, error) {
+	const fn = "PayRulesService.Compute"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	summary, err := s.summaries.GetSummary(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := s.Effective(ctx, f.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	// sessions are assigned to days in the time zone of the rules, a day
+	// more on either side covers every zone
+	now := time.Now()
+	from, to := now, now
+	if f.StartTime != nil {
+		from = *f.StartTime
+	}
+	if f.EndTime != nil {
+		to = *f.EndTime
+	}
+	for _, session := range summary.Sessions {
+		if f.StartTime == nil && session.StartTime.Before(from) {
+			from = session.StartTime
+		}
+		if f.EndTime == nil && session.StartTime.After(to) {
+			to = session.StartTime
+		}
+	}
+
+	schedule, calendar, err := s.calendars.ForUser(ctx, f.UserId, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1))
+	if err != nil {
+		logger.Error("cannot read calendar", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	computed, err := rules.Compute(summary.Sessions, calendar)
+	if err != nil {
+		logger.Error("cannot compute totals", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	result := &domain.ComputedSummary{
+		Raw:      summary,
+		Rules:    rules,
+		Computed: computed,
+	}
+
+	if f.StartTime != nil && f.EndTime != nil {
+		if to.After(now) {
+			to = now
+		}
+		var expected time.Duration
+		if !to.Before(from) {
+			expected = calendar.ExpectedTime(schedule, from, to)
+		}
+		result.ExpectedTime = &expected
+	}
+
+	return result, nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"log/slog"
+	"slices"
+)
+
+type ReportsResolver interface {
+	Read(ctx context.Context, id string) (*domain.User, error)
+	ReadReportIds(ctx context.Context, managerId string) ([]string, error)
+}
+
+// Policy decides what the principal of a request may do with whose data.
+type Policy struct {
+	users ReportsResolver
+}
+
+func NewPolicy(users ReportsResolver) *Policy {
+	return &Policy{
+		users: users,
+	}
+}
+
+func (p *Policy) principal(ctx context.Context) (*domain.Principal, error) {
+	principal, ok := domain.PrincipalFrom(ctx)
+	if !ok || principal == nil {
+		return nil, domain.ErrUnauthorized
+	}
+	return principal, nil
+}
+
+func (p *Policy) RequireRole(ctx context.Context, roles ...domain.Role) error {
+	principal, err := p.principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !principal.Is(roles...) {
+		slog.Debug("role not allowed", slog.String("principal", principal.Id), slog.String("role", string(principal.Role)))
+		return domain.ErrForbidden
+	}
+
+	return nil
+}
+
+// RequirePlatform allows admins that are not bound to an organization, such
+// as the bootstrap key, to manage organizations.
+func (p *Policy) RequirePlatform(ctx context.Context) error {
+	principal, err := p.principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !principal.IsPlatform() || !principal.Is(domain.RoleAdmin) {
+		return domain.ErrForbidden
+	}
+
+	return nil
+}
+
+func (p *Policy) isReport(ctx context.Context, managerId, userId string) (bool, error) {
+	user, err := p.users.Read(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+
+	if user.ManagerId != nil && *user.ManagerId == managerId {
+		return true, nil
+	}
+
+	// members of managed teams are reports as well
+	reports, err := p.users.ReadReportIds(ctx, managerId)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(reports, userId), nil
+}
+
+// CanTrack allows clocking userId in and out.
+func (p *Policy) CanTrack(ctx context.Context, userId string) error {
+	principal, err := p.principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if principal.Is(domain.RoleAdmin, domain.RoleKiosk) || principal.UserId == userId {
+		return nil
+	}
+
+	return domain.ErrForbidden
+}
+
+// CanView allows reading the activity of userId.
+func (p *Policy) CanView(ctx context.Context, userId string) error {
+	principal, err := p.principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case principal.Is(domain.RoleKiosk):
+		return domain.ErrForbidden
+	case principal.Is(domain.RoleAdmin), principal.UserId == userId:
+		return nil
+	case principal.Is(domain.RoleManager):
+		return p.requireReport(ctx, principal, userId)
+	}
+
+	return domain.ErrForbidden
+}
+
+// CanCorrect allows editing and manually entering sessions of userId.
+// Employees cannot correct their own time.
+func (p *Policy) CanCorrect(ctx context.Context, userId string) error {
+	principal, err := p.principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case principal.Is(domain.RoleAdmin):
+		return nil
+	case principal.Is(domain.RoleManager):
+		return p.requireReport(ctx, principal, userId)
+	}
+
+	return domain.ErrForbidden
+}
+
+// CanSubmit allows submitting the timesheets of userId, which users do for
+// themselves.
+func (p *Policy) CanSubmit(ctx context.Context, userId string) error {
+	principal, err := p.principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if principal.Is(domain.RoleAdmin) || (principal.UserId == userId && !principal.Is(domain.RoleKiosk)) {
+		return nil
+	}
+
+	return domain.ErrForbidden
+}
+
+// CanReview allows approving and rejecting the timesheets of userId. Nobody
+// reviews their own timesheets.
+func (p *Policy) CanReview(ctx context.Context, userId string) error {
+	principal, err := p.principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if principal.UserId == userId {
+		return domain.ErrForbidden
+	}
+
+	return p.CanCorrect(ctx, userId)
+}
+
+func (p *Policy) requireReport(ctx context.Context, principal *domain.Principal, userId string) error {
+	ok, err := p.isReport(ctx, principal.UserId, userId)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// VisibleUsers returns the users whose activity the principal may view;
+// all is true when there is no restriction.
+func (p *Policy) VisibleUsers(ctx context.Context) (all bool, ids []string, err error) {
+	principal, err := p.principal(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+
+	switch {
+	case principal.Is(domain.RoleAdmin):
+		return true, nil, nil
+	case principal.Is(domain.RoleKiosk) || principal.UserId == "":
+		return false, nil, domain.ErrForbidden
+	case principal.Is(domain.RoleManager):
+		reports, err := p.users.ReadReportIds(ctx, principal.UserId)
+		if err != nil {
+			return false, nil, err
+		}
+		return false, append(reports, principal.UserId), nil
+	}
+
+	return false, []string{principal.UserId}, nil
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+type ProjectRepository interface {
+	CreateClient(ctx context.Context, d *dto.SaveClientDto) (*domain.Client, error)
+	ReadClient(ctx context.Context, id string) (*domain.Client, error)
+	ReadClients(ctx context.Context) ([]*domain.Client, error)
+	UpdateClient(ctx context.Context, id string, d *dto.SaveClientDto) (*domain.Client, error)
+	DeleteClient(ctx context.Context, id string) error
+	Create(ctx context.Context, d *dto.SaveProjectDto) (*domain.Project, error)
+	Read(ctx context.Context, id string) (*domain.Project, error)
+	ReadAll(ctx context.Context, f *filters.Projects) ([]*domain.Project, error)
+	Update(ctx context.Context, id string, d *dto.UpdateProjectDto) (*domain.Project, error)
+	Delete(ctx context.Context, id string) error
+	SetRate(ctx context.Context, d *dto.SetRateDto) (*domain.ProjectRate, error)
+	DeleteRate(ctx context.Context, projectId, userId string) error
+	ReadRates(ctx context.Context, projectId string) ([]*domain.ProjectRate, error)
+	ReadTotals(ctx context.Context, f *filters.ProjectSummary) ([]*domain.ProjectUserTotal, error)
+}
+
+// ProjectService manages the clients and projects of an organization and
+// bills the time tracked on them. Admins and managers maintain clients and
+// projects, rates are reserved to admins.
+type ProjectService struct {
+	repository ProjectRepository
+	policy     *Policy
+}
+
+func NewProjectService(repository ProjectRepository, policy *Policy) *ProjectService {
+	return &ProjectService{
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+func (s *ProjectService) CreateClient(ctx context.Context, d *dto.SaveClientDto) (*domain.Client, error) {
+	const fn = "ProjectService.CreateClient"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", domain.ErrInvalidClient)
+	}
+
+	client, err := s.repository.CreateClient(ctx, d)
+	if err != nil {
+		logger.Error("cannot save client", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (s *ProjectService) Clients(ctx context.Context) ([]*domain.Client, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadClients(ctx)
+}
+
+func (s *ProjectService) GetClient(ctx context.Context, id string) (*domain.Client, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadClient(ctx, id)
+}
+
+func (s *ProjectService) UpdateClient(ctx context.Context, id string, d *dto.SaveClientDto) (*domain.Client, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", domain.ErrInvalidClient)
+	}
+
+	return s.repository.UpdateClient(ctx, id, d)
+}
+
+// DeleteClient removes a client, which must not have projects left.
+func (s *ProjectService) DeleteClient(ctx context.Context, id string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return err
+	}
+
+	return s.repository.DeleteClient(ctx, id)
+}
+
+func (s *ProjectService) Create(ctx context.Context, d *dto.SaveProjectDto) (*domain.Project, error) {
+	const fn = "ProjectService.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", domain.ErrInvalidProject)
+	}
+
+	if d.HourlyRate < 0 {
+		return nil, fmt.Errorf("%w: hourly rate must not be negative", domain.ErrInvalidProject)
+	}
+
+	d.Currency = strings.ToUpper(strings.TrimSpace(d.Currency))
+	if d.Currency == "" {
+		d.Currency = domain.DefaultCurrency
+	}
+	if err := domain.ValidateCurrency(d.Currency); err != nil {
+		return nil, err
+	}
+
+	if d.ClientId != nil {
+		if _, err := s.repository.ReadClient(ctx, *d.ClientId); err != nil {
+			return nil, err
+		}
+	}
+
+	project, err := s.repository.Create(ctx, d)
+	if err != nil {
+		logger.Error("cannot save project", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// List returns the projects of the organization, optionally of a client.
+func (s *ProjectService) List(ctx context.Context, f *filters.Projects) ([]*domain.Project, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadAll(ctx, f)
+}
+
+func (s *ProjectService) Get(ctx context.Context, id string) (*domain.Project, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.Read(ctx, id)
+}
+
+// Update changes a project. An empty client id detaches the project from
+// its client.
+func (s *ProjectService) Update(ctx context.Context, id string, d *dto.UpdateProjectDto) (*domain.Project, error) {
+	const fn = "ProjectService.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	if d.Name != nil {
+		name := strings.TrimSpace(*d.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name is required", domain.ErrInvalidProject)
+		}
+		d.Name = &name
+	}
+
+	if d.HourlyRate != nil && *d.HourlyRate < 0 {
+		return nil, fmt.Errorf("%w: hourly rate must not be negative", domain.ErrInvalidProject)
+	}
+
+	if d.Currency != nil {
+		currency := strings.ToUpper(strings.TrimSpace(*d.Currency))
+		if err := domain.ValidateCurrency(currency); err != nil {
+			return nil, err
+		}
+		d.Currency = &currency
+	}
+
+	if d.ClientId != nil && *d.ClientId != "" {
+		if _, err := s.repository.ReadClient(ctx, *d.ClientId); err != nil {
+			return nil, err
+		}
+	}
+
+	project, err := s.repository.Update(ctx, id, d)
+	if err != nil {
+		logger.Error("cannot update project", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// Delete removes a project, which must not have tasks left.
+func (s *ProjectService) Delete(ctx context.Context, id string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, id)
+}
+
+// SetRate overrides the hourly rate of a project for a user of the
+// organization.
+func (s *ProjectService) SetRate(ctx context.Context, d *dto.SetRateDto) (*domain.ProjectRate, error) {
+	const fn = "ProjectService.SetRate"
+	logger := slog.With(slog.String("fn", fn), slog.String("projectId", d.ProjectId))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.HourlyRate < 0 {
+		return nil, fmt.Errorf("%w: hourly rate must not be negative", domain.ErrInvalidProject)
+	}
+
+	if _, err := s.repository.Read(ctx, d.ProjectId); err != nil {
+		return nil, err
+	}
+
+	rate, err := s.repository.SetRate(ctx, d)
+	if err != nil {
+		logger.Error("cannot save rate", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return rate, nil
+}
+
+// DeleteRate returns a user to the hourly rate of the project.
+func (s *ProjectService) DeleteRate(ctx context.Context, projectId, userId string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	if _, err := s.repository.Read(ctx, projectId); err != nil {
+		return err
+	}
+
+	return s.repository.DeleteRate(ctx, projectId, userId)
+}
+
+func (s *ProjectService) Rates(ctx context.Context, projectId string) ([]*domain.ProjectRate, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.Read(ctx, projectId); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadRates(ctx, projectId)
+}
+
+// Summary totals the time tracked on the tasks of a project per user and
+// bills it at their rates. Only users visible to the caller are included.
+func (s *ProjectService) Summary(ctx context.Context, f *filters.ProjectSummary) (*domain.ProjectSummary, error) {
+	const fn = "ProjectService.Summary"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	all, visible, err := s.policy.VisibleUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !all {
+		if f.UserIds == nil {
+			f.UserIds = visible
+		} else {
+			f.UserIds = slices.DeleteFunc(f.UserIds, func(id string) bool {
+				return !slices.Contains(visible, id)
+			})
+		}
+	}
+
+	project, err := s.repository.Read(ctx, f.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := s.repository.ReadTotals(ctx, f)
+	if err != nil {
+		logger.Error("cannot read project totals", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	rates, err := s.repository.ReadRates(ctx, f.ProjectId)
+	if err != nil {
+		logger.Error("cannot read project rates", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return project.Bill(totals, rates), nil
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// maxReportDays bounds the period of an attendance report.
+const maxReportDays = 366
+
+type ScheduleRepository interface {
+	Create(ctx context.Context, d *dto.SaveScheduleDto) (*domain.Schedule, error)
+	Read(ctx context.Context, id string) (*domain.Schedule, error)
+	ReadAll(ctx context.Context) ([]*domain.Schedule, error)
+	Update(ctx context.Context, id string, d *dto.UpdateScheduleDto) (*domain.Schedule, error)
+	Delete(ctx context.Context, id string) error
+	AssignUser(ctx context.Context, userId string, scheduleId *string, from string) (*domain.User, error)
+	AssignTeam(ctx context.Context, teamId string, scheduleId *string, from string) (*domain.Team, error)
+	ReadEffective(ctx context.Context, userId string) (*domain.Schedule, error)
+}
+
+type LeaveReader interface {
+	ReadMany(ctx context.Context, f *filters.Leave) ([]*domain.LeaveRequest, error)
+}
+
+// RegionCalendars return the holidays and working days of a region.
+type RegionCalendars interface {
+	Calendar(ctx context.Context, region string, from, to time.Time) (domain.Calendar, error)
+}
+
+// ScheduleService manages the expected working weeks of an organization
+// and compares them against tracked sessions. A user follows their own
+// schedule, or else the one of the nearest team above them. The calendar
+// of the schedule's region overrides its working week on holidays and
+// transferred working days.
+type ScheduleService struct {
+	repository ScheduleRepository
+	sessions   SessionReader
+	leave      LeaveReader
+	calendars  RegionCalendars
+	publisher  EventPublisher
+	policy     *Policy
+}
+
+func NewScheduleService(repository ScheduleRepository, sessions SessionReader, leave LeaveReader, calendars RegionCalendars, publisher EventPublisher, policy *Policy) *ScheduleService {
+	return &ScheduleService{
+		repository: repository,
+		sessions:   sessions,
+		leave:      leave,
+		calendars:  calendars,
+		publisher:  publisher,
+		policy:     policy,
+	}
+}
+
+func validateTimezone(name string) error {
+	// Local is whatever zone the server runs in, not a zone of the schedule
+	if name == "Local" {
+		return fmt.Errorf("%w: unknown timezone", domain.ErrInvalidSchedule)
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("%w: unknown timezone", domain.ErrInvalidSchedule)
+	}
+	return nil
+}
+
+func validateDays(days []domain.ScheduleDay) error {
+	seen := make(map[int]bool, len(days))
+	for i := range days {
+		if err := days[i].Validate(); err != nil {
+			return err
+		}
+		if seen[days[i].Weekday] {
+			return fmt.Errorf("%w: weekday %d is listed twice", domain.ErrInvalidSchedule, days[i].Weekday)
+		}
+		seen[days[i].Weekday] = true
+	}
+	return nil
+}
+
+func (s *ScheduleService) Create(ctx context.Context, d *dto.SaveScheduleDto) (*domain.Schedule, error) {
+	const fn = "ScheduleService.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", domain.ErrInvalidSchedule)
+	}
+
+	if d.Timezone == "" {
+		d.Timezone = "UTC"
+	}
+	if err := validateTimezone(d.Timezone); err != nil {
+		return nil, err
+	}
+
+	d.Region = strings.TrimSpace(d.Region)
+
+	if d.GraceMinutes < 0 {
+		return nil, fmt.Errorf("%w: grace minutes must not be negative", domain.ErrInvalidSchedule)
+	}
+
+	if d.Days == nil {
+		d.Days = make([]domain.ScheduleDay, 0)
+	}
+	if err := validateDays(d.Days); err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.repository.Create(ctx, d)
+	if err != nil {
+		logger.Error("cannot save schedule", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *ScheduleService) List(ctx context.Context) ([]*domain.Schedule, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadAll(ctx)
+}
+
+func (s *ScheduleService) Get(ctx context.Context, id string) (*domain.Schedule, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.Read(ctx, id)
+}
+
+func (s *ScheduleService) Update(ctx context.Context, id string, d *dto.UpdateScheduleDto) (*domain.Schedule, error) {
+	const fn = "ScheduleService.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.Name != nil {
+		name := strings.TrimSpace(*d.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name is required", domain.ErrInvalidSchedule)
+		}
+		d.Name = &name
+	}
+
+	if d.Timezone != nil {
+		if err := validateTimezone(*d.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Region != nil {
+		region := strings.TrimSpace(*d.Region)
+		d.Region = &region
+	}
+
+	if d.GraceMinutes != nil && *d.GraceMinutes < 0 {
+		return nil, fmt.Errorf("%w: grace minutes must not be negative", domain.ErrInvalidSchedule)
+	}
+
+	if err := validateDays(d.Days); err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.repository.Update(ctx, id, d)
+	if err != nil {
+		logger.Error("cannot update schedule", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *ScheduleService) Delete(ctx context.Context, id string) error {
+	const fn = "ScheduleService.Delete"
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	slog.Info("deleting schedule", slog.String("fn", fn), slog.String("id", id))
+
+	return s.repository.Delete(ctx, id)
+}
+
+// AssignUser assigns schedule id to a user, or takes it away again when
+// assign is false.
+func (s *ScheduleService) AssignUser(ctx context.Context, id, userId string, assign bool) (*domain.User, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.Read(ctx, id); err != nil {
+		return nil, err
+	}
+
+	var scheduleId *string
+	if assign {
+		scheduleId = &id
+	}
+
+	user, err := s.repository.AssignUser(ctx, userId, scheduleId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventUserUpdated,
+		UserId:     user.Id,
+		OccurredAt: time.Now(),
+		Data:       user.WithoutPassport(),
+	})
+
+	return user, nil
+}
+
+// AssignTeam assigns schedule id to a team, or takes it away again when
+// assign is false.
+func (s *ScheduleService) AssignTeam(ctx context.Context, id, teamId string, assign bool) (*domain.Team, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.Read(ctx, id); err != nil {
+		return nil, err
+	}
+
+	var scheduleId *string
+	if assign {
+		scheduleId = &id
+	}
+
+	return s.repository.AssignTeam(ctx, teamId, scheduleId, id)
+}
+
+// Effective returns the schedule userId follows.
+func (s *ScheduleService) Effective(ctx context.Context, userId string) (*domain.Schedule, error) {
+	if err := s.policy.CanView(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadEffective(ctx, userId)
+}
+
+// Report compares the sessions of userId against their schedule for every
+// day from from to to, both inclusive, in the time zone of the schedule.
+// Days that have not started yet are left out.
+func (s *ScheduleService) Report(ctx context.Context, userId string, from, to time.Time) (*domain.AttendanceReport, error) {
+	const fn = "ScheduleService.Report"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", userId))
+
+	if err := s.policy.CanView(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.repository.ReadEffective(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc)
+	if last.Before(first) || last.Sub(first) > maxReportDays*24*time.Hour {
+		return nil, domain.ErrInvalidPeriod
+	}
+
+	// sessions only count when they lie within the filter, widen it by a
+	// day so sessions crossing the edges of the period are clipped instead
+	// of lost
+	start, end := first.AddDate(0, 0, -1), last.AddDate(0, 0, 2)
+	sessions, err := s.sessions.GetSessions(ctx, &filters.Activity{
+		UserId:    userId,
+		StartTime: &start,
+		EndTime:   &end,
+	})
+	if err != nil {
+		logger.Error("cannot read sessions", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	approved := domain.LeaveApproved
+	from, to = domain.DayOf(first), domain.DayOf(last)
+	leave, err := s.leave.ReadMany(ctx, &filters.Leave{
+		UserId: &userId,
+		Status: &approved,
+		From:   &from,
+		To:     &to,
+	})
+	if err != nil {
+		logger.Error("cannot read leave", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	calendar, err := s.calendars.Calendar(ctx, schedule.Region, from, to)
+	if err != nil {
+		logger.Error("cannot read calendar", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	report := &domain.AttendanceReport{
+		UserId:     userId,
+		ScheduleId: schedule.Id,
+		Days:       make([]*domain.AttendanceDay, 0),
+	}
+
+	now := time.Now()
+	for date := first; !date.After(last) && date.Before(now); date = date.AddDate(0, 0, 1) {
+		day, err := attendance(schedule, calendar, sessions, leave, date, loc, now)
+		if err != nil {
+			return nil, err
+		}
+		report.Days = append(report.Days, day)
+
+		report.ExpectedTime += day.Expected
+		report.WorkedTime += day.Worked
+		report.CreditedTime += day.Credited
+	}
+	report.Balance = report.WorkedTime + report.CreditedTime - report.ExpectedTime
+
+	return report, nil
+}
+
+// attendance compares the sessions overlapping date with the schedule of
+// that day. Days of approved leave are credited instead, work on holidays
+// is overtime.
+func attendance(schedule *domain.Schedule, calendar domain.Calendar, sessions []*domain.Session, leave []*domain.LeaveRequest, date time.Time, loc *time.Location, now time.Time) (*domain.AttendanceDay, error) {
+	dayStart, dayEnd := date, date.AddDate(0, 0, 1)
+
+	day := &domain.AttendanceDay{
+		Date:  date.Format("2006-01-02"),
+		Flags: make([]domain.AttendanceFlag, 0),
+	}
+
+	for _, session := range sessions {
+		if session.EndTime == nil {
+			continue
+		}
+
+		start, end := session.StartTime.In(loc), session.EndTime.In(loc)
+		if start.Before(dayStart) {
+			start = dayStart
+		}
+		if end.After(dayEnd) {
+			end = dayEnd
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		day.Worked += end.Sub(start)
+		if day.FirstStart == nil || start.Before(*day.FirstStart) {
+			day.FirstStart = &start
+		}
+		if day.LastEnd == nil || end.After(*day.LastEnd) {
+			day.LastEnd = &end
+		}
+	}
+
+	if holiday := calendar.On(date); holiday != nil && holiday.Kind == domain.CalendarHoliday {
+		day.Holiday = &holiday.Name
+		day.Flags = append(day.Flags, domain.AttendanceHoliday)
+	}
+
+	expected := calendar.WorkDay(schedule, date)
+	if expected == nil {
+		if day.Worked > 0 {
+			day.Overtime = day.Worked
+			day.Flags = append(day.Flags, domain.AttendanceOvertime)
+		}
+		return day, nil
+	}
+
+	start, end, err := expected.Bounds(date, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	day.Scheduled = true
+	day.ExpectedStart, day.ExpectedEnd = &start, &end
+	day.Expected = expected.Duration()
+
+	for _, l := range leave {
+		if l.Covers(day.Date) {
+			day.LeaveTypeId = &l.LeaveTypeId
+			day.Credited = day.Expected
+			day.Flags = append(day.Flags, domain.AttendanceLeave)
+			return day, nil
+		}
+	}
+
+	if day.Worked == 0 {
+		// nobody is absent before the working day is over
+		if !end.After(now) {
+			day.Flags = append(day.Flags, domain.AttendanceAbsent)
+		}
+		return day, nil
+	}
+
+	grace := schedule.Grace()
+
+	if late := day.FirstStart.Sub(start); late > grace {
+		day.Late = late
+		day.Flags = append(day.Flags, domain.AttendanceLate)
+	}
+
+	if early := end.Sub(*day.LastEnd); early > grace && !end.After(now) {
+		day.EarlyLeave = early
+		day.Flags = append(day.Flags, domain.AttendanceEarlyLeave)
+	}
+
+	if overtime := day.Worked - day.Expected; overtime > 0 {
+		day.Overtime = overtime
+		day.Flags = append(day.Flags, domain.AttendanceOvertime)
+	}
+
+	return day, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"log/slog"
+)
+
+type TagRepository interface {
+	Create(ctx context.Context, d *dto.SaveTagDto) (*domain.Tag, error)
+	ReadAll(ctx context.Context) ([]*domain.Tag, error)
+	Update(ctx context.Context, id string, d *dto.SaveTagDto) (*domain.Tag, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// TagService manages the tags sessions are labelled with. Admins and
+// managers maintain the vocabulary, everyone may use it.
+type TagService struct {
+	repository TagRepository
+	policy     *Policy
+}
+
+func NewTagService(repository TagRepository, policy *Policy) *TagService {
+	return &TagService{
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+func (s *TagService) Create(ctx context.Context, d *dto.SaveTagDto) (*domain.Tag, error) {
+	const fn = "TagService.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	name, err := domain.TagName(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	d.Name = name
+
+	tag, err := s.repository.Create(ctx, d)
+	if err != nil {
+		logger.Error("cannot save tag", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+func (s *TagService) List(ctx context.Context) ([]*domain.Tag, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadAll(ctx)
+}
+
+func (s *TagService) Update(ctx context.Context, id string, d *dto.SaveTagDto) (*domain.Tag, error) {
+	const fn = "TagService.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	name, err := domain.TagName(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	d.Name = name
+
+	tag, err := s.repository.Update(ctx, id, d)
+	if err != nil {
+		logger.Error("cannot update tag", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// Delete removes a tag and takes it off the sessions it labels.
+func (s *TagService) Delete(ctx context.Context, id string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, id)
+}
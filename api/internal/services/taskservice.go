@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+type TaskRepository interface {
+	Create(ctx context.Context, d *dto.SaveTaskDto) (*domain.Task, error)
+	Read(ctx context.Context, id string) (*domain.Task, error)
+	ReadAll(ctx context.Context, f *filters.Tasks) ([]*domain.Task, error)
+	Update(ctx context.Context, id string, d *dto.UpdateTaskDto) (*domain.Task, error)
+	Delete(ctx context.Context, id string) error
+	ReadTotals(ctx context.Context, f *filters.TaskTotals) ([]*domain.TaskUserTotal, error)
+	IsInSubtree(ctx context.Context, taskId, candidateId string) (bool, error)
+	ReadTime(ctx context.Context, id string, userIds []string) ([]*domain.TaskUserTime, error)
+}
+
+// ProjectReader reads the projects tasks belong to.
+type ProjectReader interface {
+	Read(ctx context.Context, id string) (*domain.Project, error)
+}
+
+// TaskService manages the tasks of the projects of an organization. Admins
+// and managers maintain tasks, everyone may track time on them. Tasks nest
+// into subtasks of the same project.
+type TaskService struct {
+	repository TaskRepository
+	projects   ProjectReader
+	policy     *Policy
+}
+
+func NewTaskService(repository TaskRepository, projects ProjectReader, policy *Policy) *TaskService {
+	return &TaskService{
+		repository: repository,
+		projects:   projects,
+		policy:     policy,
+	}
+}
+
+func (s *TaskService) Create(ctx context.Context, d *dto.SaveTaskDto) (*domain.Task, error) {
+	const fn = "TaskService.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	d.Title = strings.TrimSpace(d.Title)
+	if d.Title == "" {
+		return nil, fmt.Errorf("%w: title is required", domain.ErrInvalidTask)
+	}
+
+	if d.Status == "" {
+		d.Status = domain.TaskTodo
+	}
+	if err := d.Status.Validate(); err != nil {
+		return nil, err
+	}
+
+	if d.EstimateMinutes != nil && *d.EstimateMinutes <= 0 {
+		return nil, fmt.Errorf("%w: estimate must be positive", domain.ErrInvalidTask)
+	}
+
+	// a subtask is of the project of its parent
+	if d.ParentId != nil {
+		parent, err := s.repository.Read(ctx, *d.ParentId)
+		if err != nil {
+			return nil, err
+		}
+		if d.ProjectId == "" {
+			d.ProjectId = parent.ProjectId
+		}
+		if parent.ProjectId != d.ProjectId {
+			return nil, errParentOfOtherProject
+		}
+	}
+
+	if _, err := s.projects.Read(ctx, d.ProjectId); err != nil {
+		return nil, err
+	}
+
+	task, err := s.repository.Create(ctx, d)
+	if err != nil {
+		logger.Error("cannot save task", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// List returns the tasks of the organization, optionally of a project, in
+// a status or assigned to a user.
+func (s *TaskService) List(ctx context.Context, f *filters.Tasks) ([]*domain.Task, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	if f.Status != nil {
+		if err := f.Status.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repository.ReadAll(ctx, f)
+}
+
+func (s *TaskService) Get(ctx context.Context, id string) (*domain.Task, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.Read(ctx, id)
+}
+
+var errParentOfOtherProject = fmt.Errorf("%w: parent task is of another project", domain.ErrInvalidTask)
+
+// Update changes a task. Moving it to another project moves the time
+// tracked on it and its subtasks along.
+func (s *TaskService) Update(ctx context.Context, id string, d *dto.UpdateTaskDto) (*domain.Task, error) {
+	const fn = "TaskService.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	if d.Title != nil {
+		title := strings.TrimSpace(*d.Title)
+		if title == "" {
+			return nil, fmt.Errorf("%w: title is required", domain.ErrInvalidTask)
+		}
+		d.Title = &title
+	}
+
+	if d.Status != nil {
+		if err := d.Status.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.EstimateMinutes != nil && *d.EstimateMinutes < 0 {
+		return nil, fmt.Errorf("%w: estimate must be positive", domain.ErrInvalidTask)
+	}
+
+	if d.ProjectId != nil {
+		if _, err := s.projects.Read(ctx, *d.ProjectId); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.checkParent(ctx, id, d); err != nil {
+		return nil, err
+	}
+
+	task, err := s.repository.Update(ctx, id, d)
+	if err != nil {
+		logger.Error("cannot update task", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// checkParent checks the task id keeps, after d, a parent of its project
+// that is not one of its own subtasks.
+func (s *TaskService) checkParent(ctx context.Context, id string, d *dto.UpdateTaskDto) error {
+	if d.ParentId == nil && d.ProjectId == nil {
+		return nil
+	}
+
+	task, err := s.repository.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	parentId := task.ParentId
+	if d.ParentId != nil {
+		parentId = d.ParentId
+	}
+	if parentId == nil || *parentId == "" {
+		return nil
+	}
+
+	projectId := task.ProjectId
+	if d.ProjectId != nil {
+		projectId = *d.ProjectId
+	}
+
+	parent, err := s.repository.Read(ctx, *parentId)
+	if err != nil {
+		return err
+	}
+	if parent.ProjectId != projectId {
+		return errParentOfOtherProject
+	}
+
+	// nesting a task under one of its own subtasks would cut the subtree
+	// off the tree
+	cycle, err := s.repository.IsInSubtree(ctx, id, *parentId)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return domain.ErrInvalidParentTask
+	}
+
+	return nil
+}
+
+// Delete removes a task without subtasks no time was tracked on.
+func (s *TaskService) Delete(ctx context.Context, id string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, id)
+}
+
+// EstimateReport compares estimated and tracked time of the tasks of f per
+// task and per assignee. Managers only see the time of users they may
+// view.
+func (s *TaskService) EstimateReport(ctx context.Context, f *filters.Tasks) (*domain.EstimateReport, error) {
+	const fn = "TaskService.EstimateReport"
+	logger := slog.With(slog.String("fn", fn), slog.Any("filters", f))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager); err != nil {
+		return nil, err
+	}
+
+	all, visible, err := s.policy.VisibleUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.repository.ReadAll(ctx, f)
+	if err != nil {
+		logger.Error("cannot read tasks", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	totals := make([]*domain.TaskUserTotal, 0)
+	if len(tasks) > 0 {
+		totalsFilter := &filters.TaskTotals{TaskIds: make([]string, 0, len(tasks))}
+		for _, t := range tasks {
+			totalsFilter.TaskIds = append(totalsFilter.TaskIds, t.Id)
+		}
+		if !all {
+			totalsFilter.UserIds = visible
+		}
+
+		totals, err = s.repository.ReadTotals(ctx, totalsFilter)
+		if err != nil {
+			logger.Error("cannot read task totals", slog.String("err", err.Error()))
+			return nil, err
+		}
+	}
+
+	report := domain.CompareEstimates(tasks, totals)
+	if !all {
+		report.Assignees = slices.DeleteFunc(report.Assignees, func(a *domain.AssigneeEstimate) bool {
+			return !slices.Contains(visible, a.UserId)
+		})
+	}
+
+	return report, nil
+}
+
+// Time returns the time tracked on a task itself and on its whole subtree,
+// per user. Only users visible to the caller are included.
+func (s *TaskService) Time(ctx context.Context, id string) (*domain.TaskTime, error) {
+	const fn = "TaskService.Time"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.Read(ctx, id); err != nil {
+		return nil, err
+	}
+
+	all, visible, err := s.policy.VisibleUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIds []string
+	if !all {
+		userIds = visible
+	}
+
+	users, err := s.repository.ReadTime(ctx, id, userIds)
+	if err != nil {
+		logger.Error("cannot read task time", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	taskTime := &domain.TaskTime{TaskId: id, Users: users}
+	for _, u := range users {
+		taskTime.DirectTime += u.DirectTime
+		taskTime.TotalTime += u.TotalTime
+	}
+
+	return taskTime, nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+type TeamRepository interface {
+	Create(ctx context.Context, d *dto.SaveTeamDto) (*domain.Team, error)
+	Read(ctx context.Context, id string) (*domain.Team, error)
+	ReadAll(ctx context.Context) ([]*domain.Team, error)
+	Update(ctx context.Context, id string, d *dto.UpdateTeamDto) (*domain.Team, error)
+	Delete(ctx context.Context, id string) error
+	IsInSubtree(ctx context.Context, teamId, candidateId string) (bool, error)
+	AddMember(ctx context.Context, teamId, userId string) error
+	RemoveMember(ctx context.Context, teamId, userId string) error
+	ReadMemberIds(ctx context.Context, teamId string) ([]string, error)
+}
+
+// TeamService manages the team tree of an organization. Changing the tree
+// and its membership is reserved to admins.
+type TeamService struct {
+	repository TeamRepository
+	users      UserRepository
+	policy     *Policy
+}
+
+func NewTeamService(repository TeamRepository, users UserRepository, policy *Policy) *TeamService {
+	return &TeamService{
+		repository: repository,
+		users:      users,
+		policy:     policy,
+	}
+}
+
+// requireUser maps a missing user of the organization to err.
+func (s *TeamService) requireUser(ctx context.Context, id string, err error) error {
+	if _, e := s.users.Read(ctx, id); e != nil {
+		if errors.Is(e, domain.ErrUserNotFound) {
+			return err
+		}
+		return e
+	}
+	return nil
+}
+
+func (s *TeamService) Create(ctx context.Context, d *dto.SaveTeamDto) (*domain.Team, error) {
+	const fn = "TeamService.Create"
+	logger := slog.With(slog.String("fn", fn))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return nil, domain.ErrInvalidTeamName
+	}
+
+	if d.ParentId != nil {
+		if _, err := s.repository.Read(ctx, *d.ParentId); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.ManagerId != nil {
+		if err := s.requireUser(ctx, *d.ManagerId, domain.ErrInvalidManager); err != nil {
+			return nil, err
+		}
+	}
+
+	team, err := s.repository.Create(ctx, d)
+	if err != nil {
+		logger.Error("cannot save team", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return team, nil
+}
+
+func (s *TeamService) List(ctx context.Context) ([]*domain.Team, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadAll(ctx)
+}
+
+func (s *TeamService) Get(ctx context.Context, id string) (*domain.Team, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin, domain.RoleManager, domain.RoleEmployee); err != nil {
+		return nil, err
+	}
+
+	return s.repository.Read(ctx, id)
+}
+
+func (s *TeamService) Update(ctx context.Context, id string, d *dto.UpdateTeamDto) (*domain.Team, error) {
+	const fn = "TeamService.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.Name != nil {
+		name := strings.TrimSpace(*d.Name)
+		if name == "" {
+			return nil, domain.ErrInvalidTeamName
+		}
+		d.Name = &name
+	}
+
+	if d.ParentId != nil && *d.ParentId != "" {
+		if _, err := s.repository.Read(ctx, *d.ParentId); err != nil {
+			return nil, err
+		}
+
+		// moving a team below one of its own descendants would cut the
+		// subtree off the tree
+		cycle, err := s.repository.IsInSubtree(ctx, id, *d.ParentId)
+		if err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, domain.ErrInvalidParentTeam
+		}
+	}
+
+	if d.ManagerId != nil && *d.ManagerId != "" {
+		if err := s.requireUser(ctx, *d.ManagerId, domain.ErrInvalidManager); err != nil {
+			return nil, err
+		}
+	}
+
+	team, err := s.repository.Update(ctx, id, d)
+	if err != nil {
+		logger.Error("cannot update team", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return team, nil
+}
+
+func (s *TeamService) Delete(ctx context.Context, id string) error {
+	const fn = "TeamService.Delete"
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	slog.Info("deleting team", slog.String("fn", fn), slog.String("id", id))
+
+	return s.repository.Delete(ctx, id)
+}
+
+func (s *TeamService) AddMember(ctx context.Context, teamId, userId string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	if _, err := s.repository.Read(ctx, teamId); err != nil {
+		return err
+	}
+
+	if err := s.requireUser(ctx, userId, domain.ErrUserNotFound); err != nil {
+		return err
+	}
+
+	return s.repository.AddMember(ctx, teamId, userId)
+}
+
+func (s *TeamService) RemoveMember(ctx context.Context, teamId, userId string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	return s.repository.RemoveMember(ctx, teamId, userId)
+}
+
+// MemberIds returns the members of teamId and its descendant teams the
+// caller is allowed to view.
+func (s *TeamService) MemberIds(ctx context.Context, teamId string) ([]string, error) {
+	all, visible, err := s.policy.VisibleUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.Read(ctx, teamId); err != nil {
+		return nil, err
+	}
+
+	members, err := s.repository.ReadMemberIds(ctx, teamId)
+	if err != nil {
+		return nil, err
+	}
+
+	if all {
+		return members, nil
+	}
+
+	return slices.DeleteFunc(members, func(id string) bool {
+		return !slices.Contains(visible, id)
+	}), nil
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bytes"
+	"em-test/internal/domain"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importEntry is a time entry read from a row of an import file.
+type importEntry struct {
+	row     int
+	person  string
+	client  string
+	project string
+	task    string
+	note    string
+	start   time.Time
+	end     time.Time
+}
+
+// importColumns names the columns of Toggl and Clockify detailed reports,
+// lower cased. Toggl calls the person User or Member, Clockify writes the
+// duration both as a clock and as a decimal.
+var importColumns = map[string][]string{
+	"person":      {"user", "member"},
+	"client":      {"client"},
+	"project":     {"project"},
+	"task":        {"task"},
+	"description": {"description"},
+	"startDate":   {"start date"},
+	"startTime":   {"start time"},
+	"endDate":     {"end date"},
+	"endTime":     {"end time"},
+	"duration":    {"duration", "duration (h)"},
+}
+
+// importDateLayouts are the dates both trackers write depending on the
+// workspace settings. Dates with slashes are read month first, as both
+// write them by default.
+var importDateLayouts = []string{"2006-01-02", "01/02/2006", "02.01.2006", "2006/01/02"}
+
+var importTimeLayouts = []string{"15:04:05", "15:04", "03:04:05 PM", "3:04:05 PM", "03:04 PM", "3:04 PM"}
+
+// guessImportSource tells the tracker of an export by its header: Toggl
+// writes "Start date", Clockify "Start Date" next to "Duration (h)".
+func guessImportSource(header []string) (domain.ImportSource, error) {
+	for _, name := range header {
+		switch strings.TrimSpace(name) {
+		case "Start date":
+			return domain.ImportToggl, nil
+		case "Start Date", "Duration (h)":
+			return domain.ImportClockify, nil
+		}
+	}
+	return "", fmt.Errorf("%w: cannot tell the source from the header, set it", domain.ErrInvalidImportFile)
+}
+
+// importComma is the separator of the header line, exports of spreadsheets
+// in decimal comma locales use semicolons.
+func importComma(data []byte) rune {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+// parseTimeEntries reads the time entries of a CSV export of source, which
+// is guessed from the header when empty. Times are read in loc. Rows that
+// cannot be read are returned as rejections, the file fails as a whole
+// only when it is not CSV or lacks needed columns.
+func parseTimeEntries(source domain.ImportSource, data []byte, loc *time.Location) (domain.ImportSource, []*importEntry, []*domain.ImportRejection, error) {
+	// spreadsheets save UTF-8 with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comma = importComma(data)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return "", nil, nil, fmt.Errorf("%w: file is empty", domain.ErrInvalidImportFile)
+	}
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %s", domain.ErrInvalidImportFile, err.Error())
+	}
+
+	if source == "" {
+		if source, err = guessImportSource(header); err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	columns := make(map[string]int, len(importColumns))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for column, names := range importColumns {
+			if _, ok := columns[column]; !ok && slices.Contains(names, name) {
+				columns[column] = i
+			}
+		}
+	}
+
+	for _, column := range []string{"person", "startDate", "startTime"} {
+		if _, ok := columns[column]; !ok {
+			return "", nil, nil, fmt.Errorf("%w: column %q is missing", domain.ErrInvalidImportFile, importColumns[column][0])
+		}
+	}
+	_, hasEnd := columns["endTime"]
+	if _, ok := columns["duration"]; !ok && !hasEnd {
+		return "", nil, nil, fmt.Errorf("%w: either the end time or the duration is needed", domain.ErrInvalidImportFile)
+	}
+
+	entries := make([]*importEntry, 0)
+	rejected := make([]*domain.ImportRejection, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("%w: %s", domain.ErrInvalidImportFile, err.Error())
+		}
+
+		row, _ := reader.FieldPos(0)
+		field := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		entry := &importEntry{
+			row:     row,
+			person:  field("person"),
+			client:  field("client"),
+			project: field("project"),
+			task:    field("task"),
+			note:    field("description"),
+		}
+
+		if err := readEntryTimes(entry, field, loc); err != nil {
+			rejected = append(rejected, &domain.ImportRejection{Row: row, User: entry.person, Reason: err.Error()})
+			continue
+		}
+
+		if entry.person == "" {
+			rejected = append(rejected, &domain.ImportRejection{Row: row, Reason: "user is missing"})
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return source, entries, rejected, nil
+}
+
+// readEntryTimes reads the start and end of an entry. The end date defaults
+// to the start date, the end to the start plus the duration.
+func readEntryTimes(entry *importEntry, field func(string) string, loc *time.Location) error {
+	start, err := importTime(field("startDate"), field("startTime"), loc)
+	if err != nil {
+		return err
+	}
+	entry.start = start
+
+	if endTime := field("endTime"); endTime != "" {
+		endDate := field("endDate")
+		if endDate == "" {
+			endDate = field("startDate")
+		}
+		if entry.end, err = importTime(endDate, endTime, loc); err != nil {
+			return err
+		}
+	} else {
+		duration, err := importDuration(field("duration"))
+		if err != nil {
+			return err
+		}
+		entry.end = start.Add(duration)
+	}
+
+	if !entry.end.After(entry.start) {
+		return errors.New("end is not after start")
+	}
+	return nil
+}
+
+// importTime reads a date and a time of day in loc.
+func importTime(date, clock string, loc *time.Location) (time.Time, error) {
+	value := date + " " + strings.ToUpper(clock)
+	for _, dateLayout := range importDateLayouts {
+		for _, timeLayout := range importTimeLayouts {
+			if t, err := time.ParseInLocation(dateLayout+" "+timeLayout, value, loc); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date and time %q", value)
+}
+
+// importDuration reads a duration written as H:MM:SS or H:MM, hours going
+// past a day.
+func importDuration(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	var d time.Duration
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (i > 0 && n > 59) {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(n) * units[i]
+	}
+	return d, nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"em-test/internal/domain"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestImportDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"1:30:00", 90 * time.Minute, false},
+		{"0:05", 5 * time.Minute, false},
+		{"26:00:01", 26*time.Hour + time.Second, false},
+		{"00:00:00", 0, false},
+		{"1:60:00", 0, true},
+		{"1:00:60", 0, true},
+		{"-1:00", 0, true},
+		{"1.5", 0, true},
+		{"1:2:3:4", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := importDuration(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("importDuration(%q) = %v, %v, want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestImportTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date, clock string
+		want        time.Time
+		wantErr     bool
+	}{
+		{"2024-03-04", "09:15:00", time.Date(2024, 3, 4, 9, 15, 0, 0, berlin), false},
+		{"2024-03-04", "09:15", time.Date(2024, 3, 4, 9, 15, 0, 0, berlin), false},
+		{"03/04/2024", "9:15:00 pm", time.Date(2024, 3, 4, 21, 15, 0, 0, berlin), false},
+		{"03/04/2024", "12:05 AM", time.Date(2024, 3, 4, 0, 5, 0, 0, berlin), false},
+		{"04.03.2024", "17:00", time.Date(2024, 3, 4, 17, 0, 0, 0, berlin), false},
+		{"2024/03/04", "17:00:30", time.Date(2024, 3, 4, 17, 0, 30, 0, berlin), false},
+		{"2024-13-04", "09:00", time.Time{}, true},
+		{"2024-03-04", "25:00", time.Time{}, true},
+		{"", "09:00", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := importTime(tt.date, tt.clock, berlin)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("importTime(%q, %q) = %v, %v, want %v, error %v", tt.date, tt.clock, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// csvLines joins lines into a file ended by a line break.
+func csvLines(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func TestParseTimeEntries(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	type entry struct {
+		row                           int
+		person, client, project, task string
+		note                          string
+		start, end                    time.Time
+	}
+
+	tests := []struct {
+		name     string
+		source   domain.ImportSource
+		data     []byte
+		want     domain.ImportSource
+		entries  []entry
+		rejected []int
+		wantErr  bool
+	}{
+		{
+			name: "toggl",
+			data: csvLines(
+				"User,Email,Client,Project,Task,Description,Billable,Start date,Start time,End date,End time,Duration,Tags",
+				"Ann Lee,ann@example.com,Acme,Site,Design,\"Header, footer\",Yes,2024-03-04,09:00:00,2024-03-04,10:30:00,01:30:00,",
+				"Bob Ray,bob@example.com,,Site,,,No,2024-03-04,23:00:00,2024-03-05,01:00:00,02:00:00,",
+			),
+			want: domain.ImportToggl,
+			entries: []entry{
+				{2, "Ann Lee", "Acme", "Site", "Design", "Header, footer", at(4, 9, 0), at(4, 10, 30)},
+				{3, "Bob Ray", "", "Site", "", "", at(4, 23, 0), at(5, 1, 0)},
+			},
+		},
+		{
+			name: "clockify with semicolons and a byte order mark",
+			data: append([]byte("\xef\xbb\xbf"), csvLines(
+				"Project;Client;Description;Task;User;Email;Tags;Billable;Start Date;Start Time;End Date;End Time;Duration (h);Duration (decimal)",
+				"Site;Acme;Review;Design;Ann Lee;ann@example.com;;Yes;03/04/2024;09:00:00 AM;03/04/2024;01:15:00 PM;04:15:00;4,25",
+			)...),
+			want: domain.ImportClockify,
+			entries: []entry{
+				{2, "Ann Lee", "Acme", "Site", "Design", "Review", at(4, 9, 0), at(4, 13, 15)},
+			},
+		},
+		{
+			name: "duration without end",
+			data: csvLines(
+				"Member,Project,Start date,Start time,Duration",
+				"Ann Lee,Site,2024-03-04,22:00,03:00:00",
+			),
+			want: domain.ImportToggl,
+			entries: []entry{
+				{2, "Ann Lee", "", "Site", "", "", at(4, 22, 0), at(5, 1, 0)},
+			},
+		},
+		{
+			name:   "source set",
+			source: domain.ImportClockify,
+			data: csvLines(
+				"User,Start date,Start time,Duration",
+				"Ann Lee,2024-03-04,09:00,1:00",
+			),
+			want: domain.ImportClockify,
+			entries: []entry{
+				{2, "Ann Lee", "", "", "", "", at(4, 9, 0), at(4, 10, 0)},
+			},
+		},
+		{
+			name: "rows rejected",
+			data: csvLines(
+				"User,Project,Start date,Start time,End date,End time,Duration",
+				"Ann Lee,Site,2024-03-04,09:00,2024-03-04,10:00,1:00",
+				"Ann Lee,Site,yesterday,09:00,,,1:00",
+				"Ann Lee,Site,2024-03-04,11:00,2024-03-04,10:00,",
+				",Site,2024-03-04,12:00,2024-03-04,13:00,1:00",
+				"Ann Lee,Site,2024-03-04,14:00,,,soon",
+				"Ann Lee,Site,2024-03-04,15:00",
+			),
+			want: domain.ImportToggl,
+			entries: []entry{
+				{2, "Ann Lee", "", "Site", "", "", at(4, 9, 0), at(4, 10, 0)},
+			},
+			rejected: []int{3, 4, 5, 6, 7},
+		},
+		{
+			name:    "empty",
+			data:    []byte(""),
+			wantErr: true,
+		},
+		{
+			name:    "unknown source",
+			data:    csvLines("Name,Begin,Finish", "Ann Lee,09:00,10:00"),
+			wantErr: true,
+		},
+		{
+			name:    "person missing",
+			data:    csvLines("Project,Start date,Start time,Duration", "Site,2024-03-04,09:00,1:00"),
+			wantErr: true,
+		},
+		{
+			name:    "end and duration missing",
+			data:    csvLines("User,Start date,Start time", "Ann Lee,2024-03-04,09:00"),
+			wantErr: true,
+		},
+		{
+			name:    "not csv",
+			data:    csvLines("User,Start date,Start time,Duration", "\"Ann Lee,2024-03-04,09:00,1:00"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, entries, rejected, err := parseTimeEntries(tt.source, tt.data, time.UTC)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidImportFile) {
+					t.Fatalf("parseTimeEntries() error = %v, want %v", err, domain.ErrInvalidImportFile)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTimeEntries() error = %v", err)
+			}
+
+			if source != tt.want {
+				t.Errorf("parseTimeEntries() source = %q, want %q", source, tt.want)
+			}
+
+			if len(entries) != len(tt.entries) {
+				t.Fatalf("parseTimeEntries() read %d entries, want %d", len(entries), len(tt.entries))
+			}
+			for i, want := range tt.entries {
+				got := entry{
+					entries[i].row, entries[i].person, entries[i].client, entries[i].project, entries[i].task,
+					entries[i].note, entries[i].start, entries[i].end,
+				}
+				if got != want {
+					t.Errorf("entry %d = %+v, want %+v", i, got, want)
+				}
+			}
+
+			rows := make([]int, 0, len(rejected))
+			for _, rejection := range rejected {
+				rows = append(rows, rejection.Row)
+				if rejection.Reason == "" {
+					t.Errorf("row %d rejected without a reason", rejection.Row)
+				}
+			}
+			if !slices.Equal(rows, tt.rejected) {
+				t.Errorf("parseTimeEntries() rejected rows %v, want %v", rows, tt.rejected)
+			}
+		})
+	}
+}
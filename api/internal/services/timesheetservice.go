@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type TimesheetRepository interface {
+	Submit(ctx context.Context, d *dto.SubmitTimesheetDto) (*domain.Timesheet, error)
+	Review(ctx context.Context, d *dto.ReviewTimesheetDto) (*domain.Timesheet, error)
+	Read(ctx context.Context, id string) (*domain.Timesheet, error)
+	ReadMany(ctx context.Context, f *filters.Timesheets) ([]*domain.Timesheet, error)
+}
+
+type SessionReader interface {
+	GetSessions(ctx context.Context, f *filters.Activity) ([]*domain.Session, error)
+	GetRunning(ctx context.Context, userId string) ([]*domain.Session, error)
+}
+
+// TimesheetService moves weekly timesheets through submission and review.
+// Timesheets hold no time of their own, their sessions are the activity
+// records of the week.
+type TimesheetService struct {
+	repository TimesheetRepository
+	sessions   SessionReader
+	publisher  EventPublisher
+	policy     *Policy
+}
+
+func NewTimesheetService(repository TimesheetRepository, sessions SessionReader, publisher EventPublisher, policy *Policy) *TimesheetService {
+	return &TimesheetService{
+		repository: repository,
+		sessions:   sessions,
+		publisher:  publisher,
+		policy:     policy,
+	}
+}
+
+// withSessions fills in the sessions and total time of the week.
+func (s *TimesheetService) withSessions(ctx context.Context, t *domain.Timesheet) (*domain.Timesheet, error) {
+	start, end := t.WeekStart, t.WeekEnd()
+
+	sessions, err := s.sessions.GetSessions(ctx, &filters.Activity{
+		UserId:    t.UserId,
+		StartTime: &start,
+		EndTime:   &end,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.Sessions = sessions
+	t.TotalTime = 0
+	for _, session := range sessions {
+		if session.EndTime != nil {
+			t.TotalTime += session.EndTime.Sub(session.StartTime)
+		}
+	}
+
+	return t, nil
+}
+
+func (s *TimesheetService) publish(ctx context.Context, t *domain.Timesheet, eventType domain.EventType) {
+	s.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       eventType,
+		UserId:     t.UserId,
+		OccurredAt: time.Now(),
+		Data:       t,
+	})
+}
+
+func (s *TimesheetService) Submit(ctx context.Context, d *dto.SubmitTimesheetDto) (*domain.Timesheet, error) {
+	const fn = "TimesheetService.Submit"
+	logger := slog.With(slog.String("fn", fn), slog.String("userId", d.UserId))
+
+	if err := s.policy.CanSubmit(ctx, d.UserId); err != nil {
+		return nil, err
+	}
+
+	if !domain.WeekStartOf(d.WeekStart).Equal(d.WeekStart) {
+		return nil, domain.ErrInvalidWeekStart
+	}
+
+	// an approved week is locked, sessions of a week still in progress
+	// could no longer be tracked
+	if d.WeekStart.Add(domain.TimesheetPeriod).After(time.Now()) {
+		return nil, domain.ErrWeekNotOver
+	}
+
+	timesheet, err := s.repository.Submit(ctx, d)
+	if err != nil {
+		logger.Error("cannot submit timesheet", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	s.publish(ctx, timesheet, domain.EventTimesheetSubmitted)
+
+	return s.withSessions(ctx, timesheet)
+}
+
+func (s *TimesheetService) Approve(ctx context.Context, id string, comment *string) (*domain.Timesheet, error) {
+	return s.review(ctx, id, domain.TimesheetApproved, comment)
+}
+
+func (s *TimesheetService) Reject(ctx context.Context, id string, comment *string) (*domain.Timesheet, error) {
+	if comment == nil || strings.TrimSpace(*comment) == "" {
+		return nil, domain.ErrCommentRequired
+	}
+
+	return s.review(ctx, id, domain.TimesheetRejected, comment)
+}
+
+func (s *TimesheetService) review(ctx context.Context, id string, status domain.TimesheetStatus, comment *string) (*domain.Timesheet, error) {
+	const fn = "TimesheetService.review"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id), slog.String("status", string(status)))
+
+	timesheet, err := s.repository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.policy.CanReview(ctx, timesheet.UserId); err != nil {
+		return nil, err
+	}
+
+	if !timesheet.Status.CanTransition(status) {
+		return nil, domain.ErrInvalidTransition
+	}
+
+	// a session running into the week would be stopped after the week is
+	// locked and add its time to it
+	if status == domain.TimesheetApproved {
+		if timesheet.WeekEnd().After(time.Now()) {
+			return nil, domain.ErrWeekNotOver
+		}
+
+		running, err := s.sessions.GetRunning(ctx, timesheet.UserId)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range running {
+			if session.StartTime.Before(timesheet.WeekEnd()) {
+				logger.Debug("session still running", slog.Int64("sessionId", session.Id))
+				return nil, domain.ErrSessionRunning
+			}
+		}
+	}
+
+	principal, _ := domain.PrincipalFrom(ctx)
+
+	timesheet, err = s.repository.Review(ctx, &dto.ReviewTimesheetDto{
+		Id:         id,
+		Status:     status,
+		ReviewerId: principal.Id,
+		Comment:    comment,
+	})
+	if err != nil {
+		logger.Error("cannot review timesheet", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if status == domain.TimesheetApproved {
+		s.publish(ctx, timesheet, domain.EventTimesheetApproved)
+	} else {
+		s.publish(ctx, timesheet, domain.EventTimesheetRejected)
+	}
+
+	return s.withSessions(ctx, timesheet)
+}
+
+func (s *TimesheetService) Get(ctx context.Context, id string) (*domain.Timesheet, error) {
+	timesheet, err := s.repository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.policy.CanView(ctx, timesheet.UserId); err != nil {
+		return nil, err
+	}
+
+	return s.withSessions(ctx, timesheet)
+}
+
+// List returns timesheets of the users visible to the caller, without
+// their sessions.
+func (s *TimesheetService) List(ctx context.Context, f *filters.Timesheets) ([]*domain.Timesheet, error) {
+	all, visible, err := s.policy.VisibleUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !all {
+		f.UserIds = visible
+	}
+
+	return s.repository.ReadMany(ctx, f)
+}
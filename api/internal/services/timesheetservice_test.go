@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"em-test/internal/lib/filters"
+	"errors"
+	"testing"
+	"time"
+)
+
+// timesheetsStub holds a single timesheet.
+type timesheetsStub struct {
+	TimesheetRepository
+	timesheet *domain.Timesheet
+}
+
+func (r *timesheetsStub) Read(_ context.Context, id string) (*domain.Timesheet, error) {
+	if id != r.timesheet.Id {
+		return nil, domain.ErrTimesheetNotFound
+	}
+	t := *r.timesheet
+	return &t, nil
+}
+
+func (r *timesheetsStub) Review(_ context.Context, d *dto.ReviewTimesheetDto) (*domain.Timesheet, error) {
+	r.timesheet.Status = d.Status
+	r.timesheet.Comment = d.Comment
+	t := *r.timesheet
+	return &t, nil
+}
+
+func (r *timesheetsStub) Submit(_ context.Context, d *dto.SubmitTimesheetDto) (*domain.Timesheet, error) {
+	r.timesheet = &domain.Timesheet{Id: "week", UserId: d.UserId, WeekStart: d.WeekStart, Status: domain.TimesheetSubmitted}
+	t := *r.timesheet
+	return &t, nil
+}
+
+type sessionsStub struct {
+	running []*domain.Session
+}
+
+func (r *sessionsStub) GetSessions(context.Context, *filters.Activity) ([]*domain.Session, error) {
+	return nil, nil
+}
+
+func (r *sessionsStub) GetRunning(context.Context, string) ([]*domain.Session, error) {
+	return r.running, nil
+}
+
+type publisherStub struct {
+	events []domain.Event
+}
+
+func (p *publisherStub) Publish(e domain.Event) {
+	p.events = append(p.events, e)
+}
+
+func TestTimesheetApprove(t *testing.T) {
+	weekStart := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		started []time.Time
+		wantErr error
+	}{
+		{"nothing running", nil, nil},
+		{"running from within the week", []time.Time{weekStart.Add(50 * time.Hour)}, domain.ErrSessionRunning},
+		{"running from before the week", []time.Time{weekStart.Add(-time.Hour)}, domain.ErrSessionRunning},
+		{"running from a later week", []time.Time{weekStart.AddDate(0, 0, 7)}, nil},
+	}
+
+	admin := &domain.Principal{Kind: domain.PrincipalUser, Id: "admin", UserId: "admin", Role: domain.RoleAdmin, OrgId: "org"}
+	ctx := domain.WithOrg(domain.WithPrincipal(context.Background(), admin), admin.OrgId)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &sessionsStub{}
+			for i, start := range tt.started {
+				sessions.running = append(sessions.running, &domain.Session{Id: int64(i + 1), UserId: "user", StartTime: start})
+			}
+
+			timesheets := &timesheetsStub{timesheet: &domain.Timesheet{
+				Id:        "week",
+				UserId:    "user",
+				WeekStart: weekStart,
+				Status:    domain.TimesheetSubmitted,
+			}}
+			publisher := &publisherStub{}
+			s := NewTimesheetService(timesheets, sessions, publisher, NewPolicy(nil))
+
+			timesheet, err := s.Approve(ctx, "week", nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Approve() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if timesheets.timesheet.Status != domain.TimesheetSubmitted || len(publisher.events) != 0 {
+					t.Errorf("refused approval changed the timesheet to %q", timesheets.timesheet.Status)
+				}
+				return
+			}
+
+			if timesheet.Status != domain.TimesheetApproved {
+				t.Errorf("Approve() status = %q, want %q", timesheet.Status, domain.TimesheetApproved)
+			}
+			if len(publisher.events) != 1 || publisher.events[0].Type != domain.EventTimesheetApproved {
+				t.Errorf("Approve() published %v", publisher.events)
+			}
+		})
+	}
+}
+
+func TestTimesheetSubmit(t *testing.T) {
+	thisWeek := domain.WeekStartOf(time.Now())
+
+	tests := []struct {
+		name      string
+		weekStart time.Time
+		wantErr   error
+	}{
+		{"last week", thisWeek.AddDate(0, 0, -7), nil},
+		{"this week", thisWeek, domain.ErrWeekNotOver},
+		{"next week", thisWeek.AddDate(0, 0, 7), domain.ErrWeekNotOver},
+		{"not a monday", thisWeek.AddDate(0, 0, -6), domain.ErrInvalidWeekStart},
+	}
+
+	user := &domain.Principal{Kind: domain.PrincipalUser, Id: "user", UserId: "user", Role: domain.RoleEmployee, OrgId: "org"}
+	ctx := domain.WithOrg(domain.WithPrincipal(context.Background(), user), user.OrgId)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timesheets := &timesheetsStub{}
+			s := NewTimesheetService(timesheets, &sessionsStub{}, &publisherStub{}, NewPolicy(nil))
+
+			_, err := s.Submit(ctx, &dto.SubmitTimesheetDto{UserId: "user", WeekStart: tt.weekStart})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Submit() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && timesheets.timesheet != nil {
+				t.Errorf("refused submission was saved")
+			}
+		})
+	}
+}
+
+func TestTimesheetApproveWeekInProgress(t *testing.T) {
+	admin := &domain.Principal{Kind: domain.PrincipalUser, Id: "admin", UserId: "admin", Role: domain.RoleAdmin, OrgId: "org"}
+	ctx := domain.WithOrg(domain.WithPrincipal(context.Background(), admin), admin.OrgId)
+
+	// a week submitted before submissions waited for its end
+	timesheets := &timesheetsStub{timesheet: &domain.Timesheet{
+		Id:        "week",
+		UserId:    "user",
+		WeekStart: domain.WeekStartOf(time.Now()),
+		Status:    domain.TimesheetSubmitted,
+	}}
+	s := NewTimesheetService(timesheets, &sessionsStub{}, &publisherStub{}, NewPolicy(nil))
+
+	if _, err := s.Approve(ctx, "week", nil); !errors.Is(err, domain.ErrWeekNotOver) {
+		t.Fatalf("Approve() error = %v, want %v", err, domain.ErrWeekNotOver)
+	}
+	if timesheets.timesheet.Status != domain.TimesheetSubmitted {
+		t.Errorf("refused approval changed the timesheet to %q", timesheets.timesheet.Status)
+	}
+
+	comment := "hours missing"
+	if _, err := s.Reject(ctx, "week", &comment); err != nil {
+		t.Errorf("Reject() error = %v", err)
+	}
+}
@@ -1,19 +1,26 @@
 package services
 
 import (
+	"context"
 	"em-test/internal/adapters"
 	"em-test/internal/domain"
 	"em-test/internal/lib/dto"
 	"em-test/internal/lib/filters"
+	"errors"
 	"log/slog"
+	"time"
 )
 
 var _ adapters.UsersService = (*UsersService)(nil)
 
 type UserRepository interface {
-	Add(dto dto.SaveUserDto) (*domain.User, error)
-	Read(id string) (*domain.User, error)
-	ReadMany(filters *filters.UsersFilters) ([]*domain.User, int64, error)
+	Add(ctx context.Context, dto dto.SaveUserDto) (*domain.User, error)
+	Read(ctx context.Context, id string) (*domain.User, error)
+	ReadMany(ctx context.Context, filters *filters.UsersFilters) ([]*domain.User, int64, error)
+	ReadReportIds(ctx context.Context, managerId string) ([]string, error)
+	IsInReports(ctx context.Context, userId, candidateId string) (bool, error)
+	Update(ctx context.Context, id string, d *dto.UpdateUserDto) (*domain.User, error)
+	Delete(ctx context.Context, id string) error
 }
 
 type UserFinder interface {
@@ -23,19 +30,27 @@ type UserFinder interface {
 type UsersService struct {
 	repository UserRepository
 	userFinder UserFinder
+	publisher  EventPublisher
+	policy     *Policy
 }
 
-func NewUserService(userRepository UserRepository, passportApiRepository UserFinder) *UsersService {
+func NewUserService(userRepository UserRepository, passportApiRepository UserFinder, publisher EventPublisher, policy *Policy) *UsersService {
 	return &UsersService{
 		repository: userRepository,
 		userFinder: passportApiRepository,
+		publisher:  publisher,
+		policy:     policy,
 	}
 }
 
-func (u *UsersService) AddUser(addUserDto *dto.AddUserDto) (*domain.User, error) {
+func (u *UsersService) AddUser(ctx context.Context, addUserDto *dto.AddUserDto) (*domain.User, error) {
 	const fn = "UsersService.AddUser"
 	logger := slog.With(slog.String("fn", fn))
 
+	if err := u.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
 	info, err := u.userFinder.GetInfo(addUserDto.PassportSerie, addUserDto.PassportNumber)
 	if err != nil {
 		logger.Error("user not found", slog.String("err", err.Error()))
@@ -48,29 +63,165 @@ func (u *UsersService) AddUser(addUserDto *dto.AddUserDto) (*domain.User, error)
 		UserInfoDto: info,
 	}
 
-	user, err := u.repository.Add(saveUserDto)
+	user, err := u.repository.Add(ctx, saveUserDto)
 	if err != nil {
 		logger.Error("error with saving user in repository", slog.Any("save user dto", saveUserDto), slog.String("err", err.Error()))
 		return nil, err
 	}
 	logger.Debug("user saved", slog.Any("user", user))
 
+	u.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventUserCreated,
+		UserId:     user.Id,
+		OccurredAt: time.Now(),
+		Data:       user.WithoutPassport(),
+	})
+
 	return user, nil
 }
 
-func (u *UsersService) GetUsers(filters *filters.UsersFilters) (users []*domain.User, total int64, err error) {
+// GetUsers lists users visible to the caller: admins see everyone,
+// managers their direct reports and members of the teams they manage, and
+// employees only themselves. Passport data is only returned to admins.
+func (u *UsersService) GetUsers(ctx context.Context, filters *filters.UsersFilters) (users []*domain.User, total int64, err error) {
 	const fn = "UsersService.GetUsers"
 	logger := slog.With(slog.String("fn", fn))
 
-	logger.Debug("get users", slog.Any("filters", filters))
+	principal, ok := domain.PrincipalFrom(ctx)
+	if !ok {
+		return nil, 0, domain.ErrUnauthorized
+	}
+
+	logger.Debug("get users", slog.Any("filters", filters), slog.String("role", string(principal.Role)))
+
+	switch {
+	case principal.Is(domain.RoleAdmin):
+	case principal.Is(domain.RoleManager) && principal.UserId != "":
+		reports, err := u.repository.ReadReportIds(ctx, principal.UserId)
+		if err != nil {
+			return nil, 0, err
+		}
+		filters.Ids = reports
+	case principal.Is(domain.RoleEmployee) && principal.UserId != "":
+		user, err := u.repository.Read(ctx, principal.UserId)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []*domain.User{user.WithoutPassport()}, 1, nil
+	default:
+		return nil, 0, domain.ErrForbidden
+	}
 
-	users, total, err = u.repository.ReadMany(filters)
+	users, total, err = u.repository.ReadMany(ctx, filters)
 	if err != nil {
 		logger.Error("error with getting users from repository", slog.Any("filters", filters), slog.String("err", err.Error()))
 		return nil, 0, err
 	}
 
+	if !principal.Is(domain.RoleAdmin) {
+		for i, user := range users {
+			users[i] = user.WithoutPassport()
+		}
+	}
+
 	logger.Debug("got users", slog.Any("users", users))
 
 	return users, total, nil
 }
+
+// GetUser returns a single user visible to the caller, passport data is
+// only returned to admins.
+func (u *UsersService) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	if err := u.policy.CanView(ctx, id); err != nil {
+		return nil, err
+	}
+
+	user, err := u.repository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if principal, _ := domain.PrincipalFrom(ctx); !principal.Is(domain.RoleAdmin) {
+		return user.WithoutPassport(), nil
+	}
+
+	return user, nil
+}
+
+func (u *UsersService) UpdateUser(ctx context.Context, id string, d *dto.UpdateUserDto) (*domain.User, error) {
+	const fn = "UsersService.UpdateUser"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := u.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.Role != nil && !d.Role.IsUserRole() {
+		return nil, domain.ErrInvalidRole
+	}
+
+	if d.ManagerId != nil && *d.ManagerId == id {
+		return nil, domain.ErrInvalidManager
+	}
+
+	// the manager has to be part of the same organization, an empty one
+	// removes the manager
+	if d.ManagerId != nil && *d.ManagerId != "" {
+		if _, err := u.repository.Read(ctx, *d.ManagerId); err != nil {
+			if errors.Is(err, domain.ErrUserNotFound) {
+				return nil, domain.ErrInvalidManager
+			}
+			return nil, err
+		}
+
+		// a report of the user becoming their manager would close a cycle
+		// in which each sees the data of the other
+		cycle, err := u.repository.IsInReports(ctx, id, *d.ManagerId)
+		if err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, domain.ErrInvalidManager
+		}
+	}
+
+	user, err := u.repository.Update(ctx, id, d)
+	if err != nil {
+		logger.Error("error with updating user", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	u.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventUserUpdated,
+		UserId:     user.Id,
+		OccurredAt: time.Now(),
+		Data:       user.WithoutPassport(),
+	})
+
+	return user, nil
+}
+
+func (u *UsersService) DeleteUser(ctx context.Context, id string) error {
+	const fn = "UsersService.DeleteUser"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := u.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	if err := u.repository.Delete(ctx, id); err != nil {
+		logger.Error("error with deleting user", slog.String("err", err.Error()))
+		return err
+	}
+
+	u.publisher.Publish(domain.Event{
+		OrgId:      orgOf(ctx),
+		Type:       domain.EventUserDeleted,
+		UserId:     id,
+		OccurredAt: time.Now(),
+	})
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// managedUsersStub follows manager ids of the users it serves and keeps
+// the updates.
+type managedUsersStub struct {
+	usersStub
+	updated []*dto.UpdateUserDto
+}
+
+func (r *managedUsersStub) IsInReports(_ context.Context, userId, candidateId string) (bool, error) {
+	reports := []string{userId}
+	for i := 0; i < len(reports); i++ {
+		if reports[i] == candidateId {
+			return true, nil
+		}
+		for _, user := range r.users {
+			if user.ManagerId != nil && *user.ManagerId == reports[i] && !slices.Contains(reports, user.Id) {
+				reports = append(reports, user.Id)
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *managedUsersStub) Update(_ context.Context, id string, d *dto.UpdateUserDto) (*domain.User, error) {
+	r.updated = append(r.updated, d)
+	user := *r.users[id]
+	if d.ManagerId != nil {
+		user.ManagerId = nil
+		if *d.ManagerId != "" {
+			user.ManagerId = d.ManagerId
+		}
+	}
+	return &user, nil
+}
+
+func TestUpdateUserManager(t *testing.T) {
+	ann, bob := "ann", "bob"
+	users := map[string]*domain.User{
+		"ann": {Id: "ann", OrgId: "org", Role: domain.RoleManager},
+		"bob": {Id: "bob", OrgId: "org", ManagerId: &ann},
+		"cid": {Id: "cid", OrgId: "org", ManagerId: &bob},
+		"dan": {Id: "dan", OrgId: "org"},
+	}
+
+	admin := &domain.Principal{Kind: domain.PrincipalUser, Id: "admin", UserId: "admin", Role: domain.RoleAdmin, OrgId: "org"}
+	ctx := domain.WithOrg(domain.WithPrincipal(context.Background(), admin), admin.OrgId)
+
+	tests := []struct {
+		name        string
+		id          string
+		managerId   string
+		wantErr     error
+		wantManager *string
+	}{
+		{"assigned", "dan", "ann", nil, &ann},
+		{"removed", "bob", "", nil, nil},
+		{"removed when there is none", "dan", "", nil, nil},
+		{"themselves", "ann", "ann", domain.ErrInvalidManager, nil},
+		{"unknown", "dan", "eve", domain.ErrInvalidManager, nil},
+		{"direct report", "ann", "bob", domain.ErrInvalidManager, nil},
+		{"indirect report", "ann", "cid", domain.ErrInvalidManager, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &managedUsersStub{usersStub: usersStub{users: users}}
+			s := NewUserService(repository, nil, &publisherStub{}, NewPolicy(nil))
+
+			user, err := s.UpdateUser(ctx, tt.id, &dto.UpdateUserDto{ManagerId: &tt.managerId})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(repository.updated) != 0 {
+					t.Errorf("refused update was saved")
+				}
+				return
+			}
+
+			if (user.ManagerId == nil) != (tt.wantManager == nil) || (user.ManagerId != nil && *user.ManagerId != *tt.wantManager) {
+				t.Errorf("UpdateUser() manager = %v, want %v", user.ManagerId, tt.wantManager)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"em-test/internal/config"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type WebhookOutbox interface {
+	FanOut(ctx context.Context, batch int) (int64, error)
+	ClaimDue(ctx context.Context, batch int, lease time.Duration) ([]*domain.PendingDelivery, error)
+	RecordAttempt(ctx context.Context, d *dto.WebhookAttemptDto) error
+}
+
+// WebhookDispatcher relays outbox events to subscribed webhooks, retrying
+// failed deliveries with exponential backoff.
+type WebhookDispatcher struct {
+	outbox WebhookOutbox
+	client *http.Client
+
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	backoffBase  time.Duration
+	backoffMax   time.Duration
+}
+
+func NewWebhookDispatcher(cfg *config.Config, outbox WebhookOutbox) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		outbox:       outbox,
+		client:       &http.Client{Timeout: cfg.Webhooks.Timeout},
+		pollInterval: cfg.Webhooks.PollInterval,
+		batchSize:    cfg.Webhooks.BatchSize,
+		maxAttempts:  cfg.Webhooks.MaxAttempts,
+		backoffBase:  cfg.Webhooks.BackoffBase,
+		backoffMax:   cfg.Webhooks.BackoffMax,
+	}
+}
+
+// Sign returns the signature sent in the X-Webhook-Signature header:
+// hex encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	fn := "WebhookDispatcher.Run"
+	logger := slog.With(slog.String("fn", fn))
+
+	logger.Info("webhook dispatcher started")
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+			d.tick(ctx)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) tick(ctx context.Context) {
+	fn := "WebhookDispatcher.tick"
+	logger := slog.With(slog.String("fn", fn))
+
+	processed, err := d.outbox.FanOut(ctx, d.batchSize)
+	if err != nil {
+		logger.Error("failed to fan out outbox", slog.String("err", err.Error()))
+	} else if processed > 0 {
+		logger.Debug("outbox events fanned out", slog.Int64("events", processed))
+	}
+
+	// a claimed delivery is leased for long enough to be sent even if every
+	// delivery of the batch hits the client timeout
+	lease := d.client.Timeout*time.Duration(d.batchSize) + d.pollInterval
+	deliveries, err := d.outbox.ClaimDue(ctx, d.batchSize, lease)
+	if err != nil {
+		logger.Error("failed to claim deliveries", slog.String("err", err.Error()))
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		d.deliver(ctx, delivery)
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *domain.PendingDelivery) {
+	fn := "WebhookDispatcher.deliver"
+	logger := slog.With(slog.String("fn", fn), slog.Int64("deliveryId", delivery.Id), slog.String("url", delivery.Url))
+
+	attempt := &dto.WebhookAttemptDto{
+		DeliveryId: delivery.Id,
+		Attempt:    delivery.Attempts + 1,
+		Status:     domain.WebhookDeliveryDelivered,
+	}
+
+	started := time.Now()
+	statusCode, err := d.send(ctx, delivery)
+	attempt.Duration = time.Since(started)
+
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+
+	if err == nil && (statusCode < 200 || statusCode >= 300) {
+		err = fmt.Errorf("unexpected status code %d", statusCode)
+	}
+
+	if err != nil {
+		msg := err.Error()
+		attempt.Error = &msg
+
+		if attempt.Attempt >= d.maxAttempts {
+			attempt.Status = domain.WebhookDeliveryFailed
+		} else {
+			attempt.Status = domain.WebhookDeliveryPending
+			attempt.NextAttemptAt = time.Now().Add(d.backoff(attempt.Attempt))
+		}
+
+		logger.Warn("delivery attempt failed", slog.Int("attempt", attempt.Attempt), slog.String("err", msg))
+	}
+
+	if err := d.outbox.RecordAttempt(ctx, attempt); err != nil {
+		logger.Error("failed to record attempt", slog.String("err", err.Error()))
+	}
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *domain.PendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "time-tracker-webhooks")
+	req.Header.Set("X-Webhook-Id", delivery.WebhookId)
+	req.Header.Set("X-Webhook-Event", string(delivery.EventType))
+	req.Header.Set("X-Webhook-Event-Id", strconv.FormatInt(delivery.OutboxId, 10))
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	return res.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt: base * 2^(attempt-1),
+// capped at backoffMax, with up to 20% jitter.
+func (d *WebhookDispatcher) backoff(attempt int) time.Duration {
+	delay := d.backoffBase
+	for i := 1; i < attempt && delay < d.backoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > d.backoffMax {
+		delay = d.backoffMax
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	return delay + jitter
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"em-test/internal/domain"
+	"em-test/internal/lib/dto"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+)
+
+type WebhookRepository interface {
+	Create(ctx context.Context, d *dto.SaveWebhookDto) (*domain.Webhook, error)
+	Read(ctx context.Context, id string) (*domain.Webhook, error)
+	ReadAll(ctx context.Context) ([]*domain.Webhook, error)
+	Update(ctx context.Context, id string, d *dto.UpdateWebhookDto) (*domain.Webhook, error)
+	Delete(ctx context.Context, id string) error
+	ReadDeliveries(ctx context.Context, webhookId string, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+// WebhookService manages webhook subscriptions, which is reserved to admins.
+type WebhookService struct {
+	repository WebhookRepository
+	policy     *Policy
+}
+
+func NewWebhookService(repository WebhookRepository, policy *Policy) *WebhookService {
+	return &WebhookService{
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+func validateWebhook(rawUrl *string, events []domain.EventType) error {
+	if rawUrl != nil {
+		u, err := url.ParseRequestURI(*rawUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return domain.ErrInvalidWebhookUrl
+		}
+	}
+
+	for _, e := range events {
+		if !e.IsKnown() {
+			return domain.ErrUnknownEventType
+		}
+	}
+
+	return nil
+}
+
+// Create registers a webhook. When no secret is given a random one is
+// generated; it is only ever returned from this call.
+func (s *WebhookService) Create(ctx context.Context, d *dto.SaveWebhookDto) (*domain.Webhook, error) {
+	const fn = "WebhookService.Create"
+	logger := slog.With(slog.String("fn", fn), slog.String("url", d.Url))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if len(d.Events) == 0 {
+		return nil, domain.ErrUnknownEventType
+	}
+
+	if err := validateWebhook(&d.Url, d.Events); err != nil {
+		logger.Debug("invalid webhook", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	if d.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.Error("cannot generate secret", slog.String("err", err.Error()))
+			return nil, err
+		}
+		d.Secret = hex.EncodeToString(secret)
+	}
+
+	webhook, err := s.repository.Create(ctx, d)
+	if err != nil {
+		logger.Error("cannot save webhook", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (s *WebhookService) Get(ctx context.Context, id string) (*domain.Webhook, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.repository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]*domain.Webhook, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.repository.ReadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range webhooks {
+		w.Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookService) Update(ctx context.Context, id string, d *dto.UpdateWebhookDto) (*domain.Webhook, error) {
+	const fn = "WebhookService.Update"
+	logger := slog.With(slog.String("fn", fn), slog.String("id", id))
+
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if d.Events != nil && len(d.Events) == 0 {
+		return nil, domain.ErrUnknownEventType
+	}
+
+	if err := validateWebhook(d.Url, d.Events); err != nil {
+		logger.Debug("invalid webhook", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	webhook, err := s.repository.Update(ctx, id, d)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id string) error {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return err
+	}
+
+	return s.repository.Delete(ctx, id)
+}
+
+func (s *WebhookService) Deliveries(ctx context.Context, id string, limit int) ([]*domain.WebhookDelivery, error) {
+	if err := s.policy.RequireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.Read(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ReadDeliveries(ctx, id, limit)
+}
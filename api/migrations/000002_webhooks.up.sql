@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS "outbox" (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "event_type" VARCHAR NOT NULL,
+  "payload" JSONB NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  "processed_at" TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS "outbox_unprocessed_index" ON "outbox"("id") WHERE "processed_at" IS NULL;
+
+CREATE TABLE IF NOT EXISTS "webhooks" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "url" VARCHAR NOT NULL,
+  "secret" VARCHAR NOT NULL,
+  "events" VARCHAR[] NOT NULL,
+  "active" BOOLEAN NOT NULL DEFAULT TRUE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "webhook_id" VARCHAR NOT NULL REFERENCES "webhooks"("id") ON DELETE CASCADE,
+  "outbox_id" BIGINT NOT NULL REFERENCES "outbox"("id"),
+  "status" VARCHAR NOT NULL DEFAULT 'pending',
+  "attempts" INT NOT NULL DEFAULT 0,
+  "next_attempt_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  "delivered_at" TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_due_index" ON "webhook_deliveries"("next_attempt_at") WHERE "status" = 'pending';
+
+CREATE TABLE IF NOT EXISTS "webhook_delivery_attempts" (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "delivery_id" BIGINT NOT NULL REFERENCES "webhook_deliveries"("id") ON DELETE CASCADE,
+  "attempt" INT NOT NULL,
+  "status_code" INT,
+  "error" VARCHAR,
+  "duration_ms" BIGINT NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
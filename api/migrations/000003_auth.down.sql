@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "api_keys_hash_uindex";
+
+DROP TABLE IF EXISTS "api_keys";
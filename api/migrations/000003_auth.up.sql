@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "api_keys" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "name" VARCHAR NOT NULL,
+  "prefix" VARCHAR NOT NULL,
+  "hash" VARCHAR NOT NULL,
+  "user_id" VARCHAR REFERENCES "users"("id") ON DELETE CASCADE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  "expires_at" TIMESTAMP,
+  "last_used_at" TIMESTAMP,
+  "revoked_at" TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "api_keys_hash_uindex" ON "api_keys"("hash");
@@ -0,0 +1,15 @@
+ALTER TABLE "activity" DROP CONSTRAINT IF EXISTS "activity_user_id_fkey";
+
+ALTER TABLE "activity" ADD CONSTRAINT "activity_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users"("id");
+
+ALTER TABLE "api_keys" DROP CONSTRAINT IF EXISTS "api_keys_role_check";
+
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS "role";
+
+DROP INDEX IF EXISTS "users_manager_id_index";
+
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "users_role_check";
+
+ALTER TABLE "users"
+  DROP COLUMN IF EXISTS "manager_id",
+  DROP COLUMN IF EXISTS "role";
@@ -0,0 +1,15 @@
+ALTER TABLE "users"
+  ADD COLUMN IF NOT EXISTS "role" VARCHAR NOT NULL DEFAULT 'employee',
+  ADD COLUMN IF NOT EXISTS "manager_id" VARCHAR REFERENCES "users"("id") ON DELETE SET NULL;
+
+ALTER TABLE "users" ADD CONSTRAINT "users_role_check" CHECK ("role" IN ('employee', 'manager', 'admin'));
+
+CREATE INDEX IF NOT EXISTS "users_manager_id_index" ON "users"("manager_id");
+
+ALTER TABLE "api_keys" ADD COLUMN IF NOT EXISTS "role" VARCHAR NOT NULL DEFAULT 'kiosk';
+
+ALTER TABLE "api_keys" ADD CONSTRAINT "api_keys_role_check" CHECK ("role" IN ('employee', 'manager', 'admin', 'kiosk'));
+
+ALTER TABLE "activity" DROP CONSTRAINT IF EXISTS "activity_user_id_fkey";
+
+ALTER TABLE "activity" ADD CONSTRAINT "activity_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE;
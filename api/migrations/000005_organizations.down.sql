@@ -0,0 +1,21 @@
+DROP INDEX IF EXISTS "webhooks_org_id_index";
+
+DROP INDEX IF EXISTS "activity_org_id_user_id_index";
+
+DROP INDEX IF EXISTS "users_org_id_index";
+
+DROP INDEX IF EXISTS "users_org_passport_uindex";
+
+CREATE UNIQUE INDEX IF NOT EXISTS "users_passport_uindex" ON "users"("passport_serie", "passport_number");
+
+ALTER TABLE "outbox" DROP COLUMN IF EXISTS "org_id";
+
+ALTER TABLE "webhooks" DROP COLUMN IF EXISTS "org_id";
+
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS "org_id";
+
+ALTER TABLE "activity" DROP COLUMN IF EXISTS "org_id";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "org_id";
+
+DROP TABLE IF EXISTS "organizations";
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS "organizations" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "name" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- rows created before organizations existed move to a default organization
+INSERT INTO "organizations" ("id", "name") VALUES ('00000000-0000-0000-0000-000000000000', 'Default') ON CONFLICT DO NOTHING;
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "org_id" VARCHAR REFERENCES "organizations"("id") ON DELETE CASCADE;
+UPDATE "users" SET "org_id" = '00000000-0000-0000-0000-000000000000' WHERE "org_id" IS NULL;
+ALTER TABLE "users" ALTER COLUMN "org_id" SET NOT NULL;
+
+ALTER TABLE "activity" ADD COLUMN IF NOT EXISTS "org_id" VARCHAR REFERENCES "organizations"("id") ON DELETE CASCADE;
+UPDATE "activity" SET "org_id" = '00000000-0000-0000-0000-000000000000' WHERE "org_id" IS NULL;
+ALTER TABLE "activity" ALTER COLUMN "org_id" SET NOT NULL;
+
+ALTER TABLE "api_keys" ADD COLUMN IF NOT EXISTS "org_id" VARCHAR REFERENCES "organizations"("id") ON DELETE CASCADE;
+UPDATE "api_keys" SET "org_id" = '00000000-0000-0000-0000-000000000000' WHERE "org_id" IS NULL;
+ALTER TABLE "api_keys" ALTER COLUMN "org_id" SET NOT NULL;
+
+ALTER TABLE "webhooks" ADD COLUMN IF NOT EXISTS "org_id" VARCHAR REFERENCES "organizations"("id") ON DELETE CASCADE;
+UPDATE "webhooks" SET "org_id" = '00000000-0000-0000-0000-000000000000' WHERE "org_id" IS NULL;
+ALTER TABLE "webhooks" ALTER COLUMN "org_id" SET NOT NULL;
+
+ALTER TABLE "outbox" ADD COLUMN IF NOT EXISTS "org_id" VARCHAR;
+UPDATE "outbox" SET "org_id" = '00000000-0000-0000-0000-000000000000' WHERE "org_id" IS NULL;
+ALTER TABLE "outbox" ALTER COLUMN "org_id" SET NOT NULL;
+
+DROP INDEX IF EXISTS "users_passport_uindex";
+
+CREATE UNIQUE INDEX IF NOT EXISTS "users_org_passport_uindex" ON "users"("org_id", "passport_serie", "passport_number");
+
+CREATE INDEX IF NOT EXISTS "users_org_id_index" ON "users"("org_id");
+
+CREATE INDEX IF NOT EXISTS "activity_org_id_user_id_index" ON "activity"("org_id", "user_id");
+
+CREATE INDEX IF NOT EXISTS "webhooks_org_id_index" ON "webhooks"("org_id");
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS "teams" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "parent_id" VARCHAR REFERENCES "teams"("id") ON DELETE CASCADE,
+  "manager_id" VARCHAR REFERENCES "users"("id") ON DELETE SET NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  CONSTRAINT "teams_parent_check" CHECK ("parent_id" <> "id")
+);
+
+CREATE INDEX IF NOT EXISTS "teams_org_id_index" ON "teams"("org_id");
+
+CREATE INDEX IF NOT EXISTS "teams_parent_id_index" ON "teams"("parent_id");
+
+CREATE INDEX IF NOT EXISTS "teams_manager_id_index" ON "teams"("manager_id");
+
+CREATE TABLE IF NOT EXISTS "team_members" (
+  "team_id" VARCHAR NOT NULL REFERENCES "teams"("id") ON DELETE CASCADE,
+  "user_id" VARCHAR NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY ("team_id", "user_id")
+);
+
+CREATE INDEX IF NOT EXISTS "team_members_user_id_index" ON "team_members"("user_id");
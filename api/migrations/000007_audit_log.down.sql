@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS "audit_log_no_truncate" ON "audit_log";
+
+DROP TRIGGER IF EXISTS "audit_log_immutable" ON "audit_log";
+
+DROP TABLE IF EXISTS "audit_log";
+
+DROP FUNCTION IF EXISTS "audit_log_immutable"();
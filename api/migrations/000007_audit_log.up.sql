@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS "audit_log" (
+  "id" BIGSERIAL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL,
+  "actor_kind" VARCHAR NOT NULL,
+  "actor_id" VARCHAR NOT NULL,
+  "action" VARCHAR NOT NULL,
+  "entity" VARCHAR NOT NULL,
+  "entity_id" VARCHAR NOT NULL,
+  "before" JSONB,
+  "after" JSONB,
+  "request_id" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "audit_log_org_id_created_at_index" ON "audit_log"("org_id", "created_at");
+
+CREATE INDEX IF NOT EXISTS "audit_log_entity_index" ON "audit_log"("org_id", "entity", "entity_id");
+
+CREATE INDEX IF NOT EXISTS "audit_log_actor_index" ON "audit_log"("org_id", "actor_id");
+
+-- the audit log is append-only, rows can neither be changed nor removed
+CREATE OR REPLACE FUNCTION "audit_log_immutable"() RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "audit_log_immutable" ON "audit_log";
+
+CREATE TRIGGER "audit_log_immutable"
+  BEFORE UPDATE OR DELETE ON "audit_log"
+  FOR EACH ROW EXECUTE FUNCTION "audit_log_immutable"();
+
+DROP TRIGGER IF EXISTS "audit_log_no_truncate" ON "audit_log";
+
+CREATE TRIGGER "audit_log_no_truncate"
+  BEFORE TRUNCATE ON "audit_log"
+  FOR EACH STATEMENT EXECUTE FUNCTION "audit_log_immutable"();
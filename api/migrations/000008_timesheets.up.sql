@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "timesheets" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "user_id" VARCHAR NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+  "week_start" DATE NOT NULL,
+  "status" VARCHAR NOT NULL,
+  "comment" VARCHAR,
+  "submitted_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  "reviewed_by" VARCHAR,
+  "reviewed_at" TIMESTAMP,
+  CONSTRAINT "timesheets_status_check" CHECK ("status" IN ('submitted', 'approved', 'rejected')),
+  CONSTRAINT "timesheets_week_start_check" CHECK (EXTRACT(ISODOW FROM "week_start") = 1)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "timesheets_user_week_uindex" ON "timesheets"("user_id", "week_start");
+
+CREATE INDEX IF NOT EXISTS "timesheets_org_id_status_index" ON "timesheets"("org_id", "status");
@@ -0,0 +1,5 @@
+ALTER TABLE "teams" DROP COLUMN IF EXISTS "schedule_id";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "schedule_id";
+
+DROP TABLE IF EXISTS "schedules";
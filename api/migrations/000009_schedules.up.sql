@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS "schedules" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "timezone" VARCHAR NOT NULL DEFAULT 'UTC',
+  "grace_minutes" INTEGER NOT NULL DEFAULT 0,
+  "days" JSONB NOT NULL DEFAULT '[]',
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "schedules_org_id_index" ON "schedules"("org_id");
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "schedule_id" VARCHAR REFERENCES "schedules"("id") ON DELETE SET NULL;
+
+ALTER TABLE "teams" ADD COLUMN IF NOT EXISTS "schedule_id" VARCHAR REFERENCES "schedules"("id") ON DELETE SET NULL;
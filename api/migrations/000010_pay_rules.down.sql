@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "pay_rules";
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS "pay_rules" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "team_id" VARCHAR REFERENCES "teams"("id") ON DELETE CASCADE,
+  "rounding_mode" VARCHAR NOT NULL DEFAULT 'none',
+  "rounding_minutes" INTEGER NOT NULL DEFAULT 0,
+  "min_session_minutes" INTEGER NOT NULL DEFAULT 0,
+  "daily_overtime_minutes" INTEGER,
+  "daily_multiplier" NUMERIC(4, 2) NOT NULL DEFAULT 1,
+  "weekly_overtime_minutes" INTEGER,
+  "weekly_multiplier" NUMERIC(4, 2) NOT NULL DEFAULT 1,
+  "timezone" VARCHAR NOT NULL DEFAULT 'UTC',
+  "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  CONSTRAINT "pay_rules_rounding_mode_check" CHECK ("rounding_mode" IN ('none', 'nearest', 'up', 'down')),
+  CONSTRAINT "pay_rules_rounding_minutes_check" CHECK ("rounding_minutes" IN (0, 5, 15, 30))
+);
+
+-- one organization wide rule set, and at most one per team
+CREATE UNIQUE INDEX IF NOT EXISTS "pay_rules_org_uindex" ON "pay_rules"("org_id") WHERE "team_id" IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "pay_rules_team_uindex" ON "pay_rules"("team_id") WHERE "team_id" IS NOT NULL;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS "leave_types" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "paid" BOOLEAN NOT NULL DEFAULT TRUE,
+  "requires_balance" BOOLEAN NOT NULL DEFAULT TRUE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "leave_types_org_name_uindex" ON "leave_types"("org_id", "name");
+
+CREATE TABLE IF NOT EXISTS "leave_balances" (
+  "user_id" VARCHAR NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+  "leave_type_id" VARCHAR NOT NULL REFERENCES "leave_types"("id") ON DELETE CASCADE,
+  "year" INTEGER NOT NULL,
+  "allowance_days" INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY ("user_id", "leave_type_id", "year"),
+  CONSTRAINT "leave_balances_allowance_check" CHECK ("allowance_days" >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS "leave_requests" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "user_id" VARCHAR NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+  "leave_type_id" VARCHAR NOT NULL REFERENCES "leave_types"("id"),
+  "start_date" DATE NOT NULL,
+  "end_date" DATE NOT NULL,
+  "days" INTEGER NOT NULL,
+  "status" VARCHAR NOT NULL DEFAULT 'pending',
+  "reason" VARCHAR,
+  "comment" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  "reviewed_by" VARCHAR,
+  "reviewed_at" TIMESTAMP,
+  CONSTRAINT "leave_requests_status_check" CHECK ("status" IN ('pending', 'approved', 'rejected', 'cancelled')),
+  CONSTRAINT "leave_requests_dates_check" CHECK ("end_date" >= "start_date")
+);
+
+CREATE INDEX IF NOT EXISTS "leave_requests_user_dates_index" ON "leave_requests"("user_id", "start_date", "end_date");
+
+CREATE INDEX IF NOT EXISTS "leave_requests_org_id_status_index" ON "leave_requests"("org_id", "status");
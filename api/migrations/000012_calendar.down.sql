@@ -0,0 +1,3 @@
+ALTER TABLE "schedules" DROP COLUMN IF EXISTS "region";
+
+DROP TABLE IF EXISTS "calendar_days";
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS "calendar_days" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "region" VARCHAR NOT NULL DEFAULT '',
+  "date" DATE NOT NULL,
+  "name" VARCHAR NOT NULL,
+  "kind" VARCHAR NOT NULL DEFAULT 'holiday',
+  "as_weekday" INTEGER,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  CONSTRAINT "calendar_days_kind_check" CHECK ("kind" IN ('holiday', 'working_day')),
+  CONSTRAINT "calendar_days_as_weekday_check" CHECK ("as_weekday" BETWEEN 1 AND 7)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "calendar_days_org_region_date_uindex" ON "calendar_days"("org_id", "region", "date");
+
+ALTER TABLE "schedules" ADD COLUMN IF NOT EXISTS "region" VARCHAR NOT NULL DEFAULT '';
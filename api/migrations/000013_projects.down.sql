@@ -0,0 +1,9 @@
+ALTER TABLE "activity" DROP COLUMN IF EXISTS "task_id";
+
+DROP TABLE IF EXISTS "tasks";
+
+DROP TABLE IF EXISTS "project_rates";
+
+DROP TABLE IF EXISTS "projects";
+
+DROP TABLE IF EXISTS "clients";
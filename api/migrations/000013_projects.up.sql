@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS "clients" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "clients_org_name_uindex" ON "clients"("org_id", "name");
+
+CREATE TABLE IF NOT EXISTS "projects" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "client_id" VARCHAR REFERENCES "clients"("id"),
+  "name" VARCHAR NOT NULL,
+  "billable" BOOLEAN NOT NULL DEFAULT TRUE,
+  "hourly_rate" BIGINT NOT NULL DEFAULT 0,
+  "currency" VARCHAR(3) NOT NULL DEFAULT 'USD',
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  CONSTRAINT "projects_hourly_rate_check" CHECK ("hourly_rate" >= 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "projects_org_name_uindex" ON "projects"("org_id", "name");
+
+CREATE INDEX IF NOT EXISTS "projects_client_id_index" ON "projects"("client_id");
+
+CREATE TABLE IF NOT EXISTS "project_rates" (
+  "project_id" VARCHAR NOT NULL REFERENCES "projects"("id") ON DELETE CASCADE,
+  "user_id" VARCHAR NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+  "hourly_rate" BIGINT NOT NULL,
+  PRIMARY KEY ("project_id", "user_id"),
+  CONSTRAINT "project_rates_hourly_rate_check" CHECK ("hourly_rate" >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS "tasks" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "project_id" VARCHAR NOT NULL REFERENCES "projects"("id"),
+  "title" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "tasks_project_id_index" ON "tasks"("project_id");
+
+ALTER TABLE "activity" ADD COLUMN IF NOT EXISTS "task_id" VARCHAR REFERENCES "tasks"("id");
+
+CREATE INDEX IF NOT EXISTS "activity_task_id_index" ON "activity"("task_id");
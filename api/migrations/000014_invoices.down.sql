@@ -0,0 +1,5 @@
+ALTER TABLE "activity" DROP COLUMN IF EXISTS "invoice_id";
+
+DROP TABLE IF EXISTS "invoice_lines";
+
+DROP TABLE IF EXISTS "invoices";
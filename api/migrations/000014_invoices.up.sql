@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS "invoices" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "client_id" VARCHAR NOT NULL REFERENCES "clients"("id"),
+  "status" VARCHAR NOT NULL DEFAULT 'draft',
+  "group_by" VARCHAR NOT NULL,
+  "currency" VARCHAR(3) NOT NULL,
+  "period_start" DATE NOT NULL,
+  "period_end" DATE NOT NULL,
+  -- nanoseconds, as time.Duration
+  "total_time" BIGINT NOT NULL DEFAULT 0,
+  "amount" BIGINT NOT NULL DEFAULT 0,
+  "created_by" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  "issued_at" TIMESTAMP,
+  "paid_at" TIMESTAMP,
+  CONSTRAINT "invoices_status_check" CHECK ("status" IN ('draft', 'issued', 'paid')),
+  CONSTRAINT "invoices_group_by_check" CHECK ("group_by" IN ('task', 'user')),
+  CONSTRAINT "invoices_period_check" CHECK ("period_end" >= "period_start")
+);
+
+CREATE INDEX IF NOT EXISTS "invoices_org_client_index" ON "invoices"("org_id", "client_id");
+
+CREATE TABLE IF NOT EXISTS "invoice_lines" (
+  "invoice_id" VARCHAR NOT NULL REFERENCES "invoices"("id") ON DELETE CASCADE,
+  "position" INTEGER NOT NULL,
+  "project_id" VARCHAR NOT NULL,
+  "task_id" VARCHAR,
+  "user_id" VARCHAR,
+  "description" VARCHAR NOT NULL,
+  -- nanoseconds, as time.Duration
+  "duration" BIGINT NOT NULL,
+  "session_count" INTEGER NOT NULL,
+  "hourly_rate" BIGINT NOT NULL,
+  "amount" BIGINT NOT NULL,
+  PRIMARY KEY ("invoice_id", "position")
+);
+
+ALTER TABLE "activity" ADD COLUMN IF NOT EXISTS "invoice_id" VARCHAR REFERENCES "invoices"("id") ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS "activity_invoice_id_index" ON "activity"("invoice_id");
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "budgets" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "project_id" VARCHAR REFERENCES "projects"("id") ON DELETE CASCADE,
+  "task_id" VARCHAR REFERENCES "tasks"("id") ON DELETE CASCADE,
+  "time_minutes" INTEGER,
+  "amount" BIGINT,
+  "thresholds" INTEGER[] NOT NULL DEFAULT '{80,100}',
+  "notified_percent" INTEGER NOT NULL DEFAULT 0,
+  "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  -- a budget is of either a project or a task
+  CONSTRAINT "budgets_target_check" CHECK (("project_id" IS NULL) <> ("task_id" IS NULL)),
+  CONSTRAINT "budgets_limit_check" CHECK ("time_minutes" > 0 OR "amount" > 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "budgets_project_id_uindex" ON "budgets"("project_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "budgets_task_id_uindex" ON "budgets"("task_id");
@@ -0,0 +1,5 @@
+ALTER TABLE "activity" DROP COLUMN IF EXISTS "note";
+
+DROP TABLE IF EXISTS "activity_tags";
+
+DROP TABLE IF EXISTS "tags";
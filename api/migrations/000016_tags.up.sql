@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS "tags" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "tags_org_name_uindex" ON "tags"("org_id", LOWER("name"));
+
+-- deleting a tag takes it off the sessions it labels
+CREATE TABLE IF NOT EXISTS "activity_tags" (
+  "activity_id" INTEGER NOT NULL REFERENCES "activity"("id") ON DELETE CASCADE,
+  "tag_id" VARCHAR NOT NULL REFERENCES "tags"("id") ON DELETE CASCADE,
+  PRIMARY KEY ("activity_id", "tag_id")
+);
+
+CREATE INDEX IF NOT EXISTS "activity_tags_tag_id_index" ON "activity_tags"("tag_id");
+
+ALTER TABLE "activity" ADD COLUMN IF NOT EXISTS "note" TEXT;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS "task_assignees";
+
+ALTER TABLE "tasks" DROP CONSTRAINT IF EXISTS "tasks_estimate_minutes_check";
+ALTER TABLE "tasks" DROP CONSTRAINT IF EXISTS "tasks_status_check";
+
+ALTER TABLE "tasks" DROP COLUMN IF EXISTS "due_date";
+ALTER TABLE "tasks" DROP COLUMN IF EXISTS "status";
+ALTER TABLE "tasks" DROP COLUMN IF EXISTS "estimate_minutes";
@@ -0,0 +1,14 @@
+ALTER TABLE "tasks" ADD COLUMN IF NOT EXISTS "estimate_minutes" INTEGER;
+ALTER TABLE "tasks" ADD COLUMN IF NOT EXISTS "status" VARCHAR NOT NULL DEFAULT 'todo';
+ALTER TABLE "tasks" ADD COLUMN IF NOT EXISTS "due_date" DATE;
+
+ALTER TABLE "tasks" ADD CONSTRAINT "tasks_status_check" CHECK ("status" IN ('todo', 'in_progress', 'done'));
+ALTER TABLE "tasks" ADD CONSTRAINT "tasks_estimate_minutes_check" CHECK ("estimate_minutes" > 0);
+
+CREATE TABLE IF NOT EXISTS "task_assignees" (
+  "task_id" VARCHAR NOT NULL REFERENCES "tasks"("id") ON DELETE CASCADE,
+  "user_id" VARCHAR NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+  PRIMARY KEY ("task_id", "user_id")
+);
+
+CREATE INDEX IF NOT EXISTS "task_assignees_user_id_index" ON "task_assignees"("user_id");
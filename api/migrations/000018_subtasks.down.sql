@@ -0,0 +1,3 @@
+ALTER TABLE "tasks" DROP CONSTRAINT IF EXISTS "tasks_parent_check";
+
+ALTER TABLE "tasks" DROP COLUMN IF EXISTS "parent_id";
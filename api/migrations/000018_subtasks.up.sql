@@ -0,0 +1,6 @@
+-- a task with subtasks cannot be deleted before them
+ALTER TABLE "tasks" ADD COLUMN IF NOT EXISTS "parent_id" VARCHAR REFERENCES "tasks"("id");
+
+ALTER TABLE "tasks" ADD CONSTRAINT "tasks_parent_check" CHECK ("parent_id" <> "id");
+
+CREATE INDEX IF NOT EXISTS "tasks_parent_id_index" ON "tasks"("parent_id");
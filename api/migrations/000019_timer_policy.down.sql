@@ -0,0 +1,3 @@
+ALTER TABLE "organizations" DROP CONSTRAINT IF EXISTS "organizations_timer_policy_check";
+
+ALTER TABLE "organizations" DROP COLUMN IF EXISTS "timer_policy";
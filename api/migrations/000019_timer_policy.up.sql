@@ -0,0 +1,4 @@
+-- single allows one running session per user, multiple one per task
+ALTER TABLE "organizations" ADD COLUMN IF NOT EXISTS "timer_policy" VARCHAR NOT NULL DEFAULT 'single';
+
+ALTER TABLE "organizations" ADD CONSTRAINT "organizations_timer_policy_check" CHECK ("timer_policy" IN ('single', 'multiple'));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "feeds_hash_uindex";
+DROP INDEX IF EXISTS "feeds_user_id_uindex";
+
+DROP TABLE IF EXISTS "feeds";
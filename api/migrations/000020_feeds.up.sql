@@ -0,0 +1,13 @@
+-- one calendar feed per user, its token is kept hashed like api keys
+CREATE TABLE IF NOT EXISTS "feeds" (
+  "id" VARCHAR NOT NULL PRIMARY KEY,
+  "org_id" VARCHAR NOT NULL REFERENCES "organizations"("id") ON DELETE CASCADE,
+  "user_id" VARCHAR NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+  "prefix" VARCHAR NOT NULL,
+  "hash" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+  "last_used_at" TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "feeds_user_id_uindex" ON "feeds"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "feeds_hash_uindex" ON "feeds"("hash");